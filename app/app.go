@@ -16,12 +16,15 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/ortuman/jackal/auth/backend"
+	"github.com/ortuman/jackal/auth/lockout"
 	"github.com/ortuman/jackal/c2s"
 	c2srouter "github.com/ortuman/jackal/c2s/router"
 	"github.com/ortuman/jackal/component"
@@ -32,8 +35,11 @@ import (
 	"github.com/ortuman/jackal/s2s"
 	s2srouter "github.com/ortuman/jackal/s2s/router"
 	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/storage/anonymous"
+	"github.com/ortuman/jackal/storage/measured"
 	"github.com/ortuman/jackal/version"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
@@ -42,6 +48,8 @@ const (
 	darwinOpenMax = 10240
 
 	defaultShutDownWaitTime = time.Duration(5) * time.Second
+
+	certificatesCheckInterval = time.Duration(30) * time.Second
 )
 
 var logoStr = []string{
@@ -67,10 +75,17 @@ Common Options:
 type Application struct {
 	output           io.Writer
 	args             []string
+	configFile       string
+	cfg              *Config
+	allocID          string
 	logger           log.Logger
+	repContainer     *anonymous.Storage
 	router           router.Router
 	mods             *module.Modules
+	retiredMods      []*module.Modules
 	comps            *component.Components
+	authBackend      backend.Backend
+	lockout          *lockout.Tracker
 	s2sOutProvider   *s2s.OutProvider
 	s2s              *s2s.S2S
 	c2s              *c2s.C2S
@@ -129,6 +144,8 @@ func (a *Application) Run() error {
 	if err != nil {
 		return err
 	}
+	a.configFile = configFile
+	a.cfg = &cfg
 
 	// create PID file
 	if err := a.createPIDFile(cfg.PIDFile); err != nil {
@@ -145,15 +162,20 @@ func (a *Application) Run() error {
 	if len(allocID) == 0 {
 		allocID = uuid.New().String()
 	}
+	a.allocID = allocID
 
 	// show jackal's fancy logo
 	a.printLogo(allocID)
 
 	// initialize storage
-	repContainer, err := storage.New(&cfg.Storage)
+	persistentRep, err := storage.New(&cfg.Storage)
 	if err != nil {
 		return err
 	}
+	// anonymous accounts data is kept in memory
+	repContainer := anonymous.New(measured.New(persistentRep))
+	a.repContainer = repContainer
+
 	if err := repContainer.Presences().ClearPresences(context.Background()); err != nil {
 		return err
 	}
@@ -163,6 +185,12 @@ func (a *Application) Run() error {
 	if err != nil {
 		return err
 	}
+	hosts.WatchCertificates(certificatesCheckInterval)
+	// initialize authentication backend
+	a.authBackend, err = backend.New(&cfg.Auth, repContainer.User(), hosts.DefaultHostName())
+	if err != nil {
+		return err
+	}
 	// initialize router
 	var s2sRouter router.S2SRouter
 
@@ -172,7 +200,7 @@ func (a *Application) Run() error {
 	}
 	a.router, err = router.New(
 		hosts,
-		c2srouter.New(repContainer.User(), repContainer.BlockList()),
+		c2srouter.New(a.authBackend, repContainer.BlockList()),
 		s2sRouter,
 	)
 	if err != nil {
@@ -180,8 +208,8 @@ func (a *Application) Run() error {
 	}
 
 	// initialize modules & components...
-	a.mods = module.New(&cfg.Modules, a.router, repContainer, allocID)
-	a.comps = component.New(&cfg.Components, a.mods.DiscoInfo)
+	a.mods = module.New(&cfg.Modules, a.router, repContainer, a.authBackend, allocID)
+	a.comps = component.New(&cfg.Components, a.mods.DiscoInfo, a.router, repContainer)
 
 	// start serving s2s...
 	if err := a.setRLimit(); err != nil {
@@ -192,7 +220,10 @@ func (a *Application) Run() error {
 		a.s2s.Start()
 	}
 	// start serving c2s...
-	a.c2s, err = c2s.New(cfg.C2S, a.mods, a.comps, a.router, repContainer.User(), repContainer.BlockList())
+	if cfg.Lockout.Enabled {
+		a.lockout = lockout.New(&cfg.Lockout)
+	}
+	a.c2s, err = c2s.New(cfg.C2S, a.mods, a.comps, a.router, a.authBackend, repContainer.BlockList(), repContainer, repContainer.FastToken(), a.lockout)
 	if err != nil {
 		return err
 	}
@@ -287,7 +318,13 @@ func (a *Application) setRLimit() error {
 }
 
 func (a *Application) initDebugServer(port int) error {
-	a.debugSrv = &http.Server{}
+	mux := http.NewServeMux()
+	mux.Handle("/", http.DefaultServeMux) // http profile handlers
+	mux.Handle("/metrics", promhttp.Handler())
+	if a.lockout != nil {
+		mux.Handle("/debug/lockouts", a.lockout)
+	}
+	a.debugSrv = &http.Server{Handler: mux}
 	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		return err
@@ -299,7 +336,138 @@ func (a *Application) initDebugServer(port int) error {
 
 func (a *Application) waitForStopSignal() os.Signal {
 	signal.Notify(a.waitStopCh, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
-	return <-a.waitStopCh
+	for {
+		sig := <-a.waitStopCh
+		if sig != syscall.SIGHUP {
+			return sig
+		}
+		log.Infof("received %s signal... reloading configuration...", sig.String())
+		a.reload()
+	}
+}
+
+// reload re-reads configuration file applying every change that can be hot-applied
+// without dropping established sessions.
+func (a *Application) reload() {
+	var cfg Config
+	if err := cfg.FromFile(a.configFile); err != nil {
+		log.Errorf("failed to reload configuration: %v", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), a.shutDownWaitSecs)
+	defer cancel()
+
+	a.reloadLogger(&cfg.Logger)
+	if err := a.reloadHosts(cfg.Hosts); err != nil {
+		log.Errorf("failed to reload hosts: %v", err)
+		return
+	}
+	mods := a.loadModules(&cfg.Modules)
+
+	if err := a.c2s.Reload(ctx, cfg.C2S, mods); err != nil {
+		log.Errorf("failed to reload c2s configuration: %v", err)
+		if mods != a.mods {
+			mods.Retire() // discard unused module set
+			mods = a.mods
+		}
+	} else {
+		a.cfg.C2S = cfg.C2S
+	}
+	switch {
+	case a.s2s != nil:
+		a.s2s.Reload(ctx, cfg.S2S, mods)
+		a.cfg.S2S = cfg.S2S
+		if cfg.S2S == nil {
+			log.Warnf("s2s: outgoing connections will remain available until restart")
+		}
+	case cfg.S2S != nil:
+		log.Warnf("s2s: enabling s2s requires a restart to be applied")
+	}
+	if mods != a.mods {
+		a.reloadModules(mods, &cfg.Modules)
+	}
+
+	// ...report changes that cannot be hot-applied
+	for _, c := range []struct {
+		setting string
+		changed bool
+	}{
+		{"pid_path", cfg.PIDFile != a.cfg.PIDFile},
+		{"debug", !reflect.DeepEqual(cfg.Debug, a.cfg.Debug)},
+		{"storage", !reflect.DeepEqual(cfg.Storage, a.cfg.Storage)},
+		{"auth", !reflect.DeepEqual(cfg.Auth, a.cfg.Auth)},
+		{"lockout", !reflect.DeepEqual(cfg.Lockout, a.cfg.Lockout)},
+		{"components", !reflect.DeepEqual(cfg.Components, a.cfg.Components)},
+	} {
+		if c.changed {
+			log.Warnf("%s changes require a restart to be applied", c.setting)
+		}
+	}
+	log.Infof("configuration successfully reloaded")
+}
+
+func (a *Application) reloadLogger(config *loggerConfig) {
+	if config.LogPath != a.cfg.Logger.LogPath {
+		log.Warnf("logger: log_path changes require a restart to be applied")
+	}
+	if config.Level == a.cfg.Logger.Level {
+		return
+	}
+	if err := log.SetLevel(config.Level); err != nil {
+		log.Errorf("logger: failed to set level: %v", err)
+		return
+	}
+	a.cfg.Logger.Level = config.Level
+	log.Infof("logger: level set to %s", config.Level)
+}
+
+func (a *Application) reloadHosts(hostsConfig []host.Config) error {
+	hosts := a.router.Hosts()
+	added, removed, updated, err := hosts.Reload(hostsConfig)
+	if err != nil {
+		return err
+	}
+	for _, h := range added {
+		log.Infof("hosts: %s added", h)
+	}
+	for _, h := range removed {
+		log.Infof("hosts: %s removed", h)
+	}
+	for _, h := range updated {
+		log.Infof("hosts: %s certificate updated", h)
+	}
+	if len(hostsConfig) > 0 && hostsConfig[0].Name != hosts.DefaultHostName() {
+		log.Warnf("hosts: default host changes require a restart to be applied")
+	}
+	a.cfg.Hosts = hostsConfig
+	return nil
+}
+
+// loadModules returns a new module set whenever modules configuration has changed.
+func (a *Application) loadModules(config *module.Config) *module.Modules {
+	if reflect.DeepEqual(*config, a.cfg.Modules) {
+		return a.mods
+	}
+	mods := module.New(config, a.router, a.repContainer, a.authBackend, a.allocID)
+	mods.DiscoInfo.ImportServerItems(a.mods.DiscoInfo) // keep registered components
+	return mods
+}
+
+func (a *Application) reloadModules(mods *module.Modules, config *module.Config) {
+	// established sessions keep using the previous module set until they finish
+	a.mods.Retire()
+
+	var retiredMods []*module.Modules
+	for _, m := range append(a.retiredMods, a.mods) {
+		if !m.IsShutdown() {
+			retiredMods = append(retiredMods, m)
+		}
+	}
+	a.retiredMods = retiredMods
+	a.mods = mods
+	a.cfg.Modules = *config
+
+	log.Infof("modules: reloaded... (new sessions will use the new module set)")
 }
 
 func (a *Application) gracefullyShutdown() error {
@@ -340,12 +508,24 @@ func (a *Application) doShutdown(ctx context.Context) error {
 	if err := a.mods.Shutdown(ctx); err != nil {
 		return err
 	}
+	for _, mods := range a.retiredMods {
+		if err := mods.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
 
 	if outProvider := a.s2sOutProvider; outProvider != nil {
 		if err := outProvider.Shutdown(ctx); err != nil {
 			return err
 		}
 	}
+	if authBackend := a.authBackend; authBackend != nil {
+		if err := authBackend.Close(ctx); err != nil {
+			return err
+		}
+	}
+	a.router.Hosts().Shutdown()
+
 	log.Unset()
 	return nil
 }
@@ -8,6 +8,8 @@ package app
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"os"
 	"sync"
 	"syscall"
@@ -63,14 +65,29 @@ func TestApplication_Run(t *testing.T) {
 	w := newWriterBuffer()
 	args := []string{"./jackal", "--config=../testdata/config_basic.yml"}
 	ap := New(w, args)
+
+	metricsCh := make(chan string, 1)
 	go func() {
 		time.Sleep(time.Millisecond * 1500) // wait until initialized
+
+		var body []byte
+		if resp, err := http.Get("http://127.0.0.1:16060/metrics"); err == nil {
+			body, _ = ioutil.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+		}
+		metricsCh <- string(body)
+
 		ap.waitStopCh <- syscall.SIGTERM
 	}()
 	ap.shutDownWaitSecs = time.Duration(2) * time.Second // wait only two seconds
 	err := ap.Run()
 	require.Nil(t, err)
 
+	// make sure metrics were exposed through debug server
+	metricsOut := <-metricsCh
+	require.Contains(t, metricsOut, "# TYPE jackal_storage_query_duration_seconds histogram")
+	require.Contains(t, metricsOut, "jackal_storage_query_duration_seconds_count")
+
 	os.RemoveAll(".cert/")
 
 	// make sure pid and log files had been created
@@ -83,6 +100,27 @@ func TestApplication_Run(t *testing.T) {
 	os.Remove("test.jackal.log")
 }
 
+func TestApplication_Reload(t *testing.T) {
+	w := newWriterBuffer()
+	args := []string{"./jackal", "--config=../testdata/config_basic.yml"}
+	ap := New(w, args)
+	go func() {
+		time.Sleep(time.Millisecond * 1500) // wait until initialized
+		ap.waitStopCh <- syscall.SIGHUP
+		time.Sleep(time.Millisecond * 250)
+		ap.waitStopCh <- syscall.SIGTERM
+	}()
+	ap.shutDownWaitSecs = time.Duration(2) * time.Second // wait only two seconds
+	err := ap.Run()
+	require.Nil(t, err)
+
+	require.Contains(t, w.String(), "configuration successfully reloaded")
+
+	os.RemoveAll(".cert/")
+	os.Remove("test.jackal.pid")
+	os.Remove("test.jackal.log")
+}
+
 func expectedUsageString() string {
 	var r string
 	for i := range logoStr {
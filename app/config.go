@@ -9,6 +9,8 @@ import (
 	"bytes"
 	"io/ioutil"
 
+	"github.com/ortuman/jackal/auth/backend"
+	"github.com/ortuman/jackal/auth/lockout"
 	"github.com/ortuman/jackal/c2s"
 	"github.com/ortuman/jackal/component"
 	"github.com/ortuman/jackal/module"
@@ -34,6 +36,8 @@ type Config struct {
 	Debug      debugConfig      `yaml:"debug"`
 	Logger     loggerConfig     `yaml:"logger"`
 	Storage    storage.Config   `yaml:"storage"`
+	Auth       backend.Config   `yaml:"auth"`
+	Lockout    lockout.Config   `yaml:"lockout"`
 	Hosts      []host.Config    `yaml:"hosts"`
 	Modules    module.Config    `yaml:"modules"`
 	Components component.Config `yaml:"components"`
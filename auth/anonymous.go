@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+
+	"github.com/google/uuid"
+	"github.com/ortuman/jackal/storage/anonymous"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+)
+
+// Anonymous represents a SASL ANONYMOUS (RFC 4505) authenticator.
+type Anonymous struct {
+	stm           stream.C2S
+	anonRep       *anonymous.Storage
+	username      string
+	authenticated bool
+}
+
+// NewAnonymous returns a new anonymous authenticator instance.
+func NewAnonymous(stm stream.C2S, anonRep *anonymous.Storage) *Anonymous {
+	return &Anonymous{stm: stm, anonRep: anonRep}
+}
+
+// Mechanism returns authenticator mechanism name.
+func (a *Anonymous) Mechanism() string {
+	return "ANONYMOUS"
+}
+
+// Username returns authenticated username in case
+// authentication process has been completed.
+func (a *Anonymous) Username() string {
+	return a.username
+}
+
+// Authenticated returns whether or not user has been authenticated.
+func (a *Anonymous) Authenticated() bool {
+	return a.authenticated
+}
+
+// UsesChannelBinding returns whether or not anonymous authenticator
+// requires channel binding bytes.
+func (a *Anonymous) UsesChannelBinding() bool {
+	return false
+}
+
+// ProcessElement process an incoming authenticator element.
+func (a *Anonymous) ProcessElement(ctx context.Context, elem xmpp.XElement) error {
+	if a.authenticated {
+		return nil
+	}
+	// optional trace information
+	if txt := elem.Text(); len(txt) > 0 && txt != "=" {
+		if _, err := base64.StdEncoding.DecodeString(txt); err != nil {
+			return ErrSASLIncorrectEncoding
+		}
+	}
+	username := uuid.New().String()
+	if err := a.anonRep.Register(ctx, username); err != nil {
+		return err
+	}
+	a.username = username
+	a.authenticated = true
+
+	a.stm.SendElement(ctx, xmpp.NewElementNamespace("success", saslNamespace))
+	return nil
+}
+
+// Reset resets anonymous authenticator internal state.
+func (a *Anonymous) Reset() {
+	a.username = ""
+	a.authenticated = false
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage/anonymous"
+	memorystorage "github.com/ortuman/jackal/storage/memory"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthAnonymousAuthentication(t *testing.T) {
+	testStm, _ := authTestSetup(&model.User{Username: "mariana", Password: "1234"})
+
+	c, _ := memorystorage.New()
+	anonRep := anonymous.New(c)
+
+	authr := NewAnonymous(testStm, anonRep)
+	require.Equal(t, authr.Mechanism(), "ANONYMOUS")
+	require.False(t, authr.UsesChannelBinding())
+
+	elem := xmpp.NewElementNamespace("auth", "urn:ietf:params:xml:ns:xmpp-sasl")
+	elem.SetAttribute("mechanism", "ANONYMOUS")
+
+	// invalid trace encoding
+	elem.SetText("not base64!")
+	require.Equal(t, ErrSASLIncorrectEncoding, authr.ProcessElement(context.Background(), elem))
+
+	elem.SetText("")
+	require.Nil(t, authr.ProcessElement(context.Background(), elem))
+	require.True(t, authr.Authenticated())
+	require.NotEmpty(t, authr.Username())
+	require.True(t, anonRep.IsAnonymous(authr.Username()))
+	require.Equal(t, "success", testStm.ReceiveElement().Name())
+
+	// a new username is generated on each login
+	username := authr.Username()
+	authr.Reset()
+	require.Nil(t, authr.ProcessElement(context.Background(), elem))
+	require.NotEqual(t, username, authr.Username())
+}
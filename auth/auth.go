@@ -37,6 +37,18 @@ type Authenticator interface {
 	Reset()
 }
 
+// AttemptedUsernameProvider is implemented by authenticators able to report
+// the username involved in an authentication attempt, even if it did not succeed.
+type AttemptedUsernameProvider interface {
+	AttemptedUsername() string
+}
+
+// UsernameParser is implemented by authenticators able to extract the username involved
+// in an authentication attempt before any credentials are verified.
+type UsernameParser interface {
+	ParseUsername(elem xmpp.XElement) string
+}
+
 // SASLError represents specific SASL error type.
 type SASLError struct {
 	reason string
@@ -57,6 +69,12 @@ func (se *SASLError) Error() string {
 }
 
 var (
+	// ErrSASLAborted represents an 'aborted' authentication error.
+	ErrSASLAborted = newSASLError("aborted")
+
+	// ErrSASLInvalidAuthzID represents an 'invalid-authzid' authentication error.
+	ErrSASLInvalidAuthzID = newSASLError("invalid-authzid")
+
 	// ErrSASLIncorrectEncoding represents a 'incorrect-encoding' authentication error.
 	ErrSASLIncorrectEncoding = newSASLError("incorrect-encoding")
 
@@ -0,0 +1,59 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package backend
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ortuman/jackal/auth/backend/extauth"
+	"github.com/ortuman/jackal/auth/backend/ldap"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage/repository"
+)
+
+var (
+	// ErrPasswordChangeNotSupported is returned by SetPassword when the backend doesn't support password changes.
+	ErrPasswordChangeNotSupported = ldap.ErrPasswordChangeNotSupported
+
+	// ErrPasswordChangeRefused is returned by SetPassword when the backend refuses a password change.
+	ErrPasswordChangeRefused = extauth.ErrPasswordChangeRefused
+)
+
+// Backend defines the interface used to validate and manage user credentials.
+type Backend interface {
+	// CheckPassword tells whether or not a password is valid for a given user.
+	CheckPassword(ctx context.Context, username, password string) (bool, error)
+
+	// UserExists tells whether or not a user exists.
+	UserExists(ctx context.Context, username string) (bool, error)
+
+	// SetPassword changes a user password.
+	SetPassword(ctx context.Context, username, password string) error
+
+	// Close shuts down authentication backend.
+	Close(ctx context.Context) error
+}
+
+// CredentialsProvider is implemented by backends able to provide the stored SCRAM credentials of a user.
+type CredentialsProvider interface {
+	// FetchUser retrieves a user entity along with its stored credentials.
+	FetchUser(ctx context.Context, username string) (*model.User, error)
+}
+
+// New initializes configured authentication backend type.
+func New(cfg *Config, userRep repository.User, domain string) (Backend, error) {
+	switch cfg.Type {
+	case Storage:
+		return NewStorage(userRep), nil
+	case External:
+		return extauth.New(cfg.External, domain)
+	case LDAP:
+		return ldap.New(cfg.LDAP, userRep, domain)
+	default:
+		return nil, fmt.Errorf("backend: unrecognized authentication backend type: %d", cfg.Type)
+	}
+}
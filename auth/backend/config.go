@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package backend
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ortuman/jackal/auth/backend/extauth"
+	"github.com/ortuman/jackal/auth/backend/ldap"
+)
+
+// Type represents an authentication backend type.
+type Type int
+
+const (
+	// Storage represents a storage authentication backend type.
+	Storage Type = iota
+
+	// External represents an external program authentication backend type.
+	External
+
+	// LDAP represents an LDAP authentication backend type.
+	LDAP
+)
+
+var typeStringMap = map[Type]string{
+	Storage:  "Storage",
+	External: "External",
+	LDAP:     "LDAP",
+}
+
+func (t Type) String() string { return typeStringMap[t] }
+
+// Config represents an authentication backend configuration.
+type Config struct {
+	Type     Type
+	External *extauth.Config
+	LDAP     *ldap.Config
+}
+
+type configProxy struct {
+	Type     string          `yaml:"type"`
+	External *extauth.Config `yaml:"external"`
+	LDAP     *ldap.Config    `yaml:"ldap"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	switch p.Type {
+	case "storage", "":
+		c.Type = Storage
+
+	case "external":
+		if p.External == nil {
+			return errors.New("backend.Config: couldn't read external configuration")
+		}
+		c.Type = External
+		c.External = p.External
+
+	case "ldap":
+		if p.LDAP == nil {
+			return errors.New("backend.Config: couldn't read LDAP configuration")
+		}
+		c.Type = LDAP
+		c.LDAP = p.LDAP
+
+	default:
+		return fmt.Errorf("backend.Config: unrecognized authentication backend type: %s", p.Type)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig(t *testing.T) {
+	var cfg Config
+
+	err := yaml.Unmarshal([]byte(`type: storage`), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, Storage, cfg.Type)
+
+	err = yaml.Unmarshal([]byte(`type: external`), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte(`type: kerberos`), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte(`{type: external, external: {command: /usr/bin/auth}}`), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, External, cfg.Type)
+	require.Equal(t, "/usr/bin/auth", cfg.External.Command)
+
+	err = yaml.Unmarshal([]byte(`type: ldap`), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte(`{type: ldap, ldap: {url: "ldap://127.0.0.1", base_dn: "dc=jackal,dc=im"}}`), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, LDAP, cfg.Type)
+	require.Equal(t, "dc=jackal,dc=im", cfg.LDAP.BaseDN)
+}
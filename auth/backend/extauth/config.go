@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package extauth
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	defaultPoolSize = 1
+	defaultTimeout  = time.Duration(5) * time.Second
+)
+
+// Config represents an external authentication program configuration.
+type Config struct {
+	Command  string
+	Args     []string
+	PoolSize int
+	Timeout  time.Duration
+}
+
+type configProxy struct {
+	Command  string   `yaml:"command"`
+	Args     []string `yaml:"args"`
+	PoolSize int      `yaml:"pool_size"`
+	Timeout  int      `yaml:"timeout"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if len(p.Command) == 0 {
+		return errors.New("extauth.Config: command value must be set")
+	}
+	if p.PoolSize < 0 {
+		return errors.New("extauth.Config: pool_size must be a positive value")
+	}
+	cfg.Command = p.Command
+	cfg.Args = p.Args
+	cfg.PoolSize = p.PoolSize
+	if cfg.PoolSize == 0 {
+		cfg.PoolSize = defaultPoolSize
+	}
+	cfg.Timeout = time.Duration(p.Timeout) * time.Second
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	return nil
+}
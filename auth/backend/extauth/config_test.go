@@ -0,0 +1,36 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package extauth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig(t *testing.T) {
+	var cfg Config
+
+	err := yaml.Unmarshal([]byte(`pool_size: 2`), &cfg)
+	require.NotNil(t, err) // missing command
+
+	err = yaml.Unmarshal([]byte(`{command: /usr/bin/auth, pool_size: -1}`), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte(`{command: /usr/bin/auth, args: [-v]}`), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, "/usr/bin/auth", cfg.Command)
+	require.Equal(t, []string{"-v"}, cfg.Args)
+	require.Equal(t, defaultPoolSize, cfg.PoolSize)
+	require.Equal(t, defaultTimeout, cfg.Timeout)
+
+	err = yaml.Unmarshal([]byte(`{command: /usr/bin/auth, pool_size: 4, timeout: 2}`), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, 4, cfg.PoolSize)
+	require.Equal(t, 2*time.Second, cfg.Timeout)
+}
@@ -0,0 +1,223 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package extauth
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/ortuman/jackal/log"
+)
+
+var errInvalidResponse = errors.New("extauth: invalid program response")
+
+// ErrPasswordChangeRefused is returned when the external program refuses a password change.
+var ErrPasswordChangeRefused = errors.New("extauth: program refused password change")
+
+// ExtAuth represents an authentication backend that delegates on an external program.
+//
+// Each request is written to the program standard input as a single line:
+//
+//	auth:<username>:<domain>:<password>
+//	isuser:<username>:<domain>
+//	setpass:<username>:<domain>:<password>
+//
+// and must be answered with a line containing either '1' (success) or '0' (failure).
+// Since fields are colon-delimited, usernames containing ':' are always rejected.
+type ExtAuth struct {
+	cfg    *Config
+	domain string
+	slots  chan *slot
+}
+
+type slot struct {
+	proc *process
+}
+
+type process struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// New returns a new external program authentication backend.
+func New(cfg *Config, domain string) (*ExtAuth, error) {
+	x := &ExtAuth{
+		cfg:    cfg,
+		domain: domain,
+		slots:  make(chan *slot, cfg.PoolSize),
+	}
+	for i := 0; i < cfg.PoolSize; i++ {
+		proc, err := x.startProcess()
+		if err != nil {
+			x.stopProcesses(i)
+			return nil, err
+		}
+		x.slots <- &slot{proc: proc}
+	}
+	log.Infof("extauth: started %d '%s' process(es)", cfg.PoolSize, cfg.Command)
+	return x, nil
+}
+
+// CheckPassword satisfies backend.Backend interface.
+func (x *ExtAuth) CheckPassword(ctx context.Context, username, password string) (bool, error) {
+	if !isValidUsername(username) || !isValidArg(password) {
+		return false, nil
+	}
+	return x.request(ctx, fmt.Sprintf("auth:%s:%s:%s", username, x.domain, password))
+}
+
+// UserExists satisfies backend.Backend interface.
+func (x *ExtAuth) UserExists(ctx context.Context, username string) (bool, error) {
+	if !isValidUsername(username) {
+		return false, nil
+	}
+	return x.request(ctx, fmt.Sprintf("isuser:%s:%s", username, x.domain))
+}
+
+// SetPassword satisfies backend.Backend interface.
+func (x *ExtAuth) SetPassword(ctx context.Context, username, password string) error {
+	if !isValidUsername(username) || !isValidArg(password) {
+		return ErrPasswordChangeRefused
+	}
+	ok, err := x.request(ctx, fmt.Sprintf("setpass:%s:%s:%s", username, x.domain, password))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrPasswordChangeRefused
+	}
+	return nil
+}
+
+// Close satisfies backend.Backend interface.
+func (x *ExtAuth) Close(ctx context.Context) error {
+	for i := 0; i < x.cfg.PoolSize; i++ {
+		select {
+		case s := <-x.slots:
+			if s.proc != nil {
+				s.proc.stop()
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (x *ExtAuth) request(ctx context.Context, req string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, x.cfg.Timeout)
+	defer cancel()
+
+	// acquire an idle process
+	var s *slot
+	select {
+	case s = <-x.slots:
+		break
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+	defer func() { x.slots <- s }()
+
+	if s.proc == nil {
+		proc, err := x.startProcess()
+		if err != nil {
+			return false, err
+		}
+		s.proc = proc
+	}
+	proc := s.proc
+
+	type result struct {
+		ok  bool
+		err error
+	}
+	resCh := make(chan result, 1)
+	go func() {
+		ok, err := proc.roundTrip(req)
+		resCh <- result{ok: ok, err: err}
+	}()
+
+	select {
+	case res := <-resCh:
+		if res.err != nil {
+			// process state is unknown at this point... restart it on next request
+			log.Warnf("extauth: %v", res.err)
+			proc.stop()
+			s.proc = nil
+		}
+		return res.ok, res.err
+
+	case <-ctx.Done():
+		proc.stop()
+		s.proc = nil
+		return false, ctx.Err()
+	}
+}
+
+func (x *ExtAuth) startProcess() (*process, error) {
+	cmd := exec.Command(x.cfg.Command, x.cfg.Args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &process{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+	}, nil
+}
+
+func (x *ExtAuth) stopProcesses(n int) {
+	for i := 0; i < n; i++ {
+		s := <-x.slots
+		s.proc.stop()
+	}
+}
+
+func (p *process) roundTrip(req string) (bool, error) {
+	if _, err := io.WriteString(p.stdin, req+"\n"); err != nil {
+		return false, err
+	}
+	line, err := p.stdout.ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	switch strings.TrimSpace(line) {
+	case "1":
+		return true, nil
+	case "0":
+		return false, nil
+	default:
+		return false, errInvalidResponse
+	}
+}
+
+func (p *process) stop() {
+	_ = p.stdin.Close()
+	_ = p.cmd.Process.Kill()
+	_ = p.cmd.Wait()
+}
+
+func isValidArg(s string) bool {
+	return !strings.ContainsAny(s, "\r\n")
+}
+
+func isValidUsername(s string) bool {
+	return isValidArg(s) && !strings.Contains(s, ":")
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package extauth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtAuth_Requests(t *testing.T) {
+	x, err := New(tUtilConfig(2, time.Second), "jackal.im")
+	require.Nil(t, err)
+	defer func() { _ = x.Close(context.Background()) }()
+
+	ctx := context.Background()
+
+	ok, err := x.CheckPassword(ctx, "ortuman", "1234")
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	ok, err = x.CheckPassword(ctx, "ortuman", "4321")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	ok, err = x.CheckPassword(ctx, "ortuman", "1234\nauth:ortuman:jackal.im:1234")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	// colon-delimited fields can't be smuggled within username
+	ok, err = x.CheckPassword(ctx, "ortuman:jackal.im", "1234")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	ok, err = x.UserExists(ctx, "ortuman")
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	ok, err = x.UserExists(ctx, "ortuman:jackal.im")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	ok, err = x.UserExists(ctx, "noelia")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	require.Nil(t, x.SetPassword(ctx, "ortuman", "5678"))
+	require.Equal(t, ErrPasswordChangeRefused, x.SetPassword(ctx, "noelia", "5678"))
+
+	// invalid response restarts program
+	_, err = x.UserExists(ctx, "garbage")
+	require.Equal(t, errInvalidResponse, err)
+
+	ok, err = x.UserExists(ctx, "ortuman")
+	require.Nil(t, err)
+	require.True(t, ok)
+}
+
+func TestExtAuth_Timeout(t *testing.T) {
+	x, err := New(tUtilConfig(1, time.Millisecond*250), "jackal.im")
+	require.Nil(t, err)
+	defer func() { _ = x.Close(context.Background()) }()
+
+	ctx := context.Background()
+
+	_, err = x.UserExists(ctx, "sleepy")
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	ok, err := x.UserExists(ctx, "ortuman")
+	require.Nil(t, err)
+	require.True(t, ok)
+}
+
+func TestExtAuth_InvalidCommand(t *testing.T) {
+	cfg := tUtilConfig(1, time.Second)
+	cfg.Command = "testdata/missing.sh"
+
+	x, err := New(cfg, "jackal.im")
+	require.NotNil(t, err)
+	require.Nil(t, x)
+}
+
+func tUtilConfig(poolSize int, timeout time.Duration) *Config {
+	return &Config{
+		Command:  "testdata/extauth.sh",
+		PoolSize: poolSize,
+		Timeout:  timeout,
+	}
+}
@@ -0,0 +1,14 @@
+#!/bin/sh
+#
+# Stand-in external authentication program used by extauth tests.
+#
+while IFS= read -r line; do
+    case "$line" in
+        "auth:ortuman:jackal.im:1234") echo 1 ;;
+        "isuser:ortuman:jackal.im") echo 1 ;;
+        "setpass:ortuman:jackal.im:"*) echo 1 ;;
+        "isuser:sleepy:jackal.im") sleep 2; echo 1 ;;
+        "isuser:garbage:jackal.im") echo yes ;;
+        *) echo 0 ;;
+    esac
+done
@@ -0,0 +1,108 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+const (
+	defaultUIDAttribute = "uid"
+	defaultPoolSize     = 4
+	defaultTimeout      = time.Duration(5) * time.Second
+)
+
+// TLSConfig represents LDAP connection TLS configuration.
+type TLSConfig struct {
+	StartTLS           bool   `yaml:"start_tls"`
+	CAPath             string `yaml:"ca_path"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// Config represents an LDAP authentication backend configuration.
+type Config struct {
+	URL          string
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	UIDAttribute string
+	Filter       string
+	PoolSize     int
+	Timeout      time.Duration
+	TLS          TLSConfig
+}
+
+type configProxy struct {
+	URL          string    `yaml:"url"`
+	BindDN       string    `yaml:"bind_dn"`
+	BindPassword string    `yaml:"bind_password"`
+	BaseDN       string    `yaml:"base_dn"`
+	UIDAttribute string    `yaml:"uid_attribute"`
+	Filter       string    `yaml:"filter"`
+	PoolSize     int       `yaml:"pool_size"`
+	Timeout      int       `yaml:"timeout"`
+	TLS          TLSConfig `yaml:"tls"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if len(p.URL) == 0 {
+		return errors.New("ldap.Config: url value must be set")
+	}
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return fmt.Errorf("ldap.Config: invalid url: %v", err)
+	}
+	switch u.Scheme {
+	case "ldap", "ldaps":
+		break
+	default:
+		return fmt.Errorf("ldap.Config: unsupported url scheme: %s", u.Scheme)
+	}
+	if u.Scheme == "ldaps" && p.TLS.StartTLS {
+		return errors.New("ldap.Config: start_tls cannot be enabled over ldaps")
+	}
+	if len(p.BaseDN) == 0 {
+		return errors.New("ldap.Config: base_dn value must be set")
+	}
+	if len(p.Filter) > 0 {
+		if _, err := goldap.CompileFilter(p.Filter); err != nil {
+			return fmt.Errorf("ldap.Config: invalid filter: %v", err)
+		}
+	}
+	if p.PoolSize < 0 {
+		return errors.New("ldap.Config: pool_size must be a positive value")
+	}
+	cfg.URL = p.URL
+	cfg.BindDN = p.BindDN
+	cfg.BindPassword = p.BindPassword
+	cfg.BaseDN = p.BaseDN
+	cfg.Filter = p.Filter
+	cfg.TLS = p.TLS
+
+	cfg.UIDAttribute = p.UIDAttribute
+	if len(cfg.UIDAttribute) == 0 {
+		cfg.UIDAttribute = defaultUIDAttribute
+	}
+	cfg.PoolSize = p.PoolSize
+	if cfg.PoolSize == 0 {
+		cfg.PoolSize = defaultPoolSize
+	}
+	cfg.Timeout = time.Duration(p.Timeout) * time.Second
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package ldap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig(t *testing.T) {
+	var cfg Config
+
+	err := yaml.Unmarshal([]byte(`base_dn: dc=jackal,dc=im`), &cfg)
+	require.NotNil(t, err) // missing url
+
+	err = yaml.Unmarshal([]byte(`{url: "http://ldap.jackal.im", base_dn: "dc=jackal,dc=im"}`), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte(`url: ldap://ldap.jackal.im`), &cfg)
+	require.NotNil(t, err) // missing base_dn
+
+	err = yaml.Unmarshal([]byte(`{url: "ldap://ldap.jackal.im", base_dn: "dc=jackal,dc=im", filter: "objectClass=person"}`), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte(`{url: "ldaps://ldap.jackal.im", base_dn: "dc=jackal,dc=im", tls: {start_tls: true}}`), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte(`{url: "ldap://ldap.jackal.im", base_dn: "dc=jackal,dc=im"}`), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, defaultUIDAttribute, cfg.UIDAttribute)
+	require.Equal(t, defaultPoolSize, cfg.PoolSize)
+	require.Equal(t, defaultTimeout, cfg.Timeout)
+
+	err = yaml.Unmarshal([]byte(`
+url: ldap://ldap.jackal.im:389
+bind_dn: cn=jackal,dc=jackal,dc=im
+bind_password: s3cr3t
+base_dn: ou=people,dc=jackal,dc=im
+uid_attribute: sAMAccountName
+filter: (objectClass=person)
+pool_size: 8
+timeout: 2
+tls:
+  start_tls: true
+  insecure_skip_verify: true
+`), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, "cn=jackal,dc=jackal,dc=im", cfg.BindDN)
+	require.Equal(t, "sAMAccountName", cfg.UIDAttribute)
+	require.Equal(t, "(objectClass=person)", cfg.Filter)
+	require.Equal(t, 8, cfg.PoolSize)
+	require.Equal(t, 2*time.Second, cfg.Timeout)
+	require.True(t, cfg.TLS.StartTLS)
+	require.True(t, cfg.TLS.InsecureSkipVerify)
+}
@@ -0,0 +1,258 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage/repository"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+// ErrPasswordChangeNotSupported is returned when trying to change a user password stored in the directory.
+var ErrPasswordChangeNotSupported = errors.New("ldap: password changes are not supported")
+
+// LDAP represents an authentication backend that validates user credentials against an LDAP directory.
+//
+// Users are looked up by their uid attribute under the configured search base, and authenticated
+// by binding with their own DN. Any other user data keeps living in the configured storage.
+type LDAP struct {
+	cfg     *Config
+	tlsCfg  *tls.Config
+	userRep repository.User
+	domain  string
+	slots   chan *slot
+}
+
+type slot struct {
+	conn *goldap.Conn
+}
+
+// New returns a new LDAP authentication backend.
+func New(cfg *Config, userRep repository.User, domain string) (*LDAP, error) {
+	tlsCfg, err := tlsConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	l := &LDAP{
+		cfg:     cfg,
+		tlsCfg:  tlsCfg,
+		userRep: userRep,
+		domain:  domain,
+		slots:   make(chan *slot, cfg.PoolSize),
+	}
+	// check directory availability
+	conn, err := l.dial()
+	if err != nil {
+		return nil, err
+	}
+	l.slots <- &slot{conn: conn}
+	for i := 1; i < cfg.PoolSize; i++ {
+		l.slots <- &slot{}
+	}
+	log.Infof("ldap: connected to %s", cfg.URL)
+	return l, nil
+}
+
+// CheckPassword satisfies backend.Backend interface.
+func (l *LDAP) CheckPassword(ctx context.Context, username, password string) (bool, error) {
+	if len(password) == 0 {
+		return false, nil // an empty password would result in an unauthenticated bind
+	}
+	var ok bool
+	err := l.withConn(ctx, func(conn *goldap.Conn) error {
+		dn, err := l.userDN(conn, username)
+		if err != nil || len(dn) == 0 {
+			return err
+		}
+		switch err := conn.Bind(dn, password); {
+		case err == nil:
+			ok = true
+		case !goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials):
+			return err
+		}
+		// restore connection identity
+		return l.bind(conn)
+	})
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := l.provisionUser(ctx, username); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UserExists satisfies backend.Backend interface.
+func (l *LDAP) UserExists(ctx context.Context, username string) (bool, error) {
+	var exists bool
+	err := l.withConn(ctx, func(conn *goldap.Conn) error {
+		dn, err := l.userDN(conn, username)
+		exists = len(dn) > 0
+		return err
+	})
+	return exists, err
+}
+
+// SetPassword satisfies backend.Backend interface.
+func (l *LDAP) SetPassword(_ context.Context, _, _ string) error {
+	return ErrPasswordChangeNotSupported
+}
+
+// Close satisfies backend.Backend interface.
+func (l *LDAP) Close(ctx context.Context) error {
+	for i := 0; i < l.cfg.PoolSize; i++ {
+		select {
+		case s := <-l.slots:
+			if s.conn != nil {
+				s.conn.Close()
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (l *LDAP) withConn(ctx context.Context, fn func(conn *goldap.Conn) error) error {
+	// acquire an idle connection
+	var s *slot
+	select {
+	case s = <-l.slots:
+		break
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { l.slots <- s }()
+
+	if s.conn == nil || s.conn.IsClosing() {
+		conn, err := l.dial()
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	if err := fn(s.conn); err != nil {
+		// connection state is unknown at this point... redial it on next request
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (l *LDAP) userDN(conn *goldap.Conn, username string) (string, error) {
+	filter := fmt.Sprintf("(%s=%s)", l.cfg.UIDAttribute, goldap.EscapeFilter(username))
+	if len(l.cfg.Filter) > 0 {
+		filter = fmt.Sprintf("(&%s%s)", l.cfg.Filter, filter)
+	}
+	req := goldap.NewSearchRequest(
+		l.cfg.BaseDN,
+		goldap.ScopeWholeSubtree,
+		goldap.NeverDerefAliases,
+		2, // detect ambiguous results
+		int(l.cfg.Timeout.Seconds()),
+		false,
+		filter,
+		[]string{"dn"},
+		nil,
+	)
+	res, err := conn.Search(req)
+	switch {
+	case err == nil:
+		break
+	case goldap.IsErrorAnyOf(err, goldap.LDAPResultNoSuchObject, goldap.LDAPResultSizeLimitExceeded):
+		return "", nil
+	default:
+		return "", err
+	}
+	if len(res.Entries) != 1 {
+		return "", nil
+	}
+	return res.Entries[0].DN, nil
+}
+
+func (l *LDAP) provisionUser(ctx context.Context, username string) error {
+	usr, err := l.userRep.FetchUser(ctx, username)
+	if err != nil {
+		return err
+	}
+	if usr != nil {
+		return nil
+	}
+	j, err := jid.New(username, l.domain, "", true)
+	if err != nil {
+		return err
+	}
+	return l.userRep.UpsertUser(ctx, &model.User{
+		Username:     username,
+		LastPresence: xmpp.NewPresence(j, j, xmpp.UnavailableType),
+	})
+}
+
+func (l *LDAP) dial() (*goldap.Conn, error) {
+	conn, err := goldap.DialURL(l.cfg.URL,
+		goldap.DialWithDialer(&net.Dialer{Timeout: l.cfg.Timeout}),
+		goldap.DialWithTLSConfig(l.tlsCfg),
+	)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(l.cfg.Timeout)
+
+	if l.cfg.TLS.StartTLS {
+		if err := conn.StartTLS(l.tlsCfg); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if err := l.bind(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (l *LDAP) bind(conn *goldap.Conn) error {
+	if len(l.cfg.BindDN) == 0 {
+		return conn.UnauthenticatedBind("")
+	}
+	return conn.Bind(l.cfg.BindDN, l.cfg.BindPassword)
+}
+
+func tlsConfig(cfg *Config) (*tls.Config, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	tlsCfg := &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: cfg.TLS.InsecureSkipVerify,
+	}
+	if len(cfg.TLS.CAPath) > 0 {
+		b, err := ioutil.ReadFile(cfg.TLS.CAPath)
+		if err != nil {
+			return nil, err
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("ldap: no certificates found in %s", cfg.TLS.CAPath)
+		}
+		tlsCfg.RootCAs = certPool
+	}
+	return tlsCfg, nil
+}
@@ -0,0 +1,268 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package ldap
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	goldap "github.com/go-ldap/ldap/v3"
+	memorystorage "github.com/ortuman/jackal/storage/memory"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLDAP_New(t *testing.T) {
+	d := tUtilStartDirectory(t)
+	defer d.close()
+
+	cfg := tUtilConfig(d)
+	cfg.BindPassword = "wrong"
+
+	l, err := New(cfg, memorystorage.NewUser(), "jackal.im")
+	require.True(t, goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials))
+	require.Nil(t, l)
+}
+
+func TestLDAP_UserExists(t *testing.T) {
+	d := tUtilStartDirectory(t)
+	defer d.close()
+
+	cfg := tUtilConfig(d)
+	cfg.Filter = "(objectClass=person)"
+
+	l, err := New(cfg, memorystorage.NewUser(), "jackal.im")
+	require.Nil(t, err)
+	defer func() { _ = l.Close(context.Background()) }()
+
+	ctx := context.Background()
+
+	ok, err := l.UserExists(ctx, "ortuman")
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, "(&(objectClass=person)(uid=ortuman))", d.lastFilter())
+
+	ok, err = l.UserExists(ctx, "noelia*")
+	require.Nil(t, err)
+	require.False(t, ok)
+	require.Equal(t, `(&(objectClass=person)(uid=noelia\2a))`, d.lastFilter())
+
+	// ambiguous entries
+	ok, err = l.UserExists(ctx, "twin")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	// connection lost
+	d.dropConnections()
+	time.Sleep(time.Millisecond * 100)
+
+	ok, err = l.UserExists(ctx, "ortuman")
+	require.Nil(t, err)
+	require.True(t, ok)
+}
+
+func TestLDAP_CheckPassword(t *testing.T) {
+	d := tUtilStartDirectory(t)
+	defer d.close()
+
+	userRep := memorystorage.NewUser()
+
+	l, err := New(tUtilConfig(d), userRep, "jackal.im")
+	require.Nil(t, err)
+	defer func() { _ = l.Close(context.Background()) }()
+
+	ctx := context.Background()
+
+	ok, err := l.CheckPassword(ctx, "ortuman", "4321")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	ok, err = l.CheckPassword(ctx, "ortuman", "")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	ok, err = l.CheckPassword(ctx, "noelia", "1234")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	usr, _ := userRep.FetchUser(ctx, "ortuman")
+	require.Nil(t, usr)
+
+	ok, err = l.CheckPassword(ctx, "ortuman", "1234")
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	// service identity must have been restored
+	require.Equal(t, "cn=jackal,dc=jackal,dc=im", d.lastBindDN())
+
+	// local user entity provisioned
+	usr, _ = userRep.FetchUser(ctx, "ortuman")
+	require.NotNil(t, usr)
+	require.False(t, usr.HasLegacyPassword())
+	require.NotNil(t, usr.LastPresence)
+	require.Equal(t, xmpp.UnavailableType, usr.LastPresence.Type())
+	require.Equal(t, "ortuman@jackal.im", usr.LastPresence.FromJID().String())
+
+	require.Equal(t, ErrPasswordChangeNotSupported, l.SetPassword(ctx, "ortuman", "5678"))
+}
+
+func tUtilConfig(d *tUtilDirectory) *Config {
+	return &Config{
+		URL:          "ldap://" + d.ln.Addr().String(),
+		BindDN:       "cn=jackal,dc=jackal,dc=im",
+		BindPassword: "s3cr3t",
+		BaseDN:       "ou=people,dc=jackal,dc=im",
+		UIDAttribute: defaultUIDAttribute,
+		PoolSize:     2,
+		Timeout:      time.Second,
+	}
+}
+
+// tUtilDirectory represents an in-process LDAP directory stand-in.
+type tUtilDirectory struct {
+	ln        net.Listener
+	passwords map[string]string
+	entries   map[string][]string
+
+	mu       sync.Mutex
+	conns    []net.Conn
+	filter   string
+	boundDNs map[net.Conn]string
+	lastDN   string
+}
+
+var tUtilUIDFilterRegexp = regexp.MustCompile(`\(uid=([^)]*)\)`)
+
+func tUtilStartDirectory(t *testing.T) *tUtilDirectory {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+
+	d := &tUtilDirectory{
+		ln: ln,
+		passwords: map[string]string{
+			"cn=jackal,dc=jackal,dc=im":             "s3cr3t",
+			"uid=ortuman,ou=people,dc=jackal,dc=im": "1234",
+		},
+		entries: map[string][]string{
+			"ortuman": {"uid=ortuman,ou=people,dc=jackal,dc=im"},
+			"twin":    {"uid=twin,ou=people,dc=jackal,dc=im", "uid=twin,ou=staff,dc=jackal,dc=im"},
+		},
+		boundDNs: make(map[net.Conn]string),
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			d.mu.Lock()
+			d.conns = append(d.conns, conn)
+			d.mu.Unlock()
+
+			go d.serve(conn)
+		}
+	}()
+	return d
+}
+
+func (d *tUtilDirectory) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	for {
+		p, err := ber.ReadPacket(conn)
+		if err != nil || len(p.Children) < 2 {
+			return
+		}
+		id := p.Children[0].Value.(int64)
+		op := p.Children[1]
+
+		switch op.Tag {
+		case goldap.ApplicationBindRequest:
+			dn := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+
+			code := uint16(goldap.LDAPResultSuccess)
+			if pass, ok := d.passwords[dn]; !ok || pass != password {
+				code = goldap.LDAPResultInvalidCredentials
+			} else {
+				d.mu.Lock()
+				d.lastDN = dn
+				d.mu.Unlock()
+			}
+			_, _ = conn.Write(tUtilResponse(id, goldap.ApplicationBindResponse, code).Bytes())
+
+		case goldap.ApplicationSearchRequest:
+			filter, _ := goldap.DecompileFilter(op.Children[6])
+			d.mu.Lock()
+			d.filter = filter
+			d.mu.Unlock()
+
+			var dns []string
+			if m := tUtilUIDFilterRegexp.FindStringSubmatch(filter); m != nil {
+				dns = d.entries[m[1]]
+			}
+			for _, dn := range dns {
+				_, _ = conn.Write(tUtilSearchEntry(id, dn).Bytes())
+			}
+			_, _ = conn.Write(tUtilResponse(id, goldap.ApplicationSearchResultDone, goldap.LDAPResultSuccess).Bytes())
+
+		case goldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func (d *tUtilDirectory) lastFilter() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.filter
+}
+
+func (d *tUtilDirectory) lastBindDN() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lastDN
+}
+
+func (d *tUtilDirectory) dropConnections() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, conn := range d.conns {
+		_ = conn.Close()
+	}
+	d.conns = nil
+}
+
+func (d *tUtilDirectory) close() {
+	_ = d.ln.Close()
+	d.dropConnections()
+}
+
+func tUtilResponse(id int64, tag ber.Tag, code uint16) *ber.Packet {
+	res := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	res.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return tUtilEnvelope(id, res)
+}
+
+func tUtilSearchEntry(id int64, dn string) *ber.Packet {
+	entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, goldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "Object Name"))
+	entry.AppendChild(ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes"))
+	return tUtilEnvelope(id, entry)
+}
+
+func tUtilEnvelope(id int64, op *ber.Packet) *ber.Packet {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "Message ID"))
+	p.AppendChild(op)
+	return p
+}
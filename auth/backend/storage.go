@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package backend
+
+import (
+	"context"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage/repository"
+)
+
+// StorageBackend validates user credentials against the user repository.
+type StorageBackend struct {
+	userRep repository.User
+}
+
+// NewStorage returns a new storage authentication backend.
+func NewStorage(userRep repository.User) *StorageBackend {
+	return &StorageBackend{userRep: userRep}
+}
+
+// CheckPassword satisfies Backend interface.
+func (b *StorageBackend) CheckPassword(ctx context.Context, username, password string) (bool, error) {
+	user, err := b.userRep.FetchUser(ctx, username)
+	if err != nil {
+		return false, err
+	}
+	return user != nil && user.VerifyPassword(password), nil
+}
+
+// UserExists satisfies Backend interface.
+func (b *StorageBackend) UserExists(ctx context.Context, username string) (bool, error) {
+	return b.userRep.UserExists(ctx, username)
+}
+
+// SetPassword satisfies Backend interface.
+func (b *StorageBackend) SetPassword(ctx context.Context, username, password string) error {
+	user, err := b.userRep.FetchUser(ctx, username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		user = &model.User{Username: username}
+	}
+	if err := user.SetPassword(password); err != nil {
+		return err
+	}
+	return b.userRep.UpsertUser(ctx, user)
+}
+
+// FetchUser satisfies CredentialsProvider interface.
+func (b *StorageBackend) FetchUser(ctx context.Context, username string) (*model.User, error) {
+	return b.userRep.FetchUser(ctx, username)
+}
+
+// Close satisfies Backend interface.
+func (b *StorageBackend) Close(_ context.Context) error {
+	return nil
+}
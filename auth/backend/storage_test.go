@@ -0,0 +1,58 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ortuman/jackal/model"
+	memorystorage "github.com/ortuman/jackal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageBackend(t *testing.T) {
+	userRep := memorystorage.NewUser()
+	b := NewStorage(userRep)
+
+	ctx := context.Background()
+
+	ok, err := b.UserExists(ctx, "ortuman")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	require.Nil(t, b.SetPassword(ctx, "ortuman", "1234"))
+
+	ok, err = b.UserExists(ctx, "ortuman")
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	ok, err = b.CheckPassword(ctx, "ortuman", "1234")
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	ok, err = b.CheckPassword(ctx, "ortuman", "4321")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	ok, err = b.CheckPassword(ctx, "noelia", "1234")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	usr, _ := b.FetchUser(ctx, "ortuman")
+	require.NotNil(t, usr)
+	require.False(t, usr.HasLegacyPassword())
+
+	// storage error...
+	memorystorage.EnableMockedError()
+	_, err = b.CheckPassword(ctx, "ortuman", "1234")
+	require.Equal(t, memorystorage.ErrMocked, err)
+	memorystorage.DisableMockedError()
+
+	_ = userRep.UpsertUser(ctx, &model.User{Username: "noelia", Password: "abcd"})
+	ok, _ = b.CheckPassword(ctx, "noelia", "abcd")
+	require.True(t, ok)
+}
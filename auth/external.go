@@ -0,0 +1,221 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+
+	"github.com/ortuman/jackal/auth/backend"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+var (
+	oidSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidXMPPAddr       = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 8, 5}
+)
+
+// External represents a SASL EXTERNAL authenticator (XEP-0178) based on client certificates.
+type External struct {
+	stm           stream.C2S
+	tr            transport.Transport
+	authBackend   backend.Backend
+	username      string
+	authenticated bool
+}
+
+// NewExternal returns a new external authenticator instance.
+func NewExternal(stm stream.C2S, tr transport.Transport, authBackend backend.Backend) *External {
+	return &External{stm: stm, tr: tr, authBackend: authBackend}
+}
+
+// Mechanism returns authenticator mechanism name.
+func (e *External) Mechanism() string {
+	return "EXTERNAL"
+}
+
+// Username returns authenticated username in case
+// authentication process has been completed.
+func (e *External) Username() string {
+	return e.username
+}
+
+// Authenticated returns whether or not user has been authenticated.
+func (e *External) Authenticated() bool {
+	return e.authenticated
+}
+
+// UsesChannelBinding returns whether or not external authenticator
+// requires channel binding bytes.
+func (e *External) UsesChannelBinding() bool {
+	return false
+}
+
+// ProcessElement process an incoming authenticator element.
+func (e *External) ProcessElement(ctx context.Context, elem xmpp.XElement) error {
+	if e.authenticated {
+		return nil
+	}
+	// peer certificate chain has already been verified during TLS handshake
+	certs := e.tr.PeerCertificates()
+	if len(certs) == 0 {
+		return ErrSASLNotAuthorized
+	}
+	usernames := certificateUsernames(certs[0], e.stm.Domain())
+	if len(usernames) == 0 {
+		return ErrSASLNotAuthorized
+	}
+	var username string
+
+	authzID, err := decodeAuthzID(elem.Text())
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(authzID) > 0:
+		j, err := jid.NewWithString(authzID, false)
+		if err != nil || j.Domain() != e.stm.Domain() || !j.IsBare() {
+			return ErrSASLInvalidAuthzID
+		}
+		for _, u := range usernames {
+			if u == j.Node() {
+				username = u
+				break
+			}
+		}
+		if len(username) == 0 {
+			return ErrSASLInvalidAuthzID
+		}
+
+	case len(usernames) > 1:
+		return ErrSASLInvalidAuthzID // ambiguous identity
+
+	default:
+		username = usernames[0]
+	}
+	exists, err := e.authBackend.UserExists(ctx, username)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrSASLNotAuthorized
+	}
+	e.username = username
+	e.authenticated = true
+
+	e.stm.SendElement(ctx, xmpp.NewElementNamespace("success", saslNamespace))
+	return nil
+}
+
+// Reset resets external authenticator internal state.
+func (e *External) Reset() {
+	e.username = ""
+	e.authenticated = false
+}
+
+func decodeAuthzID(text string) (string, error) {
+	if len(text) == 0 || text == "=" {
+		return "", nil
+	}
+	b, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		return "", ErrSASLIncorrectEncoding
+	}
+	return string(b), nil
+}
+
+// certificateUsernames returns the local usernames a client certificate has been issued to,
+// looking at its xmppAddr and e-mail SAN entries, and falling back to its subject common name.
+func certificateUsernames(cert *x509.Certificate, domain string) []string {
+	var usernames []string
+	appendJID := func(str string) {
+		j, err := jid.NewWithString(str, false)
+		if err != nil || !j.IsBare() || j.Domain() != domain {
+			return
+		}
+		for _, u := range usernames {
+			if u == j.Node() {
+				return
+			}
+		}
+		usernames = append(usernames, j.Node())
+	}
+	for _, addr := range certificateXMPPAddrs(cert) {
+		appendJID(addr)
+	}
+	for _, email := range cert.EmailAddresses {
+		appendJID(email)
+	}
+	if len(usernames) > 0 {
+		return usernames
+	}
+	cn := cert.Subject.CommonName
+	if len(cn) == 0 {
+		return nil
+	}
+	j, err := jid.NewWithString(cn, false)
+	if err != nil {
+		return nil
+	}
+	switch {
+	case j.IsBare() && j.Domain() == domain:
+		return []string{j.Node()}
+	case j.IsServer() && !j.IsFull():
+		j, err := jid.New(cn, domain, "", false) // bare username
+		if err != nil {
+			return nil
+		}
+		return []string{j.Node()}
+	}
+	return nil
+}
+
+// certificateXMPPAddrs extracts id-on-xmppAddr (RFC 6120) entries from certificate subject alternative names.
+func certificateXMPPAddrs(cert *x509.Certificate) []string {
+	var ret []string
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidSubjectAltName) {
+			continue
+		}
+		var seq asn1.RawValue
+		if rest, err := asn1.Unmarshal(ext.Value, &seq); err != nil || len(rest) > 0 || !seq.IsCompound {
+			continue
+		}
+		names := seq.Bytes
+		for len(names) > 0 {
+			var name asn1.RawValue
+			var err error
+			names, err = asn1.Unmarshal(names, &name)
+			if err != nil {
+				break
+			}
+			if name.Class != asn1.ClassContextSpecific || name.Tag != 0 {
+				continue // not an otherName entry
+			}
+			var otherName struct {
+				TypeID asn1.ObjectIdentifier
+				Value  asn1.RawValue // [0] EXPLICIT
+			}
+			if _, err := asn1.UnmarshalWithParams(name.FullBytes, &otherName, "tag:0"); err != nil {
+				continue
+			}
+			if !otherName.TypeID.Equal(oidXMPPAddr) {
+				continue
+			}
+			var addr string
+			if _, err := asn1.UnmarshalWithParams(otherName.Value.Bytes, &addr, "utf8"); err != nil {
+				continue
+			}
+			ret = append(ret, addr)
+		}
+	}
+	return ret
+}
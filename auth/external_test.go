@@ -0,0 +1,143 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/auth/backend"
+	"github.com/ortuman/jackal/model"
+	memorystorage "github.com/ortuman/jackal/storage/memory"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthExternalAuthentication(t *testing.T) {
+	testStm, s := authTestSetup(&model.User{Username: "mariana"})
+	_ = s.UpsertUser(context.Background(), &model.User{Username: "noelia"})
+
+	tr := &fakeTransport{}
+	authr := NewExternal(testStm, tr, backend.NewStorage(s))
+	require.Equal(t, "EXTERNAL", authr.Mechanism())
+	require.False(t, authr.UsesChannelBinding())
+
+	elem := xmpp.NewElementNamespace("auth", saslNamespace)
+	elem.SetAttribute("mechanism", "EXTERNAL")
+	elem.SetText("=")
+
+	// no client certificate
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(context.Background(), elem))
+
+	// certificate issued to a foreign domain
+	tr.peerCerts = []*x509.Certificate{tUtilCertificate(t, "", []string{"mariana@jackal.im"}, nil)}
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(context.Background(), elem))
+
+	// unknown user
+	tr.peerCerts = []*x509.Certificate{tUtilCertificate(t, "", []string{"ortuman@localhost"}, nil)}
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(context.Background(), elem))
+
+	// ambiguous identity
+	tr.peerCerts = []*x509.Certificate{tUtilCertificate(t, "", []string{"mariana@localhost", "noelia@localhost"}, nil)}
+	require.Equal(t, ErrSASLInvalidAuthzID, authr.ProcessElement(context.Background(), elem))
+
+	// authorization identity not contained in certificate
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("ortuman@localhost")))
+	require.Equal(t, ErrSASLInvalidAuthzID, authr.ProcessElement(context.Background(), elem))
+
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("noelia@localhost")))
+	require.Nil(t, authr.ProcessElement(context.Background(), elem))
+	require.True(t, authr.Authenticated())
+	require.Equal(t, "noelia", authr.Username())
+
+	authr.Reset()
+	require.False(t, authr.Authenticated())
+	require.Equal(t, "", authr.Username())
+
+	// xmppAddr identity
+	elem.SetText("")
+	tr.peerCerts = []*x509.Certificate{tUtilCertificate(t, "", nil, []string{"mariana@localhost"})}
+	require.Nil(t, authr.ProcessElement(context.Background(), elem))
+	require.Equal(t, "mariana", authr.Username())
+	require.Equal(t, "success", testStm.ReceiveElement().Name())
+
+	// storage error...
+	authr.Reset()
+	memorystorage.EnableMockedError()
+	require.Equal(t, memorystorage.ErrMocked, authr.ProcessElement(context.Background(), elem))
+	memorystorage.DisableMockedError()
+}
+
+func TestAuthExternalCertificateUsernames(t *testing.T) {
+	cert := tUtilCertificate(t, "noelia", nil, nil)
+	require.Equal(t, []string{"noelia"}, certificateUsernames(cert, "localhost"))
+
+	cert = tUtilCertificate(t, "noelia@localhost", nil, nil)
+	require.Equal(t, []string{"noelia"}, certificateUsernames(cert, "localhost"))
+
+	cert = tUtilCertificate(t, "noelia@jackal.im", nil, nil)
+	require.Nil(t, certificateUsernames(cert, "localhost"))
+
+	// SAN entries take precedence over common name
+	cert = tUtilCertificate(t, "noelia", []string{"mariana@localhost"}, []string{"ortuman@localhost", "mariana@localhost/balcony"})
+	require.Equal(t, []string{"ortuman", "mariana"}, certificateUsernames(cert, "localhost"))
+}
+
+func tUtilCertificate(t *testing.T, commonName string, emails, xmppAddrs []string) *x509.Certificate {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(1),
+		Subject:        pkix.Name{CommonName: commonName},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		EmailAddresses: emails,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if len(xmppAddrs) > 0 {
+		var names []byte
+		for _, addr := range xmppAddrs {
+			val, err := asn1.MarshalWithParams(addr, "utf8")
+			require.Nil(t, err)
+			otherName := struct {
+				TypeID asn1.ObjectIdentifier
+				Value  asn1.RawValue
+			}{
+				TypeID: oidXMPPAddr,
+				Value:  asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: val},
+			}
+			b, err := asn1.MarshalWithParams(otherName, "tag:0")
+			require.Nil(t, err)
+			names = append(names, b...)
+		}
+		for _, email := range emails {
+			b, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, Bytes: []byte(email)})
+			require.Nil(t, err)
+			names = append(names, b...)
+		}
+		san, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: names})
+		require.Nil(t, err)
+
+		tmpl.EmailAddresses = nil
+		tmpl.ExtraExtensions = []pkix.Extension{{Id: oidSubjectAltName, Value: san}}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	require.Nil(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.Nil(t, err)
+	return cert
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage/repository"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/xmpp"
+)
+
+// FastType represents a FAST hashed token authenticator class.
+type FastType int
+
+const (
+	// FastNone represents HT-SHA-256-NONE authentication method.
+	FastNone FastType = iota
+
+	// FastUnique represents HT-SHA-256-UNIQ authentication method.
+	FastUnique
+
+	// FastEndPoint represents HT-SHA-256-ENDP authentication method.
+	FastEndPoint
+
+	// FastExporter represents HT-SHA-256-EXPR authentication method.
+	FastExporter
+)
+
+// FastTypes contains all supported FAST authenticator classes.
+var FastTypes = []FastType{FastNone, FastUnique, FastEndPoint, FastExporter}
+
+// Mechanism returns FAST type mechanism name.
+func (tp FastType) Mechanism() string {
+	switch tp {
+	case FastUnique:
+		return "HT-SHA-256-UNIQ"
+	case FastEndPoint:
+		return "HT-SHA-256-ENDP"
+	case FastExporter:
+		return "HT-SHA-256-EXPR"
+	}
+	return "HT-SHA-256-NONE"
+}
+
+// ChannelBindingMechanism returns the channel binding mechanism associated to a FAST type.
+func (tp FastType) ChannelBindingMechanism() (transport.ChannelBindingMechanism, bool) {
+	switch tp {
+	case FastUnique:
+		return transport.TLSUnique, true
+	case FastEndPoint:
+		return transport.TLSServerEndPoint, true
+	case FastExporter:
+		return transport.TLSExporter, true
+	}
+	return 0, false
+}
+
+// Fast represents a FAST (XEP-0484) hashed token (HT-SHA-256-*) authenticator.
+type Fast struct {
+	stm           stream.C2S
+	tr            transport.Transport
+	tp            FastType
+	fastRep       repository.FastToken
+	username      string
+	attempted     string
+	token         *model.FastToken
+	authenticated bool
+}
+
+// NewFast returns a new FAST authenticator instance.
+func NewFast(stm stream.C2S, tr transport.Transport, fastType FastType, fastRep repository.FastToken) *Fast {
+	return &Fast{
+		stm:     stm,
+		tr:      tr,
+		tp:      fastType,
+		fastRep: fastRep,
+	}
+}
+
+// Mechanism returns authenticator mechanism name.
+func (f *Fast) Mechanism() string {
+	return f.tp.Mechanism()
+}
+
+// Username returns authenticated username in case
+// authentication process has been completed.
+func (f *Fast) Username() string {
+	return f.username
+}
+
+// Authenticated returns whether or not user has been authenticated.
+func (f *Fast) Authenticated() bool {
+	return f.authenticated
+}
+
+// UsesChannelBinding returns whether or not FAST authenticator
+// requires channel binding bytes.
+func (f *Fast) UsesChannelBinding() bool {
+	_, ok := f.tp.ChannelBindingMechanism()
+	return ok
+}
+
+// Token returns the token used to authenticate in case
+// authentication process has been completed.
+func (f *Fast) Token() *model.FastToken {
+	return f.token
+}
+
+// ProcessElement process an incoming authenticator element.
+func (f *Fast) ProcessElement(ctx context.Context, elem xmpp.XElement) error {
+	if f.authenticated {
+		return nil
+	}
+	if len(elem.Text()) == 0 {
+		return ErrSASLMalformedRequest
+	}
+	b, err := base64.StdEncoding.DecodeString(elem.Text())
+	if err != nil {
+		return ErrSASLIncorrectEncoding
+	}
+	s := bytes.SplitN(b, []byte{0}, 2)
+	if len(s) != 2 || len(s[0]) == 0 {
+		return ErrSASLMalformedRequest
+	}
+	username := string(s[0])
+	f.attempted = username
+
+	var cbBytes []byte
+	if cbMechanism, ok := f.tp.ChannelBindingMechanism(); ok {
+		cbBytes = f.tr.ChannelBindingBytes(cbMechanism)
+		if len(cbBytes) == 0 {
+			return ErrSASLNotAuthorized
+		}
+	}
+	tokens, err := f.fastRep.FetchFastTokens(ctx, username)
+	if err != nil {
+		return err
+	}
+	for i := range tokens {
+		token := &tokens[i]
+		if token.Mechanism != f.Mechanism() || token.IsExpired() {
+			continue
+		}
+		if !hmac.Equal(s[1], fastHash(token.Token, "Initiator", cbBytes)) {
+			continue
+		}
+		f.username = username
+		f.token = token
+		f.authenticated = true
+
+		respText := base64.StdEncoding.EncodeToString(fastHash(token.Token, "Responder", cbBytes))
+		f.stm.SendElement(ctx, xmpp.NewElementNamespace("success", saslNamespace).SetText(respText))
+		return nil
+	}
+	return ErrSASLNotAuthorized
+}
+
+// AttemptedUsername returns the username involved in last authentication attempt.
+func (f *Fast) AttemptedUsername() string {
+	return f.attempted
+}
+
+// Reset resets FAST authenticator internal state.
+func (f *Fast) Reset() {
+	f.username = ""
+	f.attempted = ""
+	f.token = nil
+	f.authenticated = false
+}
+
+func fastHash(token, prefix string, cbBytes []byte) []byte {
+	h := hmac.New(sha256.New, []byte(token))
+	h.Write([]byte(prefix))
+	h.Write(cbBytes)
+	return h.Sum(nil)
+}
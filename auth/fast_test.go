@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/model"
+	memorystorage "github.com/ortuman/jackal/storage/memory"
+	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthFastMechanisms(t *testing.T) {
+	require.Equal(t, "HT-SHA-256-NONE", NewFast(nil, nil, FastNone, nil).Mechanism())
+	require.Equal(t, "HT-SHA-256-UNIQ", NewFast(nil, nil, FastUnique, nil).Mechanism())
+	require.Equal(t, "HT-SHA-256-ENDP", NewFast(nil, nil, FastEndPoint, nil).Mechanism())
+	require.Equal(t, "HT-SHA-256-EXPR", NewFast(nil, nil, FastExporter, nil).Mechanism())
+
+	require.False(t, NewFast(nil, nil, FastNone, nil).UsesChannelBinding())
+	require.True(t, NewFast(nil, nil, FastExporter, nil).UsesChannelBinding())
+}
+
+func TestAuthFastAuthentication(t *testing.T) {
+	testStm, _ := authTestSetup(&model.User{Username: "mariana"})
+
+	fastRep := memorystorage.NewFastToken()
+	_ = fastRep.InsertFastToken(context.Background(), &model.FastToken{
+		Username: "mariana", ClientID: "c1", Mechanism: "HT-SHA-256-NONE", Token: "expired", ExpiresAt: time.Now().Add(-time.Hour),
+	})
+	_ = fastRep.InsertFastToken(context.Background(), &model.FastToken{
+		Username: "mariana", ClientID: "c1", Mechanism: "HT-SHA-256-NONE", Token: "s3cr3t", ExpiresAt: time.Now().Add(time.Hour),
+	})
+	authr := NewFast(testStm, &fakeTransport{}, FastNone, fastRep)
+
+	elem := xmpp.NewElementNamespace("auth", saslNamespace)
+	elem.SetAttribute("mechanism", "HT-SHA-256-NONE")
+
+	// malformed request
+	require.Equal(t, ErrSASLMalformedRequest, authr.ProcessElement(context.Background(), elem))
+
+	elem.SetText("bad encoding")
+	require.Equal(t, ErrSASLIncorrectEncoding, authr.ProcessElement(context.Background(), elem))
+
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("mariana")))
+	require.Equal(t, ErrSASLMalformedRequest, authr.ProcessElement(context.Background(), elem))
+
+	// expired token
+	elem.SetText(tUtilFastInitialResponse("mariana", "expired", nil))
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(context.Background(), elem))
+
+	// unknown token
+	elem.SetText(tUtilFastInitialResponse("mariana", "unknown", nil))
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(context.Background(), elem))
+
+	elem.SetText(tUtilFastInitialResponse("mariana", "s3cr3t", nil))
+	require.Nil(t, authr.ProcessElement(context.Background(), elem))
+	require.True(t, authr.Authenticated())
+	require.Equal(t, "mariana", authr.Username())
+	require.Equal(t, "c1", authr.Token().ClientID)
+
+	success := testStm.ReceiveElement()
+	require.Equal(t, "success", success.Name())
+	require.Equal(t, base64.StdEncoding.EncodeToString(fastHash("s3cr3t", "Responder", nil)), success.Text())
+
+	authr.Reset()
+	require.False(t, authr.Authenticated())
+	require.Equal(t, "", authr.Username())
+	require.Nil(t, authr.Token())
+
+	// storage error...
+	memorystorage.EnableMockedError()
+	require.Equal(t, memorystorage.ErrMocked, authr.ProcessElement(context.Background(), elem))
+	memorystorage.DisableMockedError()
+}
+
+func TestAuthFastChannelBinding(t *testing.T) {
+	testStm, _ := authTestSetup(&model.User{Username: "mariana"})
+
+	fastRep := memorystorage.NewFastToken()
+	_ = fastRep.InsertFastToken(context.Background(), &model.FastToken{
+		Username: "mariana", ClientID: "c1", Mechanism: "HT-SHA-256-EXPR", Token: "s3cr3t", ExpiresAt: time.Now().Add(time.Hour),
+	})
+	tr := &fakeTransport{}
+	authr := NewFast(testStm, tr, FastExporter, fastRep)
+
+	elem := xmpp.NewElementNamespace("auth", saslNamespace)
+	elem.SetAttribute("mechanism", "HT-SHA-256-EXPR")
+
+	cbBytes := []byte{0x0a, 0x0b, 0x0c}
+	elem.SetText(tUtilFastInitialResponse("mariana", "s3cr3t", cbBytes))
+
+	// channel binding not available
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(context.Background(), elem))
+
+	tr.cbMechanism = transport.TLSExporter
+	tr.cbBytes = cbBytes
+	require.Nil(t, authr.ProcessElement(context.Background(), elem))
+	require.True(t, authr.Authenticated())
+}
+
+func tUtilFastInitialResponse(username, token string, cbBytes []byte) string {
+	b := append([]byte(username), 0)
+	b = append(b, fastHash(token, "Initiator", cbBytes)...)
+	return base64.StdEncoding.EncodeToString(b)
+}
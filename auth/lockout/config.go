@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package lockout
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	defaultMaxIPFailures      = 20
+	defaultMaxUserFailures    = 5
+	defaultWindow             = time.Duration(300) * time.Second
+	defaultLockoutDuration    = time.Duration(60) * time.Second
+	defaultMaxLockoutDuration = time.Duration(3600) * time.Second
+)
+
+// Config represents authentication failures tracker configuration.
+type Config struct {
+	Enabled            bool
+	MaxIPFailures      int
+	MaxUserFailures    int
+	Window             time.Duration
+	LockoutDuration    time.Duration
+	MaxLockoutDuration time.Duration
+}
+
+type configProxy struct {
+	Enabled            bool `yaml:"enabled"`
+	MaxIPFailures      int  `yaml:"max_ip_failures"`
+	MaxUserFailures    int  `yaml:"max_user_failures"`
+	Window             int  `yaml:"window"`
+	LockoutDuration    int  `yaml:"lockout_duration"`
+	MaxLockoutDuration int  `yaml:"max_lockout_duration"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	c.Enabled = p.Enabled
+	c.MaxIPFailures = p.MaxIPFailures
+	if c.MaxIPFailures == 0 {
+		c.MaxIPFailures = defaultMaxIPFailures
+	}
+	c.MaxUserFailures = p.MaxUserFailures
+	if c.MaxUserFailures == 0 {
+		c.MaxUserFailures = defaultMaxUserFailures
+	}
+	c.Window = time.Duration(p.Window) * time.Second
+	if c.Window == 0 {
+		c.Window = defaultWindow
+	}
+	c.LockoutDuration = time.Duration(p.LockoutDuration) * time.Second
+	if c.LockoutDuration == 0 {
+		c.LockoutDuration = defaultLockoutDuration
+	}
+	c.MaxLockoutDuration = time.Duration(p.MaxLockoutDuration) * time.Second
+	if c.MaxLockoutDuration == 0 {
+		c.MaxLockoutDuration = defaultMaxLockoutDuration
+	}
+	if c.MaxIPFailures < 0 || c.MaxUserFailures < 0 {
+		return errors.New("lockout.Config: failure thresholds must be positive")
+	}
+	if c.MaxLockoutDuration < c.LockoutDuration {
+		return errors.New("lockout.Config: max_lockout_duration must be greater than lockout_duration")
+	}
+	return nil
+}
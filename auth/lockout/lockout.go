@@ -0,0 +1,228 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package lockout
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ortuman/jackal/log"
+)
+
+const (
+	// IPKind identifies a blocked remote IP address.
+	IPKind = "ip"
+
+	// UserKind identifies a blocked username.
+	UserKind = "user"
+)
+
+// Block represents a currently locked out IP address or username.
+type Block struct {
+	Kind  string    `json:"kind"`
+	Key   string    `json:"key"`
+	Until time.Time `json:"until"`
+}
+
+type record struct {
+	failures    []time.Time
+	lockouts    int
+	lockedUntil time.Time
+}
+
+// Tracker keeps track of authentication failures by remote IP address and by username,
+// locking them out for an exponentially growing period once a failure threshold is reached
+// within the configured sliding window.
+type Tracker struct {
+	cfg       *Config
+	mu        sync.Mutex
+	ips       map[string]*record
+	users     map[string]*record
+	lastPurge time.Time
+}
+
+// New returns a new authentication failures tracker.
+func New(cfg *Config) *Tracker {
+	return &Tracker{
+		cfg:   cfg,
+		ips:   make(map[string]*record),
+		users: make(map[string]*record),
+	}
+}
+
+// IsLocked tells whether either the remote IP address or the username are currently locked out.
+// Empty values are ignored.
+func (t *Tracker) IsLocked(ip, username string) bool {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return isLocked(t.ips, ip, now) || isLocked(t.users, username, now)
+}
+
+// RegisterFailure records a failed authentication attempt.
+func (t *Tracker) RegisterFailure(ip, username string) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(ip) > 0 && t.register(t.ips, ip, t.cfg.MaxIPFailures, now) {
+		log.Warnf("lockout: too many authentication failures... blocking ip %s until %s", ip, t.ips[ip].lockedUntil.Format(time.RFC3339))
+	}
+	if len(username) > 0 && t.register(t.users, username, t.cfg.MaxUserFailures, now) {
+		log.Warnf("lockout: too many authentication failures... blocking user %s until %s", username, t.users[username].lockedUntil.Format(time.RFC3339))
+	}
+	if now.Sub(t.lastPurge) > t.cfg.Window {
+		t.purge(now)
+		t.lastPurge = now
+	}
+}
+
+// RegisterSuccess records a successful authentication attempt, forgetting previous user failures.
+func (t *Tracker) RegisterSuccess(_, username string) {
+	t.mu.Lock()
+	delete(t.users, username)
+	t.mu.Unlock()
+}
+
+// Blocked returns all currently locked out IP addresses and usernames.
+func (t *Tracker) Blocked() []Block {
+	now := time.Now()
+
+	t.mu.Lock()
+	var blocks []Block
+	for ip, r := range t.ips {
+		if r.lockedUntil.After(now) {
+			blocks = append(blocks, Block{Kind: IPKind, Key: ip, Until: r.lockedUntil})
+		}
+	}
+	for username, r := range t.users {
+		if r.lockedUntil.After(now) {
+			blocks = append(blocks, Block{Kind: UserKind, Key: username, Until: r.lockedUntil})
+		}
+	}
+	t.mu.Unlock()
+
+	sort.Slice(blocks, func(i, j int) bool {
+		if blocks[i].Kind != blocks[j].Kind {
+			return blocks[i].Kind < blocks[j].Kind
+		}
+		return blocks[i].Key < blocks[j].Key
+	})
+	return blocks
+}
+
+// Clear removes any failure and lockout associated to an IP address or username.
+// Every entry is cleared in case kind is empty.
+func (t *Tracker) Clear(kind, key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch kind {
+	case IPKind:
+		delete(t.ips, key)
+	case UserKind:
+		delete(t.users, key)
+	case "":
+		t.ips = make(map[string]*record)
+		t.users = make(map[string]*record)
+	}
+}
+
+// ServeHTTP satisfies http.Handler interface, listing currently locked out entries on GET requests
+// and clearing them on DELETE ones (optionally restricted through 'ip' or 'user' query parameters).
+func (t *Tracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		blocks := t.Blocked()
+		if blocks == nil {
+			blocks = []Block{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(blocks)
+
+	case http.MethodDelete:
+		q := r.URL.Query()
+		switch {
+		case len(q.Get(IPKind)) > 0:
+			t.Clear(IPKind, q.Get(IPKind))
+		case len(q.Get(UserKind)) > 0:
+			t.Clear(UserKind, q.Get(UserKind))
+		default:
+			t.Clear("", "")
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// register records a failure into m, returning true in case the key has been locked out as a consequence.
+func (t *Tracker) register(m map[string]*record, key string, threshold int, now time.Time) bool {
+	r := m[key]
+	if r == nil {
+		r = &record{}
+		m[key] = r
+	}
+	// lockout escalation is forgotten after a long enough quiet period
+	if r.lockouts > 0 && now.Sub(r.lockedUntil) > t.cfg.MaxLockoutDuration {
+		r.lockouts = 0
+	}
+	r.failures = append(recentFailures(r.failures, now.Add(-t.cfg.Window)), now)
+	if len(r.failures) < threshold {
+		return false
+	}
+	r.failures = nil
+	r.lockouts++
+	r.lockedUntil = now.Add(t.lockoutDuration(r.lockouts))
+	return true
+}
+
+// lockoutDuration returns the exponentially growing lockout duration for the n-th consecutive lockout.
+func (t *Tracker) lockoutDuration(n int) time.Duration {
+	d := t.cfg.LockoutDuration
+	for i := 1; i < n && d < t.cfg.MaxLockoutDuration; i++ {
+		d *= 2
+	}
+	if d > t.cfg.MaxLockoutDuration {
+		d = t.cfg.MaxLockoutDuration
+	}
+	return d
+}
+
+// purge discards records no longer carrying any relevant state.
+func (t *Tracker) purge(now time.Time) {
+	for _, m := range []map[string]*record{t.ips, t.users} {
+		for key, r := range m {
+			r.failures = recentFailures(r.failures, now.Add(-t.cfg.Window))
+			if len(r.failures) == 0 && now.Sub(r.lockedUntil) > t.cfg.MaxLockoutDuration {
+				delete(m, key)
+			}
+		}
+	}
+}
+
+func isLocked(m map[string]*record, key string, now time.Time) bool {
+	if len(key) == 0 {
+		return false
+	}
+	r := m[key]
+	return r != nil && r.lockedUntil.After(now)
+}
+
+func recentFailures(failures []time.Time, since time.Time) []time.Time {
+	for i, tm := range failures {
+		if tm.After(since) {
+			return failures[i:]
+		}
+	}
+	return nil
+}
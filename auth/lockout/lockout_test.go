@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package lockout
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig(t *testing.T) {
+	var cfg Config
+	err := yaml.Unmarshal([]byte("{enabled: true}"), &cfg)
+	require.Nil(t, err)
+	require.True(t, cfg.Enabled)
+	require.Equal(t, defaultMaxIPFailures, cfg.MaxIPFailures)
+	require.Equal(t, defaultMaxUserFailures, cfg.MaxUserFailures)
+	require.Equal(t, defaultWindow, cfg.Window)
+	require.Equal(t, defaultLockoutDuration, cfg.LockoutDuration)
+	require.Equal(t, defaultMaxLockoutDuration, cfg.MaxLockoutDuration)
+
+	err = yaml.Unmarshal([]byte("{enabled: true, max_user_failures: 3, window: 60, lockout_duration: 10, max_lockout_duration: 80}"), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, 3, cfg.MaxUserFailures)
+	require.Equal(t, time.Minute, cfg.Window)
+	require.Equal(t, 10*time.Second, cfg.LockoutDuration)
+	require.Equal(t, 80*time.Second, cfg.MaxLockoutDuration)
+
+	err = yaml.Unmarshal([]byte("{lockout_duration: 60, max_lockout_duration: 30}"), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("{max_ip_failures: -1}"), &cfg)
+	require.NotNil(t, err)
+}
+
+func TestTracker_Lockout(t *testing.T) {
+	tr := New(tUtilConfig())
+
+	tr.RegisterFailure("10.0.0.1", "ortuman")
+	tr.RegisterFailure("10.0.0.1", "ortuman")
+	require.False(t, tr.IsLocked("10.0.0.1", "ortuman"))
+
+	tr.RegisterFailure("10.0.0.2", "ortuman")
+	require.True(t, tr.IsLocked("", "ortuman"))
+	require.True(t, tr.IsLocked("10.0.0.3", "ortuman"))
+	require.False(t, tr.IsLocked("10.0.0.1", "noelia"))
+
+	tr.RegisterFailure("10.0.0.1", "noelia")
+	tr.RegisterFailure("10.0.0.1", "mariana")
+	require.False(t, tr.IsLocked("10.0.0.1", ""))
+
+	tr.RegisterFailure("10.0.0.1", "romeo")
+	require.True(t, tr.IsLocked("10.0.0.1", ""))
+	require.False(t, tr.IsLocked("10.0.0.2", "noelia"))
+
+	blocks := tr.Blocked()
+	require.Len(t, blocks, 2)
+	require.Equal(t, IPKind, blocks[0].Kind)
+	require.Equal(t, "10.0.0.1", blocks[0].Key)
+	require.Equal(t, UserKind, blocks[1].Kind)
+	require.Equal(t, "ortuman", blocks[1].Key)
+
+	time.Sleep(time.Millisecond * 250) // wait until lockout expires...
+	require.False(t, tr.IsLocked("10.0.0.1", "ortuman"))
+	require.Len(t, tr.Blocked(), 0)
+}
+
+func TestTracker_Backoff(t *testing.T) {
+	tr := New(tUtilConfig())
+
+	for i := 0; i < 3; i++ {
+		tr.RegisterFailure("", "ortuman")
+	}
+	first := tr.users["ortuman"].lockedUntil
+
+	time.Sleep(time.Millisecond * 250)
+	for i := 0; i < 3; i++ {
+		tr.RegisterFailure("", "ortuman")
+	}
+	require.Equal(t, 2, tr.users["ortuman"].lockouts)
+	require.True(t, tr.users["ortuman"].lockedUntil.Sub(first) > time.Millisecond*300)
+
+	// capped to max lockout duration
+	require.Equal(t, time.Second, tr.lockoutDuration(10))
+
+	// successful authentication forgets user failures
+	tr.RegisterSuccess("", "ortuman")
+	require.False(t, tr.IsLocked("", "ortuman"))
+}
+
+func TestTracker_HTTPHandler(t *testing.T) {
+	tr := New(tUtilConfig())
+	for i := 0; i < 5; i++ {
+		tr.RegisterFailure("10.0.0.1", "ortuman")
+	}
+	rec := httptest.NewRecorder()
+	tr.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/lockouts", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var blocks []Block
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &blocks))
+	require.Len(t, blocks, 2)
+
+	rec = httptest.NewRecorder()
+	tr.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/debug/lockouts?user=ortuman", nil))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.False(t, tr.IsLocked("", "ortuman"))
+	require.True(t, tr.IsLocked("10.0.0.1", ""))
+
+	rec = httptest.NewRecorder()
+	tr.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/debug/lockouts", nil))
+	require.Len(t, tr.Blocked(), 0)
+
+	rec = httptest.NewRecorder()
+	tr.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/lockouts", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func tUtilConfig() *Config {
+	return &Config{
+		Enabled:            true,
+		MaxIPFailures:      5,
+		MaxUserFailures:    3,
+		Window:             time.Minute,
+		LockoutDuration:    time.Millisecond * 200,
+		MaxLockoutDuration: time.Second,
+	}
+}
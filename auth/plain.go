@@ -10,7 +10,7 @@ import (
 	"context"
 	"encoding/base64"
 
-	"github.com/ortuman/jackal/storage/repository"
+	"github.com/ortuman/jackal/auth/backend"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/xmpp"
 )
@@ -18,14 +18,15 @@ import (
 // Plain represents a PLAIN authenticator.
 type Plain struct {
 	stm           stream.C2S
-	userRep       repository.User
+	authBackend   backend.Backend
 	username      string
+	attempted     string
 	authenticated bool
 }
 
 // NewPlain returns a new plain authenticator instance.
-func NewPlain(stm stream.C2S, userRep repository.User) *Plain {
-	return &Plain{stm: stm, userRep: userRep}
+func NewPlain(stm stream.C2S, authBackend backend.Backend) *Plain {
+	return &Plain{stm: stm, authBackend: authBackend}
 }
 
 // Mechanism returns authenticator mechanism name.
@@ -68,13 +69,14 @@ func (p *Plain) ProcessElement(ctx context.Context, elem xmpp.XElement) error {
 	}
 	username := string(s[1])
 	password := string(s[2])
+	p.attempted = username
 
 	// validate user and password
-	user, err := p.userRep.FetchUser(ctx, username)
+	ok, err := p.authBackend.CheckPassword(ctx, username, password)
 	if err != nil {
 		return err
 	}
-	if user == nil || user.Password != password {
+	if !ok {
 		return ErrSASLNotAuthorized
 	}
 	p.username = username
@@ -84,8 +86,27 @@ func (p *Plain) ProcessElement(ctx context.Context, elem xmpp.XElement) error {
 	return nil
 }
 
+// AttemptedUsername returns the username involved in last authentication attempt.
+func (p *Plain) AttemptedUsername() string {
+	return p.attempted
+}
+
+// ParseUsername returns the username involved in an authentication element, without verifying its credentials.
+func (p *Plain) ParseUsername(elem xmpp.XElement) string {
+	b, err := base64.StdEncoding.DecodeString(elem.Text())
+	if err != nil {
+		return ""
+	}
+	s := bytes.Split(b, []byte{0})
+	if len(s) != 3 {
+		return ""
+	}
+	return string(s[1])
+}
+
 // Reset resets plain authenticator internal state.
 func (p *Plain) Reset() {
 	p.username = ""
+	p.attempted = ""
 	p.authenticated = false
 }
@@ -11,6 +11,7 @@ import (
 	"encoding/base64"
 	"testing"
 
+	"github.com/ortuman/jackal/auth/backend"
 	"github.com/ortuman/jackal/model"
 	memorystorage "github.com/ortuman/jackal/storage/memory"
 	"github.com/ortuman/jackal/xmpp"
@@ -22,7 +23,7 @@ func TestAuthPlainAuthentication(t *testing.T) {
 
 	testStm, s := authTestSetup(&model.User{Username: "mariana", Password: "1234"})
 
-	authr := NewPlain(testStm, s)
+	authr := NewPlain(testStm, backend.NewStorage(s))
 	require.Equal(t, authr.Mechanism(), "PLAIN")
 	require.False(t, authr.UsesChannelBinding())
 
@@ -36,6 +37,7 @@ func TestAuthPlainAuthentication(t *testing.T) {
 	buf.WriteByte(0)
 	buf.WriteString("1234")
 	elem.SetText(base64.StdEncoding.EncodeToString(buf.Bytes()))
+	require.Equal(t, "mariana", authr.ParseUsername(elem))
 
 	// storage error...
 	memorystorage.EnableMockedError()
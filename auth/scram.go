@@ -9,22 +9,21 @@ import (
 	"bytes"
 	"context"
 	"crypto/hmac"
-	"crypto/rand"
 	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/base64"
 	"fmt"
 	"hash"
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/ortuman/jackal/auth/backend"
 	"github.com/ortuman/jackal/model"
-	"github.com/ortuman/jackal/storage/repository"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/transport"
 	utilstring "github.com/ortuman/jackal/util/string"
 	"github.com/ortuman/jackal/xmpp"
-	"golang.org/x/crypto/pbkdf2"
 )
 
 // ScramType represents a scram autheticator class
@@ -36,9 +35,10 @@ const (
 
 	// ScramSHA256 represents SCRAM-SHA-256 authentication method.
 	ScramSHA256
-)
 
-const iterationsCount = 4096
+	// ScramSHA512 represents SCRAM-SHA-512 authentication method.
+	ScramSHA512
+)
 
 type scramState int
 
@@ -54,7 +54,7 @@ type scramParameter struct {
 
 type scramParameters struct {
 	gs2Header   string
-	cbMechanism string
+	cbMechanism transport.ChannelBindingMechanism
 	authzID     string
 	params      []scramParameter
 }
@@ -82,38 +82,37 @@ func (s *scramParameters) String() string {
 // Scram represents a SCRAM authenticator.
 type Scram struct {
 	stm           stream.C2S
-	userRep       repository.User
+	credProvider  backend.CredentialsProvider
 	tr            transport.Transport
 	tp            ScramType
 	usesCb        bool
 	h             func() hash.Hash
-	hKeyLen       int
 	state         scramState
 	params        *scramParameters
 	user          *model.User
-	salt          []byte
+	credentials   *model.ScramCredentials
 	srvNonce      string
 	firstMessage  string
 	authenticated bool
 }
 
 // NewScram returns a new scram authenticator instance.
-func NewScram(stm stream.C2S, tr transport.Transport, scramType ScramType, usesChannelBinding bool, userRep repository.User) *Scram {
+func NewScram(stm stream.C2S, tr transport.Transport, scramType ScramType, usesChannelBinding bool, credProvider backend.CredentialsProvider) *Scram {
 	s := &Scram{
-		stm:     stm,
-		userRep: userRep,
-		tr:      tr,
-		tp:      scramType,
-		usesCb:  usesChannelBinding,
-		state:   startScramState,
+		stm:          stm,
+		credProvider: credProvider,
+		tr:           tr,
+		tp:           scramType,
+		usesCb:       usesChannelBinding,
+		state:        startScramState,
 	}
 	switch s.tp {
 	case ScramSHA1:
 		s.h = sha1.New
-		s.hKeyLen = sha1.Size
 	case ScramSHA256:
 		s.h = sha256.New
-		s.hKeyLen = sha256.Size
+	case ScramSHA512:
+		s.h = sha512.New
 	}
 	return s
 }
@@ -132,6 +131,12 @@ func (s *Scram) Mechanism() string {
 			return "SCRAM-SHA-256-PLUS"
 		}
 		return "SCRAM-SHA-256"
+
+	case ScramSHA512:
+		if s.usesCb {
+			return "SCRAM-SHA-512-PLUS"
+		}
+		return "SCRAM-SHA-512"
 	}
 	return ""
 }
@@ -174,6 +179,32 @@ func (s *Scram) ProcessElement(ctx context.Context, elem xmpp.XElement) error {
 	return ErrSASLNotAuthorized
 }
 
+// AttemptedUsername returns the username involved in last authentication attempt.
+func (s *Scram) AttemptedUsername() string {
+	if s.params == nil {
+		return ""
+	}
+	return s.params.getParameter("n")
+}
+
+// ParseUsername returns the username involved in an initial authentication element, without fetching its credentials.
+func (s *Scram) ParseUsername(elem xmpp.XElement) string {
+	if elem.Name() != "auth" {
+		return ""
+	}
+	p, err := s.getElementPayload(elem)
+	if err != nil {
+		return ""
+	}
+	sp := strings.Split(p, ",")
+	for i := 2; i < len(sp); i++ {
+		if key, val := utilstring.SplitKeyAndValue(sp[i], '='); key == "n" {
+			return val
+		}
+	}
+	return ""
+}
+
 // Reset resets scram internal state.
 func (s *Scram) Reset() {
 	s.authenticated = false
@@ -181,7 +212,7 @@ func (s *Scram) Reset() {
 	s.state = startScramState
 	s.params = nil
 	s.user = nil
-	s.salt = nil
+	s.credentials = nil
 	s.srvNonce = ""
 	s.firstMessage = ""
 }
@@ -200,23 +231,26 @@ func (s *Scram) handleStart(ctx context.Context, elem xmpp.XElement) error {
 	if len(username) == 0 || len(cNonce) == 0 {
 		return ErrSASLMalformedRequest
 	}
-	user, err := s.userRep.FetchUser(ctx, username)
+	user, err := s.credProvider.FetchUser(ctx, username)
 	if err != nil {
 		return err
 	}
 	if user == nil {
 		return ErrSASLNotAuthorized
 	}
-	s.user = user
-
-	s.srvNonce = cNonce + "-" + uuid.New().String()
-	s.salt = make([]byte, 32)
-	_, err = rand.Read(s.salt)
+	credentials, err := s.userCredentials(user)
 	if err != nil {
 		return err
 	}
-	sb64 := base64.StdEncoding.EncodeToString(s.salt)
-	s.firstMessage = fmt.Sprintf("r=%s,s=%s,i=%d", s.srvNonce, sb64, iterationsCount)
+	if credentials == nil {
+		return ErrSASLNotAuthorized
+	}
+	s.user = user
+	s.credentials = credentials
+
+	s.srvNonce = cNonce + "-" + uuid.New().String()
+	sb64 := base64.StdEncoding.EncodeToString(credentials.Salt)
+	s.firstMessage = fmt.Sprintf("r=%s,s=%s,i=%d", s.srvNonce, sb64, credentials.Iterations)
 
 	respElem := xmpp.NewElementNamespace("challenge", saslNamespace)
 	respElem.SetText(base64.StdEncoding.EncodeToString([]byte(s.firstMessage)))
@@ -235,23 +269,25 @@ func (s *Scram) handleChallenged(ctx context.Context, elem xmpp.XElement) error
 	initialMessage := s.params.String()
 	clientFinalMessageBare := fmt.Sprintf("c=%s,r=%s", c, s.srvNonce)
 
-	saltedPassword := s.pbkdf2([]byte(s.user.Password))
-	clientKey := s.hmac([]byte("Client Key"), saltedPassword)
-	storedKey := s.hash(clientKey)
+	if !strings.HasPrefix(p, clientFinalMessageBare+",p=") {
+		return ErrSASLNotAuthorized
+	}
+	clientProof, err := base64.StdEncoding.DecodeString(p[len(clientFinalMessageBare)+3:])
+	if err != nil || len(clientProof) != len(s.credentials.StoredKey) {
+		return ErrSASLNotAuthorized
+	}
 	authMessage := initialMessage + "," + s.firstMessage + "," + clientFinalMessageBare
-	clientSignature := s.hmac([]byte(authMessage), storedKey)
+	clientSignature := s.hmac([]byte(authMessage), s.credentials.StoredKey)
 
-	clientProof := make([]byte, len(clientKey))
-	for i := 0; i < len(clientKey); i++ {
-		clientProof[i] = clientKey[i] ^ clientSignature[i]
+	// recover client key from its proof and check it against stored key
+	clientKey := make([]byte, len(clientProof))
+	for i := 0; i < len(clientProof); i++ {
+		clientKey[i] = clientProof[i] ^ clientSignature[i]
 	}
-	serverKey := s.hmac([]byte("Server Key"), saltedPassword)
-	serverSignature := s.hmac([]byte(authMessage), serverKey)
-
-	clientFinalMessage := clientFinalMessageBare + ",p=" + base64.StdEncoding.EncodeToString(clientProof)
-	if clientFinalMessage != p {
+	if !hmac.Equal(s.hash(clientKey), s.credentials.StoredKey) {
 		return ErrSASLNotAuthorized
 	}
+	serverSignature := s.hmac([]byte(authMessage), s.credentials.ServerKey)
 	v := "v=" + base64.StdEncoding.EncodeToString(serverSignature)
 
 	respElem := xmpp.NewElementNamespace("success", saslNamespace)
@@ -262,6 +298,23 @@ func (s *Scram) handleChallenged(ctx context.Context, elem xmpp.XElement) error
 	return nil
 }
 
+// userCredentials returns the user SCRAM credentials matching authenticator hash function.
+func (s *Scram) userCredentials(user *model.User) (*model.ScramCredentials, error) {
+	if user.HasLegacyPassword() {
+		// credentials not migrated yet
+		return model.NewScramCredentials(s.h, user.Password)
+	}
+	switch s.tp {
+	case ScramSHA1:
+		return user.ScramSHA1, nil
+	case ScramSHA256:
+		return user.ScramSHA256, nil
+	case ScramSHA512:
+		return user.ScramSHA512, nil
+	}
+	return nil, nil
+}
+
 func (s *Scram) getElementPayload(elem xmpp.XElement) (string, error) {
 	if len(elem.Text()) == 0 {
 		return "", ErrSASLIncorrectEncoding
@@ -284,22 +337,30 @@ func (s *Scram) parseParameters(str string) error {
 
 	// https://tools.ietf.org/html/rfc5801#section-5
 	switch gs2BindFlag {
-	case "p":
-		// Channel binding is supported and required.
-		if !s.usesCb {
+	case "n":
+		// Channel binding is not supported.
+		break
+	case "y":
+		// Channel binding is supported by the client, but it thinks the server does not.
+		// Since -PLUS variants are offered whenever channel binding is available, this
+		// can only happen as the result of a downgrade attack. (RFC 5802 section 6)
+		if s.hasChannelBinding() {
 			return ErrSASLNotAuthorized
 		}
-	case "n", "y":
-		// Channel binding is not supported, or is supported but is not required.
-		break
 	default:
+		// Channel binding is supported and required.
 		if !strings.HasPrefix(gs2BindFlag, "p=") {
 			return ErrSASLMalformedRequest
 		}
 		if !s.usesCb {
 			return ErrSASLNotAuthorized
 		}
-		p.cbMechanism = gs2BindFlag[2:]
+		cbMechanism, ok := channelBindingMechanism(gs2BindFlag[2:])
+		if !ok || len(s.tr.ChannelBindingBytes(cbMechanism)) == 0 {
+			// unsupported channel binding type
+			return ErrSASLNotAuthorized
+		}
+		p.cbMechanism = cbMechanism
 	}
 	authzID := sp[1]
 	p.gs2Header = gs2BindFlag + "," + authzID + ","
@@ -319,22 +380,25 @@ func (s *Scram) parseParameters(str string) error {
 	return nil
 }
 
+// hasChannelBinding tells whether or not any channel binding type is available on the transport.
+func (s *Scram) hasChannelBinding() bool {
+	for _, cb := range transport.ChannelBindingMechanisms {
+		if len(s.tr.ChannelBindingBytes(cb)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Scram) getCBindInputString() string {
 	buf := new(bytes.Buffer)
 	buf.Write([]byte(s.params.gs2Header))
 	if s.usesCb {
-		switch s.params.cbMechanism {
-		case "tls-unique":
-			buf.Write(s.tr.ChannelBindingBytes(transport.TLSUnique))
-		}
+		buf.Write(s.tr.ChannelBindingBytes(s.params.cbMechanism))
 	}
 	return base64.StdEncoding.EncodeToString(buf.Bytes())
 }
 
-func (s *Scram) pbkdf2(b []byte) []byte {
-	return pbkdf2.Key(b, s.salt, iterationsCount, s.hKeyLen, s.h)
-}
-
 func (s *Scram) hmac(b []byte, key []byte) []byte {
 	m := hmac.New(s.h, key)
 	m.Write(b)
@@ -346,3 +410,12 @@ func (s *Scram) hash(b []byte) []byte {
 	h.Write(b)
 	return h.Sum(nil)
 }
+
+func channelBindingMechanism(name string) (transport.ChannelBindingMechanism, bool) {
+	for _, cb := range transport.ChannelBindingMechanisms {
+		if cb.String() == name {
+			return cb, true
+		}
+	}
+	return 0, false
+}
@@ -11,12 +11,14 @@ import (
 	"crypto/hmac"
 	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
 	"fmt"
 	"hash"
 	"math/rand"
+	"net"
 	"strconv"
 	"strings"
 	"testing"
@@ -41,7 +43,9 @@ func randomBytes(l int) []byte {
 }
 
 type fakeTransport struct {
-	cbBytes []byte
+	cbMechanism transport.ChannelBindingMechanism
+	cbBytes     []byte
+	peerCerts   []*x509.Certificate
 }
 
 func (ft *fakeTransport) Read(p []byte) (n int, err error)        { return 0, nil }
@@ -53,15 +57,20 @@ func (ft *fakeTransport) SetWriteDeadline(_ time.Time) error      { return nil }
 func (ft *fakeTransport) WriteString(s string) (n int, err error) { return 0, nil }
 func (ft *fakeTransport) StartTLS(*tls.Config, bool)              { return }
 func (ft *fakeTransport) EnableCompression(compress.Level)        { return }
-func (ft *fakeTransport) ChannelBindingBytes(transport.ChannelBindingMechanism) []byte {
+func (ft *fakeTransport) ChannelBindingBytes(mechanism transport.ChannelBindingMechanism) []byte {
+	if mechanism != ft.cbMechanism {
+		return nil
+	}
 	return ft.cbBytes
 }
-func (ft *fakeTransport) PeerCertificates() []*x509.Certificate { return nil }
+func (ft *fakeTransport) PeerCertificates() []*x509.Certificate { return ft.peerCerts }
+func (ft *fakeTransport) RemoteAddr() net.Addr                  { return nil }
 
 type scramAuthTestCase struct {
 	id          int
 	scramType   ScramType
 	usesCb      bool
+	cbMechanism transport.ChannelBindingMechanism
 	cbBytes     []byte
 	gs2BindFlag string
 	authID      string
@@ -123,6 +132,40 @@ var tt = []scramAuthTestCase{
 		r:           "d712875c-bd3b-4b41-801d-eb9c541d9884",
 		password:    "1234",
 	},
+	{
+		// SCRAM-SHA-512
+		id:          12,
+		scramType:   ScramSHA512,
+		usesCb:      false,
+		gs2BindFlag: "n",
+		n:           "ortuman",
+		r:           "0b2f3c3e-6d9f-4d0b-9d6b-0f4b7b9f6f21",
+		password:    "1234",
+	},
+	{
+		// SCRAM-SHA-512-PLUS (tls-exporter)
+		id:          13,
+		scramType:   ScramSHA512,
+		usesCb:      true,
+		cbMechanism: transport.TLSExporter,
+		cbBytes:     randomBytes(32),
+		gs2BindFlag: "p=tls-exporter",
+		n:           "ortuman",
+		r:           "3c8d2f2a-5b0e-4e55-9d4c-7f8a1c2b3d4e",
+		password:    "1234",
+	},
+	{
+		// SCRAM-SHA-256-PLUS (tls-server-end-point)
+		id:          14,
+		scramType:   ScramSHA256,
+		usesCb:      true,
+		cbMechanism: transport.TLSServerEndPoint,
+		cbBytes:     randomBytes(32),
+		gs2BindFlag: "p=tls-server-end-point",
+		n:           "ortuman",
+		r:           "9a1e7c55-2f7d-4b8e-8c1a-6e2d4f5a7b9c",
+		password:    "1234",
+	},
 
 	// Fail cases
 	{
@@ -206,6 +249,31 @@ var tt = []scramAuthTestCase{
 		password:    "1234",
 		expectedErr: ErrSASLMalformedRequest,
 	},
+	{
+		// channel binding type not available
+		id:          15,
+		scramType:   ScramSHA256,
+		usesCb:      true,
+		cbMechanism: transport.TLSUnique,
+		cbBytes:     randomBytes(32),
+		gs2BindFlag: "p=tls-exporter",
+		n:           "ortuman",
+		r:           "bb769406-eaa4-4f38-a279-2b90e596f6dd",
+		password:    "1234",
+		expectedErr: ErrSASLNotAuthorized,
+	},
+	{
+		// unknown channel binding type
+		id:          16,
+		scramType:   ScramSHA256,
+		usesCb:      true,
+		cbBytes:     randomBytes(32),
+		gs2BindFlag: "p=tls-foo",
+		n:           "ortuman",
+		r:           "bb769406-eaa4-4f38-a279-2b90e596f6dd",
+		password:    "1234",
+		expectedErr: ErrSASLNotAuthorized,
+	},
 }
 
 func TestScramMechanisms(t *testing.T) {
@@ -228,8 +296,16 @@ func TestScramMechanisms(t *testing.T) {
 	require.Equal(t, authr4.Mechanism(), "SCRAM-SHA-256-PLUS")
 	require.True(t, authr4.UsesChannelBinding())
 
-	authr5 := NewScram(testStm, testTr, ScramType(99), true, s)
-	require.Equal(t, authr5.Mechanism(), "")
+	authr5 := NewScram(testStm, testTr, ScramSHA512, false, s)
+	require.Equal(t, authr5.Mechanism(), "SCRAM-SHA-512")
+	require.False(t, authr5.UsesChannelBinding())
+
+	authr6 := NewScram(testStm, testTr, ScramSHA512, true, s)
+	require.Equal(t, authr6.Mechanism(), "SCRAM-SHA-512-PLUS")
+	require.True(t, authr6.UsesChannelBinding())
+
+	authr7 := NewScram(testStm, testTr, ScramType(99), true, s)
+	require.Equal(t, authr7.Mechanism(), "")
 }
 
 func TestScramBadPayload(t *testing.T) {
@@ -250,6 +326,27 @@ func TestScramBadPayload(t *testing.T) {
 	require.Equal(t, ErrSASLIncorrectEncoding, authr.ProcessElement(context.Background(), auth))
 }
 
+func TestScramChannelBindingDowngrade(t *testing.T) {
+	testTr := &fakeTransport{cbMechanism: transport.TLSUnique, cbBytes: randomBytes(23)}
+	testStm, s := authTestSetup(&model.User{Username: "ortuman", Password: "1234"})
+
+	authr := NewScram(testStm, testTr, ScramSHA1, false, s)
+
+	auth := xmpp.NewElementNamespace("auth", saslNamespace)
+	auth.SetAttribute("mechanism", authr.Mechanism())
+	auth.SetText(base64.StdEncoding.EncodeToString([]byte("y,,n=ortuman,r=bb769406-eaa4-4f38-a279-2b90e596f6dd")))
+	require.Equal(t, "ortuman", authr.ParseUsername(auth))
+
+	// channel binding is available, so 'y' flag must be rejected
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(context.Background(), auth))
+
+	// ...whereas it's accepted if no channel binding is offered
+	testTr.cbBytes = nil
+	authr.Reset()
+	require.Nil(t, authr.ProcessElement(context.Background(), auth))
+	require.Equal(t, "challenge", testStm.ReceiveElement().Name())
+}
+
 func TestScramTestCases(t *testing.T) {
 	for _, tc := range tt {
 		err := processScramTestCase(t, &tc)
@@ -263,6 +360,7 @@ func TestScramTestCases(t *testing.T) {
 func processScramTestCase(t *testing.T, tc *scramAuthTestCase) error {
 	tr := &fakeTransport{}
 	if tc.usesCb {
+		tr.cbMechanism = tc.cbMechanism
 		tr.cbBytes = tc.cbBytes
 	}
 	testStm, s := authTestSetup(&model.User{Username: "ortuman", Password: "1234"})
@@ -369,6 +467,8 @@ func testScramAuthPbkdf2(b []byte, salt []byte, scramType ScramType, iterationCo
 		return pbkdf2.Key(b, salt, iterationCount, sha1.Size, sha1.New)
 	case ScramSHA256:
 		return pbkdf2.Key(b, salt, iterationCount, sha256.Size, sha256.New)
+	case ScramSHA512:
+		return pbkdf2.Key(b, salt, iterationCount, sha512.Size, sha512.New)
 	}
 	return nil
 }
@@ -380,6 +480,8 @@ func testScramAuthHmac(b []byte, key []byte, scramType ScramType) []byte {
 		h = sha1.New
 	case ScramSHA256:
 		h = sha256.New
+	case ScramSHA512:
+		h = sha512.New
 	}
 	m := hmac.New(h, key)
 	m.Write(b)
@@ -393,6 +495,8 @@ func testScramAuthHash(b []byte, scramType ScramType) []byte {
 		h = sha1.New()
 	case ScramSHA256:
 		h = sha256.New()
+	case ScramSHA512:
+		h = sha512.New()
 	}
 	h.Write(b)
 	return h.Sum(nil)
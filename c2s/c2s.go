@@ -7,56 +7,84 @@ package c2s
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"sync/atomic"
 
+	"github.com/ortuman/jackal/auth/backend"
+	"github.com/ortuman/jackal/auth/lockout"
 	"github.com/ortuman/jackal/component"
 	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage/anonymous"
 	"github.com/ortuman/jackal/storage/repository"
 	"github.com/pkg/errors"
 )
 
 const (
-	streamNamespace           = "http://etherx.jabber.org/streams"
-	tlsNamespace              = "urn:ietf:params:xml:ns:xmpp-tls"
-	compressProtocolNamespace = "http://jabber.org/protocol/compress"
-	bindNamespace             = "urn:ietf:params:xml:ns:xmpp-bind"
-	sessionNamespace          = "urn:ietf:params:xml:ns:xmpp-session"
-	saslNamespace             = "urn:ietf:params:xml:ns:xmpp-sasl"
-	blockedErrorNamespace     = "urn:xmpp:blocking:errors"
+	streamNamespace             = "http://etherx.jabber.org/streams"
+	tlsNamespace                = "urn:ietf:params:xml:ns:xmpp-tls"
+	compressProtocolNamespace   = "http://jabber.org/protocol/compress"
+	bindNamespace               = "urn:ietf:params:xml:ns:xmpp-bind"
+	sessionNamespace            = "urn:ietf:params:xml:ns:xmpp-session"
+	saslNamespace               = "urn:ietf:params:xml:ns:xmpp-sasl"
+	saslChannelBindingNamespace = "urn:xmpp:sasl-cb:0"
+	sasl2Namespace              = "urn:xmpp:sasl:2"
+	bind2Namespace              = "urn:xmpp:bind:0"
+	carbonsNamespace            = "urn:xmpp:carbons:2"
+	fastNamespace               = "urn:xmpp:fast:0"
+	blockedErrorNamespace       = "urn:xmpp:blocking:errors"
+	smNamespace                 = "urn:xmpp:sm:3"
+	csiNamespace                = "urn:xmpp:csi:0"
 )
 
 type c2sServer interface {
 	start()
 	shutdown(ctx context.Context) error
+	reload(config *Config, mods *module.Modules) []string
+	connectionsByState() map[string]int
 }
 
 var createC2SServer = newC2SServer
 
 // C2S represents a client-to-server connection manager.
 type C2S struct {
-	mu      sync.RWMutex
-	servers map[string]c2sServer
-	started uint32
+	mu        sync.RWMutex
+	servers   map[string]c2sServer
+	started   uint32
+	router    router.Router
+	newServer func(config *Config, mods *module.Modules) c2sServer
 }
 
 // New returns a new instance of a c2s connection manager.
-func New(configs []Config, mods *module.Modules, comps *component.Components, router router.Router, userRep repository.User, blockListRep repository.BlockList) (*C2S, error) {
+func New(configs []Config, mods *module.Modules, comps *component.Components, router router.Router, authBackend backend.Backend, blockListRep repository.BlockList, anonRep *anonymous.Storage, fastRep repository.FastToken, lockoutTracker *lockout.Tracker) (*C2S, error) {
 	if len(configs) == 0 {
 		return nil, errors.New("at least one c2s configuration is required")
 	}
-	c := &C2S{servers: make(map[string]c2sServer)}
-	for _, config := range configs {
-		srv := createC2SServer(&config, mods, comps, router, userRep, blockListRep)
-		c.servers[config.ID] = srv
+	smReg := newSMRegistry() // shared among servers, so that sessions can be resumed through any listener
+	c := &C2S{
+		servers: make(map[string]c2sServer),
+		router:  router,
+		newServer: func(config *Config, mods *module.Modules) c2sServer {
+			return createC2SServer(config, mods, comps, router, authBackend, blockListRep, anonRep, fastRep, lockoutTracker, smReg)
+		},
+	}
+	if err := c.validate(configs); err != nil {
+		return nil, err
 	}
+	for i := range configs {
+		config := configs[i]
+		c.servers[config.ID] = c.newServer(&config, mods)
+	}
+	connections.Set(c.reportConnections)
 	return c, nil
 }
 
 // Start initializes c2s manager spawning every single server.
 func (c *C2S) Start() {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	if atomic.CompareAndSwapUint32(&c.started, 0, 1) {
 		for _, srv := range c.servers {
 			go srv.start()
@@ -64,8 +92,81 @@ func (c *C2S) Start() {
 	}
 }
 
+// Reload applies a new set of c2s configurations: listeners no longer present are shut down,
+// new ones are started and the remaining ones apply the new settings to every stream started from now on.
+// Already established sessions are left untouched.
+func (c *C2S) Reload(ctx context.Context, configs []Config, mods *module.Modules) error {
+	if len(configs) == 0 {
+		return errors.New("at least one c2s configuration is required")
+	}
+	if err := c.validate(configs); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	started := atomic.LoadUint32(&c.started) == 1
+
+	newConfigs := make(map[string]*Config, len(configs))
+	for i := range configs {
+		config := configs[i]
+		newConfigs[config.ID] = &config
+	}
+	for id, srv := range c.servers {
+		if _, ok := newConfigs[id]; ok {
+			continue
+		}
+		log.Infof("%s: listener removed", id)
+		if started {
+			if err := srv.shutdown(ctx); err != nil {
+				log.Error(err)
+			}
+		}
+		delete(c.servers, id)
+	}
+	for id, config := range newConfigs {
+		srv, ok := c.servers[id]
+		if !ok {
+			log.Infof("%s: listener added", id)
+			srv = c.newServer(config, mods)
+			c.servers[id] = srv
+			if started {
+				go srv.start()
+			}
+			continue
+		}
+		for _, setting := range srv.reload(config, mods) {
+			log.Warnf("%s: %s changes require a restart to be applied", id, setting)
+		}
+	}
+	return nil
+}
+
+func (c *C2S) validate(configs []Config) error {
+	for _, config := range configs {
+		for _, h := range config.AnonymousHosts {
+			if !c.router.Hosts().IsLocalHost(h) {
+				return fmt.Errorf("c2s: anonymous host %s is not a local domain", h)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *C2S) reportConnections(report func(val float64, labelValues ...string)) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for id, srv := range c.servers {
+		for state, count := range srv.connectionsByState() {
+			report(float64(count), id, state)
+		}
+	}
+}
+
 // Shutdown gracefully shuts down c2s manager.
 func (c *C2S) Shutdown(ctx context.Context) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	if atomic.CompareAndSwapUint32(&c.started, 1, 0) {
 		for _, srv := range c.servers {
 			if err := srv.shutdown(ctx); err != nil {
@@ -6,20 +6,26 @@
 package c2s
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"io"
 	"net"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/ortuman/jackal/auth/backend"
+	"github.com/ortuman/jackal/auth/lockout"
 	c2srouter "github.com/ortuman/jackal/c2s/router"
 	"github.com/ortuman/jackal/component"
 	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/router/host"
+	"github.com/ortuman/jackal/storage/anonymous"
 	memorystorage "github.com/ortuman/jackal/storage/memory"
 	"github.com/ortuman/jackal/storage/repository"
 	"github.com/ortuman/jackal/xmpp"
@@ -61,6 +67,9 @@ type fakeSocketConn struct {
 	wrCh    chan []byte
 	closeCh chan struct{}
 	closed  uint32
+
+	mu      sync.Mutex
+	written bytes.Buffer
 }
 
 func newFakeSocketConn() *fakeSocketConn {
@@ -88,6 +97,9 @@ func (c *fakeSocketConn) Write(b []byte) (n int, err error) {
 	}
 	wb := make([]byte, len(b))
 	copy(wb, b)
+	c.mu.Lock()
+	c.written.Write(wb)
+	c.mu.Unlock()
 	c.wrCh <- wb
 	return len(wb), nil
 }
@@ -124,6 +136,13 @@ func (c *fakeSocketConn) outboundRead() xmpp.XElement {
 	return &xmpp.Element{}
 }
 
+// outboundWritten returns every byte written to the connection, including those written right before closing it.
+func (c *fakeSocketConn) outboundWritten() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.written.String()
+}
+
 func (c *fakeSocketConn) waitClose() bool {
 	select {
 	case <-c.closeCh:
@@ -161,21 +180,33 @@ func setupTest(domain string) (router.Router, repository.User, repository.BlockL
 	blockListRep := memorystorage.NewBlockList()
 	r, _ := router.New(
 		hosts,
-		c2srouter.New(userRep, blockListRep),
+		c2srouter.New(backend.NewStorage(userRep), blockListRep),
 		nil,
 	)
 	return r, userRep, blockListRep
 }
 
+type fakeBackend struct {
+	backend.Backend
+	passwordChecks int32
+}
+
+func (b *fakeBackend) CheckPassword(ctx context.Context, username, password string) (bool, error) {
+	atomic.AddInt32(&b.passwordChecks, 1)
+	return b.Backend.CheckPassword(ctx, username, password)
+}
+
 type fakeC2SServer struct {
 	startCh    chan struct{}
 	shutdownCh chan struct{}
+	reloadCh   chan *Config
 }
 
 func newFakeC2SServer() *fakeC2SServer {
 	return &fakeC2SServer{
 		startCh:    make(chan struct{}, 1),
 		shutdownCh: make(chan struct{}, 1),
+		reloadCh:   make(chan *Config, 1),
 	}
 }
 
@@ -188,6 +219,15 @@ func (s *fakeC2SServer) shutdown(ctx context.Context) error {
 	return nil
 }
 
+func (s *fakeC2SServer) reload(config *Config, _ *module.Modules) []string {
+	s.reloadCh <- config
+	return nil
+}
+
+func (s *fakeC2SServer) connectionsByState() map[string]int {
+	return map[string]int{"bound": 2}
+}
+
 func TestC2S_StartAndShutdown(t *testing.T) {
 	c2s, fakeSrv := setupTestC2S("localhost")
 
@@ -208,9 +248,74 @@ func TestC2S_StartAndShutdown(t *testing.T) {
 	}
 }
 
+func TestC2S_AnonymousHosts(t *testing.T) {
+	r, userRep, blockListRep := setupTest("localhost")
+
+	_, err := New([]Config{{AnonymousHosts: []string{"jackal.im"}}}, &module.Modules{}, &component.Components{}, r, backend.NewStorage(userRep), blockListRep, nil, nil, nil)
+	require.NotNil(t, err)
+}
+
+func TestC2S_Reload(t *testing.T) {
+	defer func() { createC2SServer = newC2SServer }()
+
+	srvs := make(map[string]*fakeC2SServer)
+	createC2SServer = func(config *Config, _ *module.Modules, _ *component.Components, _ router.Router, _ backend.Backend, _ repository.BlockList, _ *anonymous.Storage, _ repository.FastToken, _ *lockout.Tracker, _ *smRegistry) c2sServer {
+		srv := newFakeC2SServer()
+		srvs[config.ID] = srv
+		return srv
+	}
+	r, userRep, blockListRep := setupTest("localhost")
+
+	c2s, err := New([]Config{{ID: "c2s1"}, {ID: "c2s2"}}, &module.Modules{}, &component.Components{}, r, backend.NewStorage(userRep), blockListRep, nil, nil, nil)
+	require.Nil(t, err)
+	require.Len(t, srvs, 2)
+
+	c2s.Start()
+	<-srvs["c2s1"].startCh
+	<-srvs["c2s2"].startCh
+
+	// invalid configurations leave listeners untouched
+	err = c2s.Reload(context.Background(), []Config{{ID: "c2s2", AnonymousHosts: []string{"jackal.im"}}}, &module.Modules{})
+	require.NotNil(t, err)
+	require.Len(t, srvs, 2)
+
+	err = c2s.Reload(context.Background(), []Config{{ID: "c2s2", MaxStanzaSize: 1024}, {ID: "c2s3"}}, &module.Modules{})
+	require.Nil(t, err)
+
+	select {
+	case <-srvs["c2s1"].shutdownCh:
+		break
+	case <-time.After(time.Millisecond * 250):
+		require.Fail(t, "c2s shutdown timeout")
+	}
+	select {
+	case cfg := <-srvs["c2s2"].reloadCh:
+		require.Equal(t, 1024, cfg.MaxStanzaSize)
+	case <-time.After(time.Millisecond * 250):
+		require.Fail(t, "c2s reload timeout")
+	}
+	select {
+	case <-srvs["c2s3"].startCh:
+		break
+	case <-time.After(time.Millisecond * 250):
+		require.Fail(t, "c2s start timeout")
+	}
+	c2s.Shutdown(context.Background())
+}
+
+func TestC2S_ReportConnections(t *testing.T) {
+	c2s, _ := setupTestC2S("localhost")
+
+	var reported []string
+	c2s.reportConnections(func(val float64, labelValues ...string) {
+		reported = append(reported, fmt.Sprintf("%s:%s:%v", labelValues[0], labelValues[1], val))
+	})
+	require.Equal(t, []string{":bound:2"}, reported)
+}
+
 func setupTestC2S(domain string) (*C2S, *fakeC2SServer) {
 	srv := newFakeC2SServer()
-	createC2SServer = func(_ *Config, _ *module.Modules, _ *component.Components, _ router.Router, _ repository.User, _ repository.BlockList) c2sServer {
+	createC2SServer = func(_ *Config, _ *module.Modules, _ *component.Components, _ router.Router, _ backend.Backend, _ repository.BlockList, _ *anonymous.Storage, _ repository.FastToken, _ *lockout.Tracker, _ *smRegistry) c2sServer {
 		return srv
 	}
 
@@ -220,10 +325,10 @@ func setupTestC2S(domain string) (*C2S, *fakeC2SServer) {
 	blockListRep := memorystorage.NewBlockList()
 	r, _ := router.New(
 		hosts,
-		c2srouter.New(userRep, blockListRep),
+		c2srouter.New(backend.NewStorage(userRep), blockListRep),
 		nil,
 	)
 
-	c2s, _ := New([]Config{{}}, &module.Modules{}, &component.Components{}, r, userRep, blockListRep)
+	c2s, _ := New([]Config{{}}, &module.Modules{}, &component.Components{}, r, backend.NewStorage(userRep), blockListRep, nil, nil, nil)
 	return c2s, srv
 }
@@ -6,13 +6,21 @@
 package c2s
 
 import (
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"strings"
 	"time"
 
+	"github.com/ortuman/jackal/auth/lockout"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/transport/bosh"
 	"github.com/ortuman/jackal/transport/compress"
+	"github.com/ortuman/jackal/transport/proxyproto"
+	"github.com/ortuman/jackal/util/connlimit"
+	"github.com/ortuman/jackal/util/ratelimit"
 )
 
 const (
@@ -22,6 +30,10 @@ const (
 	defaultTransportPort      = 5222
 	defaultTransportKeepAlive = time.Duration(120) * time.Second
 	defaultTransportURLPath   = "/xmpp/ws"
+	defaultBOSHURLPath        = "/http-bind"
+	defaultSMResumeTimeout    = time.Duration(300) * time.Second
+	defaultSMMaxQueueSize     = 1000
+	defaultFastTokenExpiry    = time.Duration(14*24) * time.Hour
 )
 
 // ResourceConflictPolicy represents a resource conflict policy.
@@ -68,20 +80,82 @@ func (c *CompressConfig) UnmarshalYAML(unmarshal func(interface{}) error) error
 	return nil
 }
 
+// StreamManagementConfig represents a stream management (XEP-0198) configuration.
+type StreamManagementConfig struct {
+	Enabled       bool
+	ResumeTimeout time.Duration
+	MaxQueueSize  int
+}
+
+type streamManagementProxyType struct {
+	Enabled       bool `yaml:"enabled"`
+	ResumeTimeout int  `yaml:"resume_timeout"`
+	MaxQueueSize  int  `yaml:"max_queue_size"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *StreamManagementConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := streamManagementProxyType{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	c.Enabled = p.Enabled
+	c.ResumeTimeout = time.Duration(p.ResumeTimeout) * time.Second
+	if c.ResumeTimeout == 0 {
+		c.ResumeTimeout = defaultSMResumeTimeout
+	}
+	c.MaxQueueSize = p.MaxQueueSize
+	if c.MaxQueueSize == 0 {
+		c.MaxQueueSize = defaultSMMaxQueueSize
+	}
+	return nil
+}
+
+// FastConfig represents a fast authentication mechanism (XEP-0484) configuration.
+type FastConfig struct {
+	Enabled bool
+	Expiry  time.Duration
+}
+
+type fastProxyType struct {
+	Enabled bool `yaml:"enabled"`
+	Expiry  int  `yaml:"expiry"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *FastConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := fastProxyType{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	c.Enabled = p.Enabled
+	c.Expiry = time.Duration(p.Expiry) * time.Second
+	if c.Expiry == 0 {
+		c.Expiry = defaultFastTokenExpiry
+	}
+	return nil
+}
+
 // TransportConfig represents an XMPP stream transport configuration.
 type TransportConfig struct {
-	Type        transport.Type
-	BindAddress string
-	Port        int
-	URLPath     string
+	Type          transport.Type
+	BindAddress   string
+	Port          int
+	URLPath       string
+	DirectTLS     bool
+	ProxyProtocol proxyproto.Config
+	BOSH          bosh.Config
 }
 
 type transportProxyType struct {
-	Type        string `yaml:"type"`
-	BindAddress string `yaml:"bind_addr"`
-	Port        int    `yaml:"port"`
-	KeepAlive   int    `yaml:"keep_alive"`
-	URLPath     string `yaml:"url_path"`
+	Type          string            `yaml:"type"`
+	BindAddress   string            `yaml:"bind_addr"`
+	Port          int               `yaml:"port"`
+	KeepAlive     int               `yaml:"keep_alive"`
+	URLPath       string            `yaml:"url_path"`
+	TLS           string            `yaml:"tls"`
+	ProxyProtocol proxyproto.Config `yaml:"proxy_protocol"`
+	BOSH          bosh.Config       `yaml:"bosh"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface.
@@ -95,6 +169,12 @@ func (t *TransportConfig) UnmarshalYAML(unmarshal func(interface{}) error) error
 	case "", "socket":
 		t.Type = transport.Socket
 
+	case "websocket":
+		t.Type = transport.WebSocket
+
+	case "bosh":
+		t.Type = transport.BOSH
+
 	default:
 		return fmt.Errorf("c2s.TransportConfig: unrecognized transport type: %s", p.Type)
 	}
@@ -103,8 +183,31 @@ func (t *TransportConfig) UnmarshalYAML(unmarshal func(interface{}) error) error
 
 	t.URLPath = p.URLPath
 	if len(t.URLPath) == 0 {
-		t.URLPath = defaultTransportURLPath
+		switch t.Type {
+		case transport.BOSH:
+			t.URLPath = defaultBOSHURLPath
+		default:
+			t.URLPath = defaultTransportURLPath
+		}
+	}
+	t.BOSH = p.BOSH
+
+	// validate tls mode
+	switch p.TLS {
+	case "", "starttls":
+		t.DirectTLS = false
+	case "direct":
+		if t.Type != transport.Socket {
+			return fmt.Errorf("c2s.TransportConfig: direct tls not supported by %v transport", t.Type)
+		}
+		t.DirectTLS = true
+	default:
+		return fmt.Errorf("c2s.TransportConfig: unrecognized tls mode: %s", p.TLS)
 	}
+	if p.ProxyProtocol.Enabled && t.Type != transport.Socket {
+		return fmt.Errorf("c2s.TransportConfig: proxy protocol not supported by %v transport", t.Type)
+	}
+	t.ProxyProtocol = p.ProxyProtocol
 
 	// assign transport's defaults
 	if t.Port == 0 {
@@ -115,8 +218,9 @@ func (t *TransportConfig) UnmarshalYAML(unmarshal func(interface{}) error) error
 
 // TLSConfig represents a server TLS configuration.
 type TLSConfig struct {
-	CertFile    string `yaml:"cert_path"`
-	PrivKeyFile string `yaml:"privkey_path"`
+	CertFile     string `yaml:"cert_path"`
+	PrivKeyFile  string `yaml:"privkey_path"`
+	ClientCAFile string `yaml:"client_ca_path"`
 }
 
 // Config represents C2S server configuration.
@@ -129,21 +233,32 @@ type Config struct {
 	ResourceConflict ResourceConflictPolicy
 	Transport        TransportConfig
 	SASL             []string
+	AnonymousHosts   []string
+	ClientCAs        *x509.CertPool
 	Compression      CompressConfig
+	StreamManagement StreamManagementConfig
+	Fast             FastConfig
+	RateLimit        ratelimit.Config
+	Connections      connlimit.Config
 }
 
 type configProxy struct {
-	ID               string          `yaml:"id"`
-	Domain           string          `yaml:"domain"`
-	TLS              TLSConfig       `yaml:"tls"`
-	ConnectTimeout   int             `yaml:"connect_timeout"`
-	Timeout          int             `yaml:"timeout"`
-	KeepAlive        int             `yaml:"keep_alive"`
-	MaxStanzaSize    int             `yaml:"max_stanza_size"`
-	ResourceConflict string          `yaml:"resource_conflict"`
-	Transport        TransportConfig `yaml:"transport"`
-	SASL             []string        `yaml:"sasl"`
-	Compression      CompressConfig  `yaml:"compression"`
+	ID               string                 `yaml:"id"`
+	Domain           string                 `yaml:"domain"`
+	TLS              TLSConfig              `yaml:"tls"`
+	ConnectTimeout   int                    `yaml:"connect_timeout"`
+	Timeout          int                    `yaml:"timeout"`
+	KeepAlive        int                    `yaml:"keep_alive"`
+	MaxStanzaSize    int                    `yaml:"max_stanza_size"`
+	ResourceConflict string                 `yaml:"resource_conflict"`
+	Transport        TransportConfig        `yaml:"transport"`
+	SASL             []string               `yaml:"sasl"`
+	AnonymousHosts   []string               `yaml:"anonymous_hosts"`
+	Compression      CompressConfig         `yaml:"compression"`
+	StreamManagement StreamManagementConfig `yaml:"stream_management"`
+	Fast             FastConfig             `yaml:"fast"`
+	RateLimit        ratelimit.Config       `yaml:"rate_limit"`
+	Connections      connlimit.Config       `yaml:"connections"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface.
@@ -185,18 +300,47 @@ func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	// validate SASL mechanisms
 	for _, sasl := range p.SASL {
 		switch sasl {
-		case "plain", "digest_md5", "scram_sha_1", "scram_sha_256", "scram_sha_512":
+		case "plain", "scram_sha_1", "scram_sha_256", "scram_sha_512", "anonymous":
 			continue
+		case "external":
+			if len(p.TLS.ClientCAFile) == 0 {
+				return errors.New("c2s.Config: external SASL mechanism requires a client_ca_path")
+			}
 		default:
 			return fmt.Errorf("c2s.Config: unrecognized SASL mechanism: %s", sasl)
 		}
 	}
+	// load client certificates CA pool
+	if len(p.TLS.ClientCAFile) > 0 {
+		certPool, err := loadCertPool(p.TLS.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		cfg.ClientCAs = certPool
+	}
 	cfg.Transport = p.Transport
 	cfg.SASL = p.SASL
+	cfg.AnonymousHosts = p.AnonymousHosts
 	cfg.Compression = p.Compression
+	cfg.StreamManagement = p.StreamManagement
+	cfg.Fast = p.Fast
+	cfg.RateLimit = p.RateLimit
+	cfg.Connections = p.Connections
 	return nil
 }
 
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("c2s.Config: no certificates found in %s", caFile)
+	}
+	return certPool, nil
+}
+
 type streamConfig struct {
 	connectTimeout   time.Duration
 	timeout          time.Duration
@@ -204,6 +348,14 @@ type streamConfig struct {
 	maxStanzaSize    int
 	resourceConflict ResourceConflictPolicy
 	sasl             []string
+	anonymousHosts   []string
+	clientCAs        *x509.CertPool
 	compression      CompressConfig
+	directTLS        bool
+	sm               StreamManagementConfig
+	fast             FastConfig
+	lockout          *lockout.Tracker
+	rateLimit        ratelimit.Config
+	smRegistry       *smRegistry
 	onDisconnect     func(s stream.C2S)
 }
@@ -6,8 +6,11 @@
 package c2s
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/ortuman/jackal/transport"
 	"github.com/ortuman/jackal/transport/compress"
@@ -45,6 +48,63 @@ func TestTransportConfig(t *testing.T) {
 	require.Equal(t, transport.Socket, s.Type)
 	require.Equal(t, "0.0.0.0", s.BindAddress)
 	require.Equal(t, 5222, s.Port)
+
+	err = yaml.Unmarshal([]byte("{type: websocket, bind_addr: 0.0.0.0, port: 5280}"), &s)
+	require.Nil(t, err)
+
+	require.Equal(t, transport.WebSocket, s.Type)
+	require.Equal(t, "/xmpp/ws", s.URLPath)
+
+	s = TransportConfig{}
+	err = yaml.Unmarshal([]byte("{type: bosh, port: 5280, bosh: {wait: 30, hold: 2}}"), &s)
+	require.Nil(t, err)
+
+	require.Equal(t, transport.BOSH, s.Type)
+	require.Equal(t, "/http-bind", s.URLPath)
+	require.Equal(t, time.Second*30, s.BOSH.Wait)
+	require.Equal(t, 2, s.BOSH.Hold)
+
+	err = yaml.Unmarshal([]byte("{type: invalid}"), &s)
+	require.NotNil(t, err)
+
+	s = TransportConfig{}
+	err = yaml.Unmarshal([]byte("{type: socket, port: 5223, tls: direct}"), &s)
+	require.Nil(t, err)
+	require.True(t, s.DirectTLS)
+
+	err = yaml.Unmarshal([]byte("{type: websocket, tls: direct}"), &s)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("{type: socket, tls: invalid}"), &s)
+	require.NotNil(t, err)
+}
+
+func TestStreamManagementConfig(t *testing.T) {
+	s := StreamManagementConfig{}
+
+	err := yaml.Unmarshal([]byte("{enabled: true}"), &s)
+	require.Nil(t, err)
+	require.True(t, s.Enabled)
+	require.Equal(t, defaultSMResumeTimeout, s.ResumeTimeout)
+	require.Equal(t, defaultSMMaxQueueSize, s.MaxQueueSize)
+
+	err = yaml.Unmarshal([]byte("{enabled: true, resume_timeout: 60, max_queue_size: 100}"), &s)
+	require.Nil(t, err)
+	require.Equal(t, time.Minute, s.ResumeTimeout)
+	require.Equal(t, 100, s.MaxQueueSize)
+}
+
+func TestFastConfig(t *testing.T) {
+	s := FastConfig{}
+
+	err := yaml.Unmarshal([]byte("{enabled: true}"), &s)
+	require.Nil(t, err)
+	require.True(t, s.Enabled)
+	require.Equal(t, defaultFastTokenExpiry, s.Expiry)
+
+	err = yaml.Unmarshal([]byte("{enabled: true, expiry: 3600}"), &s)
+	require.Nil(t, err)
+	require.Equal(t, time.Hour, s.Expiry)
 }
 
 func TestConfig(t *testing.T) {
@@ -67,16 +127,44 @@ func TestConfig(t *testing.T) {
 	authCfg := `
 connect_timeout: 5
 resource_conflict: reject
-sasl: [plain, digest_md5, scram_sha_1, scram_sha_256, scram_sha_512]
+sasl: [plain, scram_sha_1, scram_sha_256, scram_sha_512]
 `
 	err = yaml.Unmarshal([]byte(authCfg), &s)
 	require.Nil(t, err)
-	require.Equal(t, 5, len(s.SASL))
+	require.Equal(t, 4, len(s.SASL))
 
 	// invalid auth mechanism...
 	err = yaml.Unmarshal([]byte("{id: default, type: c2s, sasl: [invalid]}"), &s)
 	require.NotNil(t, err)
 
+	// unsupported auth mechanism...
+	err = yaml.Unmarshal([]byte("{id: default, type: c2s, sasl: [digest_md5]}"), &s)
+	require.NotNil(t, err)
+
+	// anonymous auth mechanism...
+	err = yaml.Unmarshal([]byte("{id: default, type: c2s, sasl: [anonymous], anonymous_hosts: [guest.jackal.im]}"), &s)
+	require.Nil(t, err)
+	require.Equal(t, []string{"anonymous"}, s.SASL)
+	require.Equal(t, []string{"guest.jackal.im"}, s.AnonymousHosts)
+
+	// external auth mechanism requires a client CA...
+	err = yaml.Unmarshal([]byte("{id: default, type: c2s, sasl: [external]}"), &s)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("{id: default, type: c2s, sasl: [external], tls: {client_ca_path: testdata/missing.pem}}"), &s)
+	require.NotNil(t, err)
+
+	caPEM, _ := tUtilClientCertificates(t, "ortuman@localhost")
+	caFile, err := ioutil.TempFile("", "client_ca")
+	require.Nil(t, err)
+	defer func() { _ = os.Remove(caFile.Name()) }()
+	_, _ = caFile.Write(caPEM)
+	_ = caFile.Close()
+
+	err = yaml.Unmarshal([]byte(fmt.Sprintf("{id: default, type: c2s, sasl: [plain, external], tls: {client_ca_path: %s}}", caFile.Name())), &s)
+	require.Nil(t, err)
+	require.NotNil(t, s.ClientCAs)
+
 	// invalid yaml
 	err = yaml.Unmarshal([]byte("type"), &s)
 	require.NotNil(t, err)
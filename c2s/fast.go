@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/xmpp"
+)
+
+const fastTokenSize = 32
+
+// fastFeature returns the SASL2 inline FAST (XEP-0484) feature.
+func (s *inStream) fastFeature() xmpp.XElement {
+	if len(s.fastAuths) == 0 {
+		return nil
+	}
+	fast := xmpp.NewElementNamespace("fast", fastNamespace)
+	for _, ath := range s.fastAuths {
+		fast.AppendElement(xmpp.NewElementName("mechanism").SetText(ath.Mechanism()))
+	}
+	return fast
+}
+
+// processFastRequest handles token related requests contained within a SASL2 authenticate element,
+// returning a newly issued token element in case it has been requested.
+func (s *inStream) processFastRequest(ctx context.Context, req xmpp.XElement, usedToken *model.FastToken) xmpp.XElement {
+	if s.fastRep == nil {
+		return nil
+	}
+	username := s.Username()
+
+	if usedToken != nil {
+		fast := req.Elements().ChildNamespace("fast", fastNamespace)
+		if fast != nil && isTrue(fast.Attributes().Get("invalidate")) {
+			if err := s.fastRep.DeleteFastToken(ctx, username, usedToken.Token); err != nil {
+				log.Error(err)
+			}
+			usedToken = nil
+		} else {
+			// once a newly issued token has been used, previous ones are no longer valid
+			if err := s.rotateFastTokens(ctx, usedToken.ClientID, usedToken.Token); err != nil {
+				log.Error(err)
+			}
+		}
+	}
+	tokenReq := req.Elements().ChildNamespace("request-token", fastNamespace)
+	if tokenReq == nil || len(s.fastAuths) == 0 {
+		return nil
+	}
+	if s.anonRep != nil && s.anonRep.IsAnonymous(username) {
+		return nil // temporary accounts cannot be resumed
+	}
+	var clientID string
+	if userAgent := req.Elements().Child("user-agent"); userAgent != nil {
+		clientID = userAgent.Attributes().Get("id")
+	}
+	if len(clientID) == 0 {
+		return nil // tokens are bound to a client identifier
+	}
+	mechanism := tokenReq.Attributes().Get("mechanism")
+	if !s.isFastMechanism(mechanism) {
+		return nil
+	}
+	token, err := generateFastToken()
+	if err != nil {
+		log.Error(err)
+		return nil
+	}
+	ft := &model.FastToken{
+		Username:  username,
+		ClientID:  clientID,
+		Mechanism: mechanism,
+		Token:     token,
+		ExpiresAt: time.Now().Add(s.cfg.fast.Expiry),
+	}
+	// keep the token in use valid until the new one is used
+	keep := []string{token}
+	if usedToken != nil && usedToken.ClientID == clientID {
+		keep = append(keep, usedToken.Token)
+	}
+	if err := s.fastRep.InsertFastToken(ctx, ft); err != nil {
+		log.Error(err)
+		return nil
+	}
+	if err := s.rotateFastTokens(ctx, clientID, keep...); err != nil {
+		log.Error(err)
+	}
+	tokenElem := xmpp.NewElementNamespace("token", fastNamespace)
+	tokenElem.SetAttribute("expiry", ft.ExpiresAt.UTC().Format(time.RFC3339))
+	tokenElem.SetAttribute("token", token)
+	return tokenElem
+}
+
+// rotateFastTokens deletes all tokens issued to a client, except for the given ones.
+func (s *inStream) rotateFastTokens(ctx context.Context, clientID string, keep ...string) error {
+	username := s.Username()
+
+	tokens, err := s.fastRep.FetchFastTokens(ctx, username)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if t.ClientID != clientID && !t.IsExpired() {
+			continue
+		}
+		if containsString(keep, t.Token) {
+			continue
+		}
+		if err := s.fastRep.DeleteFastToken(ctx, username, t.Token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *inStream) isFastMechanism(mechanism string) bool {
+	for _, ath := range s.fastAuths {
+		if ath.Mechanism() == mechanism {
+			return true
+		}
+	}
+	return false
+}
+
+func generateFastToken() (string, error) {
+	b := make([]byte, fastTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func isTrue(s string) bool {
+	return s == "true" || s == "1"
+}
+
+func containsString(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/auth/backend"
+	"github.com/ortuman/jackal/component"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/router"
+	memorystorage "github.com/ortuman/jackal/storage/memory"
+	"github.com/ortuman/jackal/storage/repository"
+	"github.com/ortuman/jackal/transport"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream_FastTokens(t *testing.T) {
+	r, userRep, blockListRep := setupTest("localhost")
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Password: "pencil"})
+
+	fastRep := memorystorage.NewFastToken()
+
+	// request a token while authenticating through a regular mechanism
+	_, conn := tUtilFastStreamInit(r, userRep, blockListRep, fastRep)
+	features := conn.outboundRead()
+
+	inline := features.Elements().ChildNamespace("authentication", sasl2Namespace).Elements().Child("inline")
+	fast := inline.Elements().ChildNamespace("fast", fastNamespace)
+	require.NotNil(t, fast)
+	require.Len(t, fast.Elements().All(), 1) // no channel binding available
+	require.Equal(t, "HT-SHA-256-NONE", fast.Elements().Child("mechanism").Text())
+
+	_, _ = conn.inboundWrite([]byte(`<authenticate xmlns="urn:xmpp:sasl:2" mechanism="PLAIN">
+<initial-response>AHVzZXIAcGVuY2ls</initial-response>
+<user-agent id="d4565fa7-4d72-4749-b3d3-740edbf87770"/>
+<request-token xmlns="urn:xmpp:fast:0" mechanism="HT-SHA-256-NONE"/>
+</authenticate>`))
+	elem := conn.outboundRead()
+	require.Equal(t, "success", elem.Name())
+
+	tokenElem := elem.Elements().ChildNamespace("token", fastNamespace)
+	require.NotNil(t, tokenElem)
+	token1 := tokenElem.Attributes().Get("token")
+	require.NotEmpty(t, token1)
+	require.NotEmpty(t, tokenElem.Attributes().Get("expiry"))
+
+	// authenticate using the issued token and request a new one
+	_, conn = tUtilFastStreamInit(r, userRep, blockListRep, fastRep)
+	_ = conn.outboundRead() // read stream features...
+
+	_, _ = conn.inboundWrite([]byte(fmt.Sprintf(`<authenticate xmlns="urn:xmpp:sasl:2" mechanism="HT-SHA-256-NONE">
+<initial-response>%s</initial-response>
+<user-agent id="d4565fa7-4d72-4749-b3d3-740edbf87770"/>
+<request-token xmlns="urn:xmpp:fast:0" mechanism="HT-SHA-256-NONE"/>
+</authenticate>`, tUtilFastInitialResponse("user", token1))))
+	elem = conn.outboundRead()
+	require.Equal(t, "success", elem.Name())
+	require.Equal(t, "user@localhost", elem.Elements().Child("authorization-identifier").Text())
+
+	expectedData := base64.StdEncoding.EncodeToString(tUtilFastHash(token1, "Responder"))
+	require.Equal(t, expectedData, elem.Elements().Child("additional-data").Text())
+
+	token2 := elem.Elements().ChildNamespace("token", fastNamespace).Attributes().Get("token")
+	require.NotEqual(t, token1, token2)
+
+	tokens, _ := fastRep.FetchFastTokens(context.Background(), "user")
+	require.Len(t, tokens, 2) // current token remains valid until the new one is used
+
+	// using the new token invalidates the previous one
+	_, conn = tUtilFastStreamInit(r, userRep, blockListRep, fastRep)
+	_ = conn.outboundRead() // read stream features...
+
+	_, _ = conn.inboundWrite([]byte(fmt.Sprintf(`<authenticate xmlns="urn:xmpp:sasl:2" mechanism="HT-SHA-256-NONE"><initial-response>%s</initial-response></authenticate>`,
+		tUtilFastInitialResponse("user", token2))))
+	elem = conn.outboundRead()
+	require.Equal(t, "success", elem.Name())
+	require.Nil(t, elem.Elements().ChildNamespace("token", fastNamespace))
+
+	tokens, _ = fastRep.FetchFastTokens(context.Background(), "user")
+	require.Len(t, tokens, 1)
+	require.Equal(t, token2, tokens[0].Token)
+
+	_, conn = tUtilFastStreamInit(r, userRep, blockListRep, fastRep)
+	_ = conn.outboundRead() // read stream features...
+
+	_, _ = conn.inboundWrite([]byte(fmt.Sprintf(`<authenticate xmlns="urn:xmpp:sasl:2" mechanism="HT-SHA-256-NONE"><initial-response>%s</initial-response></authenticate>`,
+		tUtilFastInitialResponse("user", token1))))
+	elem = conn.outboundRead()
+	require.Equal(t, "failure", elem.Name())
+	require.NotNil(t, elem.Elements().ChildNamespace("not-authorized", saslNamespace))
+
+	// explicit token invalidation
+	_, _ = conn.inboundWrite([]byte(fmt.Sprintf(`<authenticate xmlns="urn:xmpp:sasl:2" mechanism="HT-SHA-256-NONE">
+<initial-response>%s</initial-response>
+<fast xmlns="urn:xmpp:fast:0" invalidate="true"/>
+</authenticate>`, tUtilFastInitialResponse("user", token2))))
+	elem = conn.outboundRead()
+	require.Equal(t, "success", elem.Name())
+
+	tokens, _ = fastRep.FetchFastTokens(context.Background(), "user")
+	require.Len(t, tokens, 0)
+}
+
+func tUtilFastStreamInit(r router.Router, userRep repository.User, blockListRep repository.BlockList, fastRep repository.FastToken) (*inStream, *fakeSocketConn) {
+	cfg := tUtilInStreamDefaultConfig()
+	cfg.fast = FastConfig{Enabled: true, Expiry: time.Hour}
+
+	conn := newFakeSocketConn()
+	tr := transport.NewSocketTransport(conn)
+	stm := newStream(
+		"abcd1234",
+		cfg,
+		tr,
+		tUtilInitModules(r),
+		&component.Components{},
+		r,
+		backend.NewStorage(userRep),
+		blockListRep,
+		nil,
+		fastRep).(*inStream)
+	stm.setSecured(true) // SASL mechanisms are offered over secured streams
+
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	return stm, conn
+}
+
+func tUtilFastInitialResponse(username, token string) string {
+	b := append([]byte(username), 0)
+	b = append(b, tUtilFastHash(token, "Initiator")...)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func tUtilFastHash(token, prefix string) []byte {
+	h := hmac.New(sha256.New, []byte(token))
+	h.Write([]byte(prefix))
+	return h.Sum(nil)
+}
@@ -8,22 +8,28 @@ package c2s
 import (
 	"context"
 	"crypto/tls"
+	"errors"
+	"net"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/ortuman/jackal/auth"
+	"github.com/ortuman/jackal/auth/backend"
 	"github.com/ortuman/jackal/component"
 	streamerror "github.com/ortuman/jackal/errors"
 	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/session"
+	"github.com/ortuman/jackal/storage/anonymous"
 	"github.com/ortuman/jackal/storage/repository"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/transport"
 	"github.com/ortuman/jackal/transport/compress"
+	"github.com/ortuman/jackal/util/ratelimit"
 	"github.com/ortuman/jackal/util/runqueue"
 	"github.com/ortuman/jackal/xmpp"
 	"github.com/ortuman/jackal/xmpp/jid"
@@ -35,14 +41,19 @@ const (
 	authenticating
 	authenticated
 	bound
+	detached
 	disconnected
 )
 
+var errResourceConflict = errors.New("c2s: resource conflict")
+
 type inStream struct {
 	cfg            *streamConfig
 	router         router.Router
-	userRep        repository.User
+	authBackend    backend.Backend
 	blockListRep   repository.BlockList
+	anonRep        *anonymous.Storage
+	fastRep        repository.FastToken
 	mods           *module.Modules
 	comps          *component.Components
 	sess           *session.Session
@@ -53,26 +64,37 @@ type inStream struct {
 	readTimeoutTm  *time.Timer
 	state          uint32
 	authenticators []auth.Authenticator
+	fastAuths      []*auth.Fast
 	activeAuth     auth.Authenticator
+	sasl2          *sasl2State
+	authPending    []xmpp.XElement
 	runQueue       *runqueue.RunQueue
+	limiter        *ratelimit.Limiter
 	jid            *jid.JID
+	remoteAddr     net.Addr
 	secured        bool
 	compressed     bool
 	authenticated  bool
 	sessStarted    bool
 	presence       *xmpp.Presence
+	compPresences  map[string]*jid.JID
+	inactive       bool
+	sm             *smState
+	resumeTm       *time.Timer
 	ctx            context.Context
 	ctxCancelFn    context.CancelFunc
 }
 
-func newStream(id string, config *streamConfig, tr transport.Transport, mods *module.Modules, comps *component.Components, router router.Router, userRep repository.User, blockListRep repository.BlockList) stream.C2S {
+func newStream(id string, config *streamConfig, tr transport.Transport, mods *module.Modules, comps *component.Components, router router.Router, authBackend backend.Backend, blockListRep repository.BlockList, anonRep *anonymous.Storage, fastRep repository.FastToken) stream.C2S {
 	ctx, ctxCancelFn := context.WithCancel(context.Background())
 	s := &inStream{
 		cfg:          config,
 		tr:           tr,
 		router:       router,
-		userRep:      userRep,
+		authBackend:  authBackend,
 		blockListRep: blockListRep,
+		anonRep:      anonRep,
+		fastRep:      fastRep,
 		mods:         mods,
 		comps:        comps,
 		id:           id,
@@ -82,13 +104,19 @@ func newStream(id string, config *streamConfig, tr transport.Transport, mods *mo
 	}
 
 	// initialize stream context
-	secured := !(tr.Type() == transport.Socket)
+	secured := !(tr.Type() == transport.Socket) || config.directTLS
 	s.setSecured(secured)
 	s.setJID(&jid.JID{})
+	s.setRemoteAddr(tr.RemoteAddr())
 
 	// initialize authenticators
 	s.initializeAuthenticators()
 
+	// initialize rate limiter
+	if config.rateLimit.Enabled() {
+		s.limiter = ratelimit.New(&config.rateLimit)
+	}
+
 	// start c2s session
 	s.restartSession()
 
@@ -159,6 +187,14 @@ func (s *inStream) IsSecured() bool {
 	return s.secured
 }
 
+// RemoteAddr returns the remote peer network address.
+// In case of connections received through a trusted proxy the original client address is returned.
+func (s *inStream) RemoteAddr() net.Addr {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.remoteAddr
+}
+
 // Presence returns last sent presence element.
 func (s *inStream) Presence() *xmpp.Presence {
 	s.mu.RLock()
@@ -189,27 +225,87 @@ func (s *inStream) Disconnect(ctx context.Context, err error) {
 
 func (s *inStream) initializeAuthenticators() {
 	tr := s.tr
-	hasChannelBinding := len(tr.ChannelBindingBytes(transport.TLSUnique)) > 0
+	hasChannelBinding := len(s.channelBindingMechanisms()) > 0
+	credProvider, hasCredentials := s.authBackend.(backend.CredentialsProvider)
+
+	stm := &authStream{inStream: s}
+
 	var authenticators []auth.Authenticator
 	for _, a := range s.cfg.sasl {
+		if strings.HasPrefix(a, "scram_") && !hasCredentials {
+			continue // SCRAM mechanisms require stored credentials
+		}
 		switch a {
 		case "plain":
-			authenticators = append(authenticators, auth.NewPlain(s, s.userRep))
+			authenticators = append(authenticators, auth.NewPlain(stm, s.authBackend))
+
+		case "anonymous":
+			if s.anonRep != nil && s.isAnonymousHost(s.Domain()) {
+				authenticators = append(authenticators, auth.NewAnonymous(stm, s.anonRep))
+			}
+
+		case "external":
+			// offered only when a verified client certificate has been presented
+			if len(tr.PeerCertificates()) > 0 {
+				authenticators = append(authenticators, auth.NewExternal(stm, tr, s.authBackend))
+			}
 
 		case "scram_sha_1":
-			authenticators = append(authenticators, auth.NewScram(s, tr, auth.ScramSHA1, false, s.userRep))
+			authenticators = append(authenticators, auth.NewScram(stm, tr, auth.ScramSHA1, false, credProvider))
 			if hasChannelBinding {
-				authenticators = append(authenticators, auth.NewScram(s, tr, auth.ScramSHA1, true, s.userRep))
+				authenticators = append(authenticators, auth.NewScram(stm, tr, auth.ScramSHA1, true, credProvider))
 			}
 
 		case "scram_sha_256":
-			authenticators = append(authenticators, auth.NewScram(s, tr, auth.ScramSHA256, false, s.userRep))
+			authenticators = append(authenticators, auth.NewScram(stm, tr, auth.ScramSHA256, false, credProvider))
+			if hasChannelBinding {
+				authenticators = append(authenticators, auth.NewScram(stm, tr, auth.ScramSHA256, true, credProvider))
+			}
+
+		case "scram_sha_512":
+			authenticators = append(authenticators, auth.NewScram(stm, tr, auth.ScramSHA512, false, credProvider))
 			if hasChannelBinding {
-				authenticators = append(authenticators, auth.NewScram(s, tr, auth.ScramSHA256, true, s.userRep))
+				authenticators = append(authenticators, auth.NewScram(stm, tr, auth.ScramSHA512, true, credProvider))
 			}
 		}
 	}
 	s.authenticators = authenticators
+
+	// [xep-0484] token based mechanisms are only offered within SASL2 negotiation
+	var fastAuths []*auth.Fast
+	if s.cfg.fast.Enabled && s.fastRep != nil {
+		for _, tp := range auth.FastTypes {
+			if cbMechanism, ok := tp.ChannelBindingMechanism(); ok && len(tr.ChannelBindingBytes(cbMechanism)) == 0 {
+				continue
+			}
+			fastAuths = append(fastAuths, auth.NewFast(stm, tr, tp, s.fastRep))
+		}
+	}
+	s.fastAuths = fastAuths
+}
+
+// isAnonymousHost tells whether or not anonymous logins are allowed on a given domain.
+func (s *inStream) isAnonymousHost(domain string) bool {
+	if len(s.cfg.anonymousHosts) == 0 {
+		return true
+	}
+	for _, h := range s.cfg.anonymousHosts {
+		if h == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// channelBindingMechanisms returns the channel binding types available on the stream transport.
+func (s *inStream) channelBindingMechanisms() []transport.ChannelBindingMechanism {
+	var ret []transport.ChannelBindingMechanism
+	for _, cb := range transport.ChannelBindingMechanisms {
+		if len(s.tr.ChannelBindingBytes(cb)) > 0 {
+			ret = append(ret, cb)
+		}
+	}
+	return ret
 }
 
 func (s *inStream) connectTimeout() {
@@ -278,6 +374,10 @@ func (s *inStream) unauthenticatedFeatures() []xmpp.XElement {
 	// attach SASL mechanisms
 	shouldOfferSASL := !isSocketTr || (isSocketTr && s.IsSecured())
 
+	if shouldOfferSASL && s.activeAuth == nil {
+		// channel binding becomes available once TLS has been negotiated
+		s.initializeAuthenticators()
+	}
 	if shouldOfferSASL && len(s.authenticators) > 0 {
 		mechanisms := xmpp.NewElementName("mechanisms")
 		mechanisms.SetNamespace(saslNamespace)
@@ -287,6 +387,17 @@ func (s *inStream) unauthenticatedFeatures() []xmpp.XElement {
 			mechanisms.AppendElement(mechanism)
 		}
 		features = append(features, mechanisms)
+
+		// [xep-0440] advertise supported channel binding types
+		if cbMechanisms := s.channelBindingMechanisms(); len(cbMechanisms) > 0 {
+			cbTypes := xmpp.NewElementNamespace("sasl-channel-binding", saslChannelBindingNamespace)
+			for _, cb := range cbMechanisms {
+				cbTypes.AppendElement(xmpp.NewElementName("channel-binding").SetAttribute("type", cb.String()))
+			}
+			features = append(features, cbTypes)
+		}
+		// [xep-0388] extensible SASL profile
+		features = append(features, s.sasl2Feature())
 	}
 
 	// allow In-band registration over encrypted stream only
@@ -326,6 +437,10 @@ func (s *inStream) authenticatedFeatures() []xmpp.XElement {
 		ver := xmpp.NewElementNamespace("ver", "urn:xmpp:features:rosterver")
 		features = append(features, ver)
 	}
+	if s.cfg.sm.Enabled {
+		features = append(features, xmpp.NewElementNamespace("sm", smNamespace))
+	}
+	features = append(features, xmpp.NewElementNamespace("csi", csiNamespace))
 	return features
 }
 
@@ -337,6 +452,9 @@ func (s *inStream) handleConnected(ctx context.Context, elem xmpp.XElement) {
 	case "auth":
 		s.startAuthentication(ctx, elem)
 
+	case "authenticate":
+		s.startSASL2Authentication(ctx, elem)
+
 	case "iq":
 		iq := elem.(*xmpp.IQ)
 		if reg := s.mods.Register; reg != nil && reg.MatchesIQ(iq) {
@@ -364,6 +482,10 @@ func (s *inStream) handleConnected(ctx context.Context, elem xmpp.XElement) {
 }
 
 func (s *inStream) handleAuthenticating(ctx context.Context, elem xmpp.XElement) {
+	if s.sasl2 != nil {
+		s.handleSASL2Authenticating(ctx, elem)
+		return
+	}
 	if elem.Namespace() != saslNamespace {
 		s.disconnectWithStreamError(ctx, streamerror.ErrInvalidNamespace)
 		return
@@ -390,6 +512,21 @@ func (s *inStream) handleAuthenticated(ctx context.Context, elem xmpp.XElement)
 			s.bindResource(ctx, iq)
 		}
 
+	case "resume":
+		if !s.cfg.sm.Enabled || elem.Namespace() != smNamespace {
+			s.disconnectWithStreamError(ctx, streamerror.ErrUnsupportedStanzaType)
+			return
+		}
+		s.resumeSM(ctx, elem)
+
+	case "enable":
+		if !s.cfg.sm.Enabled || elem.Namespace() != smNamespace {
+			s.disconnectWithStreamError(ctx, streamerror.ErrUnsupportedStanzaType)
+			return
+		}
+		// [xep-0198] stream management must be enabled after binding a resource
+		s.writeElement(ctx, smFailedElement("unexpected-request"))
+
 	default:
 		s.disconnectWithStreamError(ctx, streamerror.ErrUnsupportedStanzaType)
 	}
@@ -400,11 +537,22 @@ func (s *inStream) handleBound(ctx context.Context, elem xmpp.XElement) {
 	if p := s.mods.Ping; p != nil {
 		p.SchedulePing(s)
 	}
+	if s.cfg.sm.Enabled && elem.Namespace() == smNamespace {
+		s.handleStreamManagement(ctx, elem)
+		return
+	}
+	if elem.Namespace() == csiNamespace {
+		s.handleClientState(ctx, elem)
+		return
+	}
 	stanza, ok := elem.(xmpp.Stanza)
 	if !ok {
 		s.disconnectWithStreamError(ctx, streamerror.ErrUnsupportedStanzaType)
 		return
 	}
+	if s.sm != nil {
+		s.sm.inbound++
+	}
 	// handle session IQ
 	if iq, ok := stanza.(*xmpp.IQ); ok && iq.IsSet() {
 		if iq.Elements().ChildNamespace("session", sessionNamespace) != nil {
@@ -425,6 +573,8 @@ func (s *inStream) handleBound(ctx context.Context, elem xmpp.XElement) {
 				return
 			}
 			break
+		case *xmpp.Presence:
+			s.trackComponentPresence(stanza)
 		}
 		comp.ProcessStanza(ctx, stanza, s)
 		return
@@ -432,6 +582,46 @@ func (s *inStream) handleBound(ctx context.Context, elem xmpp.XElement) {
 	s.processStanza(ctx, stanza)
 }
 
+// trackComponentPresence keeps track of directed presences sent to component entities
+// (e.g. MUC rooms) in order to make them unavailable on disconnection.
+func (s *inStream) trackComponentPresence(presence *xmpp.Presence) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	toJID := presence.ToJID()
+	switch {
+	case presence.IsAvailable():
+		if s.compPresences == nil {
+			s.compPresences = make(map[string]*jid.JID)
+		}
+		s.compPresences[toJID.ToBareJID().String()] = toJID
+	case presence.IsUnavailable():
+		delete(s.compPresences, toJID.ToBareJID().String())
+	}
+}
+
+func (s *inStream) componentPresences() []*jid.JID {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ret []*jid.JID
+	for _, j := range s.compPresences {
+		ret = append(ret, j)
+	}
+	return ret
+}
+
+func (s *inStream) handleClientState(ctx context.Context, elem xmpp.XElement) {
+	switch elem.Name() {
+	case "inactive":
+		s.inactive = true
+	case "active":
+		s.inactive = false
+	default:
+		s.disconnectWithStreamError(ctx, streamerror.ErrUnsupportedStanzaType)
+	}
+}
+
 func (s *inStream) proceedStartTLS(ctx context.Context, elem xmpp.XElement) {
 	if s.IsSecured() {
 		s.disconnectWithStreamError(ctx, streamerror.ErrNotAuthorized)
@@ -444,7 +634,12 @@ func (s *inStream) proceedStartTLS(ctx context.Context, elem xmpp.XElement) {
 	s.setSecured(true)
 	s.writeElement(ctx, xmpp.NewElementNamespace("proceed", tlsNamespace))
 
-	s.tr.StartTLS(&tls.Config{Certificates: s.router.Hosts().Certificates()}, false)
+	tlsCfg := s.router.Hosts().TLSConfig(s.Domain())
+	if s.cfg.clientCAs != nil {
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+		tlsCfg.ClientCAs = s.cfg.clientCAs
+	}
+	s.tr.StartTLS(tlsCfg, false)
 
 	log.Infof("secured stream... id: %s", s.id)
 	s.restartSession()
@@ -505,8 +700,21 @@ func (s *inStream) startAuthentication(ctx context.Context, elem xmpp.XElement)
 }
 
 func (s *inStream) continueAuthentication(ctx context.Context, elem xmpp.XElement, authr auth.Authenticator) error {
-	err := authr.ProcessElement(ctx, elem)
-	if saslErr, ok := err.(*auth.SASLError); ok {
+	if s.isAuthLockedOut(authr, elem) {
+		s.failAuthentication(ctx, auth.ErrSASLTemporaryAuthFailure.(*auth.SASLError).Element())
+		return auth.ErrSASLTemporaryAuthFailure
+	}
+	err := s.trackAuthentication(authr, authr.ProcessElement(ctx, elem))
+	reportAuthentication(authr.Mechanism(), err, authr.Authenticated())
+
+	pending := s.authPending
+	s.authPending = nil
+
+	if err == nil {
+		for _, e := range pending {
+			s.writeElement(ctx, e)
+		}
+	} else if saslErr, ok := err.(*auth.SASLError); ok {
 		s.failAuthentication(ctx, saslErr.Element())
 	} else if err != nil {
 		log.Error(err)
@@ -527,6 +735,69 @@ func (s *inStream) finishAuthentication(_ context.Context, username string) {
 	s.restartSession()
 }
 
+// isAuthLockedOut tells whether or not remote peer address, or the user an authentication element refers to,
+// has been locked out due to repeated authentication failures.
+// Checked before processing the element, so that no credentials are ever verified against a locked out user.
+func (s *inStream) isAuthLockedOut(authr auth.Authenticator, elem xmpp.XElement) bool {
+	if s.cfg.lockout == nil {
+		return false
+	}
+	ip := s.remoteIP()
+
+	var username string
+	if p, ok := authr.(auth.UsernameParser); ok {
+		username = p.ParseUsername(elem)
+	}
+	if !s.cfg.lockout.IsLocked(ip, username) {
+		return false
+	}
+	log.Warnf("authentication attempt rejected due to lockout... (id: %s, ip: %s, username: %s)", s.id, ip, username)
+	authr.Reset()
+	return true
+}
+
+// trackAuthentication records the outcome of an authentication step, turning it into
+// a temporary failure whenever remote peer address or attempted user are locked out.
+func (s *inStream) trackAuthentication(authr auth.Authenticator, err error) error {
+	tracker := s.cfg.lockout
+	if tracker == nil {
+		return err
+	}
+	ip := s.remoteIP()
+
+	var username string
+	if p, ok := authr.(auth.AttemptedUsernameProvider); ok {
+		username = p.AttemptedUsername()
+	}
+	if tracker.IsLocked(ip, username) {
+		log.Warnf("authentication attempt rejected due to lockout... (id: %s, ip: %s, username: %s)", s.id, ip, username)
+		authr.Reset()
+		return auth.ErrSASLTemporaryAuthFailure
+	}
+	switch {
+	case err == auth.ErrSASLNotAuthorized:
+		tracker.RegisterFailure(ip, username)
+	case err == nil && authr.Authenticated():
+		tracker.RegisterSuccess(ip, username)
+	}
+	return err
+}
+
+func (s *inStream) remoteIP() string {
+	addr := s.RemoteAddr()
+	if addr == nil {
+		return ""
+	}
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP.String()
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
 func (s *inStream) failAuthentication(ctx context.Context, elem xmpp.XElement) {
 	failure := xmpp.NewElementNamespace("failure", saslNamespace)
 	failure.AppendElement(elem)
@@ -552,6 +823,36 @@ func (s *inStream) bindResource(ctx context.Context, iq *xmpp.IQ) {
 		resource = uuid.New().String()
 	}
 	// try binding...
+	switch err := s.bind(ctx, resource); err {
+	case nil:
+		break
+	case errResourceConflict:
+		s.writeElement(ctx, iq.ConflictError())
+		return
+	default:
+		s.writeElement(ctx, iq.BadRequestError())
+		return
+	}
+	//...notify successful binding
+	result := xmpp.NewIQType(iq.ID(), xmpp.ResultType)
+	result.SetNamespace(iq.Namespace())
+
+	boundElem := xmpp.NewElementNamespace("bind", bindNamespace)
+	j := xmpp.NewElementName("jid")
+	j.SetText(s.Username() + "@" + s.Domain() + "/" + s.Resource())
+	boundElem.AppendElement(j)
+	result.AppendElement(boundElem)
+
+	s.writeElement(ctx, result)
+
+	// start pinging...
+	if p := s.mods.Ping; p != nil {
+		p.SchedulePing(s)
+	}
+}
+
+// bind binds stream to a resource applying configured resource conflict policy.
+func (s *inStream) bind(ctx context.Context, resource string) error {
 	var stm stream.C2S
 	streams := s.router.LocalStreams(s.JID().Node())
 	for _, s := range streams {
@@ -569,14 +870,12 @@ func (s *inStream) bindResource(ctx context.Context, iq *xmpp.IQ) {
 			stm.Disconnect(ctx, streamerror.ErrResourceConstraint)
 		default:
 			// disallow resource binding attempt...
-			s.writeElement(ctx, iq.ConflictError())
-			return
+			return errResourceConflict
 		}
 	}
 	userJID, err := jid.New(s.Username(), s.Domain(), resource, false)
 	if err != nil {
-		s.writeElement(ctx, iq.BadRequestError())
-		return
+		return err
 	}
 	s.setJID(userJID)
 	s.sess.SetJID(userJID)
@@ -587,23 +886,8 @@ func (s *inStream) bindResource(ctx context.Context, iq *xmpp.IQ) {
 
 	s.router.Bind(ctx, s)
 
-	//...notify successful binding
-	result := xmpp.NewIQType(iq.ID(), xmpp.ResultType)
-	result.SetNamespace(iq.Namespace())
-
-	boundElem := xmpp.NewElementNamespace("bind", bindNamespace)
-	j := xmpp.NewElementName("jid")
-	j.SetText(s.Username() + "@" + s.Domain() + "/" + s.Resource())
-	boundElem.AppendElement(j)
-	result.AppendElement(boundElem)
-
 	s.setState(bound)
-	s.writeElement(ctx, result)
-
-	// start pinging...
-	if p := s.mods.Ping; p != nil {
-		p.SchedulePing(s)
-	}
+	return nil
 }
 
 func (s *inStream) processStanza(ctx context.Context, elem xmpp.Stanza) {
@@ -670,13 +954,21 @@ func (s *inStream) processPresence(ctx context.Context, presence *xmpp.Presence)
 }
 
 func (s *inStream) processMessage(ctx context.Context, message *xmpp.Message) {
+	if c := s.mods.Carbons; c != nil {
+		c.ProcessSentMessage(ctx, message)
+	}
+	if mam := s.mods.Mam; mam != nil {
+		message = mam.ArchiveMessage(ctx, message)
+	}
 	msg := message
 
 sendMessage:
 	err := s.router.Route(ctx, msg)
 	switch err {
 	case nil:
-		break
+		if c := s.mods.Carbons; c != nil {
+			c.ProcessReceivedMessage(ctx, msg)
+		}
 	case router.ErrResourceNotFound:
 		// treat the stanza as if it were addressed to <node@domain>
 		msg, _ = xmpp.NewMessageFromElement(msg, msg.FromJID(), msg.ToJID().ToBareJID())
@@ -698,16 +990,38 @@ sendMessage:
 
 // Runs on it's own goroutine
 func (s *inStream) doRead() {
+	sess := s.getSession()
+
 	s.scheduleReadTimeout()
-	elem, sErr := s.sess.Receive()
+	elem, sErr := sess.Receive()
 	s.cancelReadTimeout()
 
+	action := ratelimit.Allow
+	if sErr == nil {
+		action = s.limiter.Apply(elem)
+	}
+	if action == ratelimit.Bounce || action == ratelimit.Disconnect {
+		log.Warnf("rate limit exceeded... applying %s action (id: %s, ip: %s)", action, s.id, s.remoteIP())
+	}
 	ctx, _ := context.WithTimeout(context.Background(), s.cfg.timeout)
 	if sErr == nil {
-		s.runQueue.Run(func() { s.readElement(ctx, elem) })
+		s.runQueue.Run(func() {
+			if sess != s.sess {
+				return // stale session... stream has been resumed
+			}
+			switch action {
+			case ratelimit.Bounce:
+				s.bounceElement(ctx, elem)
+				s.readElement(ctx, nil)
+			case ratelimit.Disconnect:
+				s.disconnectWithStreamError(ctx, streamerror.ErrPolicyViolation)
+			case ratelimit.Allow, ratelimit.Throttle:
+				s.readElement(ctx, elem)
+			}
+		})
 	} else {
 		s.runQueue.Run(func() {
-			if s.getState() == disconnected {
+			if st := s.getState(); st == disconnected || st == detached || sess != s.sess {
 				return
 			}
 			s.handleSessionError(ctx, sErr)
@@ -715,6 +1029,16 @@ func (s *inStream) doRead() {
 	}
 }
 
+func (s *inStream) bounceElement(ctx context.Context, elem xmpp.XElement) {
+	if s.sm != nil {
+		s.sm.inbound++
+	}
+	if elem.Type() == xmpp.ErrorType {
+		return // never reply to error stanzas
+	}
+	s.writeStanzaErrorResponse(ctx, elem, xmpp.ErrPolicyViolation)
+}
+
 func (s *inStream) handleSessionError(ctx context.Context, sErr *session.Error) {
 	switch err := sErr.UnderlyingErr.(type) {
 	case nil:
@@ -724,6 +1048,10 @@ func (s *inStream) handleSessionError(ctx context.Context, sErr *session.Error)
 	case *xmpp.StanzaError:
 		s.writeStanzaErrorResponse(ctx, sErr.Element, err)
 	default:
+		if s.isResumable() {
+			s.detach()
+			return
+		}
 		log.Error(err)
 		s.disconnectWithStreamError(ctx, streamerror.ErrUndefinedCondition)
 	}
@@ -739,16 +1067,35 @@ func (s *inStream) writeStanzaErrorResponse(ctx context.Context, elem xmpp.XElem
 }
 
 func (s *inStream) writeElement(ctx context.Context, elem xmpp.XElement) {
+	stanza, isStanza := elem.(xmpp.Stanza)
+	if isStanza && s.sm != nil {
+		if !s.trackOutgoingStanza(ctx, stanza) {
+			return
+		}
+	}
+	isDetached := s.getState() == detached
+	if msg, ok := elem.(*xmpp.Message); ok && (isDetached || s.inactive) {
+		// client might not be processing stanzas right now
+		if p := s.mods.Push; p != nil {
+			p.NotifyMessage(ctx, s.Username(), msg)
+		}
+	}
+	if isDetached {
+		return // stanza will be delivered on resumption
+	}
 	if err := s.sess.Send(ctx, elem); err != nil {
 		log.Error(err)
 	}
+	if isStanza && s.sm != nil {
+		s.requestAck(ctx)
+	}
 }
 
 func (s *inStream) readElement(ctx context.Context, elem xmpp.XElement) {
 	if elem != nil {
 		s.handleElement(ctx, elem)
 	}
-	if s.getState() != disconnected {
+	if st := s.getState(); st != disconnected && st != detached {
 		go s.doRead() // keep reading...
 	}
 }
@@ -791,15 +1138,35 @@ func (s *inStream) disconnectClosingSession(ctx context.Context, closeSession, u
 			r.ProcessPresence(ctx, xmpp.NewPresence(s.JID(), s.JID().ToBareJID(), xmpp.UnavailableType))
 		}
 	}
+	for _, toJID := range s.componentPresences() {
+		if comp := s.comps.Get(toJID.Domain()); comp != nil {
+			comp.ProcessStanza(ctx, xmpp.NewPresence(s.JID(), toJID, xmpp.UnavailableType), s)
+		}
+	}
 	if closeSession {
 		_ = s.sess.Close(ctx)
 	}
+	if s.sm != nil {
+		if s.resumeTm != nil {
+			s.resumeTm.Stop()
+		}
+		if len(s.sm.id) > 0 {
+			s.cfg.smRegistry.unregister(s.sm.id, s)
+		}
+		s.archiveUnackedMessages(ctx)
+	}
 	// unregister stream
 	if unbind {
 		s.router.Unbind(ctx, s.JID())
 	}
 	s.ctxCancelFn()
 
+	// discard temporary account data
+	if s.anonRep != nil && s.anonRep.IsAnonymous(s.Username()) {
+		if err := s.anonRep.Release(ctx, s.Username()); err != nil {
+			log.Error(err)
+		}
+	}
 	// notify disconnection
 	if s.cfg.onDisconnect != nil {
 		s.cfg.onDisconnect(s)
@@ -833,10 +1200,10 @@ func (s *inStream) isBlockedJID(ctx context.Context, j *jid.JID) bool {
 }
 
 func (s *inStream) restartSession() {
-	s.sess = session.New(s.id, &session.Config{
+	s.setSession(session.New(s.id, &session.Config{
 		JID:           s.JID(),
 		MaxStanzaSize: s.cfg.maxStanzaSize,
-	}, s.tr, s.router.Hosts())
+	}, s.tr, s.router.Hosts()))
 	s.setState(connecting)
 }
 
@@ -858,6 +1225,12 @@ func (s *inStream) setSecured(secured bool) {
 	s.secured = secured
 }
 
+func (s *inStream) setRemoteAddr(addr net.Addr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remoteAddr = addr
+}
+
 func (s *inStream) setAuthenticated(authenticated bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -902,6 +1275,10 @@ func (s *inStream) cancelReadTimeout() {
 
 func (s *inStream) readTimeout() {
 	s.runQueue.Run(func() {
+		if s.isResumable() {
+			s.detach()
+			return
+		}
 		ctx, _ := context.WithTimeout(context.Background(), s.cfg.timeout)
 		s.disconnect(ctx, streamerror.ErrConnectionTimeout)
 	})
@@ -7,21 +7,27 @@ package c2s
 
 import (
 	"context"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/ortuman/jackal/auth/backend"
+	"github.com/ortuman/jackal/auth/lockout"
 	"github.com/ortuman/jackal/component"
 	"github.com/ortuman/jackal/model"
 	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/storage/anonymous"
 	"github.com/ortuman/jackal/storage/repository"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/transport"
 	"github.com/ortuman/jackal/transport/compress"
+	"github.com/ortuman/jackal/util/ratelimit"
 	"github.com/ortuman/jackal/xmpp"
 	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -73,6 +79,28 @@ func TestStream_Features(t *testing.T) {
 	require.NotNil(t, elem.Elements().ChildNamespace("mechanisms", saslNamespace))
 }
 
+func TestStream_PasswordOnlyAuthBackend(t *testing.T) {
+	r, userRep, blockListRep := setupTest("localhost")
+
+	// backend not providing SCRAM credentials
+	authBackend := struct{ backend.Backend }{backend.NewStorage(userRep)}
+
+	stm := newStream(
+		"abcd1234",
+		tUtilInStreamDefaultConfig(),
+		transport.NewSocketTransport(newFakeSocketConn()),
+		tUtilInitModules(r),
+		&component.Components{},
+		r,
+		authBackend,
+		blockListRep,
+		nil,
+		nil).(*inStream)
+
+	require.Len(t, stm.authenticators, 1)
+	require.Equal(t, "PLAIN", stm.authenticators[0].Mechanism())
+}
+
 func TestStream_TLS(t *testing.T) {
 	r, userRep, blockListRep := setupTest("localhost")
 
@@ -110,10 +138,13 @@ func TestStream_FailAuthenticate(t *testing.T) {
 	elem := conn.outboundRead()
 	require.Equal(t, "failure", elem.Name())
 
+	failures := testutil.ToFloat64(saslAuthentications.WithLabelValues("PLAIN", "failure"))
+
 	_, _ = conn.inboundWrite([]byte(`<auth xmlns="urn:ietf:params:xml:ns:xmpp-sasl" mechanism="PLAIN">AHVzZXIAYQ==</auth>`))
 
 	elem = conn.outboundRead()
 	require.Equal(t, "failure", elem.Name())
+	require.Equal(t, failures+1, testutil.ToFloat64(saslAuthentications.WithLabelValues("PLAIN", "failure")))
 
 	// non-SASL
 	_, _ = conn.inboundWrite([]byte(`<iq type='set' id='auth2'><query xmlns='jabber:iq:auth'>
@@ -128,6 +159,162 @@ func TestStream_FailAuthenticate(t *testing.T) {
 	require.NotNil(t, elem.Elements().Child("error"))
 }
 
+func TestStream_AnonymousAuthenticate(t *testing.T) {
+	r, userRep, blockListRep := setupTest("localhost")
+
+	c, _ := storage.New(&storage.Config{Type: storage.Memory})
+	anonRep := anonymous.New(c)
+
+	cfg := tUtilInStreamDefaultConfig()
+	cfg.sasl = []string{"plain", "anonymous"}
+	cfg.anonymousHosts = []string{"jackal.im"}
+
+	// anonymous logins not allowed on this domain
+	_, conn := tUtilAnonymousStreamInit(cfg, r, userRep, blockListRep, anonRep)
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	_, _ = conn.inboundWrite([]byte(`<auth xmlns="urn:ietf:params:xml:ns:xmpp-sasl" mechanism="ANONYMOUS"/>`))
+	elem := conn.outboundRead()
+	require.Equal(t, "failure", elem.Name())
+	require.NotNil(t, elem.Elements().Child("invalid-mechanism"))
+
+	cfg.anonymousHosts = []string{"localhost"}
+
+	stm, conn := tUtilAnonymousStreamInit(cfg, r, userRep, blockListRep, anonRep)
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	_, _ = conn.inboundWrite([]byte(`<auth xmlns="urn:ietf:params:xml:ns:xmpp-sasl" mechanism="ANONYMOUS"/>`))
+	elem = conn.outboundRead()
+	require.Equal(t, "success", elem.Name())
+
+	time.Sleep(time.Millisecond * 100) // wait until authenticated
+
+	username := stm.Username()
+	require.NotEmpty(t, username)
+	require.True(t, anonRep.IsAnonymous(username))
+
+	// temporary account data is discarded on disconnection
+	stm.Disconnect(context.Background(), nil)
+	require.False(t, anonRep.IsAnonymous(username))
+}
+
+func TestStream_AuthLockout(t *testing.T) {
+	r, userRep, blockListRep := setupTest("localhost")
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Password: "pencil"})
+
+	tracker := lockout.New(&lockout.Config{
+		Enabled:            true,
+		MaxIPFailures:      5,
+		MaxUserFailures:    2,
+		Window:             time.Minute,
+		LockoutDuration:    time.Minute,
+		MaxLockoutDuration: time.Hour,
+	})
+	cfg := tUtilInStreamDefaultConfig()
+	cfg.lockout = tracker
+
+	authBackend := &fakeBackend{Backend: backend.NewStorage(userRep)}
+
+	conn := newFakeSocketConn()
+	stm := newStream("abcd1234", cfg, transport.NewSocketTransport(conn), tUtilInitModules(r), &component.Components{}, r, authBackend, blockListRep, nil, nil)
+	stm.(*inStream).setSecured(true)
+
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	for i := 0; i < 2; i++ {
+		_, _ = conn.inboundWrite([]byte(`<auth xmlns="urn:ietf:params:xml:ns:xmpp-sasl" mechanism="PLAIN">AHVzZXIAYQ==</auth>`))
+		elem := conn.outboundRead()
+		require.Equal(t, "failure", elem.Name())
+		require.NotNil(t, elem.Elements().Child("not-authorized"))
+	}
+	// user locked out... even when providing valid credentials
+	_, _ = conn.inboundWrite([]byte(`<auth xmlns="urn:ietf:params:xml:ns:xmpp-sasl" mechanism="PLAIN">AHVzZXIAcGVuY2ls</auth>`))
+	elem := conn.outboundRead()
+	require.Equal(t, "failure", elem.Name())
+	require.NotNil(t, elem.Elements().Child("temporary-auth-failure"))
+
+	// ...which are not even verified against the backend
+	require.Equal(t, int32(2), atomic.LoadInt32(&authBackend.passwordChecks))
+
+	blocks := tracker.Blocked()
+	require.Len(t, blocks, 1)
+	require.Equal(t, lockout.UserKind, blocks[0].Kind)
+	require.Equal(t, "user", blocks[0].Key)
+
+	// remote address locked out
+	tracker.Clear("", "")
+	for i := 0; i < 5; i++ {
+		tracker.RegisterFailure("str", "")
+	}
+	_, _ = conn.inboundWrite([]byte(`<auth xmlns="urn:ietf:params:xml:ns:xmpp-sasl" mechanism="PLAIN">AHVzZXIAcGVuY2ls</auth>`))
+	elem = conn.outboundRead()
+	require.Equal(t, "failure", elem.Name())
+	require.NotNil(t, elem.Elements().Child("temporary-auth-failure"))
+
+	tracker.Clear(lockout.IPKind, "str")
+	_, _ = conn.inboundWrite([]byte(`<auth xmlns="urn:ietf:params:xml:ns:xmpp-sasl" mechanism="PLAIN">AHVzZXIAcGVuY2ls</auth>`))
+	elem = conn.outboundRead()
+	require.Equal(t, "success", elem.Name())
+}
+
+func TestStream_RateLimit(t *testing.T) {
+	r, userRep, blockListRep := setupTest("localhost")
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Password: "pencil"})
+
+	rosterIQ := []byte(`<iq type="get" id="roster_1"><query xmlns="jabber:iq:roster"/></iq>`)
+
+	// bounce
+	cfg := tUtilInStreamDefaultConfig()
+	cfg.rateLimit = ratelimit.Config{StanzasPerSecond: 0.1, Burst: 2, Action: ratelimit.Bounce}
+
+	stm, conn := tUtilAnonymousStreamInit(cfg, r, userRep, blockListRep, nil)
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+	tUtilStreamAuthenticate(conn, t)
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+	tUtilStreamBind(conn, t)
+
+	_, _ = conn.inboundWrite(rosterIQ)
+	elem := conn.outboundRead()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	_, _ = conn.inboundWrite(rosterIQ)
+	elem = conn.outboundRead()
+	require.Equal(t, xmpp.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().Child("policy-violation"))
+	require.Equal(t, bound, stm.getState())
+
+	// disconnect
+	cfg = tUtilInStreamDefaultConfig()
+	cfg.rateLimit = ratelimit.Config{StanzasPerSecond: 0.1, Burst: 2, Action: ratelimit.Disconnect}
+
+	stm, conn = tUtilAnonymousStreamInit(cfg, r, userRep, blockListRep, nil)
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+	tUtilStreamAuthenticate(conn, t)
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+	tUtilStreamBind(conn, t)
+
+	_, _ = conn.inboundWrite(rosterIQ)
+	_ = conn.outboundRead()
+
+	_, _ = conn.inboundWrite(rosterIQ)
+	require.True(t, conn.waitClose())
+	require.Equal(t, disconnected, stm.getState())
+}
+
 func TestStream_Compression(t *testing.T) {
 	r, userRep, blockListRep := setupTest("localhost")
 
@@ -327,6 +514,105 @@ func TestStream_SendMessage(t *testing.T) {
 	require.Equal(t, msgID, elem.ID())
 }
 
+func TestStream_SendArchivedMessage(t *testing.T) {
+	r, userRep, blockListRep := setupTest("localhost")
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Password: "pencil"})
+
+	repContainer, _ := storage.New(&storage.Config{Type: storage.Memory})
+	_ = repContainer.User().UpsertUser(context.Background(), &model.User{Username: "ortuman", Password: "1234"})
+
+	mods := module.New(&module.Config{Enabled: map[string]struct{}{"mam": {}}}, r, repContainer, backend.NewStorage(repContainer.User()), "alloc-1234")
+	defer func() { _ = mods.Shutdown(context.Background()) }()
+
+	cfg := tUtilInStreamDefaultConfig()
+	cfg.timeout = time.Second
+	_, conn := tUtilSMStreamInit(cfg, mods, r, userRep, blockListRep)
+	_ = tUtilSMStreamAuthenticate(conn, t)
+	tUtilStreamBind(conn, t)
+
+	jFrom, _ := jid.New("user", "localhost", "balcony", true)
+	jTo, _ := jid.New("ortuman", "localhost", "garden", true)
+
+	stm2 := stream.NewMockC2S("abcd7890", jTo)
+	stm2.SetPresence(xmpp.NewPresence(jTo, jTo, xmpp.AvailableType))
+	r.Bind(context.Background(), stm2)
+
+	msg := xmpp.NewMessageType(uuid.New().String(), xmpp.ChatType)
+	msg.SetFromJID(jFrom)
+	msg.SetToJID(jTo)
+	body := xmpp.NewElementName("body")
+	body.SetText("Hi buddy!")
+	msg.AppendElement(body)
+
+	_, _ = conn.inboundWrite([]byte(msg.String()))
+
+	elem := stm2.ReceiveElement()
+	require.Equal(t, "message", elem.Name())
+
+	stanzaID := elem.Elements().ChildNamespace("stanza-id", "urn:xmpp:sid:0")
+	require.NotNil(t, stanzaID)
+	require.Equal(t, "ortuman@localhost", stanzaID.Attributes().Get("by"))
+
+	time.Sleep(time.Millisecond * 100) // wait for insertion...
+
+	msgs, err := repContainer.Archive().FetchArchivedMessages(context.Background(), "ortuman", &model.ArchiveQuery{})
+	require.Nil(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, stanzaID.Attributes().Get("id"), msgs[0].ID)
+	require.Equal(t, "user@localhost", msgs[0].With)
+}
+
+func TestStream_ClientStateIndication(t *testing.T) {
+	r, userRep, blockListRep := setupTest("localhost")
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Password: "pencil"})
+
+	repContainer, _ := storage.New(&storage.Config{Type: storage.Memory})
+	_ = repContainer.Push().UpsertPushRegistration(context.Background(), &model.PushRegistration{
+		Username: "user",
+		JID:      "push@localhost/app",
+		Node:     "yxs32uqsflafdk3iuqo",
+	})
+	mods := module.New(&module.Config{Enabled: map[string]struct{}{"push": {}}}, r, repContainer, backend.NewStorage(repContainer.User()), "alloc-1234")
+	defer func() { _ = mods.Shutdown(context.Background()) }()
+
+	cfg := tUtilInStreamDefaultConfig()
+	cfg.timeout = time.Second
+	stm, conn := tUtilSMStreamInit(cfg, mods, r, userRep, blockListRep)
+	features := tUtilSMStreamAuthenticate(conn, t)
+	require.NotNil(t, features.Elements().ChildNamespace("csi", csiNamespace))
+	tUtilStreamBind(conn, t)
+
+	appServerJID, _ := jid.New("push", "localhost", "app", true)
+	appServerStm := stream.NewMockC2S("abcd7890", appServerJID)
+	appServerStm.SetPresence(xmpp.NewPresence(appServerJID, appServerJID, xmpp.AvailableType))
+	r.Bind(context.Background(), appServerStm)
+
+	jFrom, _ := jid.New("ortuman", "localhost", "garden", true)
+	msg := xmpp.NewMessageType(uuid.New().String(), xmpp.ChatType)
+	msg.SetFromJID(jFrom)
+	msg.SetToJID(stm.JID())
+	msg.AppendElement(xmpp.NewElementName("body").SetText("Hi buddy!"))
+
+	_, _ = conn.inboundWrite([]byte(`<inactive xmlns="urn:xmpp:csi:0"/>`))
+	time.Sleep(time.Millisecond * 100) // wait for state change...
+
+	stm.SendElement(context.Background(), msg)
+	require.Equal(t, "message", conn.outboundRead().Name())
+
+	elem := appServerStm.ReceiveElement()
+	require.Equal(t, "iq", elem.Name())
+	require.NotNil(t, elem.Elements().ChildNamespace("pubsub", "http://jabber.org/protocol/pubsub"))
+
+	_, _ = conn.inboundWrite([]byte(`<active xmlns="urn:xmpp:csi:0"/>`))
+	time.Sleep(time.Millisecond * 100)
+
+	stm.SendElement(context.Background(), msg)
+	require.Equal(t, "message", conn.outboundRead().Name())
+
+	appServerStm.SendElement(context.Background(), xmpp.NewElementName("marker"))
+	require.Equal(t, "marker", appServerStm.ReceiveElement().Name())
+}
+
 func TestStream_SendToBlockedJID(t *testing.T) {
 	r, userRep, blockListRep := setupTest("localhost")
 
@@ -413,11 +699,31 @@ func tUtilStreamInit(r router.Router, userRep repository.User, blockListRep repo
 		tUtilInitModules(r),
 		&component.Components{},
 		r,
-		userRep,
-		blockListRep)
+		backend.NewStorage(userRep),
+		blockListRep,
+		nil,
+		nil)
 	return stm.(*inStream), conn
 }
 
+func tUtilAnonymousStreamInit(cfg *streamConfig, r router.Router, userRep repository.User, blockListRep repository.BlockList, anonRep *anonymous.Storage) (*inStream, *fakeSocketConn) {
+	conn := newFakeSocketConn()
+	tr := transport.NewSocketTransport(conn)
+	stm := newStream(
+		"abcd1234",
+		cfg,
+		tr,
+		tUtilInitModules(r),
+		&component.Components{},
+		r,
+		backend.NewStorage(userRep),
+		blockListRep,
+		anonRep,
+		nil).(*inStream)
+	stm.setSecured(true) // SASL mechanisms are offered over secured streams
+	return stm, conn
+}
+
 func tUtilInStreamDefaultConfig() *streamConfig {
 	return &streamConfig{
 		connectTimeout:   time.Second,
@@ -425,7 +731,7 @@ func tUtilInStreamDefaultConfig() *streamConfig {
 		maxStanzaSize:    8192,
 		resourceConflict: Reject,
 		compression:      CompressConfig{Level: compress.DefaultCompression},
-		sasl:             []string{"plain", "digest_md5", "scram_sha_1", "scram_sha_256", "scram_sha_512"},
+		sasl:             []string{"plain", "scram_sha_1", "scram_sha_256", "scram_sha_512"},
 	}
 }
 
@@ -435,5 +741,5 @@ func tUtilInitModules(r router.Router) *module.Modules {
 	modules["blocking_command"] = struct{}{}
 
 	repContainer, _ := storage.New(&storage.Config{Type: storage.Memory})
-	return module.New(&module.Config{Enabled: modules}, r, repContainer, "alloc-1234")
+	return module.New(&module.Config{Enabled: modules}, r, repContainer, backend.NewStorage(repContainer.User()), "alloc-1234")
 }
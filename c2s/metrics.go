@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	connections = newConnectionsCollector(prometheus.NewDesc(
+		"jackal_c2s_connections",
+		"Number of current c2s connections by listener and stream state.",
+		[]string{"listener", "state"}, nil,
+	))
+	saslAuthentications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "jackal_c2s_sasl_authentications_total",
+		Help: "SASL authentication attempts by mechanism and result.",
+	}, []string{"mechanism", "result"})
+)
+
+var stateNames = map[uint32]string{
+	connecting:     "connecting",
+	connected:      "connected",
+	authenticating: "authenticating",
+	authenticated:  "authenticated",
+	bound:          "bound",
+	detached:       "detached",
+	disconnected:   "disconnected",
+}
+
+// connectionsCollector reports current connection gauges on every scrape.
+type connectionsCollector struct {
+	desc   *prometheus.Desc
+	mu     sync.RWMutex
+	report func(report func(val float64, labelValues ...string))
+}
+
+func newConnectionsCollector(desc *prometheus.Desc) *connectionsCollector {
+	c := &connectionsCollector{desc: desc}
+	prometheus.MustRegister(c)
+	return c
+}
+
+// Set assigns the function in charge of reporting connection gauges, replacing any previous one.
+func (c *connectionsCollector) Set(report func(report func(val float64, labelValues ...string))) {
+	c.mu.Lock()
+	c.report = report
+	c.mu.Unlock()
+}
+
+// Describe implements prometheus.Collector interface.
+func (c *connectionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector interface.
+func (c *connectionsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	report := c.report
+	c.mu.RUnlock()
+	if report == nil {
+		return
+	}
+	report(func(val float64, labelValues ...string) {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, val, labelValues...)
+	})
+}
+
+// reportAuthentication records the outcome of an authentication step, ignoring
+// those that still require further challenges to be completed.
+func reportAuthentication(mechanism string, err error, authenticated bool) {
+	switch {
+	case err != nil:
+		saslAuthentications.WithLabelValues(mechanism, "failure").Inc()
+	case authenticated:
+		saslAuthentications.WithLabelValues(mechanism, "success").Inc()
+	}
+}
@@ -0,0 +1,16 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2srouter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var boundResources = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "jackal_c2s_bound_resources",
+	Help: "Number of currently bound c2s resources.",
+})
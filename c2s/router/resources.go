@@ -54,6 +54,7 @@ func (r *resources) bind(stm stream.C2S) {
 		}
 	}
 	r.streams = append(r.streams, stm)
+	boundResources.Inc()
 }
 
 func (r *resources) unbind(res string) {
@@ -65,6 +66,7 @@ func (r *resources) unbind(res string) {
 			continue
 		}
 		r.streams = append(r.streams[:i], r.streams[i+1:]...)
+		boundResources.Dec()
 		return
 	}
 }
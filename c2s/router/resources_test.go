@@ -15,6 +15,7 @@ import (
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/xmpp"
 	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -25,8 +26,12 @@ func TestResources_Binding(t *testing.T) {
 	res := resources{}
 	require.Equal(t, 0, res.len())
 
+	bound := testutil.ToFloat64(boundResources)
+
 	res.bind(stm)
+	res.bind(stm) // already bound
 	require.Equal(t, 1, res.len())
+	require.Equal(t, bound+1, testutil.ToFloat64(boundResources))
 
 	require.NotNil(t, res.stream("yard"))
 	require.Len(t, res.allStreams(), 1)
@@ -35,6 +40,7 @@ func TestResources_Binding(t *testing.T) {
 
 	require.Nil(t, res.stream("yard"))
 	require.Len(t, res.allStreams(), 0)
+	require.Equal(t, bound, testutil.ToFloat64(boundResources))
 }
 
 func TestResources_Route(t *testing.T) {
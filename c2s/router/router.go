@@ -9,6 +9,7 @@ import (
 	"context"
 	"sync"
 
+	"github.com/ortuman/jackal/auth/backend"
 	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/storage/repository"
@@ -20,14 +21,14 @@ import (
 type c2sRouter struct {
 	mu           sync.RWMutex
 	tbl          map[string]*resources
-	userRep      repository.User
+	authBackend  backend.Backend
 	blockListRep repository.BlockList
 }
 
-func New(userRep repository.User, blockListRep repository.BlockList) router.C2SRouter {
+func New(authBackend backend.Backend, blockListRep repository.BlockList) router.C2SRouter {
 	return &c2sRouter{
 		tbl:          make(map[string]*resources),
-		userRep:      userRep,
+		authBackend:  authBackend,
 		blockListRep: blockListRep,
 	}
 }
@@ -46,7 +47,7 @@ func (r *c2sRouter) Route(ctx context.Context, stanza xmpp.Stanza, validateStanz
 	r.mu.RUnlock()
 
 	if rs == nil {
-		exists, err := r.userRep.UserExists(ctx, username)
+		exists, err := r.authBackend.UserExists(ctx, username)
 		if err != nil {
 			return err
 		}
@@ -9,6 +9,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/ortuman/jackal/auth/backend"
 	"github.com/ortuman/jackal/model"
 	"github.com/ortuman/jackal/router"
 	memorystorage "github.com/ortuman/jackal/storage/memory"
@@ -87,5 +88,5 @@ func TestRouter_Routing(t *testing.T) {
 func setupTest() (router.C2SRouter, repository.User, repository.BlockList) {
 	userRep := memorystorage.NewUser()
 	blockListRep := memorystorage.NewBlockList()
-	return New(userRep, blockListRep), userRep, blockListRep
+	return New(backend.NewStorage(userRep), blockListRep), userRep, blockListRep
 }
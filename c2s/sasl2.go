@@ -0,0 +1,257 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/ortuman/jackal/auth"
+	streamerror "github.com/ortuman/jackal/errors"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+// sasl2State holds an in progress SASL2 (XEP-0388) negotiation.
+type sasl2State struct {
+	request xmpp.XElement
+}
+
+// authStream is the stream handed over to authenticators. The SASL elements they send
+// are retained until the outcome of the authentication step has been evaluated, so that
+// they can be either discarded or translated into their SASL2 counterparts.
+type authStream struct {
+	*inStream
+}
+
+// SendElement retains an authenticator element.
+func (s *authStream) SendElement(_ context.Context, elem xmpp.XElement) {
+	s.authPending = append(s.authPending, elem) // always accessed from within stream run queue
+}
+
+// sasl2Feature returns the SASL2 authentication stream feature, along with its supported inline features.
+func (s *inStream) sasl2Feature() xmpp.XElement {
+	authentication := xmpp.NewElementNamespace("authentication", sasl2Namespace)
+	for _, ath := range s.authenticators {
+		authentication.AppendElement(xmpp.NewElementName("mechanism").SetText(ath.Mechanism()))
+	}
+	// [xep-0386] bind 2 inline features
+	bindInline := xmpp.NewElementName("inline")
+	if s.mods.Carbons != nil {
+		bindInline.AppendElement(xmpp.NewElementName("feature").SetAttribute("var", carbonsNamespace))
+	}
+	if s.cfg.sm.Enabled {
+		bindInline.AppendElement(xmpp.NewElementName("feature").SetAttribute("var", smNamespace))
+	}
+	bindInline.AppendElement(xmpp.NewElementName("feature").SetAttribute("var", csiNamespace))
+
+	bind := xmpp.NewElementNamespace("bind", bind2Namespace)
+	bind.AppendElement(bindInline)
+
+	inline := xmpp.NewElementName("inline")
+	inline.AppendElement(bind)
+	if fast := s.fastFeature(); fast != nil {
+		inline.AppendElement(fast)
+	}
+	authentication.AppendElement(inline)
+	return authentication
+}
+
+func (s *inStream) startSASL2Authentication(ctx context.Context, elem xmpp.XElement) {
+	if elem.Namespace() != sasl2Namespace {
+		s.disconnectWithStreamError(ctx, streamerror.ErrInvalidNamespace)
+		return
+	}
+	mechanism := elem.Attributes().Get("mechanism")
+	if authenticator := s.sasl2Authenticator(mechanism); authenticator != nil {
+		s.sasl2 = &sasl2State{request: elem}
+
+		// translate into a regular SASL element
+		authElem := xmpp.NewElementNamespace("auth", saslNamespace)
+		authElem.SetAttribute("mechanism", mechanism)
+		if initialResponse := elem.Elements().Child("initial-response"); initialResponse != nil {
+			authElem.SetText(initialResponse.Text())
+		}
+		s.continueSASL2Authentication(ctx, authElem, authenticator)
+		return
+	}
+	// ...mechanism not found...
+	failure := xmpp.NewElementNamespace("failure", sasl2Namespace)
+	failure.AppendElement(xmpp.NewElementNamespace("invalid-mechanism", saslNamespace))
+	s.writeElement(ctx, failure)
+}
+
+func (s *inStream) handleSASL2Authenticating(ctx context.Context, elem xmpp.XElement) {
+	if elem.Namespace() != sasl2Namespace {
+		s.disconnectWithStreamError(ctx, streamerror.ErrInvalidNamespace)
+		return
+	}
+	switch elem.Name() {
+	case "response":
+		respElem := xmpp.NewElementNamespace("response", saslNamespace)
+		respElem.SetText(elem.Text())
+		s.continueSASL2Authentication(ctx, respElem, s.activeAuth)
+
+	case "abort":
+		s.failSASL2Authentication(ctx, auth.ErrSASLAborted.(*auth.SASLError), "")
+
+	default:
+		s.failSASL2Authentication(ctx, auth.ErrSASLMalformedRequest.(*auth.SASLError), "")
+	}
+}
+
+func (s *inStream) continueSASL2Authentication(ctx context.Context, elem xmpp.XElement, authr auth.Authenticator) {
+	if s.isAuthLockedOut(authr, elem) {
+		s.failSASL2Authentication(ctx, auth.ErrSASLTemporaryAuthFailure.(*auth.SASLError), "")
+		return
+	}
+	err := s.trackAuthentication(authr, authr.ProcessElement(ctx, elem))
+	reportAuthentication(authr.Mechanism(), err, authr.Authenticated())
+
+	pending := s.authPending
+	s.authPending = nil
+
+	if err != nil {
+		saslErr, ok := err.(*auth.SASLError)
+		if !ok {
+			log.Error(err)
+			saslErr = auth.ErrSASLTemporaryAuthFailure.(*auth.SASLError)
+		}
+		s.failSASL2Authentication(ctx, saslErr, "")
+		return
+	}
+	if authr.Authenticated() {
+		var additionalData string
+		for _, e := range pending {
+			if e.Name() == "success" {
+				additionalData = e.Text()
+			}
+		}
+		s.finishSASL2Authentication(ctx, authr, additionalData)
+		return
+	}
+	for _, e := range pending {
+		if e.Name() == "challenge" {
+			s.writeElement(ctx, xmpp.NewElementNamespace("challenge", sasl2Namespace).SetText(e.Text()))
+		}
+	}
+	s.activeAuth = authr
+	s.setState(authenticating)
+}
+
+func (s *inStream) sasl2Authenticator(mechanism string) auth.Authenticator {
+	for _, authenticator := range s.authenticators {
+		if authenticator.Mechanism() == mechanism {
+			return authenticator
+		}
+	}
+	for _, authenticator := range s.fastAuths {
+		if authenticator.Mechanism() == mechanism {
+			return authenticator
+		}
+	}
+	return nil
+}
+
+func (s *inStream) finishSASL2Authentication(ctx context.Context, authr auth.Authenticator, additionalData string) {
+	username := authr.Username()
+
+	var usedToken *model.FastToken
+	if fastAuth, ok := authr.(*auth.Fast); ok {
+		usedToken = fastAuth.Token()
+	}
+	if s.activeAuth != nil {
+		s.activeAuth.Reset()
+		s.activeAuth = nil
+	}
+	req := s.sasl2.request
+	s.sasl2 = nil
+
+	j, _ := jid.New(username, s.Domain(), "", true)
+	s.setJID(j)
+	s.setAuthenticated(true)
+	s.setState(authenticated)
+
+	success := xmpp.NewElementNamespace("success", sasl2Namespace)
+	if len(additionalData) > 0 {
+		success.AppendElement(xmpp.NewElementName("additional-data").SetText(additionalData))
+	}
+	authzID := xmpp.NewElementName("authorization-identifier")
+	success.AppendElement(authzID)
+
+	// [xep-0386] bind a resource within the same round trip
+	if bindReq := req.Elements().ChildNamespace("bind", bind2Namespace); bindReq != nil {
+		bound, err := s.bind2(ctx, bindReq)
+		if err != nil {
+			log.Error(err)
+
+			s.setJID(&jid.JID{})
+			s.setAuthenticated(false)
+			s.failSASL2Authentication(ctx, auth.ErrSASLTemporaryAuthFailure.(*auth.SASLError), "resource binding failed")
+			return
+		}
+		success.AppendElement(bound)
+	}
+	// [xep-0484] token invalidation, rotation and issuance
+	if token := s.processFastRequest(ctx, req, usedToken); token != nil {
+		success.AppendElement(token)
+	}
+	authzID.SetText(s.JID().String())
+	s.writeElement(ctx, success)
+
+	log.Infof("authenticated stream through SASL2... id: %s", s.id)
+}
+
+func (s *inStream) failSASL2Authentication(ctx context.Context, saslErr *auth.SASLError, text string) {
+	failure := xmpp.NewElementNamespace("failure", sasl2Namespace)
+	failure.AppendElement(xmpp.NewElementNamespace(saslErr.Error(), saslNamespace))
+	if len(text) > 0 {
+		failure.AppendElement(xmpp.NewElementName("text").SetText(text))
+	}
+	s.writeElement(ctx, failure)
+
+	if s.activeAuth != nil {
+		s.activeAuth.Reset()
+		s.activeAuth = nil
+	}
+	s.sasl2 = nil
+	s.setState(connected)
+}
+
+// bind2 binds a server generated resource, enabling requested inline features.
+func (s *inStream) bind2(ctx context.Context, bindReq xmpp.XElement) (xmpp.XElement, error) {
+	resource := uuid.New().String()
+	if tag := bindReq.Elements().Child("tag"); tag != nil && len(tag.Text()) > 0 {
+		resource = tag.Text() + "." + resource
+	}
+	if err := s.bind(ctx, resource); err != nil {
+		return nil, err
+	}
+	s.setSessionStarted(true)
+
+	bound := xmpp.NewElementNamespace("bound", bind2Namespace)
+	for _, feature := range bindReq.Elements().All() {
+		switch feature.Namespace() {
+		case carbonsNamespace:
+			if feature.Name() == "enable" && s.mods.Carbons != nil {
+				s.mods.Carbons.Enable(s)
+			}
+		case smNamespace:
+			if feature.Name() == "enable" && s.cfg.sm.Enabled && s.sm == nil {
+				bound.AppendElement(s.startSM(feature))
+			}
+		case csiNamespace:
+			s.inactive = feature.Name() == "inactive"
+		}
+	}
+	// start pinging...
+	if p := s.mods.Ping; p != nil {
+		p.SchedulePing(s)
+	}
+	return bound, nil
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/auth/backend"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream_SASL2Authenticate(t *testing.T) {
+	r, userRep, blockListRep := setupTest("localhost")
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Password: "pencil"})
+
+	cfg := tUtilSMStreamConfig(newSMRegistry(), time.Minute)
+	stm, conn := tUtilSMStreamInit(cfg, tUtilSASL2InitModules(r), r, userRep, blockListRep)
+	stm.setSecured(true)
+
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	features := conn.outboundRead()
+
+	authentication := features.Elements().ChildNamespace("authentication", sasl2Namespace)
+	require.NotNil(t, authentication)
+	require.Equal(t, "PLAIN", authentication.Elements().Child("mechanism").Text())
+
+	bindFeature := authentication.Elements().Child("inline").Elements().ChildNamespace("bind", bind2Namespace)
+	require.NotNil(t, bindFeature)
+	require.Len(t, bindFeature.Elements().Child("inline").Elements().All(), 3)
+
+	// unsupported mechanism
+	_, _ = conn.inboundWrite([]byte(`<authenticate xmlns="urn:xmpp:sasl:2" mechanism="FOO"/>`))
+	elem := conn.outboundRead()
+	require.Equal(t, "failure", elem.Name())
+	require.Equal(t, sasl2Namespace, elem.Namespace())
+	require.NotNil(t, elem.Elements().ChildNamespace("invalid-mechanism", saslNamespace))
+
+	// invalid credentials
+	_, _ = conn.inboundWrite([]byte(`<authenticate xmlns="urn:xmpp:sasl:2" mechanism="PLAIN"><initial-response>AHVzZXIAYQ==</initial-response></authenticate>`))
+	elem = conn.outboundRead()
+	require.Equal(t, "failure", elem.Name())
+	require.NotNil(t, elem.Elements().ChildNamespace("not-authorized", saslNamespace))
+
+	// authenticate, bind and enable inline features within a single round trip
+	_, _ = conn.inboundWrite([]byte(`<authenticate xmlns="urn:xmpp:sasl:2" mechanism="PLAIN">
+<initial-response>AHVzZXIAcGVuY2ls</initial-response>
+<user-agent id="d4565fa7-4d72-4749-b3d3-740edbf87770"><software>AwesomeXMPP</software></user-agent>
+<bind xmlns="urn:xmpp:bind:0">
+<tag>AwesomeXMPP</tag>
+<enable xmlns="urn:xmpp:carbons:2"/>
+<enable xmlns="urn:xmpp:sm:3" resume="true"/>
+</bind>
+</authenticate>`))
+
+	elem = conn.outboundRead()
+	require.Equal(t, "success", elem.Name())
+	require.Equal(t, sasl2Namespace, elem.Namespace())
+
+	authzID := elem.Elements().Child("authorization-identifier").Text()
+	require.True(t, strings.HasPrefix(authzID, "user@localhost/AwesomeXMPP."))
+
+	bound := elem.Elements().ChildNamespace("bound", bind2Namespace)
+	require.NotNil(t, bound)
+	enabled := bound.Elements().ChildNamespace("enabled", smNamespace)
+	require.NotNil(t, enabled)
+	require.Equal(t, "true", enabled.Attributes().Get("resume"))
+
+	time.Sleep(time.Millisecond * 100) // wait until processed...
+
+	require.True(t, stm.IsAuthenticated())
+	require.Equal(t, authzID, stm.JID().String())
+	require.True(t, stm.isSessionStarted())
+	require.Equal(t, true, stm.Value("carbons:enabled"))
+
+	// no stream restart is required
+	_, _ = conn.inboundWrite([]byte(`<r xmlns="urn:xmpp:sm:3"/>`))
+	elem = conn.outboundRead()
+	require.Equal(t, "a", elem.Name())
+}
+
+func TestStream_SASL2WithoutBind(t *testing.T) {
+	r, userRep, blockListRep := setupTest("localhost")
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Password: "pencil"})
+
+	stm, conn := tUtilStreamInit(r, userRep, blockListRep)
+	stm.setSecured(true)
+
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	_, _ = conn.inboundWrite([]byte(`<authenticate xmlns="urn:xmpp:sasl:2" mechanism="PLAIN"><initial-response>AHVzZXIAcGVuY2ls</initial-response></authenticate>`))
+	elem := conn.outboundRead()
+	require.Equal(t, "success", elem.Name())
+	require.Equal(t, "user@localhost", elem.Elements().Child("authorization-identifier").Text())
+	require.Nil(t, elem.Elements().Child("bound"))
+
+	// regular resource binding
+	tUtilStreamBind(conn, t)
+	require.Equal(t, "balcony", stm.Resource())
+}
+
+func tUtilSASL2InitModules(r router.Router) *module.Modules {
+	modules := map[string]struct{}{}
+	modules["roster"] = struct{}{}
+	modules["carbons"] = struct{}{}
+
+	repContainer, _ := storage.New(&storage.Config{Type: storage.Memory})
+	return module.New(&module.Config{Enabled: modules}, r, repContainer, backend.NewStorage(repContainer.User()), "alloc-1234")
+}
@@ -7,47 +7,75 @@ package c2s
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
+	"net/http"
+	"reflect"
 	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/gorilla/websocket"
+	"github.com/ortuman/jackal/auth/backend"
+	"github.com/ortuman/jackal/auth/lockout"
 	"github.com/ortuman/jackal/component"
 	streamerror "github.com/ortuman/jackal/errors"
 	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage/anonymous"
 	"github.com/ortuman/jackal/storage/repository"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/transport/bosh"
+	"github.com/ortuman/jackal/transport/proxyproto"
+	"github.com/ortuman/jackal/util/connlimit"
 )
 
+const xmppClientALPN = "xmpp-client"
+
 var listenerProvider = net.Listen
 
 type server struct {
 	cfg             *Config
+	mu              sync.RWMutex
+	streamCfg       *Config
 	mods            *module.Modules
 	comps           *component.Components
 	router          router.Router
-	userRep         repository.User
+	authBackend     backend.Backend
 	blockListRep    repository.BlockList
+	anonRep         *anonymous.Storage
+	fastRep         repository.FastToken
+	lockout         *lockout.Tracker
+	smRegistry      *smRegistry
+	connLimiter     *connlimit.Limiter
 	inConnectionsMu sync.Mutex
 	inConnections   map[string]stream.C2S
 	ln              net.Listener
+	httpSrv         *http.Server
+	wsUpgrader      *websocket.Upgrader
+	boshHandler     *bosh.Handler
 	stmSeq          uint64
 	listening       uint32
 }
 
-func newC2SServer(config *Config, mods *module.Modules, comps *component.Components, router router.Router, userRep repository.User, blockListRep repository.BlockList) c2sServer {
+func newC2SServer(config *Config, mods *module.Modules, comps *component.Components, router router.Router, authBackend backend.Backend, blockListRep repository.BlockList, anonRep *anonymous.Storage, fastRep repository.FastToken, lockoutTracker *lockout.Tracker, smRegistry *smRegistry) c2sServer {
 	return &server{
 		cfg:           config,
+		streamCfg:     config,
 		mods:          mods,
 		comps:         comps,
 		router:        router,
-		userRep:       userRep,
+		authBackend:   authBackend,
 		blockListRep:  blockListRep,
+		anonRep:       anonRep,
+		fastRep:       fastRep,
+		lockout:       lockoutTracker,
+		smRegistry:    smRegistry,
+		connLimiter:   connlimit.New(&config.Connections),
 		inConnections: make(map[string]stream.C2S),
 	}
 }
@@ -63,6 +91,10 @@ func (s *server) start() {
 	switch s.cfg.Transport.Type {
 	case transport.Socket:
 		err = s.listenSocketConn(address)
+	case transport.WebSocket:
+		err = s.listenWebSocketConn(address)
+	case transport.BOSH:
+		err = s.listenBOSHConn(address)
 	}
 	if err != nil {
 		log.Fatalf("%v", err)
@@ -80,13 +112,164 @@ func (s *server) listenSocketConn(address string) error {
 	for atomic.LoadUint32(&s.listening) == 1 {
 		conn, err := ln.Accept()
 		if err == nil {
-			go s.startStream(transport.NewSocketTransport(conn), s.cfg.KeepAlive)
+			go s.acceptSocketConn(conn)
 			continue
 		}
 	}
 	return nil
 }
 
+func (s *server) acceptSocketConn(conn net.Conn) {
+	// [PROXY protocol] original client address is conveyed by trusted proxies
+	if s.cfg.Transport.ProxyProtocol.IsTrusted(conn.RemoteAddr()) {
+		pConn, err := proxyproto.Accept(conn, s.streamConfig().ConnectTimeout)
+		if err != nil {
+			log.Warnf("%s: failed to read proxy protocol header from %s: %v", s.cfg.ID, conn.RemoteAddr(), err)
+			_ = conn.Close()
+			return
+		}
+		conn = pConn
+	}
+	lConn, err := s.connLimiter.Admit(conn)
+	if err != nil {
+		s.rejectConn(conn, err)
+		_ = conn.Close()
+		return
+	}
+	if s.cfg.Transport.DirectTLS {
+		s.startDirectTLSStream(lConn)
+		return
+	}
+	s.startStream(transport.NewSocketTransport(lConn), s.streamConfig().KeepAlive)
+}
+
+func (s *server) rejectConn(conn net.Conn, err error) {
+	log.Warnf("%s: rejected connection from %s: %v (total rejected: %d)", s.cfg.ID, conn.RemoteAddr(), err, s.connLimiter.Rejected())
+}
+
+// startDirectTLSStream performs TLS handshake before starting the stream (XEP-0368).
+func (s *server) startDirectTLSStream(conn net.Conn) {
+	tlsCfg := s.router.Hosts().TLSConfig("")
+	tlsCfg.NextProtos = []string{xmppClientALPN}
+	if clientCAs := s.streamConfig().ClientCAs; clientCAs != nil {
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+		tlsCfg.ClientCAs = clientCAs
+	}
+	tlsConn := tls.Server(conn, tlsCfg)
+	if s.streamConfig().ConnectTimeout > 0 {
+		_ = tlsConn.SetDeadline(time.Now().Add(s.streamConfig().ConnectTimeout))
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		log.Warnf("%s: tls handshake failed: %v", s.cfg.ID, err)
+		_ = tlsConn.Close()
+		return
+	}
+	_ = tlsConn.SetDeadline(time.Time{})
+
+	go s.startStream(transport.NewTLSSocketTransport(tlsConn, tlsCfg), s.streamConfig().KeepAlive)
+}
+
+func (s *server) listenWebSocketConn(address string) error {
+	ln, err := listenerProvider("tcp", address)
+	if err != nil {
+		return err
+	}
+	ln = s.connLimiter.Listener(ln, s.rejectConn)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(s.cfg.Transport.URLPath, s.websocketUpgrade)
+
+	s.httpSrv = &http.Server{
+		Handler:   mux,
+		TLSConfig: s.router.Hosts().TLSConfig(""),
+	}
+	s.wsUpgrader = &websocket.Upgrader{
+		Subprotocols: []string{"xmpp"},
+		CheckOrigin:  func(r *http.Request) bool { return true },
+	}
+	atomic.StoreUint32(&s.listening, 1)
+
+	if err := s.httpSrv.ServeTLS(ln, "", ""); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (s *server) listenBOSHConn(address string) error {
+	ln, err := listenerProvider("tcp", address)
+	if err != nil {
+		return err
+	}
+	ln = s.connLimiter.Listener(ln, s.rejectConn)
+
+	s.boshHandler = bosh.NewHandler(&s.cfg.Transport.BOSH, func(tr transport.Transport) {
+		s.startStream(tr, s.streamConfig().KeepAlive)
+	})
+	mux := http.NewServeMux()
+	mux.Handle(s.cfg.Transport.URLPath, s.boshHandler)
+
+	s.httpSrv = &http.Server{
+		Handler:   mux,
+		TLSConfig: s.router.Hosts().TLSConfig(""),
+	}
+	atomic.StoreUint32(&s.listening, 1)
+
+	if err := s.httpSrv.ServeTLS(ln, "", ""); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (s *server) websocketUpgrade(w http.ResponseWriter, r *http.Request) {
+	if !websocket.IsWebSocketUpgrade(r) {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	conn, err := s.wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	// [rfc7395] the 'xmpp' subprotocol must have been negotiated
+	if conn.Subprotocol() != "xmpp" {
+		_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseProtocolError, ""), time.Now().Add(time.Second))
+		_ = conn.Close()
+		return
+	}
+	go s.startStream(transport.NewWebSocketTransport(conn), s.streamConfig().KeepAlive)
+}
+
+// reload applies a new listener configuration to streams started from now on,
+// returning the name of the settings whose changes require a restart to be applied.
+func (s *server) reload(config *Config, mods *module.Modules) []string {
+	var ignored []string
+	if !reflect.DeepEqual(config.Transport, s.cfg.Transport) {
+		ignored = append(ignored, "transport")
+	}
+	if !reflect.DeepEqual(config.Connections, s.cfg.Connections) {
+		ignored = append(ignored, "connections")
+	}
+	s.mu.Lock()
+	s.streamCfg = config
+	s.mods = mods
+	s.mu.Unlock()
+	return ignored
+}
+
+func (s *server) streamConfig() *Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.streamCfg
+}
+
+// acquireModules returns the current module set, marking it as being used by a new stream.
+func (s *server) acquireModules() *module.Modules {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.mods.Acquire()
+	return s.mods
+}
+
 func (s *server) shutdown(ctx context.Context) error {
 	if atomic.CompareAndSwapUint32(&s.listening, 1, 0) {
 		// stop listening
@@ -95,6 +278,10 @@ func (s *server) shutdown(ctx context.Context) error {
 			if err := s.ln.Close(); err != nil {
 				return err
 			}
+		case transport.WebSocket:
+			if err := s.httpSrv.Shutdown(ctx); err != nil {
+				return err
+			}
 		}
 		// close all connections
 		c, err := s.closeConnections(ctx)
@@ -102,22 +289,43 @@ func (s *server) shutdown(ctx context.Context) error {
 			return err
 		}
 		log.Infof("%s: closed %d connection(s)", s.cfg.ID, c)
+
+		// held BOSH requests are released once their sessions have been terminated
+		if s.cfg.Transport.Type == transport.BOSH {
+			s.boshHandler.Shutdown()
+			if err := s.httpSrv.Shutdown(ctx); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
 func (s *server) startStream(tr transport.Transport, keepAlive time.Duration) {
+	config := s.streamConfig()
 	cfg := &streamConfig{
-		resourceConflict: s.cfg.ResourceConflict,
-		connectTimeout:   s.cfg.ConnectTimeout,
-		keepAlive:        s.cfg.KeepAlive,
-		timeout:          s.cfg.Timeout,
-		maxStanzaSize:    s.cfg.MaxStanzaSize,
-		sasl:             s.cfg.SASL,
-		compression:      s.cfg.Compression,
-		onDisconnect:     s.unregisterStream,
-	}
-	stm := newStream(s.nextID(), cfg, tr, s.mods, s.comps, s.router, s.userRep, s.blockListRep)
+		resourceConflict: config.ResourceConflict,
+		connectTimeout:   config.ConnectTimeout,
+		keepAlive:        config.KeepAlive,
+		timeout:          config.Timeout,
+		maxStanzaSize:    config.MaxStanzaSize,
+		sasl:             config.SASL,
+		anonymousHosts:   config.AnonymousHosts,
+		clientCAs:        config.ClientCAs,
+		compression:      config.Compression,
+		directTLS:        s.cfg.Transport.DirectTLS,
+		sm:               config.StreamManagement,
+		fast:             config.Fast,
+		lockout:          s.lockout,
+		rateLimit:        config.RateLimit,
+		smRegistry:       s.smRegistry,
+	}
+	mods := s.acquireModules()
+	cfg.onDisconnect = func(stm stream.C2S) {
+		s.unregisterStream(stm)
+		mods.Release()
+	}
+	stm := newStream(s.nextID(), cfg, tr, mods, s.comps, s.router, s.authBackend, s.blockListRep, s.anonRep, s.fastRep)
 	s.registerStream(stm)
 }
 
@@ -126,7 +334,7 @@ func (s *server) registerStream(stm stream.C2S) {
 	s.inConnections[stm.ID()] = stm
 	s.inConnectionsMu.Unlock()
 
-	log.Infof("registered c2s stream... (id: %s)", stm.ID())
+	log.Infof("registered c2s stream... (id: %s, remote: %v)", stm.ID(), stm.RemoteAddr())
 }
 
 func (s *server) unregisterStream(stm stream.C2S) {
@@ -137,6 +345,19 @@ func (s *server) unregisterStream(stm stream.C2S) {
 	log.Infof("unregistered c2s stream... (id: %s)", stm.ID())
 }
 
+// connectionsByState returns the number of registered streams grouped by state name.
+func (s *server) connectionsByState() map[string]int {
+	ret := make(map[string]int)
+	s.inConnectionsMu.Lock()
+	for _, stm := range s.inConnections {
+		if in, ok := stm.(*inStream); ok {
+			ret[stateNames[in.getState()]]++
+		}
+	}
+	s.inConnectionsMu.Unlock()
+	return ret
+}
+
 func (s *server) nextID() string {
 	return fmt.Sprintf("c2s:%s:%d", s.cfg.ID, atomic.AddUint64(&s.stmSeq, 1))
 }
@@ -7,14 +7,36 @@ package c2s
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
 	"net"
+	"os"
 	"testing"
 	"time"
 
+	"github.com/gorilla/websocket"
+	"github.com/ortuman/jackal/auth/backend"
+	c2srouter "github.com/ortuman/jackal/c2s/router"
 	"github.com/ortuman/jackal/component"
+	"github.com/ortuman/jackal/model"
 	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/router/host"
+	memorystorage "github.com/ortuman/jackal/storage/memory"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/transport/proxyproto"
+	"github.com/ortuman/jackal/util/connlimit"
+	utiltls "github.com/ortuman/jackal/util/tls"
+	"github.com/ortuman/jackal/xmpp"
 	"github.com/stretchr/testify/require"
 )
 
@@ -34,9 +56,11 @@ func TestC2SSocketServer(t *testing.T) {
 	}
 	srv := server{
 		cfg:           &cfg,
+		streamCfg:     &cfg,
 		router:        r,
 		mods:          &module.Modules{},
 		comps:         &component.Components{},
+		connLimiter:   connlimit.New(&cfg.Connections),
 		inConnections: make(map[string]stream.C2S),
 	}
 	go srv.start()
@@ -69,3 +93,558 @@ func TestC2SSocketServer(t *testing.T) {
 	err := <-errCh
 	require.Nil(t, err)
 }
+
+func TestC2SSocketServer_ConnectionLimits(t *testing.T) {
+	r, _, _ := setupTest("localhost")
+
+	cfg := Config{
+		ID:               "srv-1234",
+		ConnectTimeout:   time.Second * time.Duration(5),
+		KeepAlive:        time.Second * time.Duration(5),
+		MaxStanzaSize:    8192,
+		ResourceConflict: Reject,
+		Transport: TransportConfig{
+			Type: transport.Socket,
+			Port: 9995,
+		},
+		Connections: connlimit.Config{MaxPerIP: 1},
+	}
+	srv := server{
+		cfg:           &cfg,
+		streamCfg:     &cfg,
+		router:        r,
+		mods:          &module.Modules{},
+		comps:         &component.Components{},
+		connLimiter:   connlimit.New(&cfg.Connections),
+		inConnections: make(map[string]stream.C2S),
+	}
+	go srv.start()
+	time.Sleep(time.Millisecond * 150)
+
+	conn1, err := net.Dial("tcp", "127.0.0.1:9995")
+	require.Nil(t, err)
+
+	time.Sleep(time.Millisecond * 150) // wait until accepted
+
+	// connection limit reached... closed right away
+	conn2, err := net.Dial("tcp", "127.0.0.1:9995")
+	require.Nil(t, err)
+	_ = conn2.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn2.Read(make([]byte, 1))
+	require.NotNil(t, err)
+	require.Equal(t, uint64(1), srv.connLimiter.Rejected())
+	require.Equal(t, 1, srv.connLimiter.Connections())
+
+	// closed connections release their slot
+	_ = conn1.Close()
+	time.Sleep(time.Millisecond * 150) // wait until disconnected
+	require.Equal(t, 0, srv.connLimiter.Connections())
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*5))
+	defer cancel()
+	_ = srv.shutdown(ctx)
+}
+
+func TestC2SSocketServer_ProxyProtocol(t *testing.T) {
+	r, _, _ := setupTest("localhost")
+
+	_, trusted, _ := net.ParseCIDR("127.0.0.0/8")
+	cfg := Config{
+		ID:               "srv-1234",
+		ConnectTimeout:   time.Second * time.Duration(5),
+		KeepAlive:        time.Second * time.Duration(5),
+		MaxStanzaSize:    8192,
+		ResourceConflict: Reject,
+		Transport: TransportConfig{
+			Type:          transport.Socket,
+			Port:          9994,
+			ProxyProtocol: proxyproto.Config{Enabled: true, Trusted: []*net.IPNet{trusted}},
+		},
+	}
+	srv := server{
+		cfg:           &cfg,
+		streamCfg:     &cfg,
+		router:        r,
+		mods:          &module.Modules{},
+		comps:         &component.Components{},
+		connLimiter:   connlimit.New(&cfg.Connections),
+		inConnections: make(map[string]stream.C2S),
+	}
+	go srv.start()
+	time.Sleep(time.Millisecond * 150)
+
+	conn, err := net.Dial("tcp", "127.0.0.1:9994")
+	require.Nil(t, err)
+	_, err = conn.Write([]byte("PROXY TCP4 203.0.113.7 127.0.0.1 40000 9994\r\n"))
+	require.Nil(t, err)
+
+	time.Sleep(time.Millisecond * 150) // wait until stream is registered
+
+	srv.inConnectionsMu.Lock()
+	require.Len(t, srv.inConnections, 1)
+	for _, stm := range srv.inConnections {
+		require.Equal(t, "203.0.113.7:40000", stm.RemoteAddr().String())
+	}
+	srv.inConnectionsMu.Unlock()
+
+	_ = conn.Close()
+	time.Sleep(time.Millisecond * 150) // wait until disconnected
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*5))
+	defer cancel()
+	_ = srv.shutdown(ctx)
+}
+
+func TestC2SSocketServer_StartTLS(t *testing.T) {
+	defer os.RemoveAll("./.cert")
+
+	cer, err := utiltls.LoadCertificate("", "", "localhost")
+	require.Nil(t, err)
+
+	hosts, _ := host.New([]host.Config{{Name: "localhost", Certificate: cer}})
+	r, _ := router.New(hosts, c2srouter.New(backend.NewStorage(memorystorage.NewUser()), memorystorage.NewBlockList()), nil)
+
+	_, trusted, _ := net.ParseCIDR("127.0.0.0/8")
+	for _, tc := range []struct {
+		port          int
+		proxyProtocol proxyproto.Config
+		proxyHeader   string
+	}{
+		// connection admitted by the limiter
+		{port: 9993},
+		// connection accepted from a trusted proxy
+		{
+			port:          9991,
+			proxyProtocol: proxyproto.Config{Enabled: true, Trusted: []*net.IPNet{trusted}},
+			proxyHeader:   "PROXY TCP4 203.0.113.7 127.0.0.1 40000 9991\r\n",
+		},
+	} {
+		cfg := Config{
+			ID:               "srv-1234",
+			ConnectTimeout:   time.Second * time.Duration(5),
+			Timeout:          time.Second * time.Duration(5),
+			KeepAlive:        time.Second * time.Duration(5),
+			MaxStanzaSize:    8192,
+			ResourceConflict: Reject,
+			Transport: TransportConfig{
+				Type:          transport.Socket,
+				Port:          tc.port,
+				ProxyProtocol: tc.proxyProtocol,
+			},
+			Connections: connlimit.Config{MaxPerIP: 10},
+			SASL:        []string{"plain"},
+		}
+		srv := server{
+			cfg:           &cfg,
+			streamCfg:     &cfg,
+			router:        r,
+			mods:          &module.Modules{},
+			comps:         &component.Components{},
+			connLimiter:   connlimit.New(&cfg.Connections),
+			inConnections: make(map[string]stream.C2S),
+		}
+		go srv.start()
+		time.Sleep(time.Millisecond * 150)
+
+		tUtilStartTLS(t, fmt.Sprintf("127.0.0.1:%d", tc.port), tc.proxyHeader)
+
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*5))
+		_ = srv.shutdown(ctx)
+		cancel()
+	}
+}
+
+// tUtilStartTLS negotiates STARTTLS over a new connection, checking stream is actually secured afterwards.
+func tUtilStartTLS(t *testing.T, address, proxyHeader string) {
+	conn, err := net.Dial("tcp", address)
+	require.Nil(t, err)
+	defer func() { _ = conn.Close() }()
+
+	_ = conn.SetDeadline(time.Now().Add(time.Second * 5))
+
+	_, err = conn.Write([]byte(proxyHeader))
+	require.Nil(t, err)
+
+	open := `<?xml version="1.0"?><stream:stream xmlns="jabber:client" xmlns:stream="http://etherx.jabber.org/streams" to="localhost" version="1.0">`
+	features := tUtilOpenStream(t, conn, open)
+	require.NotNil(t, features.Elements().Child("starttls"))
+
+	_, err = conn.Write([]byte(`<starttls xmlns="urn:ietf:params:xml:ns:xmpp-tls"/>`))
+	require.Nil(t, err)
+	proceed, err := xmpp.NewParser(conn, xmpp.SocketStream, 0).ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "proceed", proceed.Name())
+
+	// TLS handshake fails if server keeps talking plaintext
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: "localhost", InsecureSkipVerify: true})
+	require.Nil(t, tlsConn.Handshake())
+
+	features = tUtilOpenStream(t, tlsConn, open)
+	require.Nil(t, features.Elements().Child("starttls"))
+	require.NotNil(t, features.Elements().Child("mechanisms"))
+}
+
+func tUtilOpenStream(t *testing.T, conn net.Conn, open string) xmpp.XElement {
+	_, err := conn.Write([]byte(open))
+	require.Nil(t, err)
+
+	var elem xmpp.XElement
+	p := xmpp.NewParser(conn, xmpp.SocketStream, 0)
+	for elem == nil || elem.Name() != "stream:features" {
+		elem, err = p.ParseElement()
+		require.Nil(t, err)
+	}
+	return elem
+}
+
+func TestC2SWebSocketServer(t *testing.T) {
+	defer os.RemoveAll("./.cert")
+
+	cer, err := utiltls.LoadCertificate("", "", "localhost")
+	require.Nil(t, err)
+
+	hosts, _ := host.New([]host.Config{{Name: "localhost", Certificate: cer}})
+	r, _ := router.New(hosts, c2srouter.New(backend.NewStorage(memorystorage.NewUser()), memorystorage.NewBlockList()), nil)
+
+	errCh := make(chan error)
+	cfg := Config{
+		ID:               "srv-1234",
+		ConnectTimeout:   time.Second * time.Duration(5),
+		Timeout:          time.Second * time.Duration(5),
+		KeepAlive:        time.Second * time.Duration(5),
+		MaxStanzaSize:    8192,
+		ResourceConflict: Reject,
+		Transport: TransportConfig{
+			Type:    transport.WebSocket,
+			URLPath: "/xmpp/ws",
+			Port:    9999,
+		},
+	}
+	srv := server{
+		cfg:           &cfg,
+		streamCfg:     &cfg,
+		router:        r,
+		mods:          &module.Modules{},
+		comps:         &component.Components{},
+		connLimiter:   connlimit.New(&cfg.Connections),
+		inConnections: make(map[string]stream.C2S),
+	}
+	go srv.start()
+
+	go func() {
+		time.Sleep(time.Millisecond * 150)
+
+		// test XMPP websocket endpoint...
+		d := &websocket.Dialer{
+			Subprotocols:    []string{"xmpp"},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+		conn, _, err := d.Dial("wss://127.0.0.1:9999/xmpp/ws", nil)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		open := `<open xmlns="urn:ietf:params:xml:ns:xmpp-framing" to="localhost" version="1.0"/>`
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(open)); err != nil {
+			errCh <- err
+			return
+		}
+		// expect open + features frames
+		for i := 0; i < 2; i++ {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				errCh <- err
+				return
+			}
+		}
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*5))
+		defer cancel()
+
+		_ = srv.shutdown(ctx)
+		errCh <- nil
+	}()
+	err = <-errCh
+	require.Nil(t, err)
+}
+
+func TestC2SWebSocketServer_ConnectionLimits(t *testing.T) {
+	defer os.RemoveAll("./.cert")
+
+	cer, err := utiltls.LoadCertificate("", "", "localhost")
+	require.Nil(t, err)
+
+	hosts, _ := host.New([]host.Config{{Name: "localhost", Certificate: cer}})
+	r, _ := router.New(hosts, c2srouter.New(backend.NewStorage(memorystorage.NewUser()), memorystorage.NewBlockList()), nil)
+
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	cfg := Config{
+		ID:               "srv-1234",
+		ConnectTimeout:   time.Second * time.Duration(5),
+		Timeout:          time.Second * time.Duration(5),
+		KeepAlive:        time.Second * time.Duration(5),
+		MaxStanzaSize:    8192,
+		ResourceConflict: Reject,
+		Transport: TransportConfig{
+			Type:    transport.WebSocket,
+			URLPath: "/xmpp/ws",
+			Port:    9992,
+		},
+		Connections: connlimit.Config{Deny: []*net.IPNet{loopback}},
+	}
+	srv := server{
+		cfg:           &cfg,
+		streamCfg:     &cfg,
+		router:        r,
+		mods:          &module.Modules{},
+		comps:         &component.Components{},
+		connLimiter:   connlimit.New(&cfg.Connections),
+		inConnections: make(map[string]stream.C2S),
+	}
+	go srv.start()
+	time.Sleep(time.Millisecond * 150)
+
+	// denied connections are closed before the websocket handshake takes place
+	d := &websocket.Dialer{
+		Subprotocols:     []string{"xmpp"},
+		TLSClientConfig:  &tls.Config{InsecureSkipVerify: true},
+		HandshakeTimeout: time.Second,
+	}
+	_, _, err = d.Dial("wss://127.0.0.1:9992/xmpp/ws", nil)
+	require.NotNil(t, err)
+	require.Equal(t, uint64(1), srv.connLimiter.Rejected())
+	require.Equal(t, 0, srv.connLimiter.Connections())
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*5))
+	defer cancel()
+	_ = srv.shutdown(ctx)
+}
+
+func TestC2SDirectTLSServer(t *testing.T) {
+	defer os.RemoveAll("./.cert")
+
+	cer, err := utiltls.LoadCertificate("", "", "localhost")
+	require.Nil(t, err)
+
+	hosts, _ := host.New([]host.Config{{Name: "localhost", Certificate: cer}})
+	r, _ := router.New(hosts, c2srouter.New(backend.NewStorage(memorystorage.NewUser()), memorystorage.NewBlockList()), nil)
+
+	errCh := make(chan error)
+	cfg := Config{
+		ID:               "srv-1234",
+		ConnectTimeout:   time.Second * time.Duration(5),
+		Timeout:          time.Second * time.Duration(5),
+		KeepAlive:        time.Second * time.Duration(5),
+		MaxStanzaSize:    8192,
+		ResourceConflict: Reject,
+		Transport: TransportConfig{
+			Type:      transport.Socket,
+			Port:      9997,
+			DirectTLS: true,
+		},
+		SASL: []string{"plain"},
+	}
+	srv := server{
+		cfg:           &cfg,
+		streamCfg:     &cfg,
+		router:        r,
+		mods:          &module.Modules{},
+		comps:         &component.Components{},
+		connLimiter:   connlimit.New(&cfg.Connections),
+		inConnections: make(map[string]stream.C2S),
+	}
+	go srv.start()
+
+	var features xmpp.XElement
+	go func() {
+		time.Sleep(time.Millisecond * 150)
+
+		conn, err := tls.Dial("tcp", "127.0.0.1:9997", &tls.Config{
+			ServerName:         "localhost",
+			NextProtos:         []string{"xmpp-client"},
+			InsecureSkipVerify: true,
+		})
+		if err != nil {
+			errCh <- err
+			return
+		}
+		if conn.ConnectionState().NegotiatedProtocol != "xmpp-client" {
+			errCh <- errors.New("alpn protocol not negotiated")
+			return
+		}
+		open := `<?xml version="1.0"?><stream:stream xmlns="jabber:client" xmlns:stream="http://etherx.jabber.org/streams" to="localhost" version="1.0">`
+		if _, err := conn.Write([]byte(open)); err != nil {
+			errCh <- err
+			return
+		}
+		p := xmpp.NewParser(conn, xmpp.SocketStream, 0)
+		for features == nil || features.Name() != "stream:features" {
+			features, err = p.ParseElement()
+			if err != nil {
+				errCh <- err
+				return
+			}
+		}
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*5))
+		defer cancel()
+
+		_ = srv.shutdown(ctx)
+		errCh <- nil
+	}()
+	err = <-errCh
+	require.Nil(t, err)
+
+	// stream is already secured, so STARTTLS must not be offered
+	require.Equal(t, "stream:features", features.Name())
+	require.Nil(t, features.Elements().Child("starttls"))
+	require.NotNil(t, features.Elements().Child("mechanisms"))
+}
+
+func TestC2SDirectTLSServer_External(t *testing.T) {
+	defer os.RemoveAll("./.cert")
+
+	cer, err := utiltls.LoadCertificate("", "", "localhost")
+	require.Nil(t, err)
+
+	caPEM, clientCer := tUtilClientCertificates(t, "ortuman@localhost")
+	clientCAs := x509.NewCertPool()
+	require.True(t, clientCAs.AppendCertsFromPEM(caPEM))
+
+	userRep := memorystorage.NewUser()
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "ortuman", Password: "1234"})
+
+	hosts, _ := host.New([]host.Config{{Name: "localhost", Certificate: cer}})
+	r, _ := router.New(hosts, c2srouter.New(backend.NewStorage(userRep), memorystorage.NewBlockList()), nil)
+
+	errCh := make(chan error)
+	cfg := Config{
+		ID:               "srv-1234",
+		ConnectTimeout:   time.Second * time.Duration(5),
+		Timeout:          time.Second * time.Duration(5),
+		KeepAlive:        time.Second * time.Duration(5),
+		MaxStanzaSize:    8192,
+		ResourceConflict: Reject,
+		Transport: TransportConfig{
+			Type:      transport.Socket,
+			Port:      9996,
+			DirectTLS: true,
+		},
+		SASL:      []string{"plain", "external"},
+		ClientCAs: clientCAs,
+	}
+	srv := server{
+		cfg:           &cfg,
+		streamCfg:     &cfg,
+		router:        r,
+		authBackend:   backend.NewStorage(userRep),
+		mods:          &module.Modules{},
+		comps:         &component.Components{},
+		connLimiter:   connlimit.New(&cfg.Connections),
+		inConnections: make(map[string]stream.C2S),
+	}
+	go srv.start()
+
+	var features, result xmpp.XElement
+	go func() {
+		time.Sleep(time.Millisecond * 150)
+
+		conn, err := tls.Dial("tcp", "127.0.0.1:9996", &tls.Config{
+			ServerName:         "localhost",
+			Certificates:       []tls.Certificate{clientCer},
+			InsecureSkipVerify: true,
+		})
+		if err != nil {
+			errCh <- err
+			return
+		}
+		open := `<?xml version="1.0"?><stream:stream xmlns="jabber:client" xmlns:stream="http://etherx.jabber.org/streams" to="localhost" version="1.0">`
+		if _, err := conn.Write([]byte(open)); err != nil {
+			errCh <- err
+			return
+		}
+		p := xmpp.NewParser(conn, xmpp.SocketStream, 0)
+		for features == nil || features.Name() != "stream:features" {
+			features, err = p.ParseElement()
+			if err != nil {
+				errCh <- err
+				return
+			}
+		}
+		auth := `<auth xmlns="urn:ietf:params:xml:ns:xmpp-sasl" mechanism="EXTERNAL">=</auth>`
+		if _, err := conn.Write([]byte(auth)); err != nil {
+			errCh <- err
+			return
+		}
+		result, err = p.ParseElement()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*5))
+		defer cancel()
+
+		_ = srv.shutdown(ctx)
+		errCh <- nil
+	}()
+	err = <-errCh
+	require.Nil(t, err)
+
+	mechanisms := features.Elements().Child("mechanisms")
+	require.NotNil(t, mechanisms)
+
+	var hasExternal bool
+	for _, m := range mechanisms.Elements().All() {
+		hasExternal = hasExternal || m.Text() == "EXTERNAL"
+	}
+	require.True(t, hasExternal)
+	require.Equal(t, "success", result.Name())
+}
+
+// tUtilClientCertificates returns a PEM encoded CA certificate along with a client certificate signed by it.
+func tUtilClientCertificates(t *testing.T, commonName string) ([]byte, tls.Certificate) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "jackal test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.Nil(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.Nil(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	require.Nil(t, err)
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+	return caPEM, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestC2SServer_Reload(t *testing.T) {
+	cfg := Config{ID: "c2s1", MaxStanzaSize: 8192, Transport: TransportConfig{Type: transport.Socket, Port: 5222}}
+	srv := server{cfg: &cfg, streamCfg: &cfg}
+
+	ignored := srv.reload(&Config{ID: "c2s1", MaxStanzaSize: 1024, Transport: TransportConfig{Type: transport.Socket, Port: 5222}}, &module.Modules{})
+	require.Len(t, ignored, 0)
+	require.Equal(t, 1024, srv.streamConfig().MaxStanzaSize)
+	require.Equal(t, 8192, srv.cfg.MaxStanzaSize)
+
+	ignored = srv.reload(&Config{ID: "c2s1", Transport: TransportConfig{Type: transport.Socket, Port: 5223}, Connections: connlimit.Config{MaxPerIP: 5}}, &module.Modules{})
+	require.Equal(t, []string{"transport", "connections"}, ignored)
+	mods := srv.acquireModules()
+	require.NotNil(t, mods)
+	mods.Release()
+}
@@ -0,0 +1,344 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	streamerror "github.com/ortuman/jackal/errors"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/session"
+	"github.com/ortuman/jackal/xmpp"
+)
+
+const stanzaErrorNamespace = "urn:ietf:params:xml:ns:xmpp-stanzas"
+
+type smStanza struct {
+	h      uint32
+	stanza xmpp.Stanza
+}
+
+// smState holds stream management (XEP-0198) state of a single stream.
+type smState struct {
+	id           string
+	inbound      uint32
+	outbound     uint32
+	queue        []smStanza
+	ackRequested bool
+}
+
+func (sm *smState) enqueue(stanza xmpp.Stanza) {
+	sm.outbound++
+	sm.queue = append(sm.queue, smStanza{h: sm.outbound, stanza: stanza})
+}
+
+func (sm *smState) ack(h uint32) bool {
+	if int32(h-sm.outbound) > 0 {
+		return false // acknowledging more stanzas than sent
+	}
+	var i int
+	for i < len(sm.queue) && int32(sm.queue[i].h-h) <= 0 {
+		i++
+	}
+	sm.queue = sm.queue[i:]
+	return true
+}
+
+// smRegistry keeps track of resumable stream management sessions.
+type smRegistry struct {
+	mu      sync.Mutex
+	streams map[string]*inStream
+}
+
+func newSMRegistry() *smRegistry {
+	return &smRegistry{streams: make(map[string]*inStream)}
+}
+
+func (r *smRegistry) register(id string, stm *inStream) {
+	r.mu.Lock()
+	r.streams[id] = stm
+	r.mu.Unlock()
+}
+
+// unregister removes a resumable session, reporting whether or not it was still registered.
+func (r *smRegistry) unregister(id string, stm *inStream) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.streams[id] != stm {
+		return false
+	}
+	delete(r.streams, id)
+	return true
+}
+
+// take removes and returns a resumable session, so that it can be claimed only once.
+func (r *smRegistry) take(id string) *inStream {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stm := r.streams[id]
+	delete(r.streams, id)
+	return stm
+}
+
+func (s *inStream) handleStreamManagement(ctx context.Context, elem xmpp.XElement) {
+	switch elem.Name() {
+	case "enable":
+		s.enableSM(ctx, elem)
+
+	case "r":
+		if s.sm == nil {
+			s.writeElement(ctx, smFailedElement("unexpected-request"))
+			return
+		}
+		a := xmpp.NewElementNamespace("a", smNamespace)
+		a.SetAttribute("h", strconv.FormatUint(uint64(s.sm.inbound), 10))
+		s.writeElement(ctx, a)
+
+	case "a":
+		if s.sm == nil {
+			s.writeElement(ctx, smFailedElement("unexpected-request"))
+			return
+		}
+		h, err := strconv.ParseUint(elem.Attributes().Get("h"), 10, 32)
+		if err != nil {
+			s.disconnectWithStreamError(ctx, streamerror.ErrUndefinedCondition)
+			return
+		}
+		if !s.sm.ack(uint32(h)) {
+			tooHigh := xmpp.NewElementNamespace("handled-count-too-high", smNamespace)
+			tooHigh.SetAttribute("h", strconv.FormatUint(h, 10))
+			tooHigh.SetAttribute("send-count", strconv.FormatUint(uint64(s.sm.outbound), 10))
+			s.disconnectWithStreamError(ctx, streamerror.ErrUndefinedCondition.WithApplicationCondition(tooHigh))
+			return
+		}
+		s.sm.ackRequested = false
+		if len(s.sm.queue) > 0 {
+			s.requestAck(ctx)
+		}
+
+	default:
+		s.disconnectWithStreamError(ctx, streamerror.ErrUnsupportedStanzaType)
+	}
+}
+
+func (s *inStream) enableSM(ctx context.Context, elem xmpp.XElement) {
+	if s.sm != nil {
+		s.writeElement(ctx, smFailedElement("unexpected-request"))
+		return
+	}
+	s.writeElement(ctx, s.startSM(elem))
+}
+
+// startSM initializes stream management state returning the corresponding 'enabled' element.
+func (s *inStream) startSM(enable xmpp.XElement) xmpp.XElement {
+	s.sm = &smState{}
+
+	enabled := xmpp.NewElementNamespace("enabled", smNamespace)
+	if resume := enable.Attributes().Get("resume"); (resume == "true" || resume == "1") && s.cfg.smRegistry != nil {
+		s.sm.id = uuid.New().String()
+		s.cfg.smRegistry.register(s.sm.id, s)
+
+		enabled.SetAttribute("id", s.sm.id)
+		enabled.SetAttribute("resume", "true")
+		enabled.SetAttribute("max", strconv.Itoa(int(s.cfg.sm.ResumeTimeout.Seconds())))
+	}
+	log.Infof("enabled stream management... id: %s", s.id)
+	return enabled
+}
+
+func (s *inStream) resumeSM(ctx context.Context, elem xmpp.XElement) {
+	previd := elem.Attributes().Get("previd")
+	h, err := strconv.ParseUint(elem.Attributes().Get("h"), 10, 32)
+	if err != nil || len(previd) == 0 {
+		s.writeElement(ctx, smFailedElement("bad-request"))
+		return
+	}
+	var prev *inStream
+	if s.cfg.smRegistry != nil {
+		prev = s.cfg.smRegistry.take(previd)
+	}
+	if prev == nil {
+		s.writeElement(ctx, smFailedElement("item-not-found"))
+		return
+	}
+	if prev.Username() != s.Username() || prev.Domain() != s.Domain() {
+		s.cfg.smRegistry.register(previd, prev) // not ours... give it back
+		s.writeElement(ctx, smFailedElement("item-not-found"))
+		return
+	}
+	// hand over transport and session to the previous stream
+	var handover int32
+	resumedCh := prev.resume(ctx, s, uint32(h), &handover)
+	select {
+	case ok := <-resumedCh:
+		if !ok {
+			s.writeElement(ctx, smFailedElement("item-not-found"))
+			return
+		}
+	case <-ctx.Done():
+		if atomic.CompareAndSwapInt32(&handover, 0, 1) {
+			s.writeElement(ctx, smFailedElement("item-not-found"))
+			return
+		}
+		<-resumedCh // previous stream already took over the transport
+	}
+	// transport is now owned by the resumed stream
+	s.ctxCancelFn()
+	if s.cfg.onDisconnect != nil {
+		s.cfg.onDisconnect(s)
+	}
+	s.setState(disconnected)
+	s.runQueue.Stop(nil)
+}
+
+// resume takes over 'from' stream transport and session, resending every unacknowledged stanza.
+// Handover is skipped whenever 'handover' flag has been previously set by the caller.
+func (s *inStream) resume(ctx context.Context, from *inStream, h uint32, handover *int32) <-chan bool {
+	resumedCh := make(chan bool, 1)
+	secured := from.IsSecured()
+	compressed := from.isCompressed()
+	tr := from.tr
+	sess := from.sess
+
+	s.runQueue.Run(func() {
+		if s.getState() == disconnected || !atomic.CompareAndSwapInt32(handover, 0, 1) {
+			resumedCh <- false
+			return
+		}
+		switch s.getState() {
+		case detached:
+			if s.resumeTm != nil {
+				s.resumeTm.Stop()
+				s.resumeTm = nil
+			}
+		default:
+			// connection loss not detected yet
+			_ = s.tr.Close()
+		}
+		s.tr = tr
+		s.setRemoteAddr(tr.RemoteAddr())
+		s.setSession(sess)
+		s.sess.SetJID(s.JID())
+		s.setSecured(secured)
+		s.setCompressed(compressed)
+		s.setState(bound)
+
+		s.sm.ack(h)
+		s.sm.ackRequested = false
+		s.cfg.smRegistry.register(s.sm.id, s)
+
+		resumed := xmpp.NewElementNamespace("resumed", smNamespace)
+		resumed.SetAttribute("h", strconv.FormatUint(uint64(s.sm.inbound), 10))
+		resumed.SetAttribute("previd", s.sm.id)
+		s.writeElement(ctx, resumed)
+
+		// resend unacknowledged stanzas
+		for _, st := range s.sm.queue {
+			if err := s.sess.Send(ctx, st.stanza); err != nil {
+				log.Error(err)
+			}
+		}
+		if len(s.sm.queue) > 0 {
+			s.requestAck(ctx)
+		}
+		log.Infof("resumed stream... id: %s", s.id)
+
+		if p := s.mods.Ping; p != nil {
+			p.SchedulePing(s)
+		}
+		go s.doRead() // start reading from resumed transport...
+
+		resumedCh <- true
+	})
+	return resumedCh
+}
+
+func (s *inStream) isResumable() bool {
+	return s.sm != nil && len(s.sm.id) > 0 && s.getState() == bound
+}
+
+// detach keeps the stream bound waiting for a resumption to happen.
+func (s *inStream) detach() {
+	if p := s.mods.Ping; p != nil {
+		p.CancelPing(s)
+	}
+	s.setState(detached)
+	_ = s.tr.Close()
+
+	s.resumeTm = time.AfterFunc(s.cfg.sm.ResumeTimeout, s.resumeTimeout)
+
+	log.Infof("detached stream... id: %s", s.id)
+}
+
+func (s *inStream) resumeTimeout() {
+	s.runQueue.Run(func() {
+		if s.getState() != detached || !s.cfg.smRegistry.unregister(s.sm.id, s) {
+			return // already resumed
+		}
+		log.Infof("stream resumption timed out... id: %s", s.id)
+
+		// nothing gets written to a detached stream... unacked messages are archived asynchronously
+		s.disconnectClosingSession(context.Background(), false, true)
+	})
+}
+
+func (s *inStream) trackOutgoingStanza(ctx context.Context, stanza xmpp.Stanza) bool {
+	s.sm.enqueue(stanza)
+	if len(s.sm.queue) <= s.cfg.sm.MaxQueueSize {
+		return true
+	}
+	if s.getState() == detached {
+		s.disconnectClosingSession(ctx, false, true)
+	} else {
+		s.disconnectWithStreamError(ctx, streamerror.ErrResourceConstraint)
+	}
+	return false
+}
+
+func (s *inStream) requestAck(ctx context.Context) {
+	if s.sm.ackRequested {
+		return
+	}
+	s.sm.ackRequested = true
+	s.writeElement(ctx, xmpp.NewElementNamespace("r", smNamespace))
+}
+
+// archiveUnackedMessages reroutes every unacknowledged message to offline storage.
+func (s *inStream) archiveUnackedMessages(ctx context.Context) {
+	off := s.mods.Offline
+	if off == nil {
+		return
+	}
+	for _, st := range s.sm.queue {
+		if msg, ok := st.stanza.(*xmpp.Message); ok {
+			off.ArchiveMessage(ctx, msg)
+		}
+	}
+	s.sm.queue = nil
+}
+
+func (s *inStream) getSession() *session.Session {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sess
+}
+
+func (s *inStream) setSession(sess *session.Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sess = sess
+}
+
+func smFailedElement(condition string) xmpp.XElement {
+	failed := xmpp.NewElementNamespace("failed", smNamespace)
+	failed.AppendElement(xmpp.NewElementNamespace(condition, stanzaErrorNamespace))
+	return failed
+}
@@ -0,0 +1,235 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/auth/backend"
+	"github.com/ortuman/jackal/component"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/module/offline"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/storage/repository"
+	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream_SMEnableAndAck(t *testing.T) {
+	r, userRep, blockListRep := setupTest("localhost")
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Password: "pencil"})
+
+	cfg := tUtilSMStreamConfig(newSMRegistry(), time.Minute)
+	stm, conn := tUtilSMStreamInit(cfg, tUtilInitModules(r), r, userRep, blockListRep)
+
+	features := tUtilSMStreamBind(conn, t)
+	require.NotNil(t, features.Elements().ChildNamespace("sm", smNamespace))
+
+	// enable without resumption
+	_, _ = conn.inboundWrite([]byte(`<enable xmlns="urn:xmpp:sm:3"/>`))
+	elem := conn.outboundRead()
+	require.Equal(t, "enabled", elem.Name())
+	require.Equal(t, "", elem.Attributes().Get("id"))
+
+	// enable twice
+	_, _ = conn.inboundWrite([]byte(`<enable xmlns="urn:xmpp:sm:3"/>`))
+	elem = conn.outboundRead()
+	require.Equal(t, "failed", elem.Name())
+	require.NotNil(t, elem.Elements().Child("unexpected-request"))
+
+	_, _ = conn.inboundWrite([]byte(`<r xmlns="urn:xmpp:sm:3"/>`))
+	elem = conn.outboundRead()
+	require.Equal(t, "a", elem.Name())
+	require.Equal(t, "0", elem.Attributes().Get("h"))
+
+	// handled inbound stanza
+	_, _ = conn.inboundWrite([]byte(`<iq type="set" id="sess_1"><session xmlns="urn:ietf:params:xml:ns:xmpp-session"/></iq>`))
+	elem = conn.outboundRead()
+	require.Equal(t, "iq", elem.Name())
+	elem = conn.outboundRead()
+	require.Equal(t, "r", elem.Name())
+
+	_, _ = conn.inboundWrite([]byte(`<r xmlns="urn:xmpp:sm:3"/>`))
+	elem = conn.outboundRead()
+	require.Equal(t, "a", elem.Name())
+	require.Equal(t, "1", elem.Attributes().Get("h"))
+
+	// acknowledge outgoing stanza
+	_, _ = conn.inboundWrite([]byte(`<a xmlns="urn:xmpp:sm:3" h="1"/>`))
+	time.Sleep(time.Millisecond * 100)
+
+	done := make(chan struct{})
+	stm.runQueue.Run(func() {
+		require.Len(t, stm.sm.queue, 0)
+		require.Equal(t, uint32(1), stm.sm.outbound)
+		close(done)
+	})
+	<-done
+
+	// acknowledging unsent stanzas
+	_, _ = conn.inboundWrite([]byte(`<a xmlns="urn:xmpp:sm:3" h="5"/>`))
+	require.True(t, conn.waitClose())
+	require.Equal(t, disconnected, stm.getState())
+
+	written := conn.outboundWritten()
+	require.Contains(t, written, `<undefined-condition xmlns="urn:ietf:params:xml:ns:xmpp-streams"/>`)
+	require.Contains(t, written, `<handled-count-too-high xmlns="urn:xmpp:sm:3" h="5" send-count="1"/>`)
+}
+
+func TestStream_SMResume(t *testing.T) {
+	r, userRep, blockListRep := setupTest("localhost")
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Password: "pencil"})
+
+	smReg := newSMRegistry()
+	cfg := tUtilSMStreamConfig(smReg, time.Minute)
+	mods := tUtilInitModules(r)
+
+	stm, conn := tUtilSMStreamInit(cfg, mods, r, userRep, blockListRep)
+	_ = tUtilSMStreamBind(conn, t)
+
+	_, _ = conn.inboundWrite([]byte(`<enable xmlns="urn:xmpp:sm:3" resume="true"/>`))
+	elem := conn.outboundRead()
+	require.Equal(t, "enabled", elem.Name())
+	require.Equal(t, "true", elem.Attributes().Get("resume"))
+	smID := elem.Attributes().Get("id")
+	require.True(t, len(smID) > 0)
+
+	// connection loss
+	_ = conn.Close()
+	time.Sleep(time.Millisecond * 100)
+	require.Equal(t, detached, stm.getState())
+
+	// stanzas are queued while detached
+	j, _ := jid.New("user", "localhost", "balcony", true)
+	require.Equal(t, stm, r.LocalStream("user", "balcony"))
+
+	msg := xmpp.NewMessageType("msg_1", xmpp.ChatType)
+	msg.SetFromJID(j)
+	msg.SetToJID(j)
+	stm.SendElement(context.Background(), msg)
+
+	// unknown session
+	stm2, conn2 := tUtilSMStreamInit(cfg, mods, r, userRep, blockListRep)
+	tUtilSMStreamAuthenticate(conn2, t)
+
+	_, _ = conn2.inboundWrite([]byte(`<resume xmlns="urn:xmpp:sm:3" h="0" previd="foo"/>`))
+	elem = conn2.outboundRead()
+	require.Equal(t, "failed", elem.Name())
+	require.NotNil(t, elem.Elements().Child("item-not-found"))
+
+	// resume session
+	_, _ = conn2.inboundWrite([]byte(`<resume xmlns="urn:xmpp:sm:3" h="0" previd="` + smID + `"/>`))
+	elem = conn2.outboundRead()
+	require.Equal(t, "resumed", elem.Name())
+	require.Equal(t, smID, elem.Attributes().Get("previd"))
+	require.Equal(t, "0", elem.Attributes().Get("h"))
+
+	elem = conn2.outboundRead()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, "msg_1", elem.ID())
+
+	elem = conn2.outboundRead()
+	require.Equal(t, "r", elem.Name())
+
+	require.Equal(t, bound, stm.getState())
+	require.Equal(t, disconnected, stm2.getState())
+	require.Equal(t, stm, r.LocalStream("user", "balcony"))
+
+	// resumed stream keeps processing elements
+	_, _ = conn2.inboundWrite([]byte(`<r xmlns="urn:xmpp:sm:3"/>`))
+	elem = conn2.outboundRead()
+	require.Equal(t, "a", elem.Name())
+	require.Equal(t, "0", elem.Attributes().Get("h"))
+}
+
+func TestStream_SMResumptionTimeout(t *testing.T) {
+	r, userRep, blockListRep := setupTest("localhost")
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Password: "pencil"})
+
+	repContainer, _ := storage.New(&storage.Config{Type: storage.Memory})
+	mods := module.New(&module.Config{
+		Enabled: map[string]struct{}{"offline": {}},
+		Offline: offline.Config{QueueSize: 10},
+	}, r, repContainer, backend.NewStorage(repContainer.User()), "alloc-1234")
+
+	smReg := newSMRegistry()
+	stm, conn := tUtilSMStreamInit(tUtilSMStreamConfig(smReg, time.Millisecond*250), mods, r, userRep, blockListRep)
+	_ = tUtilSMStreamBind(conn, t)
+
+	_, _ = conn.inboundWrite([]byte(`<enable xmlns="urn:xmpp:sm:3" resume="true"/>`))
+	elem := conn.outboundRead()
+	require.Equal(t, "enabled", elem.Name())
+	smID := elem.Attributes().Get("id")
+
+	_ = conn.Close()
+	time.Sleep(time.Millisecond * 100)
+	require.Equal(t, detached, stm.getState())
+
+	j, _ := jid.New("user", "localhost", "balcony", true)
+	msg := xmpp.NewMessageType("msg_1", xmpp.ChatType)
+	msg.SetFromJID(j)
+	msg.SetToJID(j)
+	body := xmpp.NewElementName("body")
+	body.SetText("Hi!")
+	msg.AppendElement(body)
+	stm.SendElement(context.Background(), msg)
+
+	time.Sleep(time.Millisecond * 500)
+
+	require.Equal(t, disconnected, stm.getState())
+	require.Nil(t, r.LocalStream("user", "balcony"))
+	require.Nil(t, smReg.take(smID))
+
+	cnt, err := repContainer.Offline().CountOfflineMessages(context.Background(), "user")
+	require.Nil(t, err)
+	require.Equal(t, 1, cnt)
+}
+
+func tUtilSMStreamAuthenticate(conn *fakeSocketConn, t *testing.T) xmpp.XElement {
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	tUtilStreamAuthenticate(conn, t)
+
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	return conn.outboundRead()
+}
+
+func tUtilSMStreamBind(conn *fakeSocketConn, t *testing.T) xmpp.XElement {
+	features := tUtilSMStreamAuthenticate(conn, t)
+
+	// enable before binding is not allowed...
+	_, _ = conn.inboundWrite([]byte(`<enable xmlns="urn:xmpp:sm:3"/>`))
+	elem := conn.outboundRead()
+	require.Equal(t, "failed", elem.Name())
+
+	tUtilStreamBind(conn, t)
+	return features
+}
+
+func tUtilSMStreamInit(cfg *streamConfig, mods *module.Modules, r router.Router, userRep repository.User, blockListRep repository.BlockList) (*inStream, *fakeSocketConn) {
+	conn := newFakeSocketConn()
+	tr := transport.NewSocketTransport(conn)
+	stm := newStream("abcd1234", cfg, tr, mods, &component.Components{}, r, backend.NewStorage(userRep), blockListRep, nil, nil)
+	return stm.(*inStream), conn
+}
+
+func tUtilSMStreamConfig(smReg *smRegistry, resumeTimeout time.Duration) *streamConfig {
+	cfg := tUtilInStreamDefaultConfig()
+	cfg.keepAlive = time.Second * 5
+	cfg.timeout = time.Second
+	cfg.sm = StreamManagementConfig{Enabled: true, ResumeTimeout: resumeTimeout, MaxQueueSize: 10}
+	cfg.smRegistry = smReg
+	return cfg
+}
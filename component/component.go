@@ -9,8 +9,13 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/ortuman/jackal/component/external"
+	"github.com/ortuman/jackal/component/httpupload"
+	"github.com/ortuman/jackal/component/muc"
 	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage/repository"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/xmpp"
 )
@@ -28,11 +33,11 @@ type Components struct {
 }
 
 // New returns a set of components derived from a concrete configuration.
-func New(config *Config, discoInfo *xep0030.DiscoInfo) *Components {
+func New(config *Config, discoInfo *xep0030.DiscoInfo, router router.Router, reps repository.Container) *Components {
 	comps := &Components{
 		comps: make(map[string]Component),
 	}
-	cs, shutdownChs := loadComponents(config, discoInfo)
+	cs, shutdownChs := loadComponents(config, discoInfo, router, reps)
 	for _, c := range cs {
 		host := c.Host()
 		if _, ok := comps.comps[host]; ok {
@@ -83,16 +88,26 @@ func (cs *Components) shutdown() <-chan bool {
 	return c
 }
 
-func loadComponents(_ *Config, _ *xep0030.DiscoInfo) ([]Component, []chan<- chan bool) {
+func loadComponents(cfg *Config, discoInfo *xep0030.DiscoInfo, router router.Router, reps repository.Container) ([]Component, []chan<- chan bool) {
 	var comps []Component
 	var shutdownChs []chan<- chan bool
-	/*
-		discoInfo := module.Modules().DiscoInfo
-		if cfg.HttpUpload != nil {
-			comp, shutdownCh := httpupload.New(cfg.HttpUpload, discoInfo)
+
+	if cfg.HTTPUpload != nil {
+		comp, shutdownCh := httpupload.New(cfg.HTTPUpload, discoInfo)
+		comps = append(comps, comp)
+		shutdownChs = append(shutdownChs, shutdownCh)
+	}
+	if cfg.Muc != nil {
+		comp, shutdownCh := muc.New(cfg.Muc, discoInfo, router, reps.Muc())
+		comps = append(comps, comp)
+		shutdownChs = append(shutdownChs, shutdownCh)
+	}
+	if cfg.External != nil {
+		ext, shutdownCh := external.New(cfg.External, discoInfo, router)
+		for _, comp := range ext.Components() {
 			comps = append(comps, comp)
-			shutdownChs = append(shutdownChs, shutdownCh)
 		}
-	*/
+		shutdownChs = append(shutdownChs, shutdownCh)
+	}
 	return comps, shutdownChs
 }
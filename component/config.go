@@ -5,7 +5,15 @@
 
 package component
 
+import (
+	"github.com/ortuman/jackal/component/external"
+	"github.com/ortuman/jackal/component/httpupload"
+	"github.com/ortuman/jackal/component/muc"
+)
+
 // Config contains all components configuration.
 type Config struct {
-	// HttpUpload *httpupload.Config `yaml:"http_upload"`
+	HTTPUpload *httpupload.Config `yaml:"http_upload"`
+	Muc        *muc.Config        `yaml:"muc"`
+	External   *external.Config   `yaml:"external"`
 }
@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package external
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultBindAddr       = "0.0.0.0"
+	defaultPort           = 5275
+	defaultConnectTimeout = time.Duration(5) * time.Second
+	defaultKeepAlive      = time.Duration(10) * time.Minute
+	defaultTimeout        = time.Duration(20) * time.Second
+	defaultMaxStanzaSize  = 131072
+)
+
+// HostConfig represents an external component host configuration.
+type HostConfig struct {
+	Name   string `yaml:"name"`
+	Secret string `yaml:"secret"`
+}
+
+// Config represents external components (XEP-0114) listener configuration.
+type Config struct {
+	BindAddr       string
+	Port           int
+	ConnectTimeout time.Duration
+	KeepAlive      time.Duration
+	Timeout        time.Duration
+	MaxStanzaSize  int
+	Hosts          []HostConfig
+}
+
+type configProxy struct {
+	BindAddr       string       `yaml:"bind_addr"`
+	Port           int          `yaml:"port"`
+	ConnectTimeout int          `yaml:"connect_timeout"`
+	KeepAlive      int          `yaml:"keep_alive"`
+	Timeout        int          `yaml:"timeout"`
+	MaxStanzaSize  int          `yaml:"max_stanza_size"`
+	Hosts          []HostConfig `yaml:"hosts"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if len(p.Hosts) == 0 {
+		return errors.New("external.Config: at least one component host must be specified")
+	}
+	names := make(map[string]struct{}, len(p.Hosts))
+	for _, h := range p.Hosts {
+		if len(h.Name) == 0 {
+			return errors.New("external.Config: host name value must be set")
+		}
+		if len(h.Secret) == 0 {
+			return fmt.Errorf("external.Config: must specify a secret for host %s", h.Name)
+		}
+		if _, ok := names[h.Name]; ok {
+			return fmt.Errorf("external.Config: duplicated host %s", h.Name)
+		}
+		names[h.Name] = struct{}{}
+	}
+	cfg.Hosts = p.Hosts
+
+	cfg.BindAddr = p.BindAddr
+	if len(cfg.BindAddr) == 0 {
+		cfg.BindAddr = defaultBindAddr
+	}
+	cfg.Port = p.Port
+	if cfg.Port == 0 {
+		cfg.Port = defaultPort
+	}
+	cfg.ConnectTimeout = time.Duration(p.ConnectTimeout) * time.Second
+	if cfg.ConnectTimeout == 0 {
+		cfg.ConnectTimeout = defaultConnectTimeout
+	}
+	cfg.KeepAlive = time.Duration(p.KeepAlive) * time.Second
+	if cfg.KeepAlive == 0 {
+		cfg.KeepAlive = defaultKeepAlive
+	}
+	cfg.Timeout = time.Duration(p.Timeout) * time.Second
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	cfg.MaxStanzaSize = p.MaxStanzaSize
+	if cfg.MaxStanzaSize == 0 {
+		cfg.MaxStanzaSize = defaultMaxStanzaSize
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package external
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig(t *testing.T) {
+	var cfg Config
+
+	err := yaml.Unmarshal([]byte(`port: 5275`), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("hosts:\n  - name: gateway.jackal.im"), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("hosts:\n  - name: gateway.jackal.im\n    secret: s3cr3t\n  - name: gateway.jackal.im\n    secret: s3cr3t"), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("hosts:\n  - name: gateway.jackal.im\n    secret: s3cr3t"), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, defaultBindAddr, cfg.BindAddr)
+	require.Equal(t, defaultPort, cfg.Port)
+	require.Equal(t, defaultKeepAlive, cfg.KeepAlive)
+	require.Equal(t, defaultMaxStanzaSize, cfg.MaxStanzaSize)
+	require.Equal(t, []HostConfig{{Name: "gateway.jackal.im", Secret: "s3cr3t"}}, cfg.Hosts)
+}
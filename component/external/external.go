@@ -0,0 +1,211 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package external
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	streamerror "github.com/ortuman/jackal/errors"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/xmpp"
+)
+
+// Component represents an external component (XEP-0114) host.
+type Component struct {
+	host   string
+	secret string
+
+	mu  sync.RWMutex
+	stm *inStream
+}
+
+// Host returns external component host domain.
+func (c *Component) Host() string {
+	return c.host
+}
+
+// ProcessStanza forwards a stanza to the connected external component.
+func (c *Component) ProcessStanza(ctx context.Context, stanza xmpp.Stanza, stm stream.C2S) {
+	if err := c.route(ctx, stanza); err != nil && stm != nil {
+		if stanza.Type() == xmpp.ErrorType || stanza.Name() == xmpp.PresenceName {
+			return
+		}
+		stm.SendElement(ctx, xmpp.NewErrorStanzaFromStanza(stanza, xmpp.ErrServiceUnavailable, nil))
+	}
+}
+
+func (c *Component) route(ctx context.Context, stanza xmpp.Stanza) error {
+	c.mu.RLock()
+	stm := c.stm
+	c.mu.RUnlock()
+	if stm == nil {
+		return router.ErrComponentNotConnected
+	}
+	stm.sendElement(ctx, stanza)
+	return nil
+}
+
+// attach binds an authenticated stream to the component, reporting whether no other stream was already bound.
+func (c *Component) attach(stm *inStream) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stm != nil {
+		return false
+	}
+	c.stm = stm
+	return true
+}
+
+func (c *Component) detach(stm *inStream) {
+	c.mu.Lock()
+	if c.stm == stm {
+		c.stm = nil
+	}
+	c.mu.Unlock()
+}
+
+// External represents a jabber:component:accept listener.
+type External struct {
+	cfg       *Config
+	disco     *xep0030.DiscoInfo
+	router    router.Router
+	comps     map[string]*Component
+	hosts     []string
+	ln        net.Listener
+	listening uint32
+
+	mu        sync.RWMutex
+	inStreams map[string]*inStream
+}
+
+// New returns a new external components listener instance.
+func New(cfg *Config, disco *xep0030.DiscoInfo, router router.Router) (*External, chan<- chan bool) {
+	x := &External{
+		cfg:       cfg,
+		disco:     disco,
+		router:    router,
+		comps:     make(map[string]*Component),
+		inStreams: make(map[string]*inStream),
+	}
+	for _, h := range cfg.Hosts {
+		if router.Hosts().IsLocalHost(h.Name) {
+			log.Fatalf("external: component host %s conflicts with a local domain", h.Name)
+		}
+		x.comps[h.Name] = &Component{host: h.Name, secret: h.Secret}
+		x.hosts = append(x.hosts, h.Name)
+	}
+	if disco != nil {
+		for _, host := range x.hosts {
+			disco.RegisterServerItem(xep0030.Item{Jid: host})
+		}
+	}
+	if err := x.listen(); err != nil {
+		log.Fatalf("external: %v", err)
+	}
+	router.SetComponentRouter(x)
+
+	shutdownCh := make(chan chan bool)
+	go func() {
+		wc := <-shutdownCh
+		x.shutdown()
+		wc <- true
+	}()
+	return x, shutdownCh
+}
+
+// Components returns all configured external components.
+func (x *External) Components() []*Component {
+	var comps []*Component
+	for _, host := range x.hosts {
+		comps = append(comps, x.comps[host])
+	}
+	return comps
+}
+
+// IsComponentHost satisfies router.ComponentRouter interface.
+func (x *External) IsComponentHost(domain string) bool {
+	return x.comps[domain] != nil
+}
+
+// Route satisfies router.ComponentRouter interface.
+func (x *External) Route(ctx context.Context, stanza xmpp.Stanza) error {
+	comp := x.comps[stanza.ToJID().Domain()]
+	if comp == nil {
+		return router.ErrComponentNotConnected
+	}
+	return comp.route(ctx, stanza)
+}
+
+func (x *External) listen() error {
+	ln, err := net.Listen("tcp", fmt.Sprintf("%s:%d", x.cfg.BindAddr, x.cfg.Port))
+	if err != nil {
+		return err
+	}
+	x.ln = ln
+	atomic.StoreUint32(&x.listening, 1)
+
+	go func() {
+		for atomic.LoadUint32(&x.listening) == 1 {
+			conn, err := ln.Accept()
+			if err != nil {
+				continue
+			}
+			x.registerInStream(newInStream(x, transport.NewSocketTransport(conn)))
+		}
+	}()
+	log.Infof("external: listening at %s", ln.Addr())
+	return nil
+}
+
+func (x *External) registerInStream(stm *inStream) {
+	x.mu.Lock()
+	x.inStreams[stm.id] = stm
+	x.mu.Unlock()
+
+	log.Infof("registered external component stream... (id: %s)", stm.id)
+}
+
+func (x *External) unregisterInStream(stm *inStream) {
+	x.mu.Lock()
+	delete(x.inStreams, stm.id)
+	x.mu.Unlock()
+
+	log.Infof("unregistered external component stream... (id: %s)", stm.id)
+}
+
+func (x *External) shutdown() {
+	x.router.SetComponentRouter(nil)
+	if x.disco != nil {
+		for _, host := range x.hosts {
+			x.disco.UnregisterServerItem(xep0030.Item{Jid: host})
+		}
+	}
+	if atomic.CompareAndSwapUint32(&x.listening, 1, 0) {
+		if err := x.ln.Close(); err != nil {
+			log.Error(err)
+		}
+	}
+	x.mu.RLock()
+	var stms []*inStream
+	for _, stm := range x.inStreams {
+		stms = append(stms, stm)
+	}
+	x.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), x.cfg.Timeout)
+	defer cancel()
+	for _, stm := range stms {
+		stm.Disconnect(ctx, streamerror.ErrSystemShutdown)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package external
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/auth/backend"
+	c2srouter "github.com/ortuman/jackal/c2s/router"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/router/host"
+	memorystorage "github.com/ortuman/jackal/storage/memory"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExternal_Handshake(t *testing.T) {
+	r := setupTest()
+
+	x, shutdownCh := New(tUtilConfig(), nil, r)
+	defer tUtilShutdown(shutdownCh)
+
+	// unknown host
+	conn, pr, _ := tUtilOpenStream(t, x, "unknown.jackal.im")
+	elem, err := pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "stream:error", elem.Name())
+	require.NotNil(t, elem.Elements().Child("host-unknown"))
+	_ = conn.Close()
+
+	// invalid secret
+	conn, pr, _ = tUtilOpenStream(t, x, "gateway.jackal.im")
+	_, _ = fmt.Fprintf(conn, "<handshake>%s</handshake>", handshakeDigest("", "s3cr3t"))
+	elem, err = pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "stream:error", elem.Name())
+	require.NotNil(t, elem.Elements().Child("not-authorized"))
+	_ = conn.Close()
+
+	conn = tUtilConnect(t, x, "gateway.jackal.im", "s3cr3t")
+	defer func() { _ = conn.Close() }()
+
+	// only one connection per component host
+	conn2, pr2, streamID := tUtilOpenStream(t, x, "gateway.jackal.im")
+	_, _ = fmt.Fprintf(conn2, "<handshake>%s</handshake>", handshakeDigest(streamID, "s3cr3t"))
+	elem, err = pr2.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "stream:error", elem.Name())
+	require.NotNil(t, elem.Elements().Child("conflict"))
+	_ = conn2.Close()
+}
+
+func TestExternal_Routing(t *testing.T) {
+	r := setupTest()
+
+	x, shutdownCh := New(tUtilConfig(), nil, r)
+	defer tUtilShutdown(shutdownCh)
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	compJID, _ := jid.New("", "gateway.jackal.im", "", true)
+	comp := x.Components()[0]
+
+	// component not connected yet
+	iq := xmpp.NewIQType(uuid.New(), xmpp.GetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(compJID)
+	comp.ProcessStanza(context.Background(), iq, stm)
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrorType, elem.Type())
+	require.Equal(t, xmpp.ErrServiceUnavailable.Error(), elem.Error().Elements().All()[0].Name())
+
+	conn := tUtilConnect(t, x, "gateway.jackal.im", "s3cr3t")
+	defer func() { _ = conn.Close() }()
+	pr := xmpp.NewParser(conn, xmpp.SocketStream, 0)
+
+	// router -> component
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg.SetFromJID(j)
+	msg.SetToJID(compJID)
+	msg.AppendElement(xmpp.NewElementName("body").SetText("hi"))
+	require.Nil(t, r.Route(context.Background(), msg))
+
+	elem, err := pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, xmpp.MessageName, elem.Name())
+	require.Equal(t, msg.ID(), elem.ID())
+	require.Equal(t, "hi", elem.Elements().Child("body").Text())
+
+	// component -> router
+	_, _ = fmt.Fprintf(conn, `<message id="m1" type="chat" from="bot@gateway.jackal.im" to="ortuman@jackal.im/balcony"><body>hello</body></message>`)
+	elem = stm.ReceiveElement()
+	require.Equal(t, "m1", elem.ID())
+	require.Equal(t, "bot@gateway.jackal.im", elem.From())
+
+	// spoofed 'from' address
+	_, _ = fmt.Fprintf(conn, `<message id="m2" from="noelia@jackal.im" to="ortuman@jackal.im/balcony"/>`)
+	elem, err = pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "stream:error", elem.Name())
+	require.NotNil(t, elem.Elements().Child("invalid-from"))
+}
+
+func tUtilConnect(t *testing.T, x *External, host, secret string) net.Conn {
+	conn, pr, streamID := tUtilOpenStream(t, x, host)
+	_, _ = fmt.Fprintf(conn, "<handshake>%s</handshake>", handshakeDigest(streamID, secret))
+
+	elem, err := pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "handshake", elem.Name())
+	return conn
+}
+
+func tUtilOpenStream(t *testing.T, x *External, host string) (net.Conn, *xmpp.Parser, string) {
+	conn, err := net.Dial("tcp", x.ln.Addr().String())
+	require.Nil(t, err)
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	_, _ = fmt.Fprintf(conn, `<?xml version="1.0"?><stream:stream xmlns="jabber:component:accept" xmlns:stream="http://etherx.jabber.org/streams" to="%s">`, host)
+
+	pr := xmpp.NewParser(conn, xmpp.SocketStream, 0)
+	_, _ = pr.ParseElement() // read xml header
+	elem, err := pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "stream:stream", elem.Name())
+	return conn, pr, elem.ID()
+}
+
+func tUtilConfig() *Config {
+	return &Config{
+		BindAddr:      "127.0.0.1",
+		KeepAlive:     time.Minute,
+		Timeout:       time.Second,
+		MaxStanzaSize: defaultMaxStanzaSize,
+		Hosts:         []HostConfig{{Name: "gateway.jackal.im", Secret: "s3cr3t"}},
+	}
+}
+
+func tUtilShutdown(shutdownCh chan<- chan bool) {
+	wc := make(chan bool, 1)
+	shutdownCh <- wc
+	<-wc
+}
+
+func setupTest() router.Router {
+	hosts, _ := host.New([]host.Config{{Name: "jackal.im", Certificate: tls.Certificate{}}})
+
+	r, _ := router.New(
+		hosts,
+		c2srouter.New(backend.NewStorage(memorystorage.NewUser()), memorystorage.NewBlockList()),
+		nil,
+	)
+	return r
+}
@@ -0,0 +1,326 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package external
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	streamerror "github.com/ortuman/jackal/errors"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/session"
+	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/util/runqueue"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+const (
+	inConnecting uint32 = iota
+	inHandshaking
+	inConnected
+	inDisconnected
+)
+
+type inStream struct {
+	id            string
+	x             *External
+	tr            transport.Transport
+	sess          *session.Session
+	comp          *Component
+	state         uint32
+	mu            sync.RWMutex
+	connectTm     *time.Timer
+	readTimeoutTm *time.Timer
+	runQueue      *runqueue.RunQueue
+}
+
+func newInStream(x *External, tr transport.Transport) *inStream {
+	id := nextInID()
+	s := &inStream{
+		id:       id,
+		x:        x,
+		tr:       tr,
+		runQueue: runqueue.New(id),
+	}
+	j, _ := jid.New("", x.router.Hosts().DefaultHostName(), "", true)
+	s.sess = session.New(id, &session.Config{
+		JID:           j,
+		MaxStanzaSize: x.cfg.MaxStanzaSize,
+		IsComponent:   true,
+	}, tr, x.router.Hosts())
+
+	if x.cfg.ConnectTimeout > 0 {
+		s.connectTm = time.AfterFunc(x.cfg.ConnectTimeout, s.connectTimeout)
+	}
+	go s.doRead() // start reading transport...
+	return s
+}
+
+func (s *inStream) Disconnect(ctx context.Context, err error) {
+	if s.getState() == inDisconnected {
+		return
+	}
+	waitCh := make(chan struct{})
+	s.runQueue.Run(func() {
+		s.disconnect(ctx, err)
+		close(waitCh)
+	})
+	<-waitCh
+}
+
+func (s *inStream) sendElement(ctx context.Context, elem xmpp.XElement) {
+	s.runQueue.Run(func() {
+		if s.getState() != inConnected {
+			return
+		}
+		s.writeElement(ctx, elem)
+	})
+}
+
+func (s *inStream) connectTimeout() {
+	s.runQueue.Run(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), s.x.cfg.Timeout)
+		defer cancel()
+		s.disconnect(ctx, streamerror.ErrConnectionTimeout)
+	})
+}
+
+// runs on its own goroutine
+func (s *inStream) doRead() {
+	s.scheduleReadTimeout()
+	elem, sErr := s.sess.Receive()
+	s.cancelReadTimeout()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.x.cfg.Timeout)
+	if sErr == nil {
+		s.runQueue.Run(func() {
+			defer cancel()
+			s.readElement(ctx, elem)
+		})
+	} else {
+		s.runQueue.Run(func() {
+			defer cancel()
+			if s.getState() == inDisconnected {
+				return // already disconnected...
+			}
+			s.handleSessionError(ctx, sErr)
+		})
+	}
+}
+
+func (s *inStream) handleElement(ctx context.Context, elem xmpp.XElement) {
+	switch s.getState() {
+	case inConnecting:
+		s.handleConnecting(ctx, elem)
+	case inHandshaking:
+		s.handleHandshaking(ctx, elem)
+	case inConnected:
+		s.handleConnected(ctx, elem)
+	}
+}
+
+func (s *inStream) handleConnecting(ctx context.Context, elem xmpp.XElement) {
+	comp := s.x.comps[elem.To()]
+	if comp == nil {
+		s.disconnectWithStreamError(ctx, streamerror.ErrHostUnknown)
+		return
+	}
+	s.comp = comp
+
+	j, _ := jid.New("", comp.host, "", true)
+	s.sess.SetJID(j)
+	s.sess.SetRemoteDomain(comp.host)
+
+	_ = s.sess.Open(ctx, nil)
+	s.setState(inHandshaking)
+}
+
+func (s *inStream) handleHandshaking(ctx context.Context, elem xmpp.XElement) {
+	if elem.Name() != "handshake" {
+		s.disconnectWithStreamError(ctx, streamerror.ErrNotAuthorized)
+		return
+	}
+	expected := handshakeDigest(s.sess.StreamID(), s.comp.secret)
+	digest := strings.ToLower(strings.TrimSpace(elem.Text()))
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(digest)) != 1 {
+		log.Warnf("external: handshake failed for %s... (id: %s)", s.comp.host, s.id)
+		s.disconnectWithStreamError(ctx, streamerror.ErrNotAuthorized)
+		return
+	}
+	if !s.comp.attach(s) {
+		s.disconnectWithStreamError(ctx, streamerror.ErrConflict)
+		return
+	}
+	// cancel connection timeout timer
+	if s.connectTm != nil {
+		s.connectTm.Stop()
+		s.connectTm = nil
+	}
+	s.setState(inConnected)
+	s.writeElement(ctx, xmpp.NewElementName("handshake"))
+
+	log.Infof("external: component %s connected... (id: %s)", s.comp.host, s.id)
+}
+
+func (s *inStream) handleConnected(ctx context.Context, elem xmpp.XElement) {
+	stanza, ok := elem.(xmpp.Stanza)
+	if !ok {
+		s.disconnectWithStreamError(ctx, streamerror.ErrUnsupportedStanzaType)
+		return
+	}
+	switch s.x.router.Route(ctx, stanza) {
+	case nil:
+		break
+	case router.ErrResourceNotFound:
+		// treat the message as if it were addressed to <node@domain>
+		if msg, ok := stanza.(*xmpp.Message); ok {
+			msg, _ = xmpp.NewMessageFromElement(msg, msg.FromJID(), msg.ToJID().ToBareJID())
+			_ = s.x.router.Route(ctx, msg)
+			return
+		}
+		s.writeServiceUnavailable(ctx, stanza)
+	case router.ErrFailedRemoteConnect:
+		if iq, ok := stanza.(*xmpp.IQ); ok && (iq.IsGet() || iq.IsSet()) {
+			s.writeElement(ctx, iq.RemoteServerNotFoundError())
+		}
+	default:
+		s.writeServiceUnavailable(ctx, stanza)
+	}
+}
+
+func (s *inStream) writeServiceUnavailable(ctx context.Context, stanza xmpp.Stanza) {
+	if iq, ok := stanza.(*xmpp.IQ); ok && (iq.IsGet() || iq.IsSet()) {
+		s.writeElement(ctx, iq.ServiceUnavailableError())
+	}
+}
+
+func (s *inStream) writeStanzaErrorResponse(ctx context.Context, elem xmpp.XElement, stanzaErr *xmpp.StanzaError) {
+	resp := xmpp.NewElementFromElement(elem)
+	resp.SetType(xmpp.ErrorType)
+	resp.SetFrom(elem.To())
+	resp.SetTo(elem.From())
+	resp.AppendElement(stanzaErr.Element())
+	s.writeElement(ctx, resp)
+}
+
+func (s *inStream) writeElement(ctx context.Context, elem xmpp.XElement) {
+	if err := s.sess.Send(ctx, elem); err != nil {
+		log.Error(err)
+	}
+}
+
+func (s *inStream) readElement(ctx context.Context, elem xmpp.XElement) {
+	if elem != nil {
+		s.handleElement(ctx, elem)
+	}
+	if s.getState() != inDisconnected {
+		go s.doRead()
+	}
+}
+
+func (s *inStream) handleSessionError(ctx context.Context, sErr *session.Error) {
+	switch err := sErr.UnderlyingErr.(type) {
+	case nil:
+		s.disconnect(ctx, nil)
+	case *streamerror.Error:
+		s.disconnectWithStreamError(ctx, err)
+	case *xmpp.StanzaError:
+		s.writeStanzaErrorResponse(ctx, sErr.Element, err)
+	default:
+		log.Error(err)
+		s.disconnectWithStreamError(ctx, streamerror.ErrUndefinedCondition)
+	}
+}
+
+func (s *inStream) disconnect(ctx context.Context, err error) {
+	if s.getState() == inDisconnected {
+		return
+	}
+	switch err {
+	case nil:
+		s.disconnectClosingSession(ctx, false)
+	default:
+		if stmErr, ok := err.(*streamerror.Error); ok {
+			s.disconnectWithStreamError(ctx, stmErr)
+		} else {
+			log.Error(err)
+			s.disconnectClosingSession(ctx, false)
+		}
+	}
+}
+
+func (s *inStream) disconnectWithStreamError(ctx context.Context, err *streamerror.Error) {
+	if s.getState() == inConnecting {
+		_ = s.sess.Open(ctx, nil)
+	}
+	s.writeElement(ctx, err.Element())
+	s.disconnectClosingSession(ctx, true)
+}
+
+func (s *inStream) disconnectClosingSession(ctx context.Context, closeSession bool) {
+	if closeSession {
+		_ = s.sess.Close(ctx)
+	}
+	if s.comp != nil {
+		s.comp.detach(s)
+	}
+	s.x.unregisterInStream(s)
+
+	s.setState(inDisconnected)
+	_ = s.tr.Close()
+
+	s.runQueue.Stop(nil) // stop processing messages
+}
+
+func (s *inStream) scheduleReadTimeout() {
+	s.mu.Lock()
+	s.readTimeoutTm = time.AfterFunc(s.x.cfg.KeepAlive, s.readTimeout)
+	s.mu.Unlock()
+}
+
+func (s *inStream) cancelReadTimeout() {
+	s.mu.Lock()
+	s.readTimeoutTm.Stop()
+	s.mu.Unlock()
+}
+
+func (s *inStream) readTimeout() {
+	s.runQueue.Run(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), s.x.cfg.Timeout)
+		defer cancel()
+		s.disconnect(ctx, streamerror.ErrConnectionTimeout)
+	})
+}
+
+func (s *inStream) setState(state uint32) {
+	atomic.StoreUint32(&s.state, state)
+}
+
+func (s *inStream) getState() uint32 {
+	return atomic.LoadUint32(&s.state)
+}
+
+// handshakeDigest returns the expected handshake value as defined in XEP-0114.
+func handshakeDigest(streamID, secret string) string {
+	h := sha1.New()
+	h.Write([]byte(streamID + secret))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+var inStreamCounter uint64
+
+func nextInID() string {
+	return fmt.Sprintf("ext:in:%d", atomic.AddUint64(&inStreamCounter, 1))
+}
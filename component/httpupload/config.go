@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package httpupload
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultServiceName = "HTTP File Upload"
+	defaultBindAddr    = "0.0.0.0"
+	defaultPort        = 5443
+	defaultMaxFileSize = 10 * 1024 * 1024 // 10 MiB
+)
+
+// TLSConfig represents upload HTTP server TLS configuration.
+type TLSConfig struct {
+	CertFile    string `yaml:"cert_path"`
+	PrivKeyFile string `yaml:"privkey_path"`
+}
+
+// Config represents HTTP File Upload component configuration.
+type Config struct {
+	Host         string
+	Name         string
+	BaseURL      string
+	BindAddr     string
+	Port         int
+	TLS          TLSConfig
+	StoragePath  string
+	MaxFileSize  int64
+	ContentTypes []string
+	Quota        int64
+	ExpireAfter  time.Duration
+}
+
+type configProxy struct {
+	Host         string    `yaml:"host"`
+	Name         string    `yaml:"name"`
+	BaseURL      string    `yaml:"base_url"`
+	BindAddr     string    `yaml:"bind_addr"`
+	Port         int       `yaml:"port"`
+	TLS          TLSConfig `yaml:"tls"`
+	StoragePath  string    `yaml:"storage_path"`
+	MaxFileSize  int64     `yaml:"max_file_size"`
+	ContentTypes []string  `yaml:"content_types"`
+	Quota        int64     `yaml:"quota"`
+	ExpireAfter  int       `yaml:"expire_after"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if len(p.Host) == 0 {
+		return errors.New("httpupload.Config: host value must be set")
+	}
+	if len(p.StoragePath) == 0 {
+		return errors.New("httpupload.Config: storage_path value must be set")
+	}
+	if p.MaxFileSize < 0 || p.Quota < 0 || p.ExpireAfter < 0 {
+		return errors.New("httpupload.Config: invalid negative value")
+	}
+	cfg.Host = p.Host
+	cfg.Name = p.Name
+	if len(cfg.Name) == 0 {
+		cfg.Name = defaultServiceName
+	}
+	cfg.BindAddr = p.BindAddr
+	if len(cfg.BindAddr) == 0 {
+		cfg.BindAddr = defaultBindAddr
+	}
+	cfg.Port = p.Port
+	if cfg.Port == 0 {
+		cfg.Port = defaultPort
+	}
+	cfg.TLS = p.TLS
+	cfg.BaseURL = p.BaseURL
+	if len(cfg.BaseURL) == 0 {
+		scheme := "http"
+		if len(cfg.TLS.CertFile) > 0 {
+			scheme = "https"
+		}
+		cfg.BaseURL = fmt.Sprintf("%s://%s:%d", scheme, cfg.Host, cfg.Port)
+	}
+	cfg.StoragePath = p.StoragePath
+	cfg.MaxFileSize = p.MaxFileSize
+	if cfg.MaxFileSize == 0 {
+		cfg.MaxFileSize = defaultMaxFileSize
+	}
+	cfg.ContentTypes = p.ContentTypes
+	cfg.Quota = p.Quota
+	cfg.ExpireAfter = time.Duration(p.ExpireAfter) * time.Second
+	return nil
+}
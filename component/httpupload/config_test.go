@@ -0,0 +1,50 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package httpupload
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig(t *testing.T) {
+	var cfg Config
+
+	err := yaml.Unmarshal([]byte(`storage_path: /tmp/upload`), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte(`host: upload.jackal.im`), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("host: upload.jackal.im\nstorage_path: /tmp/upload\nquota: -1"), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("host: upload.jackal.im\nstorage_path: /tmp/upload"), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, defaultServiceName, cfg.Name)
+	require.Equal(t, defaultPort, cfg.Port)
+	require.Equal(t, int64(defaultMaxFileSize), cfg.MaxFileSize)
+	require.Equal(t, "http://upload.jackal.im:5443", cfg.BaseURL)
+
+	err = yaml.Unmarshal([]byte(`
+host: upload.jackal.im
+storage_path: /tmp/upload
+base_url: https://files.jackal.im
+max_file_size: 1024
+content_types: [image/png, image/jpeg]
+quota: 4096
+expire_after: 3600
+`), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, "https://files.jackal.im", cfg.BaseURL)
+	require.Equal(t, int64(1024), cfg.MaxFileSize)
+	require.Equal(t, []string{"image/png", "image/jpeg"}, cfg.ContentTypes)
+	require.Equal(t, int64(4096), cfg.Quota)
+	require.Equal(t, time.Hour, cfg.ExpireAfter)
+}
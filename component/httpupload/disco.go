@@ -0,0 +1,58 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package httpupload
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+type discoProvider struct {
+	cfg *Config
+}
+
+func (dp *discoProvider) Identities(_ context.Context, toJID, _ *jid.JID, node string) []xep0030.Identity {
+	if !isServiceJID(toJID, node) {
+		return nil
+	}
+	return []xep0030.Identity{{Category: "store", Type: "file", Name: dp.cfg.Name}}
+}
+
+func (dp *discoProvider) Items(_ context.Context, toJID, _ *jid.JID, node string) ([]xep0030.Item, *xmpp.StanzaError) {
+	if !isServiceJID(toJID, node) {
+		return nil, xmpp.ErrItemNotFound
+	}
+	return nil, nil
+}
+
+func (dp *discoProvider) Features(_ context.Context, toJID, _ *jid.JID, node string) ([]xep0030.Feature, *xmpp.StanzaError) {
+	if !isServiceJID(toJID, node) {
+		return nil, xmpp.ErrItemNotFound
+	}
+	return []xep0030.Feature{httpUploadNamespace}, nil
+}
+
+func (dp *discoProvider) Form(_ context.Context, toJID, _ *jid.JID, node string) (*xep0004.DataForm, *xmpp.StanzaError) {
+	if !isServiceJID(toJID, node) {
+		return nil, nil
+	}
+	return &xep0004.DataForm{
+		Type: xep0004.Result,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{httpUploadNamespace}},
+			{Var: "max-file-size", Values: []string{strconv.FormatInt(dp.cfg.MaxFileSize, 10)}},
+		},
+	}, nil
+}
+
+func isServiceJID(j *jid.JID, node string) bool {
+	return len(node) == 0 && j.IsServer()
+}
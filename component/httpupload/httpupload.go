@@ -0,0 +1,327 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package httpupload
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/util/runqueue"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/pborman/uuid"
+)
+
+const httpUploadNamespace = "urn:xmpp:http:upload:0"
+
+const (
+	slotTimeout   = 5 * time.Minute
+	purgeInterval = time.Hour
+)
+
+// HTTPUpload represents an HTTP File Upload (XEP-0363) component.
+type HTTPUpload struct {
+	cfg      *Config
+	disco    *xep0030.DiscoInfo
+	store    *fileStore
+	runQueue *runqueue.RunQueue
+	srv      *http.Server
+	doneCh   chan struct{}
+}
+
+// New returns a new HTTP File Upload component instance.
+func New(cfg *Config, disco *xep0030.DiscoInfo) (*HTTPUpload, chan<- chan bool) {
+	store, err := newFileStore(cfg.StoragePath)
+	if err != nil {
+		log.Fatalf("httpupload: %v", err)
+	}
+	c := &HTTPUpload{
+		cfg:      cfg,
+		disco:    disco,
+		store:    store,
+		runQueue: runqueue.NewMetered("httpupload"),
+		doneCh:   make(chan struct{}),
+	}
+	if disco != nil {
+		disco.RegisterServerItem(xep0030.Item{Jid: cfg.Host, Name: cfg.Name})
+		disco.RegisterProvider(cfg.Host, &discoProvider{cfg: cfg})
+	}
+	if err := c.listen(); err != nil {
+		log.Fatalf("httpupload: %v", err)
+	}
+	go c.purgeLoop()
+
+	shutdownCh := make(chan chan bool)
+	go func() {
+		wc := <-shutdownCh
+		c.shutdown()
+		wc <- true
+	}()
+	return c, shutdownCh
+}
+
+// Host returns HTTP File Upload component host domain.
+func (c *HTTPUpload) Host() string {
+	return c.cfg.Host
+}
+
+// ProcessStanza processes an upload slot request.
+func (c *HTTPUpload) ProcessStanza(ctx context.Context, stanza xmpp.Stanza, stm stream.C2S) {
+	iq, ok := stanza.(*xmpp.IQ)
+	if !ok {
+		return
+	}
+	c.runQueue.Run(func() { c.processIQ(ctx, iq, stm) })
+}
+
+func (c *HTTPUpload) processIQ(ctx context.Context, iq *xmpp.IQ, stm stream.C2S) {
+	if !iq.IsGet() && !iq.IsSet() {
+		return
+	}
+	request := iq.Elements().ChildNamespace("request", httpUploadNamespace)
+	if !iq.IsGet() || request == nil || len(iq.ToJID().Node()) > 0 {
+		stm.SendElement(ctx, iq.ServiceUnavailableError())
+		return
+	}
+	attrs := request.Attributes()
+	filename := attrs.Get("filename")
+	size, err := strconv.ParseInt(attrs.Get("size"), 10, 64)
+	if err != nil || size <= 0 || !isValidFilename(filename) {
+		stm.SendElement(ctx, iq.BadRequestError())
+		return
+	}
+	contentType := attrs.Get("content-type")
+	if size > c.cfg.MaxFileSize {
+		maxSize := xmpp.NewElementName("max-size").SetText(strconv.FormatInt(c.cfg.MaxFileSize, 10))
+		tooLarge := xmpp.NewElementNamespace("file-too-large", httpUploadNamespace)
+		tooLarge.AppendElement(maxSize)
+		stm.SendElement(ctx, xmpp.NewErrorStanzaFromStanza(iq, xmpp.ErrNotAcceptable, []xmpp.XElement{tooLarge}))
+		return
+	}
+	if !c.isAllowedContentType(contentType) {
+		stm.SendElement(ctx, iq.NotAcceptableError())
+		return
+	}
+	s := &slot{
+		id:          uuid.New(),
+		filename:    filename,
+		contentType: contentType,
+		owner:       iq.FromJID().ToBareJID().String(),
+		size:        size,
+		expiresAt:   time.Now().Add(slotTimeout),
+	}
+	if !c.store.addSlot(s, c.cfg.Quota) {
+		stm.SendElement(ctx, iq.ResourceConstraintError())
+		return
+	}
+
+	fileURL := fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(c.cfg.BaseURL, "/"), s.id, url.PathEscape(filename))
+	slotEl := xmpp.NewElementNamespace("slot", httpUploadNamespace)
+	slotEl.AppendElement(xmpp.NewElementName("put").SetAttribute("url", fileURL))
+	slotEl.AppendElement(xmpp.NewElementName("get").SetAttribute("url", fileURL))
+
+	result := iq.ResultIQ()
+	result.AppendElement(slotEl)
+	stm.SendElement(ctx, result)
+
+	log.Infof("httpupload: granted upload slot %s... (%s, %d bytes)", s.id, iq.FromJID(), size)
+}
+
+// ServeHTTP satisfies http.Handler interface.
+func (c *HTTPUpload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, PUT, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	slotID, filename := parts[0], parts[1]
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		f := c.store.get(slotID, filename)
+		if f == nil {
+			http.NotFound(w, r)
+			return
+		}
+		c.serveFile(w, r, f)
+
+	case http.MethodPut:
+		s := c.store.slot(slotID, filename)
+		if s == nil {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		if r.ContentLength > s.size {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if r.ContentLength != s.size || (len(s.contentType) > 0 && r.Header.Get("Content-Type") != s.contentType) {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if !c.store.takeSlot(s) {
+			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+			return
+		}
+		if err := c.store.put(s, r.Body); err != nil {
+			log.Error(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+
+		log.Infof("httpupload: uploaded file %s/%s... (%s, %d bytes)", s.id, s.filename, s.owner, s.size)
+
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// serveFile writes an uploaded file using the content type declared at slot request time.
+// Content sniffing is disabled so that browsers never render uploads as anything else.
+func (c *HTTPUpload) serveFile(w http.ResponseWriter, r *http.Request, f *file) {
+	fh, err := os.Open(f.path)
+	if err != nil {
+		log.Error(err)
+		http.NotFound(w, r)
+		return
+	}
+	defer func() { _ = fh.Close() }()
+
+	contentType := f.contentType
+	if len(contentType) == 0 {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if !isInlineContentType(contentType) {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(f.path)}))
+	}
+	http.ServeContent(w, r, filepath.Base(f.path), f.modTime, fh)
+}
+
+func (c *HTTPUpload) isAllowedContentType(contentType string) bool {
+	if len(c.cfg.ContentTypes) == 0 {
+		return true
+	}
+	for _, ct := range c.cfg.ContentTypes {
+		if ct == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *HTTPUpload) listen() error {
+	ln, err := net.Listen("tcp", fmt.Sprintf("%s:%d", c.cfg.BindAddr, c.cfg.Port))
+	if err != nil {
+		return err
+	}
+	c.srv = &http.Server{Handler: c}
+
+	go func() {
+		var err error
+		if len(c.cfg.TLS.CertFile) > 0 {
+			err = c.srv.ServeTLS(ln, c.cfg.TLS.CertFile, c.cfg.TLS.PrivKeyFile)
+		} else {
+			err = c.srv.Serve(ln)
+		}
+		if err != http.ErrServerClosed {
+			log.Error(err)
+		}
+	}()
+	log.Infof("httpupload: listening at %s", ln.Addr())
+	return nil
+}
+
+func (c *HTTPUpload) purgeLoop() {
+	interval := purgeInterval
+	if c.cfg.ExpireAfter > 0 && c.cfg.ExpireAfter < interval {
+		interval = c.cfg.ExpireAfter
+	}
+	tc := time.NewTicker(interval)
+	defer tc.Stop()
+
+	for {
+		select {
+		case <-tc.C:
+			c.purge()
+		case <-c.doneCh:
+			return
+		}
+	}
+}
+
+func (c *HTTPUpload) purge() {
+	var uploadedBefore time.Time
+	if c.cfg.ExpireAfter > 0 {
+		uploadedBefore = time.Now().Add(-c.cfg.ExpireAfter)
+	}
+	count, err := c.store.purge(uploadedBefore)
+	if err != nil {
+		log.Error(err)
+	}
+	if count > 0 {
+		log.Infof("httpupload: purged %d expired files", count)
+	}
+}
+
+func (c *HTTPUpload) shutdown() {
+	if c.disco != nil {
+		c.disco.UnregisterProvider(c.cfg.Host)
+		c.disco.UnregisterServerItem(xep0030.Item{Jid: c.cfg.Host, Name: c.cfg.Name})
+	}
+	close(c.doneCh)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.srv.Shutdown(ctx); err != nil {
+		log.Error(err)
+	}
+	ch := make(chan struct{})
+	c.runQueue.Stop(func() { close(ch) })
+	<-ch
+}
+
+// isInlineContentType reports whether a content type is safe to be displayed inline by a browser.
+func isInlineContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "image/svg+xml":
+		return false
+	case strings.HasPrefix(mediaType, "image/"), strings.HasPrefix(mediaType, "audio/"), strings.HasPrefix(mediaType, "video/"):
+		return true
+	}
+	return false
+}
+
+func isValidFilename(filename string) bool {
+	if len(filename) == 0 || filename == "." || filename == ".." || strings.HasPrefix(filename, ".") {
+		return false
+	}
+	return filepath.Base(filename) == filename && !strings.ContainsAny(filename, `/\`)
+}
@@ -0,0 +1,249 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package httpupload
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPUpload_RequestSlot(t *testing.T) {
+	cfg, cleanUp := setupTest()
+	defer cleanUp()
+
+	cfg.ContentTypes = []string{"image/png"}
+	cfg.Quota = 2048
+
+	c, shutdownCh := New(cfg, nil)
+	defer tUtilShutdown(shutdownCh)
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+
+	// invalid filename
+	c.ProcessStanza(context.Background(), tUtilSlotRequestIQ(j, "../passwd", 512, "image/png"), stm)
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	// file too large
+	c.ProcessStanza(context.Background(), tUtilSlotRequestIQ(j, "avatar.png", 4096, "image/png"), stm)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrNotAcceptable.Error(), elem.Error().Elements().All()[0].Name())
+	tooLarge := elem.Error().Elements().ChildNamespace("file-too-large", httpUploadNamespace)
+	require.NotNil(t, tooLarge)
+	require.Equal(t, "1024", tooLarge.Elements().Child("max-size").Text())
+
+	// content type not allowed
+	c.ProcessStanza(context.Background(), tUtilSlotRequestIQ(j, "notes.txt", 512, "text/plain"), stm)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrNotAcceptable.Error(), elem.Error().Elements().All()[0].Name())
+
+	c.ProcessStanza(context.Background(), tUtilSlotRequestIQ(j, "avatar.png", 1024, "image/png"), stm)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	slotEl := elem.Elements().ChildNamespace("slot", httpUploadNamespace)
+	require.NotNil(t, slotEl)
+	putURL := slotEl.Elements().Child("put").Attributes().Get("url")
+	require.Equal(t, putURL, slotEl.Elements().Child("get").Attributes().Get("url"))
+	require.Contains(t, putURL, "https://upload.jackal.im/")
+
+	// quota exceeded (pending slots are accounted)
+	c.ProcessStanza(context.Background(), tUtilSlotRequestIQ(j, "photo.png", 1024, "image/png"), stm)
+	require.Equal(t, xmpp.ResultType, stm.ReceiveElement().Type())
+
+	c.ProcessStanza(context.Background(), tUtilSlotRequestIQ(j, "photo.png", 1, "image/png"), stm)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrResourceConstraint.Error(), elem.Error().Elements().All()[0].Name())
+
+	// quota is accounted per bare JID
+	j2, _ := jid.New("ortuman", "jackal.chat", "balcony", true)
+	stm2 := stream.NewMockC2S(uuid.New(), j2)
+	c.ProcessStanza(context.Background(), tUtilSlotRequestIQ(j2, "photo.png", 1024, "image/png"), stm2)
+	require.Equal(t, xmpp.ResultType, stm2.ReceiveElement().Type())
+}
+
+func TestHTTPUpload_PutAndGet(t *testing.T) {
+	cfg, cleanUp := setupTest()
+	defer cleanUp()
+
+	c, shutdownCh := New(cfg, nil)
+	defer tUtilShutdown(shutdownCh)
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+
+	content := []byte("Hello, world!")
+
+	c.ProcessStanza(context.Background(), tUtilSlotRequestIQ(j, "hello world.txt", int64(len(content)), "text/plain"), stm)
+	elem := stm.ReceiveElement()
+	putURL, _ := url.Parse(elem.Elements().ChildNamespace("slot", httpUploadNamespace).Elements().Child("put").Attributes().Get("url"))
+
+	// not uploaded yet
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, putURL.EscapedPath(), nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	// content length mismatch
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, tUtilPutRequest(putURL.EscapedPath(), []byte("Hello!"), "text/plain"))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, tUtilPutRequest(putURL.EscapedPath(), content, "text/plain"))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	// slots can only be used once
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, tUtilPutRequest(putURL.EscapedPath(), content, "text/plain"))
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, putURL.EscapedPath(), nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, content, rec.Body.Bytes())
+	require.Equal(t, "text/plain", rec.Header().Get("Content-Type"))
+	require.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+	require.Equal(t, `attachment; filename="hello world.txt"`, rec.Header().Get("Content-Disposition"))
+
+	require.Equal(t, int64(len(content)), c.store.usage("ortuman@jackal.im"))
+
+	// files are reloaded from disk
+	store, err := newFileStore(cfg.StoragePath)
+	require.Nil(t, err)
+	require.Equal(t, int64(len(content)), store.usage("ortuman@jackal.im"))
+	for _, f := range store.files {
+		require.Equal(t, "text/plain", f.contentType)
+	}
+
+	// expired files are purged
+	time.Sleep(time.Millisecond * 5)
+	count, err := c.store.purge(time.Now())
+	require.Nil(t, err)
+	require.Equal(t, 1, count)
+
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, putURL.EscapedPath(), nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Equal(t, int64(0), c.store.usage("ortuman@jackal.im"))
+}
+
+func TestHTTPUpload_ServeDeclaredContentType(t *testing.T) {
+	cfg, cleanUp := setupTest()
+	defer cleanUp()
+
+	c, shutdownCh := New(cfg, nil)
+	defer tUtilShutdown(shutdownCh)
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+
+	content := []byte("<html><script>alert(1)</script></html>")
+
+	c.ProcessStanza(context.Background(), tUtilSlotRequestIQ(j, "index.html", int64(len(content)), "image/png"), stm)
+	elem := stm.ReceiveElement()
+	putURL, _ := url.Parse(elem.Elements().ChildNamespace("slot", httpUploadNamespace).Elements().Child("put").Attributes().Get("url"))
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, tUtilPutRequest(putURL.EscapedPath(), content, "image/png"))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	// no index.html redirection nor content sniffing
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, putURL.EscapedPath(), nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, content, rec.Body.Bytes())
+	require.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+	require.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+	require.Empty(t, rec.Header().Get("Content-Disposition"))
+}
+
+func TestHTTPUpload_QuotaAccountsPendingUploads(t *testing.T) {
+	cfg, cleanUp := setupTest()
+	defer cleanUp()
+
+	cfg.Quota = 1024
+
+	c, shutdownCh := New(cfg, nil)
+	defer tUtilShutdown(shutdownCh)
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+
+	c.ProcessStanza(context.Background(), tUtilSlotRequestIQ(j, "a.txt", 1024, "text/plain"), stm)
+	elem := stm.ReceiveElement()
+	putURL, _ := url.Parse(elem.Elements().ChildNamespace("slot", httpUploadNamespace).Elements().Child("put").Attributes().Get("url"))
+
+	// slot being uploaded
+	s := c.store.slot(strings.Split(strings.TrimPrefix(putURL.Path, "/"), "/")[0], "a.txt")
+	require.NotNil(t, s)
+	require.True(t, c.store.takeSlot(s))
+	s.expiresAt = time.Now().Add(-time.Second)
+
+	c.ProcessStanza(context.Background(), tUtilSlotRequestIQ(j, "b.txt", 1, "text/plain"), stm)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrResourceConstraint.Error(), elem.Error().Elements().All()[0].Name())
+
+	// expired slots are released
+	c.store.slots[s.id].uploading = false
+
+	c.ProcessStanza(context.Background(), tUtilSlotRequestIQ(j, "b.txt", 1024, "text/plain"), stm)
+	require.Equal(t, xmpp.ResultType, stm.ReceiveElement().Type())
+}
+
+func tUtilSlotRequestIQ(from *jid.JID, filename string, size int64, contentType string) *xmpp.IQ {
+	request := xmpp.NewElementNamespace("request", httpUploadNamespace)
+	request.SetAttribute("filename", filename)
+	request.SetAttribute("size", strconv.FormatInt(size, 10))
+	request.SetAttribute("content-type", contentType)
+
+	toJID, _ := jid.New("", "upload.jackal.im", "", true)
+	iq := xmpp.NewIQType(uuid.New(), xmpp.GetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(toJID)
+	iq.AppendElement(request)
+	return iq
+}
+
+func tUtilPutRequest(path string, content []byte, contentType string) *http.Request {
+	req := httptest.NewRequest(http.MethodPut, path, bytes.NewReader(content))
+	req.Header.Set("Content-Type", contentType)
+	return req
+}
+
+func tUtilShutdown(shutdownCh chan<- chan bool) {
+	wc := make(chan bool, 1)
+	shutdownCh <- wc
+	<-wc
+}
+
+func setupTest() (*Config, func()) {
+	dir, _ := ioutil.TempDir("", "jackal-httpupload")
+	cfg := &Config{
+		Host:        "upload.jackal.im",
+		Name:        defaultServiceName,
+		BaseURL:     "https://upload.jackal.im",
+		BindAddr:    "127.0.0.1",
+		StoragePath: dir,
+		MaxFileSize: 1024,
+	}
+	return cfg, func() { _ = os.RemoveAll(dir) }
+}
@@ -0,0 +1,238 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package httpupload
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// contentTypeFile is the name of the file holding the declared content type of an upload within its slot directory.
+const contentTypeFile = ".content-type"
+
+var errSizeMismatch = errors.New("httpupload: file size mismatch")
+
+type slot struct {
+	id          string
+	filename    string
+	contentType string
+	owner       string
+	size        int64
+	expiresAt   time.Time
+	uploading   bool
+}
+
+type file struct {
+	owner       string
+	path        string
+	contentType string
+	size        int64
+	modTime     time.Time
+}
+
+// fileStore keeps uploaded files on local disk, laid out as <base>/<owner>/<slot>/<filename>,
+// where owner is the uploader bare JID escaped as a single path segment.
+type fileStore struct {
+	basePath string
+	mu       sync.RWMutex
+	slots    map[string]*slot
+	files    map[string]*file
+}
+
+func newFileStore(basePath string) (*fileStore, error) {
+	if err := os.MkdirAll(basePath, 0700); err != nil {
+		return nil, err
+	}
+	fs := &fileStore{
+		basePath: basePath,
+		slots:    make(map[string]*slot),
+		files:    make(map[string]*file),
+	}
+	if err := fs.load(); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+func (fs *fileStore) load() error {
+	paths, err := filepath.Glob(filepath.Join(fs.basePath, "*", "*", "*"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if strings.HasPrefix(filepath.Base(path), ".") {
+			continue
+		}
+		fi, err := os.Stat(path)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		slotDir := filepath.Dir(path)
+		owner, err := url.PathUnescape(filepath.Base(filepath.Dir(slotDir)))
+		if err != nil {
+			continue
+		}
+		contentType, _ := ioutil.ReadFile(filepath.Join(slotDir, contentTypeFile))
+		fs.files[filepath.Base(slotDir)] = &file{
+			owner:       owner,
+			path:        path,
+			contentType: string(contentType),
+			size:        fi.Size(),
+			modTime:     fi.ModTime(),
+		}
+	}
+	return nil
+}
+
+// usage returns the amount of bytes stored or reserved by a user.
+func (fs *fileStore) usage(owner string) int64 {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	return fs.ownerUsage(owner, time.Now())
+}
+
+func (fs *fileStore) ownerUsage(owner string, now time.Time) int64 {
+	var total int64
+	for _, f := range fs.files {
+		if f.owner == owner {
+			total += f.size
+		}
+	}
+	for _, s := range fs.slots {
+		if s.owner == owner && (s.uploading || !now.After(s.expiresAt)) {
+			total += s.size
+		}
+	}
+	return total
+}
+
+// addSlot registers a pending slot, reporting false if it would make its owner exceed a given quota.
+// A zero quota means no limit is enforced.
+func (fs *fileStore) addSlot(s *slot, quota int64) bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if quota > 0 && fs.ownerUsage(s.owner, time.Now())+s.size > quota {
+		return false
+	}
+	fs.slots[s.id] = s
+	return true
+}
+
+// slot returns a pending upload slot, as long as it hasn't expired yet.
+func (fs *fileStore) slot(id, filename string) *slot {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	s := fs.slots[id]
+	if s == nil || s.uploading || s.filename != filename || time.Now().After(s.expiresAt) {
+		return nil
+	}
+	return s
+}
+
+// takeSlot marks a pending slot as being uploaded, reporting whether it was still available.
+// The slot keeps accounting against its owner quota until put completes.
+func (fs *fileStore) takeSlot(s *slot) bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.slots[s.id] != s || s.uploading {
+		return false
+	}
+	s.uploading = true
+	return true
+}
+
+func (fs *fileStore) put(s *slot, r io.Reader) error {
+	err := fs.write(s, r)
+
+	fs.mu.Lock()
+	delete(fs.slots, s.id)
+	if err == nil {
+		fs.files[s.id] = &file{
+			owner:       s.owner,
+			path:        filepath.Join(fs.basePath, url.PathEscape(s.owner), s.id, s.filename),
+			contentType: s.contentType,
+			size:        s.size,
+			modTime:     time.Now(),
+		}
+	}
+	fs.mu.Unlock()
+	return err
+}
+
+func (fs *fileStore) write(s *slot, r io.Reader) error {
+	slotDir := filepath.Join(fs.basePath, url.PathEscape(s.owner), s.id)
+	if err := os.MkdirAll(slotDir, 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(slotDir, ".upload")
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(tmp, io.LimitReader(r, s.size+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n != s.size {
+		err = errSizeMismatch
+	}
+	if err == nil && len(s.contentType) > 0 {
+		err = ioutil.WriteFile(filepath.Join(slotDir, contentTypeFile), []byte(s.contentType), 0600)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(slotDir, s.filename))
+	}
+	if err != nil {
+		_ = os.RemoveAll(slotDir)
+		return err
+	}
+	return nil
+}
+
+func (fs *fileStore) get(id, filename string) *file {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	f := fs.files[id]
+	if f == nil || filepath.Base(f.path) != filename {
+		return nil
+	}
+	return f
+}
+
+// purge removes every expired slot and every file uploaded before a given time.
+func (fs *fileStore) purge(uploadedBefore time.Time) (int, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	now := time.Now()
+	for id, s := range fs.slots {
+		if !s.uploading && now.After(s.expiresAt) {
+			delete(fs.slots, id)
+		}
+	}
+	var count int
+	for id, f := range fs.files {
+		if !f.modTime.Before(uploadedBefore) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Dir(f.path)); err != nil {
+			return count, err
+		}
+		delete(fs.files, id)
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,38 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import "errors"
+
+const defaultServiceName = "Chatrooms"
+
+// Config represents Multi-User Chat component configuration.
+type Config struct {
+	Host string
+	Name string
+}
+
+type configProxy struct {
+	Host string `yaml:"host"`
+	Name string `yaml:"name"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if len(p.Host) == 0 {
+		return errors.New("muc.Config: host value must be set")
+	}
+	cfg.Host = p.Host
+	cfg.Name = p.Name
+	if len(cfg.Name) == 0 {
+		cfg.Name = defaultServiceName
+	}
+	return nil
+}
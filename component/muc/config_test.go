@@ -0,0 +1,29 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig(t *testing.T) {
+	var cfg Config
+
+	err := yaml.Unmarshal([]byte(`name: Rooms`), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte(`host: conference.jackal.im`), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, "conference.jackal.im", cfg.Host)
+	require.Equal(t, defaultServiceName, cfg.Name)
+
+	err = yaml.Unmarshal([]byte("host: conference.jackal.im\nname: Rooms"), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, "Rooms", cfg.Name)
+}
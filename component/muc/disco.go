@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+const roomInfoNamespace = "http://jabber.org/protocol/muc#roominfo"
+
+type discoProvider struct {
+	muc *Muc
+}
+
+func (dp *discoProvider) Identities(_ context.Context, toJID, _ *jid.JID, node string) []xep0030.Identity {
+	if len(node) > 0 || len(toJID.Resource()) > 0 {
+		return nil
+	}
+	if len(toJID.Node()) == 0 {
+		return []xep0030.Identity{{Category: "conference", Type: "text", Name: dp.muc.cfg.Name}}
+	}
+	dp.muc.mu.RLock()
+	defer dp.muc.mu.RUnlock()
+
+	r := dp.muc.getRoom(toJID.Node())
+	if r == nil {
+		return nil
+	}
+	return []xep0030.Identity{{Category: "conference", Type: "text", Name: roomName(r)}}
+}
+
+func (dp *discoProvider) Items(_ context.Context, toJID, _ *jid.JID, node string) ([]xep0030.Item, *xmpp.StanzaError) {
+	if len(node) > 0 || len(toJID.Resource()) > 0 {
+		return nil, xmpp.ErrItemNotFound
+	}
+	dp.muc.mu.RLock()
+	defer dp.muc.mu.RUnlock()
+
+	if len(toJID.Node()) > 0 {
+		if dp.muc.getRoom(toJID.Node()) == nil {
+			return nil, xmpp.ErrItemNotFound
+		}
+		return nil, nil
+	}
+	var names []string
+	for name, r := range dp.muc.rooms {
+		if r.locked || !r.Config.Public {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var items []xep0030.Item
+	for _, name := range names {
+		r := dp.muc.rooms[name]
+		items = append(items, xep0030.Item{Jid: r.jid.String(), Name: roomName(r)})
+	}
+	return items, nil
+}
+
+func (dp *discoProvider) Features(_ context.Context, toJID, _ *jid.JID, node string) ([]xep0030.Feature, *xmpp.StanzaError) {
+	if len(node) > 0 || len(toJID.Resource()) > 0 {
+		return nil, xmpp.ErrItemNotFound
+	}
+	if len(toJID.Node()) == 0 {
+		return []xep0030.Feature{mucNamespace}, nil
+	}
+	dp.muc.mu.RLock()
+	defer dp.muc.mu.RUnlock()
+
+	r := dp.muc.getRoom(toJID.Node())
+	if r == nil {
+		return nil, xmpp.ErrItemNotFound
+	}
+	cfg := &r.Config
+	return []xep0030.Feature{
+		mucNamespace,
+		featureFlag(cfg.Persistent, "muc_persistent", "muc_temporary"),
+		featureFlag(cfg.Public, "muc_public", "muc_hidden"),
+		featureFlag(cfg.MembersOnly, "muc_membersonly", "muc_open"),
+		featureFlag(cfg.Moderated, "muc_moderated", "muc_unmoderated"),
+		featureFlag(cfg.PasswordProtected, "muc_passwordprotected", "muc_unsecured"),
+		featureFlag(cfg.IsNonAnonymous(), "muc_nonanonymous", "muc_semianonymous"),
+	}, nil
+}
+
+func (dp *discoProvider) Form(_ context.Context, toJID, _ *jid.JID, node string) (*xep0004.DataForm, *xmpp.StanzaError) {
+	if len(node) > 0 || len(toJID.Node()) == 0 || len(toJID.Resource()) > 0 {
+		return nil, nil
+	}
+	dp.muc.mu.RLock()
+	defer dp.muc.mu.RUnlock()
+
+	r := dp.muc.getRoom(toJID.Node())
+	if r == nil {
+		return nil, nil
+	}
+	return &xep0004.DataForm{
+		Type: xep0004.Result,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{roomInfoNamespace}},
+			{Var: "muc#roominfo_description", Label: "Description", Values: []string{r.Config.Description}},
+			{Var: "muc#roominfo_subject", Label: "Subject", Values: []string{r.Subject}},
+			{Var: "muc#roominfo_occupants", Label: "Number of occupants", Values: []string{strconv.Itoa(len(r.occupants))}},
+		},
+	}, nil
+}
+
+func roomName(r *room) string {
+	if len(r.Config.Name) > 0 {
+		return r.Config.Name
+	}
+	return r.Name
+}
+
+func featureFlag(enabled bool, onFeature, offFeature string) xep0030.Feature {
+	if enabled {
+		return onFeature
+	}
+	return offFeature
+}
@@ -0,0 +1,340 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/ortuman/jackal/log"
+	mucmodel "github.com/ortuman/jackal/model/muc"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+type roleChange struct {
+	occupant *occupant
+	role     string
+}
+
+type affiliationChange struct {
+	jid         string
+	affiliation string
+}
+
+func (c *Muc) processIQ(ctx context.Context, iq *xmpp.IQ, stm stream.C2S) {
+	if !iq.IsGet() && !iq.IsSet() {
+		return
+	}
+	r := c.getRoom(iq.ToJID().Node())
+	if r == nil {
+		stm.SendElement(ctx, iq.ItemNotFoundError())
+		return
+	}
+	if len(iq.ToJID().Resource()) > 0 {
+		stm.SendElement(ctx, iq.ServiceUnavailableError())
+		return
+	}
+	if q := iq.Elements().ChildNamespace("query", mucOwnerNamespace); q != nil {
+		c.processOwnerIQ(ctx, r, iq, q, stm)
+		return
+	}
+	if q := iq.Elements().ChildNamespace("query", mucAdminNamespace); q != nil {
+		c.processAdminIQ(ctx, r, iq, q, stm)
+		return
+	}
+	stm.SendElement(ctx, iq.ServiceUnavailableError())
+}
+
+func (c *Muc) processOwnerIQ(ctx context.Context, r *room, iq *xmpp.IQ, q xmpp.XElement, stm stream.C2S) {
+	if r.affiliation(iq.FromJID()) != mucmodel.Owner {
+		stm.SendElement(ctx, iq.ForbiddenError())
+		return
+	}
+	if iq.IsGet() {
+		query := xmpp.NewElementNamespace("query", mucOwnerNamespace)
+		query.AppendElement(r.Config.Form().Element())
+
+		result := iq.ResultIQ()
+		result.AppendElement(query)
+		stm.SendElement(ctx, result)
+		return
+	}
+	if destroy := q.Elements().Child("destroy"); destroy != nil {
+		if err := c.destroyOccupiedRoom(ctx, r, destroy); err != nil {
+			log.Error(err)
+			stm.SendElement(ctx, iq.InternalServerError())
+			return
+		}
+		stm.SendElement(ctx, iq.ResultIQ())
+		return
+	}
+	x := q.Elements().ChildNamespace("x", xep0004.FormNamespace)
+	if x == nil {
+		stm.SendElement(ctx, iq.BadRequestError())
+		return
+	}
+	form, err := xep0004.NewFormFromElement(x)
+	if err != nil {
+		stm.SendElement(ctx, iq.BadRequestError())
+		return
+	}
+	switch form.Type {
+	case xep0004.Cancel:
+		if r.locked {
+			if err := c.destroyOccupiedRoom(ctx, r, nil); err != nil {
+				log.Error(err)
+				stm.SendElement(ctx, iq.InternalServerError())
+				return
+			}
+		}
+		stm.SendElement(ctx, iq.ResultIQ())
+
+	case xep0004.Submit:
+		c.configureRoom(ctx, r, iq, form, stm)
+
+	default:
+		stm.SendElement(ctx, iq.BadRequestError())
+	}
+}
+
+func (c *Muc) configureRoom(ctx context.Context, r *room, iq *xmpp.IQ, form *xep0004.DataForm, stm stream.C2S) {
+	cfg := &r.Config
+	if len(form.Fields) > 0 {
+		newCfg, err := mucmodel.NewConfigFromSubmitForm(form, &r.Config)
+		if err != nil {
+			stm.SendElement(ctx, iq.NotAcceptableError())
+			return
+		}
+		cfg = newCfg
+	}
+	wasPersistent := r.Config.Persistent
+	wasLocked := r.locked
+
+	r.Config = *cfg
+	r.locked = false
+	if err := c.storeRoom(ctx, r, wasPersistent); err != nil {
+		log.Error(err)
+		stm.SendElement(ctx, iq.InternalServerError())
+		return
+	}
+	stm.SendElement(ctx, iq.ResultIQ())
+
+	if !wasLocked {
+		c.sendStatusMessage(ctx, r, configChangedStatus)
+	}
+	log.Infof("muc: configured room %s... (%s)", r.jid, iq.FromJID())
+}
+
+// destroyOccupiedRoom removes every occupant from a room and destroys it.
+func (c *Muc) destroyOccupiedRoom(ctx context.Context, r *room, destroy xmpp.XElement) error {
+	if err := c.destroyRoom(ctx, r); err != nil {
+		return err
+	}
+	for _, o := range r.sortedOccupants() {
+		x := xmpp.NewElementNamespace("x", mucUserNamespace)
+		x.AppendElement(xmpp.NewElementName("item").SetAttribute("affiliation", mucmodel.None).SetAttribute("role", noneRole))
+		x.AppendElement(xmpp.NewElementName("status").SetAttribute("code", strconv.Itoa(selfPresenceStatus)))
+		if destroy != nil {
+			x.AppendElement(destroy)
+		}
+		p := xmpp.NewPresence(r.occupantJID(o.nick), o.jid, xmpp.UnavailableType)
+		p.AppendElement(x)
+		c.route(ctx, p)
+	}
+	r.occupants = make(map[string]*occupant)
+	return nil
+}
+
+func (c *Muc) processAdminIQ(ctx context.Context, r *room, iq *xmpp.IQ, q xmpp.XElement, stm stream.C2S) {
+	actor := r.occupantByJID(iq.FromJID())
+	actorAff := r.affiliation(iq.FromJID())
+
+	items := q.Elements().Children("item")
+	if len(items) == 0 {
+		stm.SendElement(ctx, iq.BadRequestError())
+		return
+	}
+	if iq.IsGet() {
+		c.sendAdminList(ctx, r, iq, items[0], actor, actorAff, stm)
+		return
+	}
+	var roleChanges []roleChange
+	var affChanges []affiliationChange
+	for _, item := range items {
+		var sErr *xmpp.StanzaError
+		if role := item.Attributes().Get("role"); len(role) > 0 {
+			var change *roleChange
+			change, sErr = checkRoleChange(r, actor, actorAff, item.Attributes().Get("nick"), role)
+			if change != nil {
+				roleChanges = append(roleChanges, *change)
+			}
+		} else if aff := item.Attributes().Get("affiliation"); len(aff) > 0 {
+			var change *affiliationChange
+			change, sErr = checkAffiliationChange(r, actorAff, item.Attributes().Get("jid"), aff)
+			if change != nil {
+				affChanges = append(affChanges, *change)
+			}
+		} else {
+			sErr = xmpp.ErrBadRequest
+		}
+		if sErr != nil {
+			stm.SendElement(ctx, xmpp.NewErrorStanzaFromStanza(iq, sErr, nil))
+			return
+		}
+	}
+	if err := c.applyAffiliationChanges(ctx, r, affChanges); err != nil {
+		log.Error(err)
+		stm.SendElement(ctx, iq.InternalServerError())
+		return
+	}
+	c.applyRoleChanges(ctx, r, roleChanges)
+
+	stm.SendElement(ctx, iq.ResultIQ())
+}
+
+func (c *Muc) sendAdminList(ctx context.Context, r *room, iq *xmpp.IQ, item xmpp.XElement, actor *occupant, actorAff string, stm stream.C2S) {
+	query := xmpp.NewElementNamespace("query", mucAdminNamespace)
+
+	if aff := item.Attributes().Get("affiliation"); len(aff) > 0 {
+		if !isValidAffiliation(aff) || aff == mucmodel.None {
+			stm.SendElement(ctx, iq.BadRequestError())
+			return
+		}
+		if affiliationRank(actorAff) < affiliationRank(mucmodel.Admin) {
+			stm.SendElement(ctx, iq.ForbiddenError())
+			return
+		}
+		for _, bareJID := range sortedKeys(r.affiliations) {
+			if r.affiliations[bareJID] != aff {
+				continue
+			}
+			query.AppendElement(xmpp.NewElementName("item").SetAttribute("affiliation", aff).SetAttribute("jid", bareJID))
+		}
+	} else if role := item.Attributes().Get("role"); len(role) > 0 {
+		if !isValidRole(role) || role == noneRole {
+			stm.SendElement(ctx, iq.BadRequestError())
+			return
+		}
+		if actor == nil || actor.role != moderatorRole {
+			stm.SendElement(ctx, iq.ForbiddenError())
+			return
+		}
+		for _, o := range r.sortedOccupants() {
+			if o.role != role {
+				continue
+			}
+			it := r.occupantItem(o, actor)
+			it.SetAttribute("nick", o.nick)
+			query.AppendElement(it)
+		}
+	} else {
+		stm.SendElement(ctx, iq.BadRequestError())
+		return
+	}
+	result := iq.ResultIQ()
+	result.AppendElement(query)
+	stm.SendElement(ctx, result)
+}
+
+func checkRoleChange(r *room, actor *occupant, actorAff string, nick, role string) (*roleChange, *xmpp.StanzaError) {
+	if !isValidRole(role) {
+		return nil, xmpp.ErrBadRequest
+	}
+	if actor == nil || actor.role != moderatorRole {
+		return nil, xmpp.ErrForbidden
+	}
+	target := r.occupants[nick]
+	if target == nil {
+		return nil, xmpp.ErrItemNotFound
+	}
+	targetAff := r.affiliation(target.jid)
+
+	// nobody can modify the role of an occupant with an equal or higher administrative affiliation
+	if affiliationRank(targetAff) >= affiliationRank(mucmodel.Admin) && affiliationRank(targetAff) >= affiliationRank(actorAff) {
+		return nil, xmpp.ErrNotAllowed
+	}
+	// only admins are allowed to grant or revoke moderator role
+	if (role == moderatorRole || target.role == moderatorRole) && affiliationRank(actorAff) < affiliationRank(mucmodel.Admin) {
+		return nil, xmpp.ErrNotAllowed
+	}
+	return &roleChange{occupant: target, role: role}, nil
+}
+
+func checkAffiliationChange(r *room, actorAff string, jidStr, affiliation string) (*affiliationChange, *xmpp.StanzaError) {
+	if !isValidAffiliation(affiliation) {
+		return nil, xmpp.ErrBadRequest
+	}
+	j, err := jid.NewWithString(jidStr, false)
+	if err != nil {
+		return nil, xmpp.ErrJidMalformed
+	}
+	if affiliationRank(actorAff) < affiliationRank(mucmodel.Admin) {
+		return nil, xmpp.ErrForbidden
+	}
+	bareJID := j.ToBareJID().String()
+	currentAff := r.affiliation(j)
+
+	// only owners are allowed to grant or revoke admin and owner affiliations
+	isPrivileged := func(aff string) bool { return aff == mucmodel.Owner || aff == mucmodel.Admin }
+	if (isPrivileged(affiliation) || isPrivileged(currentAff)) && actorAff != mucmodel.Owner {
+		return nil, xmpp.ErrNotAllowed
+	}
+	// a room must always have at least one owner
+	if currentAff == mucmodel.Owner && affiliation != mucmodel.Owner && r.ownerCount() == 1 {
+		return nil, xmpp.ErrConflict
+	}
+	return &affiliationChange{jid: bareJID, affiliation: affiliation}, nil
+}
+
+func (c *Muc) applyRoleChanges(ctx context.Context, r *room, changes []roleChange) {
+	for _, change := range changes {
+		o := change.occupant
+		if r.occupants[o.nick] != o {
+			continue // already removed
+		}
+		if change.role == noneRole {
+			c.removeOccupant(ctx, r, o, r.affiliation(o.jid), kickedStatus)
+			log.Infof("muc: kicked occupant from room %s... (%s)", r.jid, o.jid)
+			continue
+		}
+		o.role = change.role
+		for _, receiver := range r.sortedOccupants() {
+			c.route(ctx, r.occupantPresence(o, receiver, "", nil, statusCodes(o, receiver)...))
+		}
+	}
+	c.purgeRoom(r)
+}
+
+func (c *Muc) applyAffiliationChanges(ctx context.Context, r *room, changes []affiliationChange) error {
+	for _, change := range changes {
+		if err := c.storeAffiliation(ctx, r, change.jid, change.affiliation); err != nil {
+			return err
+		}
+		r.setAffiliation(change.jid, change.affiliation)
+
+		for _, o := range r.occupantsByBareJID(change.jid) {
+			switch {
+			case change.affiliation == mucmodel.Outcast:
+				c.removeOccupant(ctx, r, o, mucmodel.Outcast, bannedStatus)
+				log.Infof("muc: banned occupant from room %s... (%s)", r.jid, o.jid)
+
+			case change.affiliation == mucmodel.None && r.Config.MembersOnly:
+				c.removeOccupant(ctx, r, o, mucmodel.None, membershipStatus)
+
+			default:
+				o.role = r.defaultRole(change.affiliation)
+				for _, receiver := range r.sortedOccupants() {
+					c.route(ctx, r.occupantPresence(o, receiver, "", nil, statusCodes(o, receiver)...))
+				}
+			}
+		}
+	}
+	c.purgeRoom(r)
+	return nil
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/ortuman/jackal/log"
+	mucmodel "github.com/ortuman/jackal/model/muc"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+)
+
+func (c *Muc) processMessage(ctx context.Context, message *xmpp.Message, stm stream.C2S) {
+	if message.IsError() {
+		return
+	}
+	r := c.getRoom(message.ToJID().Node())
+	if r == nil {
+		stm.SendElement(ctx, message.ItemNotFoundError())
+		return
+	}
+	if len(message.ToJID().Resource()) > 0 {
+		c.sendPrivateMessage(ctx, r, message, stm)
+		return
+	}
+	if x := message.Elements().ChildNamespace("x", mucUserNamespace); x != nil {
+		if invite := x.Elements().Child("invite"); invite != nil {
+			c.sendInvitation(ctx, r, message, invite, stm)
+			return
+		}
+		if decline := x.Elements().Child("decline"); decline != nil {
+			c.sendDecline(ctx, r, message, decline, stm)
+			return
+		}
+	}
+	if !message.IsGroupChat() {
+		stm.SendElement(ctx, message.BadRequestError())
+		return
+	}
+	o := r.occupantByJID(message.FromJID())
+	if o == nil {
+		stm.SendElement(ctx, message.NotAcceptableError())
+		return
+	}
+	subject := message.Elements().Child("subject")
+	if subject != nil && !message.IsMessageWithBody() {
+		c.changeSubject(ctx, r, o, message, subject, stm)
+		return
+	}
+	if o.role == visitorRole {
+		stm.SendElement(ctx, message.ForbiddenError())
+		return
+	}
+	c.broadcastMessage(ctx, r, o, message)
+	r.addHistory(occupantMessage(r, o, message, nil))
+}
+
+func (c *Muc) changeSubject(ctx context.Context, r *room, o *occupant, message *xmpp.Message, subject xmpp.XElement, stm stream.C2S) {
+	canChange := o.role == moderatorRole || (r.Config.AllowChangeSubject && o.role == participantRole)
+	if !canChange {
+		stm.SendElement(ctx, message.ForbiddenError())
+		return
+	}
+	r.Subject = subject.Text()
+	if r.Config.Persistent {
+		if err := c.mucRep.UpsertRoom(ctx, &r.Room); err != nil {
+			log.Error(err)
+			stm.SendElement(ctx, message.InternalServerError())
+			return
+		}
+	}
+	c.broadcastMessage(ctx, r, o, message)
+}
+
+func (c *Muc) broadcastMessage(ctx context.Context, r *room, o *occupant, message *xmpp.Message) {
+	for _, receiver := range r.sortedOccupants() {
+		c.route(ctx, occupantMessage(r, o, message, receiver.jid))
+	}
+}
+
+func (c *Muc) sendPrivateMessage(ctx context.Context, r *room, message *xmpp.Message, stm stream.C2S) {
+	if message.IsGroupChat() {
+		stm.SendElement(ctx, message.BadRequestError())
+		return
+	}
+	o := r.occupantByJID(message.FromJID())
+	if o == nil {
+		stm.SendElement(ctx, message.NotAcceptableError())
+		return
+	}
+	target := r.occupants[message.ToJID().Resource()]
+	if target == nil {
+		stm.SendElement(ctx, message.ItemNotFoundError())
+		return
+	}
+	msg := occupantMessage(r, o, message, target.jid)
+	msg.AppendElement(xmpp.NewElementNamespace("x", mucUserNamespace))
+	c.route(ctx, msg)
+}
+
+func (c *Muc) sendInvitation(ctx context.Context, r *room, message *xmpp.Message, invite xmpp.XElement, stm stream.C2S) {
+	fromJID := message.FromJID()
+	o := r.occupantByJID(fromJID)
+	if o == nil {
+		stm.SendElement(ctx, message.NotAcceptableError())
+		return
+	}
+	aff := r.affiliation(fromJID)
+	isAdmin := affiliationRank(aff) >= affiliationRank(mucmodel.Admin)
+	if !r.Config.AllowInvites && o.role != moderatorRole && !isAdmin {
+		stm.SendElement(ctx, message.ForbiddenError())
+		return
+	}
+	inviteeJID, err := jid.NewWithString(invite.Attributes().Get("to"), false)
+	if err != nil {
+		stm.SendElement(ctx, message.JidMalformedError())
+		return
+	}
+	if r.Config.MembersOnly && r.affiliation(inviteeJID) == mucmodel.None {
+		if !isAdmin {
+			stm.SendElement(ctx, message.ForbiddenError())
+			return
+		}
+		bareJID := inviteeJID.ToBareJID().String()
+		if err := c.storeAffiliation(ctx, r, bareJID, mucmodel.Member); err != nil {
+			log.Error(err)
+			stm.SendElement(ctx, message.InternalServerError())
+			return
+		}
+		r.setAffiliation(bareJID, mucmodel.Member)
+	}
+	inv := xmpp.NewElementName("invite").SetAttribute("from", fromJID.ToBareJID().String())
+	if reason := invite.Elements().Child("reason"); reason != nil {
+		inv.AppendElement(reason)
+	}
+	x := xmpp.NewElementNamespace("x", mucUserNamespace)
+	x.AppendElement(inv)
+	if r.Config.PasswordProtected {
+		x.AppendElement(xmpp.NewElementName("password").SetText(r.Config.Password))
+	}
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.NormalType)
+	msg.SetFromJID(r.jid)
+	msg.SetToJID(inviteeJID)
+	msg.AppendElement(x)
+	c.route(ctx, msg)
+}
+
+func (c *Muc) sendDecline(ctx context.Context, r *room, message *xmpp.Message, decline xmpp.XElement, stm stream.C2S) {
+	inviterJID, err := jid.NewWithString(decline.Attributes().Get("to"), false)
+	if err != nil {
+		stm.SendElement(ctx, message.JidMalformedError())
+		return
+	}
+	dec := xmpp.NewElementName("decline").SetAttribute("from", message.FromJID().ToBareJID().String())
+	if reason := decline.Elements().Child("reason"); reason != nil {
+		dec.AppendElement(reason)
+	}
+	x := xmpp.NewElementNamespace("x", mucUserNamespace)
+	x.AppendElement(dec)
+
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.NormalType)
+	msg.SetFromJID(r.jid)
+	msg.SetToJID(inviterJID)
+	msg.AppendElement(x)
+	c.route(ctx, msg)
+}
+
+func (c *Muc) sendStatusMessage(ctx context.Context, r *room, codes ...int) {
+	for _, receiver := range r.sortedOccupants() {
+		x := xmpp.NewElementNamespace("x", mucUserNamespace)
+		for _, code := range codes {
+			x.AppendElement(xmpp.NewElementName("status").SetAttribute("code", strconv.Itoa(code)))
+		}
+		msg := xmpp.NewMessageType(uuid.New(), xmpp.GroupChatType)
+		msg.SetFromJID(r.jid)
+		msg.SetToJID(receiver.jid)
+		msg.AppendElement(x)
+		c.route(ctx, msg)
+	}
+}
+
+// occupantMessage returns a copy of a message as sent on behalf of an occupant.
+func occupantMessage(r *room, o *occupant, message *xmpp.Message, toJID *jid.JID) *xmpp.Message {
+	msg := xmpp.NewElementFromElement(message)
+	msg.RemoveElementsNamespace("x", mucUserNamespace)
+	if toJID == nil {
+		toJID = r.jid
+	}
+	m, _ := xmpp.NewMessageFromElement(msg, r.occupantJID(o.nick), toJID)
+	return m
+}
@@ -0,0 +1,204 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ortuman/jackal/log"
+	mucmodel "github.com/ortuman/jackal/model/muc"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage/repository"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/util/runqueue"
+	"github.com/ortuman/jackal/xmpp"
+)
+
+const (
+	mucNamespace      = "http://jabber.org/protocol/muc"
+	mucUserNamespace  = "http://jabber.org/protocol/muc#user"
+	mucAdminNamespace = "http://jabber.org/protocol/muc#admin"
+	mucOwnerNamespace = "http://jabber.org/protocol/muc#owner"
+)
+
+// Muc represents a Multi-User Chat (XEP-0045) component.
+type Muc struct {
+	cfg      *Config
+	disco    *xep0030.DiscoInfo
+	router   router.Router
+	mucRep   repository.Muc
+	runQueue *runqueue.RunQueue
+	mu       sync.RWMutex
+	rooms    map[string]*room
+}
+
+// New returns a new Multi-User Chat component instance.
+func New(cfg *Config, disco *xep0030.DiscoInfo, router router.Router, mucRep repository.Muc) (*Muc, chan<- chan bool) {
+	c := &Muc{
+		cfg:      cfg,
+		disco:    disco,
+		router:   router,
+		mucRep:   mucRep,
+		runQueue: runqueue.NewMetered("muc"),
+		rooms:    make(map[string]*room),
+	}
+	c.loadRooms(context.Background())
+
+	if disco != nil {
+		disco.RegisterServerItem(xep0030.Item{Jid: cfg.Host, Name: cfg.Name})
+		disco.RegisterProvider(cfg.Host, &discoProvider{muc: c})
+	}
+	shutdownCh := make(chan chan bool)
+	go func() {
+		wc := <-shutdownCh
+		c.shutdown()
+		wc <- true
+	}()
+	return c, shutdownCh
+}
+
+// Host returns Multi-User Chat component host domain.
+func (c *Muc) Host() string {
+	return c.cfg.Host
+}
+
+// ProcessStanza processes a stanza addressed to the Multi-User Chat service or any of its rooms.
+func (c *Muc) ProcessStanza(ctx context.Context, stanza xmpp.Stanza, stm stream.C2S) {
+	c.runQueue.Run(func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.processStanza(ctx, stanza, stm)
+	})
+}
+
+func (c *Muc) processStanza(ctx context.Context, stanza xmpp.Stanza, stm stream.C2S) {
+	toJID := stanza.ToJID()
+	if len(toJID.Node()) == 0 {
+		// service domain stanza
+		if iq, ok := stanza.(*xmpp.IQ); ok && (iq.IsGet() || iq.IsSet()) {
+			stm.SendElement(ctx, iq.ServiceUnavailableError())
+		}
+		return
+	}
+	switch stanza := stanza.(type) {
+	case *xmpp.Presence:
+		c.processPresence(ctx, stanza, stm)
+	case *xmpp.Message:
+		c.processMessage(ctx, stanza, stm)
+	case *xmpp.IQ:
+		c.processIQ(ctx, stanza, stm)
+	}
+}
+
+func (c *Muc) loadRooms(ctx context.Context) {
+	rooms, err := c.mucRep.FetchRooms(ctx, c.cfg.Host)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	for i := range rooms {
+		r := newRoom(&rooms[i])
+		affiliations, err := c.mucRep.FetchRoomAffiliations(ctx, r.Host, r.Name)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		for _, aff := range affiliations {
+			r.setAffiliation(aff.JID, aff.Affiliation)
+		}
+		c.rooms[r.Name] = r
+	}
+	if len(c.rooms) > 0 {
+		log.Infof("muc: loaded %d persistent rooms", len(c.rooms))
+	}
+}
+
+func (c *Muc) getRoom(name string) *room {
+	return c.rooms[name]
+}
+
+func (c *Muc) createRoom(name string) *room {
+	r := newRoom(&mucmodel.Room{
+		Host:   c.cfg.Host,
+		Name:   name,
+		Config: mucmodel.DefaultConfig(),
+	})
+	r.locked = true
+	c.rooms[name] = r
+	return r
+}
+
+// purgeRoom removes a temporary room once its last occupant leaves.
+func (c *Muc) purgeRoom(r *room) {
+	if len(r.occupants) > 0 || r.Config.Persistent {
+		return
+	}
+	delete(c.rooms, r.Name)
+	log.Infof("muc: destroyed temporary room %s", r.jid)
+}
+
+func (c *Muc) destroyRoom(ctx context.Context, r *room) error {
+	if r.Config.Persistent {
+		if err := c.mucRep.DeleteRoom(ctx, r.Host, r.Name); err != nil {
+			return err
+		}
+	}
+	delete(c.rooms, r.Name)
+	log.Infof("muc: destroyed room %s", r.jid)
+	return nil
+}
+
+// storeRoom persists room state, or removes it from storage in case it's no longer persistent.
+func (c *Muc) storeRoom(ctx context.Context, r *room, wasPersistent bool) error {
+	if !r.Config.Persistent {
+		if wasPersistent {
+			return c.mucRep.DeleteRoom(ctx, r.Host, r.Name)
+		}
+		return nil
+	}
+	if err := c.mucRep.UpsertRoom(ctx, &r.Room); err != nil {
+		return err
+	}
+	if wasPersistent {
+		return nil
+	}
+	for bareJID, aff := range r.affiliations {
+		err := c.mucRep.UpsertRoomAffiliation(ctx, &mucmodel.Affiliation{JID: bareJID, Affiliation: aff}, r.Host, r.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Muc) storeAffiliation(ctx context.Context, r *room, bareJID, affiliation string) error {
+	if !r.Config.Persistent {
+		return nil
+	}
+	if affiliation == mucmodel.None {
+		return c.mucRep.DeleteRoomAffiliation(ctx, bareJID, r.Host, r.Name)
+	}
+	return c.mucRep.UpsertRoomAffiliation(ctx, &mucmodel.Affiliation{JID: bareJID, Affiliation: affiliation}, r.Host, r.Name)
+}
+
+func (c *Muc) route(ctx context.Context, stanza xmpp.Stanza) {
+	if err := c.router.Route(ctx, stanza); err != nil {
+		log.Warnf("muc: failed to route stanza to %s: %v", stanza.ToJID(), err)
+	}
+}
+
+func (c *Muc) shutdown() {
+	if c.disco != nil {
+		c.disco.UnregisterProvider(c.cfg.Host)
+		c.disco.UnregisterServerItem(xep0030.Item{Jid: c.cfg.Host, Name: c.cfg.Name})
+	}
+	ch := make(chan struct{})
+	c.runQueue.Stop(func() { close(ch) })
+	<-ch
+}
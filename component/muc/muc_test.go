@@ -0,0 +1,410 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	"github.com/ortuman/jackal/auth/backend"
+	c2srouter "github.com/ortuman/jackal/c2s/router"
+	mucmodel "github.com/ortuman/jackal/model/muc"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/router/host"
+	memorystorage "github.com/ortuman/jackal/storage/memory"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+const testHost = "conference.jackal.im"
+
+func TestMuc_CreateAndJoinRoom(t *testing.T) {
+	r, mucRep := setupTest("jackal.im")
+
+	c, shutdownCh := New(&Config{Host: testHost, Name: defaultServiceName}, nil, r, mucRep)
+	defer tUtilShutdown(shutdownCh)
+
+	stm1 := tUtilStream(r, "ortuman", "balcony")
+	stm2 := tUtilStream(r, "noelia", "garden")
+
+	// create room
+	c.ProcessStanza(context.Background(), tUtilJoinPresence(stm1.JID(), "lobby", "ortuman", nil), stm1)
+
+	elem := stm1.ReceiveElement()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, "lobby@conference.jackal.im/ortuman", elem.From())
+	require.Equal(t, []string{"110", "201"}, tUtilStatusCodes(elem))
+	item := tUtilUserItem(elem)
+	require.Equal(t, mucmodel.Owner, item.Attributes().Get("affiliation"))
+	require.Equal(t, moderatorRole, item.Attributes().Get("role"))
+
+	elem = stm1.ReceiveElement()
+	require.Equal(t, "message", elem.Name())
+	require.NotNil(t, elem.Elements().Child("subject"))
+
+	// room is locked until configured
+	c.ProcessStanza(context.Background(), tUtilJoinPresence(stm2.JID(), "lobby", "noelia", nil), stm2)
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.ErrorType, elem.Type())
+	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+
+	// instant room
+	c.ProcessStanza(context.Background(), tUtilOwnerIQ(stm1.JID(), "lobby", &xep0004.DataForm{Type: xep0004.Submit}), stm1)
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	// join room
+	c.ProcessStanza(context.Background(), tUtilJoinPresence(stm2.JID(), "lobby", "noelia", nil), stm2)
+
+	elem = stm2.ReceiveElement()
+	require.Equal(t, "lobby@conference.jackal.im/ortuman", elem.From())
+	require.Equal(t, "", tUtilUserItem(elem).Attributes().Get("jid")) // semi-anonymous room
+
+	elem = stm2.ReceiveElement()
+	require.Equal(t, "lobby@conference.jackal.im/noelia", elem.From())
+	require.Equal(t, []string{"110"}, tUtilStatusCodes(elem))
+	require.Equal(t, participantRole, tUtilUserItem(elem).Attributes().Get("role"))
+
+	elem = stm2.ReceiveElement()
+	require.NotNil(t, elem.Elements().Child("subject"))
+
+	elem = stm1.ReceiveElement()
+	require.Equal(t, "lobby@conference.jackal.im/noelia", elem.From())
+	require.Equal(t, stm2.JID().String(), tUtilUserItem(elem).Attributes().Get("jid")) // moderators see real JIDs
+
+	// nick conflict
+	stm3 := tUtilStream(r, "romeo", "yard")
+	c.ProcessStanza(context.Background(), tUtilJoinPresence(stm3.JID(), "lobby", "noelia", nil), stm3)
+	elem = stm3.ReceiveElement()
+	require.Equal(t, xmpp.ErrConflict.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestMuc_GroupChat(t *testing.T) {
+	r, mucRep := setupTest("jackal.im")
+
+	c, shutdownCh := New(&Config{Host: testHost, Name: defaultServiceName}, nil, r, mucRep)
+	defer tUtilShutdown(shutdownCh)
+
+	stm1 := tUtilStream(r, "ortuman", "balcony")
+	stm2 := tUtilStream(r, "noelia", "garden")
+	tUtilCreateRoom(t, c, stm1, "lobby", "ortuman")
+	tUtilJoinRoom(t, c, stm2, "lobby", "noelia", 1)
+	stm1.ReceiveElement() // noelia's presence
+
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.GroupChatType)
+	msg.SetFromJID(stm2.JID())
+	msg.SetToJID(tUtilRoomJID("lobby", ""))
+	msg.AppendElement(xmpp.NewElementName("body").SetText("Hi!"))
+	c.ProcessStanza(context.Background(), msg, stm2)
+
+	for _, stm := range []*stream.MockC2S{stm1, stm2} {
+		elem := stm.ReceiveElement()
+		require.Equal(t, "message", elem.Name())
+		require.Equal(t, "lobby@conference.jackal.im/noelia", elem.From())
+		require.Equal(t, stm.JID().String(), elem.To())
+		require.Equal(t, "Hi!", elem.Elements().Child("body").Text())
+	}
+
+	// private message
+	msg = xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg.SetFromJID(stm1.JID())
+	msg.SetToJID(tUtilRoomJID("lobby", "noelia"))
+	msg.AppendElement(xmpp.NewElementName("body").SetText("psst"))
+	c.ProcessStanza(context.Background(), msg, stm1)
+
+	elem := stm2.ReceiveElement()
+	require.Equal(t, xmpp.ChatType, elem.Type())
+	require.Equal(t, "lobby@conference.jackal.im/ortuman", elem.From())
+	require.Equal(t, "psst", elem.Elements().Child("body").Text())
+
+	// non-occupant message
+	stm3 := tUtilStream(r, "romeo", "yard")
+	msg = xmpp.NewMessageType(uuid.New(), xmpp.GroupChatType)
+	msg.SetFromJID(stm3.JID())
+	msg.SetToJID(tUtilRoomJID("lobby", ""))
+	msg.AppendElement(xmpp.NewElementName("body").SetText("Hey!"))
+	c.ProcessStanza(context.Background(), msg, stm3)
+	elem = stm3.ReceiveElement()
+	require.Equal(t, xmpp.ErrNotAcceptable.Error(), elem.Error().Elements().All()[0].Name())
+
+	// history on join
+	c.ProcessStanza(context.Background(), tUtilJoinPresence(stm3.JID(), "lobby", "romeo", nil), stm3)
+	for i := 0; i < 3; i++ {
+		require.Equal(t, "presence", stm3.ReceiveElement().Name())
+	}
+	elem = stm3.ReceiveElement()
+	require.Equal(t, "lobby@conference.jackal.im/noelia", elem.From())
+	require.Equal(t, "Hi!", elem.Elements().Child("body").Text())
+	require.NotNil(t, elem.Elements().ChildNamespace("delay", delayNamespace))
+
+	elem = stm3.ReceiveElement()
+	require.NotNil(t, elem.Elements().Child("subject"))
+
+	// leave room
+	c.ProcessStanza(context.Background(), xmpp.NewPresence(stm3.JID(), tUtilRoomJID("lobby", "romeo"), xmpp.UnavailableType), stm3)
+	elem = stm3.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, []string{"110"}, tUtilStatusCodes(elem))
+}
+
+func TestMuc_Moderation(t *testing.T) {
+	r, mucRep := setupTest("jackal.im")
+
+	c, shutdownCh := New(&Config{Host: testHost, Name: defaultServiceName}, nil, r, mucRep)
+	defer tUtilShutdown(shutdownCh)
+
+	stm1 := tUtilStream(r, "ortuman", "balcony")
+	stm2 := tUtilStream(r, "noelia", "garden")
+	tUtilCreateRoom(t, c, stm1, "lobby", "ortuman")
+	tUtilJoinRoom(t, c, stm2, "lobby", "noelia", 1)
+	stm1.ReceiveElement() // noelia's presence
+
+	// participants can't kick
+	c.ProcessStanza(context.Background(), tUtilAdminIQ(stm2.JID(), "lobby", xmpp.NewElementName("item").SetAttribute("nick", "ortuman").SetAttribute("role", noneRole)), stm2)
+	elem := stm2.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	// kick
+	c.ProcessStanza(context.Background(), tUtilAdminIQ(stm1.JID(), "lobby", xmpp.NewElementName("item").SetAttribute("nick", "noelia").SetAttribute("role", noneRole)), stm1)
+
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, []string{"307"}, tUtilStatusCodes(elem))
+	require.Equal(t, xmpp.ResultType, stm1.ReceiveElement().Type())
+
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, []string{"110", "307"}, tUtilStatusCodes(elem))
+
+	// ban
+	tUtilJoinRoom(t, c, stm2, "lobby", "noelia", 1)
+	stm1.ReceiveElement() // noelia's presence
+
+	c.ProcessStanza(context.Background(), tUtilAdminIQ(stm1.JID(), "lobby", xmpp.NewElementName("item").SetAttribute("jid", "noelia@jackal.im").SetAttribute("affiliation", mucmodel.Outcast)), stm1)
+
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, []string{"110", "301"}, tUtilStatusCodes(elem))
+	require.Equal(t, mucmodel.Outcast, tUtilUserItem(elem).Attributes().Get("affiliation"))
+
+	require.Equal(t, xmpp.UnavailableType, stm1.ReceiveElement().Type())
+	require.Equal(t, xmpp.ResultType, stm1.ReceiveElement().Type())
+
+	c.ProcessStanza(context.Background(), tUtilJoinPresence(stm2.JID(), "lobby", "noelia", nil), stm2)
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	// outcast list
+	iq := tUtilAdminIQ(stm1.JID(), "lobby", xmpp.NewElementName("item").SetAttribute("affiliation", mucmodel.Outcast))
+	iq.SetType(xmpp.GetType)
+	c.ProcessStanza(context.Background(), iq, stm1)
+
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	items := elem.Elements().ChildNamespace("query", mucAdminNamespace).Elements().Children("item")
+	require.Len(t, items, 1)
+	require.Equal(t, "noelia@jackal.im", items[0].Attributes().Get("jid"))
+
+	// removing last owner
+	c.ProcessStanza(context.Background(), tUtilAdminIQ(stm1.JID(), "lobby", xmpp.NewElementName("item").SetAttribute("jid", "ortuman@jackal.im").SetAttribute("affiliation", mucmodel.Member)), stm1)
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.ErrConflict.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestMuc_PersistentRoom(t *testing.T) {
+	r, mucRep := setupTest("jackal.im")
+
+	c, shutdownCh := New(&Config{Host: testHost, Name: defaultServiceName}, nil, r, mucRep)
+
+	stm1 := tUtilStream(r, "ortuman", "balcony")
+	tUtilCreateRoom(t, c, stm1, "lobby", "ortuman")
+
+	form := &xep0004.DataForm{
+		Type: xep0004.Submit,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{"http://jabber.org/protocol/muc#roomconfig"}},
+			{Var: "muc#roomconfig_roomname", Values: []string{"The Lobby"}},
+			{Var: "muc#roomconfig_persistentroom", Values: []string{"1"}},
+			{Var: "muc#roomconfig_passwordprotectedroom", Values: []string{"1"}},
+			{Var: "muc#roomconfig_roomsecret", Values: []string{"s3cr3t"}},
+		},
+	}
+	c.ProcessStanza(context.Background(), tUtilOwnerIQ(stm1.JID(), "lobby", form), stm1)
+	require.Equal(t, xmpp.ResultType, stm1.ReceiveElement().Type())
+
+	elem := stm1.ReceiveElement() // config change notification
+	require.Equal(t, []string{"104"}, tUtilStatusCodes(elem))
+
+	room, _ := mucRep.FetchRoom(context.Background(), testHost, "lobby")
+	require.NotNil(t, room)
+	require.Equal(t, "The Lobby", room.Config.Name)
+
+	affiliations, _ := mucRep.FetchRoomAffiliations(context.Background(), testHost, "lobby")
+	require.Len(t, affiliations, 1)
+	require.Equal(t, "ortuman@jackal.im", affiliations[0].JID)
+
+	// room survives its last occupant and service restarts
+	c.ProcessStanza(context.Background(), xmpp.NewPresence(stm1.JID(), tUtilRoomJID("lobby", "ortuman"), xmpp.UnavailableType), stm1)
+	stm1.ReceiveElement()
+	tUtilShutdown(shutdownCh)
+
+	c, shutdownCh = New(&Config{Host: testHost, Name: defaultServiceName}, nil, r, mucRep)
+	defer tUtilShutdown(shutdownCh)
+
+	stm2 := tUtilStream(r, "noelia", "garden")
+	c.ProcessStanza(context.Background(), tUtilJoinPresence(stm2.JID(), "lobby", "noelia", nil), stm2)
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.ErrNotAuthorized.Error(), elem.Error().Elements().All()[0].Name())
+
+	c.ProcessStanza(context.Background(), tUtilJoinPresence(stm2.JID(), "lobby", "noelia", xmpp.NewElementName("password").SetText("s3cr3t")), stm2)
+	elem = stm2.ReceiveElement()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, mucmodel.None, tUtilUserItem(elem).Attributes().Get("affiliation"))
+
+	// destroy room
+	destroy := xmpp.NewElementNamespace("query", mucOwnerNamespace)
+	destroy.AppendElement(xmpp.NewElementName("destroy"))
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(stm1.JID())
+	iq.SetToJID(tUtilRoomJID("lobby", ""))
+	iq.AppendElement(destroy)
+	c.ProcessStanza(context.Background(), iq, stm1)
+	require.Equal(t, xmpp.ResultType, stm1.ReceiveElement().Type())
+
+	stm2.ReceiveElement() // subject
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.NotNil(t, elem.Elements().ChildNamespace("x", mucUserNamespace).Elements().Child("destroy"))
+
+	room, _ = mucRep.FetchRoom(context.Background(), testHost, "lobby")
+	require.Nil(t, room)
+}
+
+func TestMuc_Disco(t *testing.T) {
+	r, mucRep := setupTest("jackal.im")
+
+	c, shutdownCh := New(&Config{Host: testHost, Name: defaultServiceName}, nil, r, mucRep)
+	defer tUtilShutdown(shutdownCh)
+
+	stm1 := tUtilStream(r, "ortuman", "balcony")
+	tUtilCreateRoom(t, c, stm1, "lobby", "ortuman")
+
+	dp := &discoProvider{muc: c}
+	serviceJID, _ := jid.New("", testHost, "", true)
+
+	identities := dp.Identities(context.Background(), serviceJID, stm1.JID(), "")
+	require.Len(t, identities, 1)
+	require.Equal(t, "conference", identities[0].Category)
+
+	items, sErr := dp.Items(context.Background(), serviceJID, stm1.JID(), "")
+	require.Nil(t, sErr)
+	require.Len(t, items, 1)
+	require.Equal(t, "lobby@conference.jackal.im", items[0].Jid)
+
+	features, sErr := dp.Features(context.Background(), tUtilRoomJID("lobby", ""), stm1.JID(), "")
+	require.Nil(t, sErr)
+	require.Contains(t, features, "muc_temporary")
+	require.Contains(t, features, "muc_semianonymous")
+
+	_, sErr = dp.Features(context.Background(), tUtilRoomJID("hall", ""), stm1.JID(), "")
+	require.Equal(t, xmpp.ErrItemNotFound, sErr)
+}
+
+func tUtilCreateRoom(t *testing.T, c *Muc, stm *stream.MockC2S, room, nick string) {
+	c.ProcessStanza(context.Background(), tUtilJoinPresence(stm.JID(), room, nick, nil), stm)
+	require.Equal(t, "presence", stm.ReceiveElement().Name())
+	require.Equal(t, "message", stm.ReceiveElement().Name())
+
+	c.ProcessStanza(context.Background(), tUtilOwnerIQ(stm.JID(), room, &xep0004.DataForm{Type: xep0004.Submit}), stm)
+	require.Equal(t, xmpp.ResultType, stm.ReceiveElement().Type())
+}
+
+func tUtilJoinRoom(t *testing.T, c *Muc, stm *stream.MockC2S, room, nick string, occupants int) {
+	c.ProcessStanza(context.Background(), tUtilJoinPresence(stm.JID(), room, nick, nil), stm)
+	for i := 0; i < occupants+1; i++ {
+		require.Equal(t, "presence", stm.ReceiveElement().Name())
+	}
+	require.Equal(t, "message", stm.ReceiveElement().Name())
+}
+
+func tUtilJoinPresence(from *jid.JID, room, nick string, child xmpp.XElement) *xmpp.Presence {
+	x := xmpp.NewElementNamespace("x", mucNamespace)
+	if child != nil {
+		x.AppendElement(child)
+	}
+	p := xmpp.NewPresence(from, tUtilRoomJID(room, nick), xmpp.AvailableType)
+	p.AppendElement(x)
+	return p
+}
+
+func tUtilOwnerIQ(from *jid.JID, room string, form *xep0004.DataForm) *xmpp.IQ {
+	query := xmpp.NewElementNamespace("query", mucOwnerNamespace)
+	query.AppendElement(form.Element())
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(tUtilRoomJID(room, ""))
+	iq.AppendElement(query)
+	return iq
+}
+
+func tUtilAdminIQ(from *jid.JID, room string, item xmpp.XElement) *xmpp.IQ {
+	query := xmpp.NewElementNamespace("query", mucAdminNamespace)
+	query.AppendElement(item)
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(tUtilRoomJID(room, ""))
+	iq.AppendElement(query)
+	return iq
+}
+
+func tUtilUserItem(elem xmpp.XElement) xmpp.XElement {
+	return elem.Elements().ChildNamespace("x", mucUserNamespace).Elements().Child("item")
+}
+
+func tUtilStatusCodes(elem xmpp.XElement) []string {
+	var codes []string
+	for _, status := range elem.Elements().ChildNamespace("x", mucUserNamespace).Elements().Children("status") {
+		codes = append(codes, status.Attributes().Get("code"))
+	}
+	return codes
+}
+
+func tUtilRoomJID(room, nick string) *jid.JID {
+	j, _ := jid.New(room, testHost, nick, true)
+	return j
+}
+
+func tUtilStream(r router.Router, username, resource string) *stream.MockC2S {
+	j, _ := jid.New(username, "jackal.im", resource, true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+	return stm
+}
+
+func tUtilShutdown(shutdownCh chan<- chan bool) {
+	wc := make(chan bool, 1)
+	shutdownCh <- wc
+	<-wc
+}
+
+func setupTest(domain string) (router.Router, *memorystorage.Muc) {
+	hosts, _ := host.New([]host.Config{{Name: domain, Certificate: tls.Certificate{}}})
+
+	r, _ := router.New(
+		hosts,
+		c2srouter.New(backend.NewStorage(memorystorage.NewUser()), memorystorage.NewBlockList()),
+		nil,
+	)
+	return r, memorystorage.NewMuc()
+}
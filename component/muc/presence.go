@@ -0,0 +1,217 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/ortuman/jackal/log"
+	mucmodel "github.com/ortuman/jackal/model/muc"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/pborman/uuid"
+)
+
+const timeLayout = "2006-01-02T15:04:05Z"
+
+const delayNamespace = "urn:xmpp:delay"
+
+func (c *Muc) processPresence(ctx context.Context, presence *xmpp.Presence, stm stream.C2S) {
+	fromJID := presence.FromJID()
+	toJID := presence.ToJID()
+
+	r := c.getRoom(toJID.Node())
+	if presence.IsUnavailable() {
+		if r == nil {
+			return
+		}
+		if o := r.occupantByJID(fromJID); o != nil {
+			c.leaveRoom(ctx, r, o, presence)
+		}
+		return
+	}
+	if !presence.IsAvailable() {
+		return
+	}
+	nick := toJID.Resource()
+	if len(nick) == 0 {
+		stm.SendElement(ctx, presence.JidMalformedError())
+		return
+	}
+	if r == nil {
+		c.joinRoom(ctx, c.createRoom(toJID.Node()), presence, stm, true)
+		return
+	}
+	o := r.occupantByJID(fromJID)
+	switch {
+	case o == nil:
+		c.joinRoom(ctx, r, presence, stm, false)
+	case o.nick != nick:
+		c.changeNick(ctx, r, o, presence, stm)
+	default:
+		o.presence = presence
+		for _, receiver := range r.sortedOccupants() {
+			c.route(ctx, r.occupantPresence(o, receiver, "", nil, statusCodes(o, receiver)...))
+		}
+	}
+}
+
+func (c *Muc) joinRoom(ctx context.Context, r *room, presence *xmpp.Presence, stm stream.C2S, created bool) {
+	fromJID := presence.FromJID()
+	nick := presence.ToJID().Resource()
+
+	if created {
+		r.setAffiliation(fromJID.ToBareJID().String(), mucmodel.Owner)
+		log.Infof("muc: created room %s... (%s)", r.jid, fromJID)
+	} else if sErr := c.checkJoin(r, presence); sErr != nil {
+		stm.SendElement(ctx, xmpp.NewErrorStanzaFromStanza(presence, sErr, nil))
+		return
+	}
+	o := &occupant{
+		jid:      fromJID,
+		nick:     nick,
+		role:     r.defaultRole(r.affiliation(fromJID)),
+		presence: presence,
+	}
+	// send current occupants presences to the new occupant
+	for _, other := range r.sortedOccupants() {
+		c.route(ctx, r.occupantPresence(other, o, "", nil))
+	}
+	r.occupants[nick] = o
+
+	// broadcast new occupant presence
+	for _, receiver := range r.sortedOccupants() {
+		var codes []int
+		if receiver == o {
+			if r.Config.IsNonAnonymous() {
+				codes = append(codes, nonAnonymousStatus)
+			}
+			if created {
+				codes = append(codes, roomCreatedStatus)
+			}
+		}
+		c.route(ctx, r.occupantPresence(o, receiver, "", nil, statusCodes(o, receiver, codes...)...))
+	}
+	c.sendHistory(ctx, r, o, presence)
+	c.sendSubject(ctx, r, o)
+
+	log.Infof("muc: occupant joined room %s... (%s)", r.jid, fromJID)
+}
+
+func (c *Muc) checkJoin(r *room, presence *xmpp.Presence) *xmpp.StanzaError {
+	fromJID := presence.FromJID()
+	nick := presence.ToJID().Resource()
+	aff := r.affiliation(fromJID)
+
+	switch {
+	case aff == mucmodel.Outcast:
+		return xmpp.ErrForbidden
+	case r.locked && aff != mucmodel.Owner:
+		return xmpp.ErrItemNotFound
+	case r.Config.MembersOnly && aff == mucmodel.None:
+		return xmpp.ErrRegistrationRequired
+	case r.Config.PasswordProtected && joinPassword(presence) != r.Config.Password:
+		return xmpp.ErrNotAuthorized
+	}
+	if o := r.occupants[nick]; o != nil {
+		return xmpp.ErrConflict
+	}
+	maxOccupants := r.Config.MaxOccupants
+	if maxOccupants > 0 && len(r.occupants) >= maxOccupants && affiliationRank(aff) < affiliationRank(mucmodel.Admin) {
+		return xmpp.ErrServiceUnavailable
+	}
+	return nil
+}
+
+func (c *Muc) changeNick(ctx context.Context, r *room, o *occupant, presence *xmpp.Presence, stm stream.C2S) {
+	nick := presence.ToJID().Resource()
+	if r.occupants[nick] != nil {
+		stm.SendElement(ctx, presence.ConflictError())
+		return
+	}
+	for _, receiver := range r.sortedOccupants() {
+		item := r.occupantItem(o, receiver)
+		item.SetAttribute("nick", nick)
+		c.route(ctx, r.occupantPresence(o, receiver, xmpp.UnavailableType, item, statusCodes(o, receiver, nickChangedStatus)...))
+	}
+	delete(r.occupants, o.nick)
+	o.nick = nick
+	o.presence = presence
+	r.occupants[nick] = o
+
+	for _, receiver := range r.sortedOccupants() {
+		c.route(ctx, r.occupantPresence(o, receiver, "", nil, statusCodes(o, receiver)...))
+	}
+}
+
+func (c *Muc) leaveRoom(ctx context.Context, r *room, o *occupant, presence *xmpp.Presence) {
+	for _, receiver := range r.sortedOccupants() {
+		p := r.occupantPresence(o, receiver, xmpp.UnavailableType, nil, statusCodes(o, receiver)...)
+		if status := presence.Elements().Child("status"); status != nil {
+			p.AppendElement(status)
+		}
+		c.route(ctx, p)
+	}
+	delete(r.occupants, o.nick)
+	c.purgeRoom(r)
+
+	log.Infof("muc: occupant left room %s... (%s)", r.jid, o.jid)
+}
+
+// removeOccupant removes an occupant from a room as a result of a kick, ban or affiliation change.
+func (c *Muc) removeOccupant(ctx context.Context, r *room, o *occupant, affiliation string, code int) {
+	for _, receiver := range r.sortedOccupants() {
+		item := r.occupantItem(o, receiver)
+		item.SetAttribute("affiliation", affiliation)
+		item.SetAttribute("role", noneRole)
+		c.route(ctx, r.occupantPresence(o, receiver, xmpp.UnavailableType, item, statusCodes(o, receiver, code)...))
+	}
+	delete(r.occupants, o.nick)
+}
+
+func (c *Muc) sendHistory(ctx context.Context, r *room, o *occupant, presence *xmpp.Presence) {
+	history := r.history
+	if x := presence.Elements().ChildNamespace("x", mucNamespace); x != nil {
+		if h := x.Elements().Child("history"); h != nil {
+			if maxStanzas, err := strconv.Atoi(h.Attributes().Get("maxstanzas")); err == nil && maxStanzas >= 0 && maxStanzas < len(history) {
+				history = history[len(history)-maxStanzas:]
+			}
+		}
+	}
+	for _, hm := range history {
+		msg := xmpp.NewElementFromElement(hm.message)
+		msg.AppendElement(xmpp.NewElementNamespace("delay", delayNamespace).
+			SetAttribute("from", r.jid.String()).
+			SetAttribute("stamp", hm.stamp.UTC().Format(timeLayout)))
+
+		m, err := xmpp.NewMessageFromElement(msg, hm.message.FromJID(), o.jid)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		c.route(ctx, m)
+	}
+}
+
+func (c *Muc) sendSubject(ctx context.Context, r *room, o *occupant) {
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.GroupChatType)
+	msg.SetFromJID(r.jid)
+	msg.SetToJID(o.jid)
+	msg.AppendElement(xmpp.NewElementName("subject").SetText(r.Subject))
+	c.route(ctx, msg)
+}
+
+func joinPassword(presence *xmpp.Presence) string {
+	x := presence.Elements().ChildNamespace("x", mucNamespace)
+	if x == nil {
+		return ""
+	}
+	if password := x.Elements().Child("password"); password != nil {
+		return password.Text()
+	}
+	return ""
+}
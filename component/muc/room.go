@@ -0,0 +1,245 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	mucmodel "github.com/ortuman/jackal/model/muc"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+// role definitions
+const (
+	moderatorRole   = "moderator"
+	participantRole = "participant"
+	visitorRole     = "visitor"
+	noneRole        = "none"
+)
+
+// status code definitions
+const (
+	nonAnonymousStatus  = 100
+	selfPresenceStatus  = 110
+	configChangedStatus = 104
+	roomCreatedStatus   = 201
+	bannedStatus        = 301
+	nickChangedStatus   = 303
+	kickedStatus        = 307
+	membershipStatus    = 321
+)
+
+type occupant struct {
+	jid      *jid.JID
+	nick     string
+	role     string
+	presence *xmpp.Presence
+}
+
+type historyMessage struct {
+	message *xmpp.Message
+	stamp   time.Time
+}
+
+type room struct {
+	mucmodel.Room
+	jid          *jid.JID
+	affiliations map[string]string
+	occupants    map[string]*occupant
+	history      []historyMessage
+	locked       bool
+}
+
+func newRoom(r *mucmodel.Room) *room {
+	roomJID, _ := jid.New(r.Name, r.Host, "", true)
+	return &room{
+		Room:         *r,
+		jid:          roomJID,
+		affiliations: make(map[string]string),
+		occupants:    make(map[string]*occupant),
+	}
+}
+
+func (r *room) affiliation(j *jid.JID) string {
+	if aff, ok := r.affiliations[j.ToBareJID().String()]; ok {
+		return aff
+	}
+	return mucmodel.None
+}
+
+func (r *room) setAffiliation(bareJID string, affiliation string) {
+	if affiliation == mucmodel.None {
+		delete(r.affiliations, bareJID)
+		return
+	}
+	r.affiliations[bareJID] = affiliation
+}
+
+func (r *room) ownerCount() int {
+	var count int
+	for _, aff := range r.affiliations {
+		if aff == mucmodel.Owner {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *room) defaultRole(affiliation string) string {
+	switch affiliation {
+	case mucmodel.Owner, mucmodel.Admin:
+		return moderatorRole
+	case mucmodel.Member:
+		return participantRole
+	}
+	if r.Config.Moderated {
+		return visitorRole
+	}
+	return participantRole
+}
+
+func (r *room) occupantByJID(j *jid.JID) *occupant {
+	for _, o := range r.occupants {
+		if o.jid.Matches(j) {
+			return o
+		}
+	}
+	return nil
+}
+
+func (r *room) occupantsByBareJID(bareJID string) []*occupant {
+	var ret []*occupant
+	for _, o := range r.sortedOccupants() {
+		if o.jid.ToBareJID().String() == bareJID {
+			ret = append(ret, o)
+		}
+	}
+	return ret
+}
+
+func (r *room) sortedOccupants() []*occupant {
+	nicks := make([]string, 0, len(r.occupants))
+	for nick := range r.occupants {
+		nicks = append(nicks, nick)
+	}
+	sort.Strings(nicks)
+
+	ret := make([]*occupant, 0, len(nicks))
+	for _, nick := range nicks {
+		ret = append(ret, r.occupants[nick])
+	}
+	return ret
+}
+
+func (r *room) occupantJID(nick string) *jid.JID {
+	j, _ := jid.New(r.Name, r.Host, nick, true)
+	return j
+}
+
+func (r *room) addHistory(message *xmpp.Message) {
+	if r.Config.HistoryLength == 0 {
+		r.history = nil
+		return
+	}
+	r.history = append(r.history, historyMessage{message: message, stamp: time.Now()})
+	if len(r.history) > r.Config.HistoryLength {
+		r.history = r.history[len(r.history)-r.Config.HistoryLength:]
+	}
+}
+
+// occupantPresence returns the presence broadcasted on behalf of an occupant to a concrete receiver.
+func (r *room) occupantPresence(o *occupant, receiver *occupant, presenceType string, item xmpp.XElement, codes ...int) *xmpp.Presence {
+	p := xmpp.NewElementName("presence")
+	if len(presenceType) > 0 {
+		p.SetType(presenceType)
+	}
+	if o.presence != nil && presenceType != xmpp.UnavailableType {
+		for _, child := range o.presence.Elements().All() {
+			switch child.Namespace() {
+			case mucNamespace, mucUserNamespace:
+				continue
+			}
+			p.AppendElement(child)
+		}
+	}
+	if item == nil {
+		item = r.occupantItem(o, receiver)
+	}
+	x := xmpp.NewElementNamespace("x", mucUserNamespace)
+	x.AppendElement(item)
+	for _, code := range codes {
+		x.AppendElement(xmpp.NewElementName("status").SetAttribute("code", strconv.Itoa(code)))
+	}
+	p.AppendElement(x)
+
+	presence, _ := xmpp.NewPresenceFromElement(p, r.occupantJID(o.nick), receiver.jid)
+	return presence
+}
+
+// occupantItem returns the muc#user item describing an occupant, exposing its real JID only when allowed.
+func (r *room) occupantItem(o *occupant, receiver *occupant) *xmpp.Element {
+	item := xmpp.NewElementName("item")
+	item.SetAttribute("affiliation", r.affiliation(o.jid))
+	item.SetAttribute("role", o.role)
+	if r.Config.IsNonAnonymous() || receiver.role == moderatorRole {
+		item.SetAttribute("jid", o.jid.String())
+	}
+	return item
+}
+
+// statusCodes returns the status codes attached to an occupant presence, including the self-presence one if required.
+func statusCodes(o *occupant, receiver *occupant, codes ...int) []int {
+	if o == receiver {
+		return append([]int{selfPresenceStatus}, codes...)
+	}
+	return codes
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func roleRank(role string) int {
+	switch role {
+	case moderatorRole:
+		return 3
+	case participantRole:
+		return 2
+	case visitorRole:
+		return 1
+	}
+	return 0
+}
+
+func affiliationRank(affiliation string) int {
+	switch affiliation {
+	case mucmodel.Owner:
+		return 4
+	case mucmodel.Admin:
+		return 3
+	case mucmodel.Member:
+		return 2
+	case mucmodel.None:
+		return 1
+	}
+	return 0
+}
+
+func isValidAffiliation(affiliation string) bool {
+	return affiliationRank(affiliation) > 0 || affiliation == mucmodel.Outcast
+}
+
+func isValidRole(role string) bool {
+	return roleRank(role) > 0 || role == noneRole
+}
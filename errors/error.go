@@ -11,7 +11,8 @@ import (
 
 // Error represents a "stream:error" element.
 type Error struct {
-	reason string
+	reason  string
+	appCond xmpp.XElement
 }
 
 var (
@@ -56,17 +57,28 @@ var (
 
 	// ErrInternalServerError represents 'internal-server-error' stream error.
 	ErrInternalServerError = newStreamError("internal-server-error")
+
+	// ErrConflict represents 'conflict' stream error.
+	ErrConflict = newStreamError("conflict")
 )
 
 func newStreamError(reason string) *Error {
 	return &Error{reason: reason}
 }
 
+// WithApplicationCondition returns a copy of the stream error carrying an application-specific condition element.
+func (se *Error) WithApplicationCondition(cond xmpp.XElement) *Error {
+	return &Error{reason: se.reason, appCond: cond}
+}
+
 // Element returns stream error XML node.
 func (se *Error) Element() xmpp.XElement {
 	ret := xmpp.NewElementName("stream:error")
 	reason := xmpp.NewElementNamespace(se.reason, "urn:ietf:params:xml:ns:xmpp-streams")
 	ret.AppendElement(reason)
+	if se.appCond != nil {
+		ret.AppendElement(se.appCond)
+	}
 	return ret
 }
 
@@ -8,6 +8,7 @@ package streamerror
 import (
 	"testing"
 
+	"github.com/ortuman/jackal/xmpp"
 	"github.com/stretchr/testify/require"
 )
 
@@ -44,4 +45,20 @@ func TestStreamError(t *testing.T) {
 
 	require.Equal(t, "internal-server-error", ErrInternalServerError.Error())
 	require.Equal(t, "internal-server-error", ErrInternalServerError.Element().Elements().All()[0].Name())
+
+	require.Equal(t, "conflict", ErrConflict.Error())
+	require.Equal(t, "conflict", ErrConflict.Element().Elements().All()[0].Name())
+}
+
+func TestStreamError_ApplicationCondition(t *testing.T) {
+	err := ErrUndefinedCondition.WithApplicationCondition(xmpp.NewElementNamespace("handled-count-too-high", "urn:xmpp:sm:3"))
+	require.Equal(t, "undefined-condition", err.Error())
+
+	elems := err.Element().Elements().All()
+	require.Len(t, elems, 2)
+	require.Equal(t, "undefined-condition", elems[0].Name())
+	require.Equal(t, "handled-count-too-high", elems[1].Name())
+
+	// original error is left untouched
+	require.Len(t, ErrUndefinedCondition.Element().Elements().All(), 1)
 }
@@ -6,6 +6,7 @@
 package log
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -15,6 +16,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -139,6 +141,20 @@ func Unset() {
 	Set(Disabled)
 }
 
+// SetLevel changes global logger level at runtime.
+func SetLevel(level string) error {
+	lvl, err := levelFromString(level)
+	if err != nil {
+		return err
+	}
+	l, ok := instance().(*logger)
+	if !ok {
+		return errors.New("log: logger level cannot be changed")
+	}
+	atomic.StoreInt32(&l.level, int32(lvl))
+	return nil
+}
+
 func instance() Logger {
 	instMu.RLock()
 	l := inst
@@ -162,7 +178,7 @@ type record struct {
 }
 
 type logger struct {
-	level  Level
+	level  int32
 	output io.Writer
 	files  []io.WriteCloser
 	b      strings.Builder
@@ -176,7 +192,7 @@ func New(level string, output io.Writer, files ...io.WriteCloser) (Logger, error
 		return nil, err
 	}
 	l := &logger{
-		level:  lvl,
+		level:  int32(lvl),
 		output: output,
 		files:  files,
 	}
@@ -186,7 +202,7 @@ func New(level string, output io.Writer, files ...io.WriteCloser) (Logger, error
 }
 
 func (l *logger) Level() Level {
-	return l.level
+	return Level(atomic.LoadInt32(&l.level))
 }
 
 func (l *logger) Log(level Level, pkg string, file string, line int, format string, args ...interface{}) {
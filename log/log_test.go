@@ -143,6 +143,24 @@ func TestFatalLog(t *testing.T) {
 	require.True(t, strings.Contains(l, "some error string"))
 }
 
+func TestSetLevel(t *testing.T) {
+	bw, _, tearDown := setupTest("warning")
+	defer tearDown()
+
+	Infof("test info log!")
+	require.Nil(t, SetLevel("info"))
+	require.Equal(t, InfoLevel, instance().Level())
+
+	Infof("test reloaded info log!")
+	time.Sleep(time.Millisecond * 250)
+
+	l := bw.String()
+	require.False(t, strings.Contains(l, "test info log!"))
+	require.True(t, strings.Contains(l, "test reloaded info log!"))
+
+	require.NotNil(t, SetLevel("verbose"))
+}
+
 func TestLogFile(t *testing.T) {
 	bw, lf, tearDown := setupTest("debug")
 
@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package model
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+
+	"github.com/ortuman/jackal/xmpp"
+)
+
+// ArchivedMessage represents a message archive (XEP-0313) storage entity.
+type ArchivedMessage struct {
+	Username string
+	ID       string
+	With     string
+	Message  *xmpp.Message
+	Stamp    time.Time
+}
+
+// FromBytes deserializes an ArchivedMessage entity from its binary representation.
+func (am *ArchivedMessage) FromBytes(buf *bytes.Buffer) error {
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&am.Username); err != nil {
+		return err
+	}
+	if err := dec.Decode(&am.ID); err != nil {
+		return err
+	}
+	if err := dec.Decode(&am.With); err != nil {
+		return err
+	}
+	if err := dec.Decode(&am.Stamp); err != nil {
+		return err
+	}
+	msg, err := xmpp.NewMessageFromBytes(buf)
+	if err != nil {
+		return err
+	}
+	am.Message = msg
+	return nil
+}
+
+// ToBytes converts an ArchivedMessage entity to its binary representation.
+func (am *ArchivedMessage) ToBytes(buf *bytes.Buffer) error {
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(&am.Username); err != nil {
+		return err
+	}
+	if err := enc.Encode(&am.ID); err != nil {
+		return err
+	}
+	if err := enc.Encode(&am.With); err != nil {
+		return err
+	}
+	if err := enc.Encode(&am.Stamp); err != nil {
+		return err
+	}
+	return am.Message.ToBytes(buf)
+}
+
+// ArchiveQuery represents a set of filters used to retrieve archived messages.
+type ArchiveQuery struct {
+	// With restricts results to those exchanged with a given bare JID.
+	With string
+
+	// Start and End restrict results to a given time interval.
+	Start time.Time
+	End   time.Time
+
+	// After and Before restrict results to those archived after or before a given message identifier.
+	After  string
+	Before string
+
+	// LastPage requests the last page of the result set.
+	LastPage bool
+
+	// Max limits the number of returned messages. Zero value means no limit.
+	Max int
+}
+
+// IsBackwards tells whether or not the result set should be paged backwards.
+func (q *ArchiveQuery) IsBackwards() bool {
+	return len(q.Before) > 0 || q.LastPage
+}
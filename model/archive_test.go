@@ -0,0 +1,48 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package model
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchivedMessage(t *testing.T) {
+	j1, _ := jid.NewWithString("ortuman@jackal.im/balcony", true)
+	j2, _ := jid.NewWithString("noelia@jackal.im/yard", true)
+
+	msg := xmpp.NewMessageType("1234", xmpp.ChatType)
+	msg.SetFromJID(j1)
+	msg.SetToJID(j2)
+
+	var am1, am2 ArchivedMessage
+	am1 = ArchivedMessage{
+		Username: "ortuman",
+		ID:       "abcd",
+		With:     "noelia@jackal.im",
+		Message:  msg,
+		Stamp:    time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+	buf := new(bytes.Buffer)
+	require.Nil(t, am1.ToBytes(buf))
+	require.Nil(t, am2.FromBytes(buf))
+	require.Equal(t, am1.Username, am2.Username)
+	require.Equal(t, am1.ID, am2.ID)
+	require.Equal(t, am1.With, am2.With)
+	require.True(t, am1.Stamp.Equal(am2.Stamp))
+	require.Equal(t, am1.Message.String(), am2.Message.String())
+}
+
+func TestArchiveQuery_IsBackwards(t *testing.T) {
+	require.False(t, (&ArchiveQuery{After: "abcd"}).IsBackwards())
+	require.True(t, (&ArchiveQuery{Before: "abcd"}).IsBackwards())
+	require.True(t, (&ArchiveQuery{LastPage: true}).IsBackwards())
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package model
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+)
+
+// FastToken represents a FAST (XEP-0484) authentication token storage entity.
+type FastToken struct {
+	Username  string
+	ClientID  string
+	Mechanism string
+	Token     string
+	ExpiresAt time.Time
+}
+
+// IsExpired tells whether or not token has expired.
+func (ft *FastToken) IsExpired() bool {
+	return time.Now().After(ft.ExpiresAt)
+}
+
+// FromBytes deserializes a FastToken entity from its binary representation.
+func (ft *FastToken) FromBytes(buf *bytes.Buffer) error {
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&ft.Username); err != nil {
+		return err
+	}
+	if err := dec.Decode(&ft.ClientID); err != nil {
+		return err
+	}
+	if err := dec.Decode(&ft.Mechanism); err != nil {
+		return err
+	}
+	if err := dec.Decode(&ft.Token); err != nil {
+		return err
+	}
+	return dec.Decode(&ft.ExpiresAt)
+}
+
+// ToBytes converts a FastToken entity to its binary representation.
+func (ft *FastToken) ToBytes(buf *bytes.Buffer) error {
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(&ft.Username); err != nil {
+		return err
+	}
+	if err := enc.Encode(&ft.ClientID); err != nil {
+		return err
+	}
+	if err := enc.Encode(&ft.Mechanism); err != nil {
+		return err
+	}
+	if err := enc.Encode(&ft.Token); err != nil {
+		return err
+	}
+	return enc.Encode(&ft.ExpiresAt)
+}
@@ -0,0 +1,34 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package model
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFastToken(t *testing.T) {
+	ft1 := FastToken{
+		Username:  "ortuman",
+		ClientID:  "d4565fa7-4d72-4749-b3d3-740edbf87770",
+		Mechanism: "HT-SHA-256-NONE",
+		Token:     "WxPSEFKvHRiOYuMr3AtF6Q",
+		ExpiresAt: time.Now().Add(time.Hour).UTC(),
+	}
+	var ft2 FastToken
+	buf := new(bytes.Buffer)
+	require.Nil(t, ft1.ToBytes(buf))
+	require.Nil(t, ft2.FromBytes(buf))
+	require.Equal(t, ft1.Token, ft2.Token)
+	require.True(t, ft1.ExpiresAt.Equal(ft2.ExpiresAt))
+	require.False(t, ft2.IsExpired())
+
+	ft2.ExpiresAt = time.Now().Add(-time.Second)
+	require.True(t, ft2.IsExpired())
+}
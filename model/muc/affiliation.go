@@ -0,0 +1,44 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mucmodel
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// affiliation definitions
+const (
+	Owner   = "owner"
+	Admin   = "admin"
+	Member  = "member"
+	Outcast = "outcast"
+	None    = "none"
+)
+
+// Affiliation represents a room affiliation
+type Affiliation struct {
+	JID         string
+	Affiliation string
+}
+
+// FromBytes deserializes a Affiliation entity from its binary representation.
+func (a *Affiliation) FromBytes(buf *bytes.Buffer) error {
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&a.JID); err != nil {
+		return err
+	}
+	return dec.Decode(&a.Affiliation)
+}
+
+// ToBytes converts a Affiliation entity to its binary representation.
+func (a *Affiliation) ToBytes(buf *bytes.Buffer) error {
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(a.JID); err != nil {
+		return err
+	}
+	return enc.Encode(a.Affiliation)
+}
@@ -0,0 +1,28 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mucmodel
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAffiliation_Serialize(t *testing.T) {
+	a := Affiliation{
+		JID:         "ortuman@jackal.im",
+		Affiliation: Owner,
+	}
+	b := bytes.NewBuffer(nil)
+	require.Nil(t, a.ToBytes(b))
+
+	var a2 Affiliation
+	require.Nil(t, a2.FromBytes(b))
+
+	require.True(t, reflect.DeepEqual(a, a2))
+}
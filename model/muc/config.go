@@ -0,0 +1,245 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mucmodel
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/ortuman/jackal/module/xep0004"
+)
+
+const roomConfigNamespace = "http://jabber.org/protocol/muc#roomconfig"
+
+const (
+	roomNameFieldVar          = "muc#roomconfig_roomname"
+	roomDescFieldVar          = "muc#roomconfig_roomdesc"
+	persistentRoomFieldVar    = "muc#roomconfig_persistentroom"
+	publicRoomFieldVar        = "muc#roomconfig_publicroom"
+	membersOnlyFieldVar       = "muc#roomconfig_membersonly"
+	moderatedRoomFieldVar     = "muc#roomconfig_moderatedroom"
+	passwordProtectedFieldVar = "muc#roomconfig_passwordprotectedroom"
+	roomSecretFieldVar        = "muc#roomconfig_roomsecret"
+	whoIsFieldVar             = "muc#roomconfig_whois"
+	maxUsersFieldVar          = "muc#roomconfig_maxusers"
+	changeSubjectFieldVar     = "muc#roomconfig_changesubject"
+	allowInvitesFieldVar      = "muc#roomconfig_allowinvites"
+	maxHistoryFetchFieldVar   = "muc#maxhistoryfetch"
+)
+
+const (
+	defaultMaxOccupants  = 100
+	defaultHistoryLength = 20
+)
+
+const (
+	// Moderators represents 'moderators' whois option (semi-anonymous room).
+	Moderators = "moderators"
+
+	// Anyone represents 'anyone' whois option (non-anonymous room).
+	Anyone = "anyone"
+)
+
+// Config represents room configuration options
+type Config struct {
+	Name               string
+	Description        string
+	Persistent         bool
+	Public             bool
+	MembersOnly        bool
+	Moderated          bool
+	PasswordProtected  bool
+	Password           string
+	WhoIs              string
+	MaxOccupants       int
+	AllowChangeSubject bool
+	AllowInvites       bool
+	HistoryLength      int
+}
+
+// DefaultConfig returns the configuration assigned to a newly created room.
+func DefaultConfig() Config {
+	return Config{
+		Public:        true,
+		WhoIs:         Moderators,
+		MaxOccupants:  defaultMaxOccupants,
+		HistoryLength: defaultHistoryLength,
+	}
+}
+
+// IsNonAnonymous returns whether or not real occupant JIDs are exposed to every occupant.
+func (c *Config) IsNonAnonymous() bool {
+	return c.WhoIs == Anyone
+}
+
+// NewConfigFromSubmitForm returns a new room Config instance derived from a submit form.
+func NewConfigFromSubmitForm(form *xep0004.DataForm, base *Config) (*Config, error) {
+	cfg := *base
+	fields := form.Fields
+
+	// validate form type
+	formType := fields.ValueForFieldOfType(xep0004.FormType, xep0004.Hidden)
+	if form.Type != xep0004.Submit || formType != roomConfigNamespace {
+		return nil, errors.New("invalid form type")
+	}
+	for _, field := range fields {
+		var value string
+		if len(field.Values) > 0 {
+			value = field.Values[0]
+		}
+		var err error
+		switch field.Var {
+		case xep0004.FormType:
+			continue
+		case roomNameFieldVar:
+			cfg.Name = value
+		case roomDescFieldVar:
+			cfg.Description = value
+		case persistentRoomFieldVar:
+			cfg.Persistent, err = parseBool(value)
+		case publicRoomFieldVar:
+			cfg.Public, err = parseBool(value)
+		case membersOnlyFieldVar:
+			cfg.MembersOnly, err = parseBool(value)
+		case moderatedRoomFieldVar:
+			cfg.Moderated, err = parseBool(value)
+		case passwordProtectedFieldVar:
+			cfg.PasswordProtected, err = parseBool(value)
+		case roomSecretFieldVar:
+			cfg.Password = value
+		case whoIsFieldVar:
+			switch value {
+			case Moderators, Anyone:
+				cfg.WhoIs = value
+			default:
+				err = fmt.Errorf("invalid whois value: %s", value)
+			}
+		case maxUsersFieldVar:
+			cfg.MaxOccupants, err = strconv.Atoi(value)
+		case changeSubjectFieldVar:
+			cfg.AllowChangeSubject, err = parseBool(value)
+		case allowInvitesFieldVar:
+			cfg.AllowInvites, err = parseBool(value)
+		case maxHistoryFetchFieldVar:
+			cfg.HistoryLength, err = strconv.Atoi(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.PasswordProtected && len(cfg.Password) == 0 {
+		return nil, errors.New("password protected room requires a secret")
+	}
+	if cfg.MaxOccupants < 0 || cfg.HistoryLength < 0 {
+		return nil, errors.New("invalid negative value")
+	}
+	return &cfg, nil
+}
+
+// Form returns Config form representation.
+func (c *Config) Form() *xep0004.DataForm {
+	form := xep0004.DataForm{
+		Type: xep0004.Form,
+	}
+	// include form type
+	form.Fields = append(form.Fields, xep0004.Field{
+		Var:    xep0004.FormType,
+		Type:   xep0004.Hidden,
+		Values: []string{roomConfigNamespace},
+	})
+	form.Fields = append(form.Fields, xep0004.Field{
+		Var:    roomNameFieldVar,
+		Type:   xep0004.TextSingle,
+		Label:  "Natural-Language Room Name",
+		Values: []string{c.Name},
+	})
+	form.Fields = append(form.Fields, xep0004.Field{
+		Var:    roomDescFieldVar,
+		Type:   xep0004.TextSingle,
+		Label:  "Short Description of Room",
+		Values: []string{c.Description},
+	})
+	form.Fields = append(form.Fields, xep0004.Field{
+		Var:    persistentRoomFieldVar,
+		Type:   xep0004.Boolean,
+		Label:  "Make Room Persistent?",
+		Values: []string{strconv.FormatBool(c.Persistent)},
+	})
+	form.Fields = append(form.Fields, xep0004.Field{
+		Var:    publicRoomFieldVar,
+		Type:   xep0004.Boolean,
+		Label:  "Make Room Publicly Searchable?",
+		Values: []string{strconv.FormatBool(c.Public)},
+	})
+	form.Fields = append(form.Fields, xep0004.Field{
+		Var:    membersOnlyFieldVar,
+		Type:   xep0004.Boolean,
+		Label:  "Make Room Members-Only?",
+		Values: []string{strconv.FormatBool(c.MembersOnly)},
+	})
+	form.Fields = append(form.Fields, xep0004.Field{
+		Var:    moderatedRoomFieldVar,
+		Type:   xep0004.Boolean,
+		Label:  "Make Room Moderated?",
+		Values: []string{strconv.FormatBool(c.Moderated)},
+	})
+	form.Fields = append(form.Fields, xep0004.Field{
+		Var:    passwordProtectedFieldVar,
+		Type:   xep0004.Boolean,
+		Label:  "Password Required to Enter?",
+		Values: []string{strconv.FormatBool(c.PasswordProtected)},
+	})
+	form.Fields = append(form.Fields, xep0004.Field{
+		Var:    roomSecretFieldVar,
+		Type:   xep0004.TextPrivate,
+		Label:  "Password",
+		Values: []string{c.Password},
+	})
+	form.Fields = append(form.Fields, xep0004.Field{
+		Var:    whoIsFieldVar,
+		Type:   xep0004.ListSingle,
+		Label:  "Who May Discover Real JIDs?",
+		Values: []string{c.WhoIs},
+		Options: []xep0004.Option{
+			{Label: "Moderators Only", Value: Moderators},
+			{Label: "Anyone", Value: Anyone},
+		},
+	})
+	form.Fields = append(form.Fields, xep0004.Field{
+		Var:    maxUsersFieldVar,
+		Type:   xep0004.TextSingle,
+		Label:  "Maximum Number of Occupants",
+		Values: []string{strconv.Itoa(c.MaxOccupants)},
+	})
+	form.Fields = append(form.Fields, xep0004.Field{
+		Var:    changeSubjectFieldVar,
+		Type:   xep0004.Boolean,
+		Label:  "Allow Occupants to Change Subject?",
+		Values: []string{strconv.FormatBool(c.AllowChangeSubject)},
+	})
+	form.Fields = append(form.Fields, xep0004.Field{
+		Var:    allowInvitesFieldVar,
+		Type:   xep0004.Boolean,
+		Label:  "Allow Occupants to Invite Others?",
+		Values: []string{strconv.FormatBool(c.AllowInvites)},
+	})
+	form.Fields = append(form.Fields, xep0004.Field{
+		Var:    maxHistoryFetchFieldVar,
+		Type:   xep0004.TextSingle,
+		Label:  "Maximum Number of History Messages Returned by Room",
+		Values: []string{strconv.Itoa(c.HistoryLength)},
+	})
+	return &form
+}
+
+// parseBool parses a boolean form value, accepting both '1' and 'true' representations.
+func parseBool(s string) (bool, error) {
+	if len(s) == 0 {
+		return false, nil
+	}
+	return strconv.ParseBool(s)
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mucmodel
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_NewFromSubmitForm(t *testing.T) {
+	base := DefaultConfig()
+
+	form := base.Form()
+	form.Type = xep0004.Submit
+	for i, field := range form.Fields {
+		switch field.Var {
+		case roomNameFieldVar:
+			form.Fields[i].Values = []string{"The Lounge"}
+		case persistentRoomFieldVar:
+			form.Fields[i].Values = []string{"1"}
+		case whoIsFieldVar:
+			form.Fields[i].Values = []string{Anyone}
+		case maxUsersFieldVar:
+			form.Fields[i].Values = []string{"30"}
+		}
+	}
+	cfg, err := NewConfigFromSubmitForm(form, &base)
+	require.Nil(t, err)
+	require.Equal(t, "The Lounge", cfg.Name)
+	require.True(t, cfg.Persistent)
+	require.True(t, cfg.Public)
+	require.True(t, cfg.IsNonAnonymous())
+	require.Equal(t, 30, cfg.MaxOccupants)
+
+	// not a submit form
+	_, err = NewConfigFromSubmitForm(base.Form(), &base)
+	require.NotNil(t, err)
+
+	// bad whois value
+	form = &xep0004.DataForm{
+		Type: xep0004.Submit,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{roomConfigNamespace}},
+			{Var: whoIsFieldVar, Values: []string{"nobody"}},
+		},
+	}
+	_, err = NewConfigFromSubmitForm(form, &base)
+	require.NotNil(t, err)
+
+	// password protected without a secret
+	form = &xep0004.DataForm{
+		Type: xep0004.Submit,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{roomConfigNamespace}},
+			{Var: passwordProtectedFieldVar, Values: []string{"true"}},
+		},
+	}
+	_, err = NewConfigFromSubmitForm(form, &base)
+	require.NotNil(t, err)
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mucmodel
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// Room represents a multi-user chat room
+type Room struct {
+	Host    string
+	Name    string
+	Subject string
+	Config  Config
+}
+
+// FromBytes deserializes a Room entity from its binary representation.
+func (r *Room) FromBytes(buf *bytes.Buffer) error {
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&r.Host); err != nil {
+		return err
+	}
+	if err := dec.Decode(&r.Name); err != nil {
+		return err
+	}
+	if err := dec.Decode(&r.Subject); err != nil {
+		return err
+	}
+	return dec.Decode(&r.Config)
+}
+
+// ToBytes converts a Room entity to its binary representation.
+func (r *Room) ToBytes(buf *bytes.Buffer) error {
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(r.Host); err != nil {
+		return err
+	}
+	if err := enc.Encode(r.Name); err != nil {
+		return err
+	}
+	if err := enc.Encode(r.Subject); err != nil {
+		return err
+	}
+	return enc.Encode(r.Config)
+}
@@ -0,0 +1,33 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mucmodel
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoom_Serialization(t *testing.T) {
+	r := Room{}
+	r.Host = "conference.jackal.im"
+	r.Name = "lounge"
+	r.Subject = "Welcome!"
+
+	r.Config = DefaultConfig()
+	r.Config.Name = "The Lounge"
+	r.Config.Persistent = true
+
+	buf := bytes.NewBuffer(nil)
+	require.Nil(t, r.ToBytes(buf))
+
+	r2 := Room{}
+	_ = r2.FromBytes(buf)
+
+	require.True(t, reflect.DeepEqual(&r, &r2))
+}
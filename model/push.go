@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package model
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"github.com/ortuman/jackal/xmpp"
+)
+
+// PushRegistration represents a push notifications (XEP-0357) registration storage entity.
+type PushRegistration struct {
+	Username string
+	JID      string
+	Node     string
+	Options  xmpp.XElement
+}
+
+// FromBytes deserializes a PushRegistration entity from its binary representation.
+func (pr *PushRegistration) FromBytes(buf *bytes.Buffer) error {
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&pr.Username); err != nil {
+		return err
+	}
+	if err := dec.Decode(&pr.JID); err != nil {
+		return err
+	}
+	if err := dec.Decode(&pr.Node); err != nil {
+		return err
+	}
+	var hasOptions bool
+	if err := dec.Decode(&hasOptions); err != nil {
+		return err
+	}
+	if hasOptions {
+		var elem xmpp.Element
+		if err := elem.FromBytes(buf); err != nil {
+			return err
+		}
+		pr.Options = &elem
+	}
+	return nil
+}
+
+// ToBytes converts a PushRegistration entity to its binary representation.
+func (pr *PushRegistration) ToBytes(buf *bytes.Buffer) error {
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(&pr.Username); err != nil {
+		return err
+	}
+	if err := enc.Encode(&pr.JID); err != nil {
+		return err
+	}
+	if err := enc.Encode(&pr.Node); err != nil {
+		return err
+	}
+	hasOptions := pr.Options != nil
+	if err := enc.Encode(hasOptions); err != nil {
+		return err
+	}
+	if pr.Options != nil {
+		return pr.Options.ToBytes(buf)
+	}
+	return nil
+}
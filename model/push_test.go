@@ -0,0 +1,35 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package model
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushRegistration(t *testing.T) {
+	pr1 := PushRegistration{Username: "ortuman", JID: "push.jackal.im", Node: "yxs32uqsflafdk3iuqo"}
+	pr1.Options = xmpp.NewElementNamespace("x", "jabber:x:data")
+
+	var pr2 PushRegistration
+	buf := new(bytes.Buffer)
+	require.Nil(t, pr1.ToBytes(buf))
+	require.Nil(t, pr2.FromBytes(buf))
+	require.True(t, reflect.DeepEqual(&pr1, &pr2))
+
+	// nil options
+	pr1.Options = nil
+
+	var pr3 PushRegistration
+	buf = new(bytes.Buffer)
+	require.Nil(t, pr1.ToBytes(buf))
+	require.Nil(t, pr3.FromBytes(buf))
+	require.True(t, reflect.DeepEqual(&pr1, &pr3))
+}
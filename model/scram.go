@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// ScramIterationsCount defines the PBKDF2 iteration count used to derive new SCRAM credentials.
+const ScramIterationsCount = 4096
+
+const scramSaltLength = 32
+
+var errInvalidScramCredentials = errors.New("model: invalid scram credentials format")
+
+// ScramCredentials represents the SCRAM (RFC 5802) keys derived from a user password.
+type ScramCredentials struct {
+	Salt       []byte
+	Iterations int
+	StoredKey  []byte
+	ServerKey  []byte
+}
+
+// NewScramCredentials derives a new set of SCRAM credentials from a password using a random salt.
+func NewScramCredentials(h func() hash.Hash, password string) (*ScramCredentials, error) {
+	salt := make([]byte, scramSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return DeriveScramCredentials(h, password, salt, ScramIterationsCount), nil
+}
+
+// DeriveScramCredentials derives SCRAM credentials from a password, salt and iteration count.
+func DeriveScramCredentials(h func() hash.Hash, password string, salt []byte, iterations int) *ScramCredentials {
+	saltedPassword := pbkdf2.Key([]byte(password), salt, iterations, h().Size(), h)
+	clientKey := scramHmac(h, saltedPassword, []byte("Client Key"))
+	return &ScramCredentials{
+		Salt:       salt,
+		Iterations: iterations,
+		StoredKey:  scramHash(h, clientKey),
+		ServerKey:  scramHmac(h, saltedPassword, []byte("Server Key")),
+	}
+}
+
+// ParseScramCredentials parses SCRAM credentials from their string representation.
+// An empty string yields nil credentials.
+func ParseScramCredentials(str string) (*ScramCredentials, error) {
+	if len(str) == 0 {
+		return nil, nil
+	}
+	// <iterations>:<salt>$<stored_key>:<server_key>
+	parts := strings.Split(str, "$")
+	if len(parts) != 2 {
+		return nil, errInvalidScramCredentials
+	}
+	params := strings.Split(parts[0], ":")
+	keys := strings.Split(parts[1], ":")
+	if len(params) != 2 || len(keys) != 2 {
+		return nil, errInvalidScramCredentials
+	}
+	iterations, err := strconv.Atoi(params[0])
+	if err != nil || iterations <= 0 {
+		return nil, errInvalidScramCredentials
+	}
+	c := &ScramCredentials{Iterations: iterations}
+	if c.Salt, err = base64.StdEncoding.DecodeString(params[1]); err != nil {
+		return nil, errInvalidScramCredentials
+	}
+	if c.StoredKey, err = base64.StdEncoding.DecodeString(keys[0]); err != nil {
+		return nil, errInvalidScramCredentials
+	}
+	if c.ServerKey, err = base64.StdEncoding.DecodeString(keys[1]); err != nil {
+		return nil, errInvalidScramCredentials
+	}
+	return c, nil
+}
+
+// String returns SCRAM credentials string representation.
+func (c *ScramCredentials) String() string {
+	if c == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d:%s$%s:%s",
+		c.Iterations,
+		base64.StdEncoding.EncodeToString(c.Salt),
+		base64.StdEncoding.EncodeToString(c.StoredKey),
+		base64.StdEncoding.EncodeToString(c.ServerKey),
+	)
+}
+
+// VerifyPassword tells whether or not a password matches SCRAM credentials.
+func (c *ScramCredentials) VerifyPassword(h func() hash.Hash, password string) bool {
+	dc := DeriveScramCredentials(h, password, c.Salt, c.Iterations)
+	return subtle.ConstantTimeCompare(dc.StoredKey, c.StoredKey) == 1
+}
+
+func scramHmac(h func() hash.Hash, key, b []byte) []byte {
+	m := hmac.New(h, key)
+	m.Write(b)
+	return m.Sum(nil)
+}
+
+func scramHash(h func() hash.Hash, b []byte) []byte {
+	hh := h()
+	hh.Write(b)
+	return hh.Sum(nil)
+}
@@ -0,0 +1,42 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package model
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelScramCredentials(t *testing.T) {
+	// RFC 5802 test vector
+	salt, _ := hex.DecodeString("4125c247e43ab1e93c6dff76")
+	c := DeriveScramCredentials(sha1.New, "pencil", salt, 4096)
+	require.Equal(t, "4096:QSXCR+Q6sek8bf92$6dlGYMOdZcOPutkcNY8U2g7vK9Y=:D+CSWLOshSulAsxiupA+qs2/fTE=", c.String())
+	require.True(t, c.VerifyPassword(sha1.New, "pencil"))
+	require.False(t, c.VerifyPassword(sha1.New, "pen"))
+
+	c2, err := ParseScramCredentials(c.String())
+	require.Nil(t, err)
+	require.Equal(t, c, c2)
+
+	c3, err := ParseScramCredentials("")
+	require.Nil(t, err)
+	require.Nil(t, c3)
+
+	_, err = ParseScramCredentials("4096:QSXCR+Q6sek8bf92")
+	require.NotNil(t, err)
+	_, err = ParseScramCredentials("foo:QSXCR+Q6sek8bf92$6dlGYMOdZcOPutkcNY8U2g7vK9Y=:D+CSWLOshSulAsxiupA+qs2/fTE=")
+	require.NotNil(t, err)
+
+	c4, err := NewScramCredentials(sha1.New, "pencil")
+	require.Nil(t, err)
+	require.Equal(t, ScramIterationsCount, c4.Iterations)
+	require.Len(t, c4.Salt, scramSaltLength)
+	require.True(t, c4.VerifyPassword(sha1.New, "pencil"))
+}
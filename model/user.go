@@ -7,6 +7,10 @@ package model
 
 import (
 	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/gob"
 	"time"
 
@@ -15,12 +19,56 @@ import (
 
 // User represents a user storage entity.
 type User struct {
-	Username       string
-	Password       string
+	Username string
+
+	// Password holds a legacy cleartext password, only present
+	// on entities whose credentials haven't been migrated yet.
+	Password string
+
+	ScramSHA1   *ScramCredentials
+	ScramSHA256 *ScramCredentials
+	ScramSHA512 *ScramCredentials
+
 	LastPresence   *xmpp.Presence
 	LastPresenceAt time.Time
 }
 
+// SetPassword derives user SCRAM credentials from a password, discarding any cleartext one.
+func (u *User) SetPassword(password string) error {
+	var err error
+	if u.ScramSHA1, err = NewScramCredentials(sha1.New, password); err != nil {
+		return err
+	}
+	if u.ScramSHA256, err = NewScramCredentials(sha256.New, password); err != nil {
+		return err
+	}
+	if u.ScramSHA512, err = NewScramCredentials(sha512.New, password); err != nil {
+		return err
+	}
+	u.Password = ""
+	return nil
+}
+
+// VerifyPassword tells whether or not a password matches user credentials.
+func (u *User) VerifyPassword(password string) bool {
+	switch {
+	case u.ScramSHA512 != nil:
+		return u.ScramSHA512.VerifyPassword(sha512.New, password)
+	case u.ScramSHA256 != nil:
+		return u.ScramSHA256.VerifyPassword(sha256.New, password)
+	case u.ScramSHA1 != nil:
+		return u.ScramSHA1.VerifyPassword(sha1.New, password)
+	case len(u.Password) > 0:
+		return subtle.ConstantTimeCompare([]byte(u.Password), []byte(password)) == 1
+	}
+	return false
+}
+
+// HasLegacyPassword tells whether or not user credentials are pending to be migrated.
+func (u *User) HasLegacyPassword() bool {
+	return len(u.Password) > 0
+}
+
 // FromBytes deserializes a User entity from it's gob binary representation.
 func (u *User) FromBytes(buf *bytes.Buffer) error {
 	dec := gob.NewDecoder(buf)
@@ -30,6 +78,17 @@ func (u *User) FromBytes(buf *bytes.Buffer) error {
 	if err := dec.Decode(&u.Password); err != nil {
 		return err
 	}
+	for _, c := range []**ScramCredentials{&u.ScramSHA1, &u.ScramSHA256, &u.ScramSHA512} {
+		var str string
+		if err := dec.Decode(&str); err != nil {
+			return err
+		}
+		sc, err := ParseScramCredentials(str)
+		if err != nil {
+			return err
+		}
+		*c = sc
+	}
 	var hasPresence bool
 	if err := dec.Decode(&hasPresence); err != nil {
 		return err
@@ -56,6 +115,12 @@ func (u *User) ToBytes(buf *bytes.Buffer) error {
 	if err := enc.Encode(&u.Password); err != nil {
 		return err
 	}
+	for _, c := range []*ScramCredentials{u.ScramSHA1, u.ScramSHA256, u.ScramSHA512} {
+		str := c.String()
+		if err := enc.Encode(&str); err != nil {
+			return err
+		}
+	}
 	hasPresence := u.LastPresence != nil
 	if err := enc.Encode(&hasPresence); err != nil {
 		return err
@@ -33,4 +33,30 @@ func TestModelUser(t *testing.T) {
 	require.Equal(t, usr1.Password, usr2.Password)
 	require.Equal(t, usr1.LastPresence.String(), usr2.LastPresence.String())
 	require.NotEqual(t, time.Time{}, usr2.LastPresenceAt)
+
+	// scram credentials
+	require.Nil(t, usr1.SetPassword("5678"))
+	require.Equal(t, "", usr1.Password)
+
+	buf.Reset()
+	require.Nil(t, usr1.ToBytes(buf))
+	usr3 := User{}
+	require.Nil(t, usr3.FromBytes(buf))
+	require.Equal(t, usr1.ScramSHA1, usr3.ScramSHA1)
+	require.Equal(t, usr1.ScramSHA256, usr3.ScramSHA256)
+	require.Equal(t, usr1.ScramSHA512, usr3.ScramSHA512)
+}
+
+func TestModelUser_VerifyPassword(t *testing.T) {
+	usr := User{Username: "ortuman", Password: "1234"}
+	require.True(t, usr.HasLegacyPassword())
+	require.True(t, usr.VerifyPassword("1234"))
+	require.False(t, usr.VerifyPassword("5678"))
+
+	require.Nil(t, usr.SetPassword("5678"))
+	require.False(t, usr.HasLegacyPassword())
+	require.True(t, usr.VerifyPassword("5678"))
+	require.False(t, usr.VerifyPassword("1234"))
+
+	require.False(t, (&User{Username: "noelia"}).VerifyPassword(""))
 }
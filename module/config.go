@@ -45,7 +45,7 @@ func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	for _, mod := range p.Enabled {
 		switch mod {
 		case "roster", "last_activity", "private", "vcard", "registration", "pep", "version", "blocking_command",
-			"ping", "offline":
+			"ping", "offline", "carbons", "mam", "push":
 			break
 		default:
 			return fmt.Errorf("module.Config: unrecognized module: %s", mod)
@@ -7,7 +7,9 @@ package module
 
 import (
 	"context"
+	"sync"
 
+	"github.com/ortuman/jackal/auth/backend"
 	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/module/offline"
 	"github.com/ortuman/jackal/module/roster"
@@ -21,6 +23,9 @@ import (
 	"github.com/ortuman/jackal/module/xep0163"
 	"github.com/ortuman/jackal/module/xep0191"
 	"github.com/ortuman/jackal/module/xep0199"
+	"github.com/ortuman/jackal/module/xep0280"
+	"github.com/ortuman/jackal/module/xep0313"
+	"github.com/ortuman/jackal/module/xep0357"
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/storage/repository"
 	"github.com/ortuman/jackal/xmpp"
@@ -56,14 +61,23 @@ type Modules struct {
 	Pep          *xep0163.Pep
 	BlockingCmd  *xep0191.BlockingCommand
 	Ping         *xep0199.Ping
+	Carbons      *xep0280.Carbons
+	Mam          *xep0313.Mam
+	Push         *xep0357.Push
 
 	router     router.Router
 	iqHandlers []IQHandler
 	all        []Module
+
+	mu           sync.Mutex
+	refs         int
+	retired      bool
+	shutdownOnce sync.Once
+	shutdownCh   chan bool
 }
 
 // New returns a set of modules derived from a concrete configuration.
-func New(config *Config, router router.Router, reps repository.Container, allocationID string) *Modules {
+func New(config *Config, router router.Router, reps repository.Container, authBackend backend.Backend, allocationID string) *Modules {
 	var presenceHub = xep0115.New(router, reps.Presences(), allocationID)
 
 	m := &Modules{router: router}
@@ -96,7 +110,7 @@ func New(config *Config, router router.Router, reps repository.Container, alloca
 
 	// XEP-0077: In-band registration (https://xmpp.org/extensions/xep-0077.html)
 	if _, ok := config.Enabled["registration"]; ok {
-		m.Register = xep0077.New(&config.Registration, m.DiscoInfo, router, reps.User())
+		m.Register = xep0077.New(&config.Registration, m.DiscoInfo, router, reps.User(), reps.FastToken(), authBackend)
 		m.iqHandlers = append(m.iqHandlers, m.Register)
 		m.all = append(m.all, m.Register)
 	}
@@ -108,12 +122,6 @@ func New(config *Config, router router.Router, reps repository.Container, alloca
 		m.all = append(m.all, m.Version)
 	}
 
-	// XEP-0160: Offline message storage (https://xmpp.org/extensions/xep-0160.html)
-	if _, ok := config.Enabled["offline"]; ok {
-		m.Offline = offline.New(&config.Offline, m.DiscoInfo, router, reps.Offline())
-		m.all = append(m.all, m.Offline)
-	}
-
 	// XEP-0163: Personal Eventing Protocol (https://xmpp.org/extensions/xep-0163.html)
 	if _, ok := config.Enabled["pep"]; ok {
 		m.Pep = xep0163.New(m.DiscoInfo, presenceHub, router, reps.Roster(), reps.PubSub())
@@ -135,6 +143,33 @@ func New(config *Config, router router.Router, reps repository.Container, alloca
 		m.all = append(m.all, m.Ping)
 	}
 
+	// XEP-0280: Message Carbons (https://xmpp.org/extensions/xep-0280.html)
+	if _, ok := config.Enabled["carbons"]; ok {
+		m.Carbons = xep0280.New(m.DiscoInfo, router)
+		m.iqHandlers = append(m.iqHandlers, m.Carbons)
+		m.all = append(m.all, m.Carbons)
+	}
+
+	// XEP-0313: Message Archive Management (https://xmpp.org/extensions/xep-0313.html)
+	if _, ok := config.Enabled["mam"]; ok {
+		m.Mam = xep0313.New(m.DiscoInfo, router, reps.User(), reps.Archive())
+		m.iqHandlers = append(m.iqHandlers, m.Mam)
+		m.all = append(m.all, m.Mam)
+	}
+
+	// XEP-0357: Push Notifications (https://xmpp.org/extensions/xep-0357.html)
+	if _, ok := config.Enabled["push"]; ok {
+		m.Push = xep0357.New(m.DiscoInfo, router, reps.Push())
+		m.iqHandlers = append(m.iqHandlers, m.Push)
+		m.all = append(m.all, m.Push)
+	}
+
+	// XEP-0160: Offline message storage (https://xmpp.org/extensions/xep-0160.html)
+	if _, ok := config.Enabled["offline"]; ok {
+		m.Offline = offline.New(&config.Offline, m.DiscoInfo, m.Push, router, reps.Offline())
+		m.all = append(m.all, m.Offline)
+	}
+
 	// Roster (https://xmpp.org/rfcs/rfc3921.html#roster)
 	if _, ok := config.Enabled["roster"]; ok {
 		m.iqHandlers = append(m.iqHandlers, presenceHub)
@@ -162,6 +197,36 @@ func (m *Modules) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
 	}
 }
 
+// Acquire marks the module set as being used by a stream.
+func (m *Modules) Acquire() {
+	m.mu.Lock()
+	m.refs++
+	m.mu.Unlock()
+}
+
+// Release signals that a stream no longer uses the module set.
+// Retired sets are shut down as soon as they are released by their last stream.
+func (m *Modules) Release() {
+	m.mu.Lock()
+	m.refs--
+	done := m.retired && m.refs == 0
+	m.mu.Unlock()
+	if done {
+		m.shutdown()
+	}
+}
+
+// Retire marks the module set as replaced, so that it gets shut down once no stream is using it.
+func (m *Modules) Retire() {
+	m.mu.Lock()
+	m.retired = true
+	done := m.refs == 0
+	m.mu.Unlock()
+	if done {
+		m.shutdown()
+	}
+}
+
 // Shutdown gracefully shuts down modules instance.
 func (m *Modules) Shutdown(ctx context.Context) error {
 	select {
@@ -172,17 +237,38 @@ func (m *Modules) Shutdown(ctx context.Context) error {
 	}
 }
 
+// IsShutdown returns whether or not the module set has been completely shut down.
+func (m *Modules) IsShutdown() bool {
+	m.mu.Lock()
+	c := m.shutdownCh
+	m.mu.Unlock()
+	if c == nil {
+		return false
+	}
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
 func (m *Modules) shutdown() <-chan bool {
-	c := make(chan bool)
-	go func() {
-		// shutdown modules in reverse order
-		for i := len(m.all) - 1; i >= 0; i-- {
-			mod := m.all[i]
-			if err := mod.Shutdown(); err != nil {
-				log.Error(err)
+	m.shutdownOnce.Do(func() {
+		c := make(chan bool)
+		go func() {
+			// shutdown modules in reverse order
+			for i := len(m.all) - 1; i >= 0; i-- {
+				mod := m.all[i]
+				if err := mod.Shutdown(); err != nil {
+					log.Error(err)
+				}
 			}
-		}
-		close(c)
-	}()
-	return c
+			close(c)
+		}()
+		m.mu.Lock()
+		m.shutdownCh = c
+		m.mu.Unlock()
+	})
+	return m.shutdownCh
 }
@@ -12,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ortuman/jackal/auth/backend"
 	"github.com/ortuman/jackal/router/host"
 
 	"github.com/google/uuid"
@@ -40,7 +41,7 @@ func TestModules_New(t *testing.T) {
 	mods := setupModules(t)
 	defer func() { _ = mods.Shutdown(context.Background()) }()
 
-	require.Equal(t, 10, len(mods.all))
+	require.Equal(t, 13, len(mods.all))
 }
 
 func TestModules_ProcessIQ(t *testing.T) {
@@ -85,6 +86,32 @@ func TestModules_Shutdown(t *testing.T) {
 	}
 }
 
+func TestModules_Retire(t *testing.T) {
+	mods := setupModules(t)
+
+	var mod fakeModule
+	mod.shutdownCh = make(chan bool)
+
+	mods.all = append(mods.all, &mod)
+
+	mods.Acquire()
+	mods.Acquire()
+	mods.Retire()
+	mods.Release()
+	require.False(t, mods.IsShutdown())
+
+	// shut down once released by its last stream
+	mods.Release()
+	select {
+	case <-mod.shutdownCh:
+		break
+	case <-time.After(time.Millisecond * 250):
+		require.Fail(t, "modules shutdown timeout")
+	}
+	_ = mods.Shutdown(context.Background())
+	require.True(t, mods.IsShutdown())
+}
+
 func setupModules(t *testing.T) *Modules {
 	var config Config
 	b, err := ioutil.ReadFile("../testdata/config_modules.yml")
@@ -97,8 +124,8 @@ func setupModules(t *testing.T) *Modules {
 	rep, _ := storage.New(&storage.Config{Type: storage.Memory})
 	r, _ := router.New(
 		hosts,
-		c2srouter.New(rep.User(), rep.BlockList()),
+		c2srouter.New(backend.NewStorage(rep.User()), rep.BlockList()),
 		nil,
 	)
-	return New(&config, r, rep, "alloc-1234")
+	return New(&config, r, rep, backend.NewStorage(rep.User()), "alloc-1234")
 }
@@ -0,0 +1,22 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package offline
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	inserts = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "jackal_offline_inserts_total",
+		Help: "Number of messages inserted into offline queues.",
+	})
+	gatewayFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "jackal_offline_gateway_failures_total",
+		Help: "Number of offline messages that could not be routed through the offline gateway.",
+	})
+)
@@ -10,6 +10,7 @@ import (
 
 	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/module/xep0357"
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/storage/repository"
 	"github.com/ortuman/jackal/stream"
@@ -27,15 +28,17 @@ const offlineDeliveredCtxKey = "offline:delivered"
 type Offline struct {
 	cfg        *Config
 	runQueue   *runqueue.RunQueue
+	push       *xep0357.Push
 	router     router.Router
 	offlineRep repository.Offline
 }
 
 // New returns an offline server stream module.
-func New(config *Config, disco *xep0030.DiscoInfo, router router.Router, offlineRep repository.Offline) *Offline {
+func New(config *Config, disco *xep0030.DiscoInfo, push *xep0357.Push, router router.Router, offlineRep repository.Offline) *Offline {
 	r := &Offline{
 		cfg:        config,
-		runQueue:   runqueue.New("xep0030"),
+		runQueue:   runqueue.NewMetered("offline"),
+		push:       push,
 		router:     router,
 		offlineRep: offlineRep,
 	}
@@ -85,10 +88,16 @@ func (x *Offline) archiveMessage(ctx context.Context, message *xmpp.Message) {
 		_ = x.router.Route(ctx, message.InternalServerError())
 		return
 	}
+	inserts.Inc()
 	log.Infof("archived offline message... id: %s", message.ID())
 
+	if x.push != nil {
+		x.push.NotifyMessage(ctx, toJID.Node(), message)
+	}
+
 	if x.cfg.Gateway != nil {
 		if err := x.cfg.Gateway.Route(message); err != nil {
+			gatewayFailures.Inc()
 			log.Errorf("bad offline gateway: %v", err)
 		}
 	}
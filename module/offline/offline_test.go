@@ -11,6 +11,8 @@ import (
 	"testing"
 	"time"
 
+	"errors"
+	"github.com/ortuman/jackal/auth/backend"
 	"github.com/ortuman/jackal/router/host"
 
 	c2srouter "github.com/ortuman/jackal/c2s/router"
@@ -20,6 +22,7 @@ import (
 	"github.com/ortuman/jackal/xmpp"
 	"github.com/ortuman/jackal/xmpp/jid"
 	"github.com/pborman/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -34,9 +37,12 @@ func TestOffline_ArchiveMessage(t *testing.T) {
 
 	r.Bind(context.Background(), stm)
 
-	x := New(&Config{QueueSize: 1}, nil, r, s)
+	x := New(&Config{QueueSize: 1, Gateway: &fakeGateway{err: errors.New("gateway down")}}, nil, nil, r, s)
 	defer func() { _ = x.Shutdown() }()
 
+	insertCount := testutil.ToFloat64(inserts)
+	failureCount := testutil.ToFloat64(gatewayFailures)
+
 	msgID := uuid.New()
 	msg := xmpp.NewMessageType(msgID, "normal")
 	msg.SetFromJID(j1)
@@ -49,6 +55,8 @@ func TestOffline_ArchiveMessage(t *testing.T) {
 	msgs, err := s.FetchOfflineMessages(context.Background(), "juliet")
 	require.Nil(t, err)
 	require.Equal(t, 1, len(msgs))
+	require.Equal(t, insertCount+1, testutil.ToFloat64(inserts))
+	require.Equal(t, failureCount+1, testutil.ToFloat64(gatewayFailures))
 
 	msg2 := xmpp.NewMessageType(msgID, "normal")
 	msg2.SetFromJID(j1)
@@ -66,7 +74,7 @@ func TestOffline_ArchiveMessage(t *testing.T) {
 
 	r.Bind(context.Background(), stm2)
 
-	x2 := New(&Config{QueueSize: 1}, nil, r, s)
+	x2 := New(&Config{QueueSize: 1}, nil, nil, r, s)
 	defer func() { _ = x.Shutdown() }()
 
 	x2.DeliverOfflineMessages(context.Background(), stm2)
@@ -76,13 +84,19 @@ func TestOffline_ArchiveMessage(t *testing.T) {
 	require.Equal(t, msgID, elem.ID())
 }
 
+type fakeGateway struct {
+	err error
+}
+
+func (g *fakeGateway) Route(_ *xmpp.Message) error { return g.err }
+
 func setupTest(domain string) (router.Router, *memorystorage.Offline) {
 	hosts, _ := host.New([]host.Config{{Name: domain, Certificate: tls.Certificate{}}})
 
 	s := memorystorage.NewOffline()
 	r, _ := router.New(
 		hosts,
-		c2srouter.New(memorystorage.NewUser(), memorystorage.NewBlockList()),
+		c2srouter.New(backend.NewStorage(memorystorage.NewUser()), memorystorage.NewBlockList()),
 		nil,
 	)
 	return r, s
@@ -11,7 +11,6 @@ import (
 	"strconv"
 
 	"github.com/ortuman/jackal/log"
-	"github.com/ortuman/jackal/model"
 	rostermodel "github.com/ortuman/jackal/model/roster"
 	"github.com/ortuman/jackal/module/xep0115"
 	"github.com/ortuman/jackal/module/xep0163"
@@ -48,7 +47,7 @@ type Roster struct {
 func New(cfg *Config, entityCaps *xep0115.EntityCaps, pep *xep0163.Pep, router router.Router, userRep repository.User, rosterRep repository.Roster) *Roster {
 	r := &Roster{
 		cfg:        cfg,
-		runQueue:   runqueue.New("roster"),
+		runQueue:   runqueue.NewMetered("roster"),
 		router:     router,
 		userRep:    userRep,
 		rosterRep:  rosterRep,
@@ -678,11 +677,8 @@ func (x *Roster) broadcastPresence(ctx context.Context, presence *xmpp.Presence)
 	if usr, err := x.userRep.FetchUser(ctx, fromJID.Node()); err != nil {
 		return err
 	} else if usr != nil {
-		return x.userRep.UpsertUser(ctx, &model.User{
-			Username:     usr.Username,
-			Password:     usr.Password,
-			LastPresence: presence,
-		})
+		usr.LastPresence = presence
+		return x.userRep.UpsertUser(ctx, usr)
 	}
 	return nil
 }
@@ -11,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ortuman/jackal/auth/backend"
 	c2srouter "github.com/ortuman/jackal/c2s/router"
 	"github.com/ortuman/jackal/model"
 	rostermodel "github.com/ortuman/jackal/model/roster"
@@ -496,7 +497,7 @@ func setupTest(domain string) (router.Router, repository.User, repository.Presen
 	rosterRep := memorystorage.NewRoster()
 	r, _ := router.New(
 		hosts,
-		c2srouter.New(userRep, memorystorage.NewBlockList()),
+		c2srouter.New(backend.NewStorage(userRep), memorystorage.NewBlockList()),
 		nil,
 	)
 	return r, userRep, presencesRep, rosterRep
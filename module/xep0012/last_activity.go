@@ -34,7 +34,7 @@ type LastActivity struct {
 // New returns a last activity IQ handler module.
 func New(disco *xep0030.DiscoInfo, router router.Router, userRep repository.User, rosterRep repository.Roster) *LastActivity {
 	x := &LastActivity{
-		runQueue:  runqueue.New("xep0012"),
+		runQueue:  runqueue.NewMetered("xep0012"),
 		router:    router,
 		userRep:   userRep,
 		rosterRep: rosterRep,
@@ -10,6 +10,7 @@ import (
 	"crypto/tls"
 	"testing"
 
+	"github.com/ortuman/jackal/auth/backend"
 	"github.com/ortuman/jackal/router/host"
 
 	c2srouter "github.com/ortuman/jackal/c2s/router"
@@ -153,7 +154,7 @@ func setupTest(domain string) (router.Router, repository.User, repository.Roster
 	rosterRep := memorystorage.NewRoster()
 	r, _ := router.New(
 		hosts,
-		c2srouter.New(userRep, memorystorage.NewBlockList()),
+		c2srouter.New(backend.NewStorage(userRep), memorystorage.NewBlockList()),
 		nil,
 	)
 	return r, userRep, rosterRep
@@ -39,7 +39,7 @@ func New(router router.Router, rosterRep repository.Roster) *DiscoInfo {
 			rosterRep: rosterRep,
 		},
 		providers: make(map[string]InfoProvider),
-		runQueue:  runqueue.New("xep0030"),
+		runQueue:  runqueue.NewMetered("xep0030"),
 	}
 	di.RegisterServerFeature(discoItemsNamespace)
 	di.RegisterServerFeature(discoInfoNamespace)
@@ -92,6 +92,26 @@ func (x *DiscoInfo) UnregisterProvider(domain string) {
 	delete(x.providers, domain)
 }
 
+// ImportServerItems registers every server item registered into another disco info instance,
+// along with its associated domain provider, if any.
+func (x *DiscoInfo) ImportServerItems(from *DiscoInfo) {
+	from.srvProvider.mu.RLock()
+	items := make([]Item, len(from.srvProvider.serverItems))
+	copy(items, from.srvProvider.serverItems)
+	from.srvProvider.mu.RUnlock()
+
+	for _, item := range items {
+		x.RegisterServerItem(item)
+
+		from.mu.RLock()
+		prov, ok := from.providers[item.Jid]
+		from.mu.RUnlock()
+		if ok {
+			x.RegisterProvider(item.Jid, prov)
+		}
+	}
+}
+
 // MatchesIQ returns whether or not an IQ should be
 // processed by the disco info module.
 func (x *DiscoInfo) MatchesIQ(iq *xmpp.IQ) bool {
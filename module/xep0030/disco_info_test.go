@@ -10,6 +10,7 @@ import (
 	"crypto/tls"
 	"testing"
 
+	"github.com/ortuman/jackal/auth/backend"
 	"github.com/ortuman/jackal/router/host"
 
 	c2srouter "github.com/ortuman/jackal/c2s/router"
@@ -196,12 +197,31 @@ func TestXEP0030_Provider(t *testing.T) {
 	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
 }
 
+func TestXEP0030_ImportServerItems(t *testing.T) {
+	r, rosterRep := setupTest("jackal.im")
+
+	x1 := New(r, rosterRep)
+	defer func() { _ = x1.Shutdown() }()
+
+	x1.RegisterServerItem(Item{Jid: "test.jackal.im", Name: "test"})
+	x1.RegisterProvider("test.jackal.im", &testDiscoInfoProvider{})
+	x1.RegisterProvider("jackal.im", &testDiscoInfoProvider{})
+
+	x2 := New(r, rosterRep)
+	defer func() { _ = x2.Shutdown() }()
+
+	x2.ImportServerItems(x1)
+	require.Equal(t, []Item{{Jid: "test.jackal.im", Name: "test"}}, x2.srvProvider.serverItems)
+	require.Len(t, x2.providers, 1)
+	require.NotNil(t, x2.providers["test.jackal.im"])
+}
+
 func setupTest(domain string) (router.Router, repository.Roster) {
 	hosts, _ := host.New([]host.Config{{Name: domain, Certificate: tls.Certificate{}}})
 	rosterRep := memorystorage.NewRoster()
 	r, _ := router.New(
 		hosts,
-		c2srouter.New(memorystorage.NewUser(), memorystorage.NewBlockList()),
+		c2srouter.New(backend.NewStorage(memorystorage.NewUser()), memorystorage.NewBlockList()),
 		nil,
 	)
 	return r, rosterRep
@@ -29,7 +29,7 @@ type Private struct {
 func New(router router.Router, privRep repository.Private) *Private {
 	x := &Private{
 		router:   router,
-		runQueue: runqueue.New("xep0049"),
+		runQueue: runqueue.NewMetered("xep0049"),
 		rep:      privRep,
 	}
 	return x
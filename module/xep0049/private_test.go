@@ -10,6 +10,7 @@ import (
 	"crypto/tls"
 	"testing"
 
+	"github.com/ortuman/jackal/auth/backend"
 	"github.com/ortuman/jackal/router/host"
 
 	c2srouter "github.com/ortuman/jackal/c2s/router"
@@ -171,7 +172,7 @@ func setupTest(domain string) (router.Router, repository.Private) {
 	s := memorystorage.NewPrivate()
 	r, _ := router.New(
 		hosts,
-		c2srouter.New(memorystorage.NewUser(), memorystorage.NewBlockList()),
+		c2srouter.New(backend.NewStorage(memorystorage.NewUser()), memorystorage.NewBlockList()),
 		nil,
 	)
 	return r, s
@@ -29,7 +29,7 @@ type VCard struct {
 func New(disco *xep0030.DiscoInfo, router router.Router, rep repository.VCard) *VCard {
 	v := &VCard{
 		router:   router,
-		runQueue: runqueue.New("xep0054"),
+		runQueue: runqueue.NewMetered("xep0054"),
 		rep:      rep,
 	}
 	if disco != nil {
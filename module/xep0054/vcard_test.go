@@ -10,6 +10,7 @@ import (
 	"crypto/tls"
 	"testing"
 
+	"github.com/ortuman/jackal/auth/backend"
 	"github.com/ortuman/jackal/router/host"
 
 	c2srouter "github.com/ortuman/jackal/c2s/router"
@@ -235,7 +236,7 @@ func setupTest(domain string) (router.Router, *memorystorage.VCard) {
 	s := memorystorage.NewVCard()
 	r, _ := router.New(
 		hosts,
-		c2srouter.New(memorystorage.NewUser(), memorystorage.NewBlockList()),
+		c2srouter.New(backend.NewStorage(memorystorage.NewUser()), memorystorage.NewBlockList()),
 		nil,
 	)
 	return r, s
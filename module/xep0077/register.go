@@ -8,6 +8,7 @@ package xep0077
 import (
 	"context"
 
+	"github.com/ortuman/jackal/auth/backend"
 	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/model"
 	"github.com/ortuman/jackal/module/xep0030"
@@ -32,19 +33,23 @@ type Config struct {
 
 // Register represents an in-band server stream module.
 type Register struct {
-	cfg      *Config
-	router   router.Router
-	runQueue *runqueue.RunQueue
-	rep      repository.User
+	cfg         *Config
+	router      router.Router
+	runQueue    *runqueue.RunQueue
+	rep         repository.User
+	fastRep     repository.FastToken
+	authBackend backend.Backend
 }
 
 // New returns an in-band registration IQ handler.
-func New(config *Config, disco *xep0030.DiscoInfo, router router.Router, userRep repository.User) *Register {
+func New(config *Config, disco *xep0030.DiscoInfo, router router.Router, userRep repository.User, fastRep repository.FastToken, authBackend backend.Backend) *Register {
 	r := &Register{
-		cfg:      config,
-		router:   router,
-		runQueue: runqueue.New("xep0077"),
-		rep:      userRep,
+		cfg:         config,
+		router:      router,
+		runQueue:    runqueue.NewMetered("xep0077"),
+		rep:         userRep,
+		fastRep:     fastRep,
+		authBackend: authBackend,
 	}
 	if disco != nil {
 		disco.RegisterServerFeature(registerNamespace)
@@ -158,9 +163,13 @@ func (x *Register) registerNewUser(ctx context.Context, iq *xmpp.IQ, query xmpp.
 	}
 	user := model.User{
 		Username:     userEl.Text(),
-		Password:     passwordEl.Text(),
 		LastPresence: xmpp.NewPresence(stm.JID(), stm.JID(), xmpp.UnavailableType),
 	}
+	if err := user.SetPassword(passwordEl.Text()); err != nil {
+		log.Error(err)
+		stm.SendElement(ctx, iq.InternalServerError())
+		return
+	}
 	if err := x.rep.UpsertUser(ctx, &user); err != nil {
 		log.Error(err)
 		stm.SendElement(ctx, iq.InternalServerError())
@@ -179,6 +188,11 @@ func (x *Register) cancelRegistration(ctx context.Context, iq *xmpp.IQ, query xm
 		stm.SendElement(ctx, iq.BadRequestError())
 		return
 	}
+	if err := x.fastRep.DeleteFastTokens(ctx, stm.Username()); err != nil {
+		log.Error(err)
+		stm.SendElement(ctx, iq.InternalServerError())
+		return
+	}
 	if err := x.rep.DeleteUser(ctx, stm.Username()); err != nil {
 		log.Error(err)
 		stm.SendElement(ctx, iq.InternalServerError())
@@ -201,23 +215,33 @@ func (x *Register) changePassword(ctx context.Context, password string, username
 		stm.SendElement(ctx, iq.NotAuthorizedError())
 		return
 	}
-	user, err := x.rep.FetchUser(ctx, username)
+	exists, err := x.authBackend.UserExists(ctx, username)
 	if err != nil {
 		log.Error(err)
 		stm.SendElement(ctx, iq.InternalServerError())
 		return
 	}
-	if user == nil {
-		stm.SendElement(ctx, iq.ResultIQ())
+	if !exists {
+		stm.SendElement(ctx, iq.NotAllowedError())
 		return
 	}
-	if user.Password != password {
-		user.Password = password
-		if err := x.rep.UpsertUser(ctx, user); err != nil {
-			log.Error(err)
-			stm.SendElement(ctx, iq.InternalServerError())
-			return
-		}
+	// credentials are changed wherever they're validated against
+	switch err := x.authBackend.SetPassword(ctx, username, password); err {
+	case nil:
+		break
+	case backend.ErrPasswordChangeNotSupported, backend.ErrPasswordChangeRefused:
+		stm.SendElement(ctx, iq.NotAllowedError())
+		return
+	default:
+		log.Error(err)
+		stm.SendElement(ctx, iq.InternalServerError())
+		return
+	}
+	// revoke previously issued authentication tokens
+	if err := x.fastRep.DeleteFastTokens(ctx, username); err != nil {
+		log.Error(err)
+		stm.SendElement(ctx, iq.InternalServerError())
+		return
 	}
 	stm.SendElement(ctx, iq.ResultIQ())
 }
@@ -9,7 +9,9 @@ import (
 	"context"
 	"crypto/tls"
 	"testing"
+	"time"
 
+	"github.com/ortuman/jackal/auth/backend"
 	"github.com/ortuman/jackal/router/host"
 
 	c2srouter "github.com/ortuman/jackal/c2s/router"
@@ -28,7 +30,7 @@ func TestXEP0077_Matching(t *testing.T) {
 
 	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
 
-	x := New(&Config{}, nil, r, s)
+	x := New(&Config{}, nil, r, s, memorystorage.NewFastToken(), backend.NewStorage(s))
 	defer func() { _ = x.Shutdown() }()
 
 	// test MatchesIQ
@@ -49,7 +51,7 @@ func TestXEP0077_InvalidToJID(t *testing.T) {
 	stm1 := stream.NewMockC2S(uuid.New(), j1)
 	r.Bind(context.Background(), stm1)
 
-	x := New(&Config{}, nil, r, s)
+	x := New(&Config{}, nil, r, s, memorystorage.NewFastToken(), backend.NewStorage(s))
 	defer func() { _ = x.Shutdown() }()
 
 	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
@@ -74,7 +76,7 @@ func TestXEP0077_NotAuthenticatedErrors(t *testing.T) {
 	stm := stream.NewMockC2S(uuid.New(), j)
 	r.Bind(context.Background(), stm)
 
-	x := New(&Config{}, nil, r, s)
+	x := New(&Config{}, nil, r, s, memorystorage.NewFastToken(), backend.NewStorage(s))
 	defer func() { _ = x.Shutdown() }()
 
 	iq := xmpp.NewIQType(uuid.New(), xmpp.ResultType)
@@ -91,7 +93,7 @@ func TestXEP0077_NotAuthenticatedErrors(t *testing.T) {
 	require.Equal(t, xmpp.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
 
 	// allow registration...
-	x = New(&Config{AllowRegistration: true}, nil, r, s)
+	x = New(&Config{AllowRegistration: true}, nil, r, s, memorystorage.NewFastToken(), backend.NewStorage(s))
 	defer func() { _ = x.Shutdown() }()
 
 	q := xmpp.NewElementNamespace("query", registerNamespace)
@@ -122,7 +124,7 @@ func TestXEP0077_AuthenticatedErrors(t *testing.T) {
 
 	stm.SetAuthenticated(true)
 
-	x := New(&Config{}, nil, r, s)
+	x := New(&Config{}, nil, r, s, memorystorage.NewFastToken(), backend.NewStorage(s))
 	defer func() { _ = x.Shutdown() }()
 
 	iq := xmpp.NewIQType(uuid.New(), xmpp.ResultType)
@@ -150,7 +152,7 @@ func TestXEP0077_RegisterUser(t *testing.T) {
 	stm := stream.NewMockC2S(uuid.New(), j)
 	r.Bind(context.Background(), stm)
 
-	x := New(&Config{AllowRegistration: true}, nil, r, s)
+	x := New(&Config{AllowRegistration: true}, nil, r, s, memorystorage.NewFastToken(), backend.NewStorage(s))
 	defer func() { _ = x.Shutdown() }()
 
 	iq := xmpp.NewIQType(uuid.New(), xmpp.GetType)
@@ -211,7 +213,7 @@ func TestXEP0077_CancelRegistration(t *testing.T) {
 
 	stm.SetAuthenticated(true)
 
-	x := New(&Config{}, nil, r, s)
+	x := New(&Config{}, nil, r, s, memorystorage.NewFastToken(), backend.NewStorage(s))
 	defer func() { _ = x.Shutdown() }()
 
 	_ = s.UpsertUser(context.Background(), &model.User{Username: "ortuman", Password: "1234"})
@@ -228,7 +230,10 @@ func TestXEP0077_CancelRegistration(t *testing.T) {
 	elem := stm.ReceiveElement()
 	require.Equal(t, xmpp.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
 
-	x = New(&Config{AllowCancel: true}, nil, r, s)
+	fastRep := memorystorage.NewFastToken()
+	_ = fastRep.InsertFastToken(context.Background(), &model.FastToken{Username: "ortuman", ClientID: "c1", Token: "t1", ExpiresAt: time.Now().Add(time.Hour)})
+
+	x = New(&Config{AllowCancel: true}, nil, r, s, fastRep, backend.NewStorage(s))
 	defer func() { _ = x.Shutdown() }()
 
 	q.AppendElement(xmpp.NewElementName("remove2"))
@@ -251,6 +256,9 @@ func TestXEP0077_CancelRegistration(t *testing.T) {
 
 	usr, _ := s.FetchUser(context.Background(), "ortuman")
 	require.Nil(t, usr)
+
+	tokens, _ := fastRep.FetchFastTokens(context.Background(), "ortuman")
+	require.Len(t, tokens, 0)
 }
 
 func TestXEP0077_ChangePassword(t *testing.T) {
@@ -264,7 +272,7 @@ func TestXEP0077_ChangePassword(t *testing.T) {
 
 	stm.SetAuthenticated(true)
 
-	x := New(&Config{}, nil, r, s)
+	x := New(&Config{}, nil, r, s, memorystorage.NewFastToken(), backend.NewStorage(s))
 	defer func() { _ = x.Shutdown() }()
 
 	_ = s.UpsertUser(context.Background(), &model.User{Username: "ortuman", Password: "1234"})
@@ -286,7 +294,10 @@ func TestXEP0077_ChangePassword(t *testing.T) {
 	elem := stm.ReceiveElement()
 	require.Equal(t, xmpp.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
 
-	x = New(&Config{AllowChange: true}, nil, r, s)
+	fastRep := memorystorage.NewFastToken()
+	_ = fastRep.InsertFastToken(context.Background(), &model.FastToken{Username: "ortuman", ClientID: "c1", Token: "t1", ExpiresAt: time.Now().Add(time.Hour)})
+
+	x = New(&Config{AllowChange: true}, nil, r, s, fastRep, backend.NewStorage(s))
 	defer func() { _ = x.Shutdown() }()
 
 	x.ProcessIQ(context.Background(), iq)
@@ -314,7 +325,33 @@ func TestXEP0077_ChangePassword(t *testing.T) {
 
 	usr, _ := s.FetchUser(context.Background(), "ortuman")
 	require.NotNil(t, usr)
-	require.Equal(t, "5678", usr.Password)
+	require.False(t, usr.HasLegacyPassword())
+	require.NotNil(t, usr.ScramSHA256)
+	require.True(t, usr.VerifyPassword("5678"))
+
+	tokens, _ := fastRep.FetchFastTokens(context.Background(), "ortuman")
+	require.Len(t, tokens, 0)
+	require.False(t, usr.VerifyPassword("1234"))
+
+	// backend not supporting password changes
+	x = New(&Config{AllowChange: true}, nil, r, s, fastRep, &fakeBackend{Backend: backend.NewStorage(s)})
+	defer func() { _ = x.Shutdown() }()
+
+	password.SetText("9012")
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
+
+	usr, _ = s.FetchUser(context.Background(), "ortuman")
+	require.True(t, usr.VerifyPassword("5678"))
+}
+
+type fakeBackend struct {
+	backend.Backend
+}
+
+func (b *fakeBackend) SetPassword(_ context.Context, _, _ string) error {
+	return backend.ErrPasswordChangeNotSupported
 }
 
 func setupTest(domain string) (router.Router, *memorystorage.User) {
@@ -322,7 +359,7 @@ func setupTest(domain string) (router.Router, *memorystorage.User) {
 	userRep := memorystorage.NewUser()
 	r, _ := router.New(
 		hosts,
-		c2srouter.New(userRep, memorystorage.NewBlockList()),
+		c2srouter.New(backend.NewStorage(userRep), memorystorage.NewBlockList()),
 		nil,
 	)
 	return r, userRep
@@ -44,7 +44,7 @@ func New(config *Config, disco *xep0030.DiscoInfo, router router.Router) *Versio
 	v := &Version{
 		cfg:      config,
 		router:   router,
-		runQueue: runqueue.New("xep0092"),
+		runQueue: runqueue.NewMetered("xep0092"),
 	}
 	if disco != nil {
 		disco.RegisterServerFeature(versionNamespace)
@@ -38,7 +38,7 @@ type EntityCaps struct {
 // New returns a new presence hub instance.
 func New(router router.Router, presencesRep repository.Presences, allocationID string) *EntityCaps {
 	return &EntityCaps{
-		runQueue:        runqueue.New("xep0115"),
+		runQueue:        runqueue.NewMetered("xep0115"),
 		router:          router,
 		presencesRep:    presencesRep,
 		allocationID:    allocationID,
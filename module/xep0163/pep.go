@@ -74,7 +74,7 @@ type Pep struct {
 // New returns a PEP command IQ handler module.
 func New(disco *xep0030.DiscoInfo, presenceHub *xep0115.EntityCaps, router router.Router, rosterRep repository.Roster, pubSubRep repository.PubSub) *Pep {
 	p := &Pep{
-		runQueue:   runqueue.New("xep0163"),
+		runQueue:   runqueue.NewMetered("xep0163"),
 		rosterRep:  rosterRep,
 		pubSubRep:  pubSubRep,
 		router:     router,
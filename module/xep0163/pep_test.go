@@ -10,6 +10,7 @@ import (
 	"crypto/tls"
 	"testing"
 
+	"github.com/ortuman/jackal/auth/backend"
 	c2srouter "github.com/ortuman/jackal/c2s/router"
 	capsmodel "github.com/ortuman/jackal/model/capabilities"
 	pubsubmodel "github.com/ortuman/jackal/model/pubsub"
@@ -951,7 +952,7 @@ func setupTest(domain string) (router.Router, repository.Presences, repository.R
 	pubSubRep := memorystorage.NewPubSub()
 	r, _ := router.New(
 		hosts,
-		c2srouter.New(memorystorage.NewUser(), memorystorage.NewBlockList()),
+		c2srouter.New(backend.NewStorage(memorystorage.NewUser()), memorystorage.NewBlockList()),
 		nil,
 	)
 	return r, presencesRep, rosterRep, pubSubRep
@@ -40,7 +40,7 @@ type BlockingCommand struct {
 // New returns a blocking command IQ handler module.
 func New(disco *xep0030.DiscoInfo, entityCaps *xep0115.EntityCaps, router router.Router, rosterRep repository.Roster, blockListRep repository.BlockList) *BlockingCommand {
 	b := &BlockingCommand{
-		runQueue:     runqueue.New("xep0191"),
+		runQueue:     runqueue.NewMetered("xep0191"),
 		router:       router,
 		blockListRep: blockListRep,
 		rosterRep:    rosterRep,
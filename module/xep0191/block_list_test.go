@@ -11,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ortuman/jackal/auth/backend"
 	c2srouter "github.com/ortuman/jackal/c2s/router"
 	"github.com/ortuman/jackal/model"
 	rostermodel "github.com/ortuman/jackal/model/roster"
@@ -291,7 +292,7 @@ func setupTest(domain string) (router.Router, repository.Presences, repository.B
 	rosterRep := memorystorage.NewRoster()
 	r, _ := router.New(
 		hosts,
-		c2srouter.New(memorystorage.NewUser(), blockListRep),
+		c2srouter.New(backend.NewStorage(memorystorage.NewUser()), blockListRep),
 		nil,
 	)
 	return r, presencesRep, blockListRep, rosterRep
@@ -74,7 +74,7 @@ func New(config *Config, disco *xep0030.DiscoInfo, router router.Router) *Ping {
 		router:      router,
 		pings:       make(map[string]*ping),
 		activePings: make(map[string]*ping),
-		runQueue:    runqueue.New("xep0199"),
+		runQueue:    runqueue.NewMetered("xep0199"),
 	}
 	if disco != nil {
 		disco.RegisterServerFeature(pingNamespace)
@@ -0,0 +1,187 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0280
+
+import (
+	"context"
+
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/util/runqueue"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+)
+
+const carbonsNamespace = "urn:xmpp:carbons:2"
+
+const forwardNamespace = "urn:xmpp:forward:0"
+
+const hintsNamespace = "urn:xmpp:hints"
+
+const carbonsEnabledCtxKey = "carbons:enabled"
+
+// Carbons represents a message carbons (XEP-0280) module.
+type Carbons struct {
+	runQueue *runqueue.RunQueue
+	router   router.Router
+}
+
+// New returns a message carbons IQ handler module.
+func New(disco *xep0030.DiscoInfo, router router.Router) *Carbons {
+	x := &Carbons{
+		runQueue: runqueue.NewMetered("xep0280"),
+		router:   router,
+	}
+	if disco != nil {
+		disco.RegisterServerFeature(carbonsNamespace)
+		disco.RegisterAccountFeature(carbonsNamespace)
+	}
+	return x
+}
+
+// MatchesIQ returns whether or not an IQ should be processed by the message carbons module.
+func (x *Carbons) MatchesIQ(iq *xmpp.IQ) bool {
+	e := iq.Elements()
+	return iq.IsSet() && (e.ChildNamespace("enable", carbonsNamespace) != nil || e.ChildNamespace("disable", carbonsNamespace) != nil)
+}
+
+// ProcessIQ processes a message carbons IQ taking according actions over the associated stream.
+func (x *Carbons) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
+	x.runQueue.Run(func() {
+		stm := x.router.LocalStream(iq.FromJID().Node(), iq.FromJID().Resource())
+		if stm == nil {
+			return
+		}
+		x.processIQ(ctx, iq, stm)
+	})
+}
+
+// ProcessSentMessage sends a copy of a message sent by a local user to every other
+// carbons-enabled resource of the sender.
+func (x *Carbons) ProcessSentMessage(ctx context.Context, message *xmpp.Message) {
+	if !isMessageCopyable(message) {
+		return
+	}
+	fromJID := message.FromJID()
+	if !x.router.Hosts().IsLocalHost(fromJID.Domain()) {
+		return
+	}
+	for _, stm := range x.router.LocalStreams(fromJID.Node()) {
+		if stm.Resource() == fromJID.Resource() || !isCarbonsEnabled(stm) {
+			continue
+		}
+		stm.SendElement(ctx, carbonCopy("sent", message, stm.JID()))
+	}
+}
+
+// ProcessReceivedMessage sends a copy of a message delivered to a local user to every
+// carbons-enabled resource of the recipient that didn't get the original one.
+func (x *Carbons) ProcessReceivedMessage(ctx context.Context, message *xmpp.Message) {
+	if !isMessageCopyable(message) {
+		return
+	}
+	toJID := message.ToJID()
+	if !x.router.Hosts().IsLocalHost(toJID.Domain()) {
+		return
+	}
+	streams := x.router.LocalStreams(toJID.Node())
+	recipients := originalRecipients(streams, toJID)
+	for _, stm := range streams {
+		if recipients[stm.Resource()] || !isCarbonsEnabled(stm) {
+			continue
+		}
+		stm.SendElement(ctx, carbonCopy("received", message, stm.JID()))
+	}
+}
+
+// Enable enables message carbons on a given stream, as if it had been requested through an IQ.
+func (x *Carbons) Enable(stm stream.C2S) {
+	stm.SetValue(carbonsEnabledCtxKey, true)
+}
+
+// Shutdown shuts down message carbons module.
+func (x *Carbons) Shutdown() error {
+	c := make(chan struct{})
+	x.runQueue.Stop(func() { close(c) })
+	<-c
+	return nil
+}
+
+func (x *Carbons) processIQ(ctx context.Context, iq *xmpp.IQ, stm stream.C2S) {
+	if !iq.ToJID().MatchesWithOptions(stm.JID(), jid.MatchesBare) {
+		stm.SendElement(ctx, iq.ForbiddenError())
+		return
+	}
+	enabled := iq.Elements().ChildNamespace("enable", carbonsNamespace) != nil
+	stm.SetValue(carbonsEnabledCtxKey, enabled)
+
+	stm.SendElement(ctx, iq.ResultIQ())
+}
+
+// originalRecipients returns the set of resources a message was delivered to,
+// according to c2s router delivery rules.
+func originalRecipients(streams []stream.C2S, toJID *jid.JID) map[string]bool {
+	recipients := make(map[string]bool)
+	if toJID.IsFullWithUser() {
+		recipients[toJID.Resource()] = true
+		return recipients
+	}
+	var highestPriority int8
+	var highest stream.C2S
+	for _, stm := range streams {
+		if p := stm.Presence(); p != nil && p.IsAvailable() && p.Priority() > highestPriority {
+			highest = stm
+			highestPriority = p.Priority()
+		}
+	}
+	if highest != nil {
+		recipients[highest.Resource()] = true
+		return recipients
+	}
+	// message has been broadcasted to every available resource
+	for _, stm := range streams {
+		if p := stm.Presence(); p != nil && p.IsAvailable() {
+			recipients[stm.Resource()] = true
+		}
+	}
+	return recipients
+}
+
+func carbonCopy(direction string, message *xmpp.Message, toJID *jid.JID) *xmpp.Message {
+	original := xmpp.NewElementFromElement(message)
+	original.SetNamespace("jabber:client")
+
+	forwarded := xmpp.NewElementNamespace("forwarded", forwardNamespace)
+	forwarded.AppendElement(original)
+
+	carbon := xmpp.NewElementNamespace(direction, carbonsNamespace)
+	carbon.AppendElement(forwarded)
+
+	msg := xmpp.NewMessageType(uuid.New(), message.Type())
+	msg.SetFromJID(toJID.ToBareJID())
+	msg.SetToJID(toJID)
+	msg.AppendElement(carbon)
+	return msg
+}
+
+func isCarbonsEnabled(stm stream.C2S) bool {
+	enabled, _ := stm.Value(carbonsEnabledCtxKey).(bool)
+	return enabled
+}
+
+func isMessageCopyable(message *xmpp.Message) bool {
+	if !message.IsChat() {
+		return false
+	}
+	e := message.Elements()
+	if e.ChildNamespace("private", carbonsNamespace) != nil || e.ChildNamespace("no-copy", hintsNamespace) != nil {
+		return false
+	}
+	// never copy a carbon copy
+	return e.ChildNamespace("sent", carbonsNamespace) == nil && e.ChildNamespace("received", carbonsNamespace) == nil
+}
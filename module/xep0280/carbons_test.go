@@ -0,0 +1,209 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0280
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	"github.com/ortuman/jackal/auth/backend"
+	c2srouter "github.com/ortuman/jackal/c2s/router"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/router/host"
+	memorystorage "github.com/ortuman/jackal/storage/memory"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXEP0280_Matching(t *testing.T) {
+	r := setupTest("jackal.im")
+
+	x := New(nil, r)
+	defer func() { _ = x.Shutdown() }()
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.AppendElement(xmpp.NewElementNamespace("enable", carbonsNamespace))
+	require.True(t, x.MatchesIQ(iq))
+
+	iq = xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.AppendElement(xmpp.NewElementNamespace("disable", carbonsNamespace))
+	require.True(t, x.MatchesIQ(iq))
+
+	iq = xmpp.NewIQType(uuid.New(), xmpp.GetType)
+	iq.AppendElement(xmpp.NewElementNamespace("enable", carbonsNamespace))
+	require.False(t, x.MatchesIQ(iq))
+}
+
+func TestXEP0280_EnableDisable(t *testing.T) {
+	r := setupTest("jackal.im")
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+	r.Bind(context.Background(), stm)
+
+	x := New(nil, r)
+	defer func() { _ = x.Shutdown() }()
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	iq.AppendElement(xmpp.NewElementNamespace("enable", carbonsNamespace))
+
+	x.ProcessIQ(context.Background(), iq)
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	require.True(t, isCarbonsEnabled(stm))
+
+	iq = xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	iq.AppendElement(xmpp.NewElementNamespace("disable", carbonsNamespace))
+
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	require.False(t, isCarbonsEnabled(stm))
+
+	// enabling someone else's carbons
+	j2, _ := jid.New("noelia", "jackal.im", "", true)
+	iq.SetToJID(j2)
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestXEP0280_SentMessage(t *testing.T) {
+	r := setupTest("jackal.im")
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("ortuman", "jackal.im", "garden", true)
+	j3, _ := jid.New("ortuman", "jackal.im", "yard", true)
+	j4, _ := jid.New("noelia", "jackal.im", "desktop", true)
+
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	stm2 := stream.NewMockC2S(uuid.New(), j2)
+	stm3 := stream.NewMockC2S(uuid.New(), j3)
+	r.Bind(context.Background(), stm1)
+	r.Bind(context.Background(), stm2)
+	r.Bind(context.Background(), stm3)
+
+	stm1.SetValue(carbonsEnabledCtxKey, true)
+	stm2.SetValue(carbonsEnabledCtxKey, true)
+
+	x := New(nil, r)
+	defer func() { _ = x.Shutdown() }()
+
+	msg := tUtilChatMessage(j1, j4)
+	x.ProcessSentMessage(context.Background(), msg)
+
+	elem := stm2.ReceiveElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, j2.String(), elem.To())
+	require.Equal(t, j2.ToBareJID().String(), elem.From())
+
+	sent := elem.Elements().ChildNamespace("sent", carbonsNamespace)
+	require.NotNil(t, sent)
+	forwarded := sent.Elements().ChildNamespace("forwarded", forwardNamespace)
+	require.NotNil(t, forwarded)
+	require.Equal(t, msg.ID(), forwarded.Elements().Child("message").ID())
+
+	// neither the sender nor a disabled resource get a copy
+	tUtilRequireNoElement(t, stm1)
+	tUtilRequireNoElement(t, stm3)
+
+	// private message
+	msg = tUtilChatMessage(j1, j4)
+	msg.AppendElement(xmpp.NewElementNamespace("private", carbonsNamespace))
+	x.ProcessSentMessage(context.Background(), msg)
+	tUtilRequireNoElement(t, stm2)
+
+	// no-copy hint
+	msg = tUtilChatMessage(j1, j4)
+	msg.AppendElement(xmpp.NewElementNamespace("no-copy", hintsNamespace))
+	x.ProcessSentMessage(context.Background(), msg)
+	tUtilRequireNoElement(t, stm2)
+}
+
+func TestXEP0280_ReceivedMessage(t *testing.T) {
+	r := setupTest("jackal.im")
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("ortuman", "jackal.im", "garden", true)
+	j3, _ := jid.New("noelia", "jackal.im", "desktop", true)
+
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	stm2 := stream.NewMockC2S(uuid.New(), j2)
+	stm1.SetPresence(xmpp.NewPresence(j1, j1, xmpp.AvailableType))
+	stm2.SetPresence(xmpp.NewPresence(j2, j2, xmpp.AvailableType))
+	r.Bind(context.Background(), stm1)
+	r.Bind(context.Background(), stm2)
+
+	stm1.SetValue(carbonsEnabledCtxKey, true)
+	stm2.SetValue(carbonsEnabledCtxKey, true)
+
+	x := New(nil, r)
+	defer func() { _ = x.Shutdown() }()
+
+	// addressed to full JID
+	msg := tUtilChatMessage(j3, j1)
+	x.ProcessReceivedMessage(context.Background(), msg)
+
+	elem := stm2.ReceiveElement()
+	require.NotNil(t, elem.Elements().ChildNamespace("received", carbonsNamespace))
+	tUtilRequireNoElement(t, stm1)
+
+	// addressed to bare JID... broadcasted to every available resource
+	msg = tUtilChatMessage(j3, j1.ToBareJID())
+	x.ProcessReceivedMessage(context.Background(), msg)
+	tUtilRequireNoElement(t, stm1)
+	tUtilRequireNoElement(t, stm2)
+
+	// addressed to bare JID... delivered to highest priority resource
+	p := xmpp.NewElementName("presence")
+	p.AppendElement(xmpp.NewElementName("priority").SetText("5"))
+	prioritized, _ := xmpp.NewPresenceFromElement(p, j2, j2)
+	stm2.SetPresence(prioritized)
+
+	x.ProcessReceivedMessage(context.Background(), msg)
+	elem = stm1.ReceiveElement()
+	require.NotNil(t, elem.Elements().ChildNamespace("received", carbonsNamespace))
+	tUtilRequireNoElement(t, stm2)
+
+	// not a chat message
+	msg = tUtilChatMessage(j3, j1)
+	msg.SetType(xmpp.NormalType)
+	x.ProcessReceivedMessage(context.Background(), msg)
+	tUtilRequireNoElement(t, stm2)
+}
+
+func tUtilChatMessage(from, to *jid.JID) *xmpp.Message {
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg.SetFromJID(from)
+	msg.SetToJID(to)
+	msg.AppendElement(xmpp.NewElementName("body").SetText("Hi!"))
+	return msg
+}
+
+// tUtilRequireNoElement checks that no element has been sent to a stream by enqueuing a marker element after them.
+func tUtilRequireNoElement(t *testing.T, stm *stream.MockC2S) {
+	stm.SendElement(context.Background(), xmpp.NewElementName("marker"))
+	require.Equal(t, "marker", stm.ReceiveElement().Name())
+}
+
+func setupTest(domain string) router.Router {
+	hosts, _ := host.New([]host.Config{{Name: domain, Certificate: tls.Certificate{}}})
+
+	r, _ := router.New(
+		hosts,
+		c2srouter.New(backend.NewStorage(memorystorage.NewUser()), memorystorage.NewBlockList()),
+		nil,
+	)
+	return r
+}
@@ -0,0 +1,344 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0313
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage/repository"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/util/runqueue"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+)
+
+const (
+	mamNamespace = "urn:xmpp:mam:2"
+
+	stanzaIDNamespace = "urn:xmpp:sid:0"
+
+	rsmNamespace = "http://jabber.org/protocol/rsm"
+
+	forwardNamespace = "urn:xmpp:forward:0"
+
+	delayNamespace = "urn:xmpp:delay"
+
+	hintsNamespace = "urn:xmpp:hints"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 250
+)
+
+const timeLayout = "2006-01-02T15:04:05Z"
+
+// Mam represents a message archive management (XEP-0313) module.
+type Mam struct {
+	runQueue   *runqueue.RunQueue
+	router     router.Router
+	userRep    repository.User
+	archiveRep repository.Archive
+}
+
+// New returns a message archive management IQ handler module.
+func New(disco *xep0030.DiscoInfo, router router.Router, userRep repository.User, archiveRep repository.Archive) *Mam {
+	x := &Mam{
+		runQueue:   runqueue.NewMetered("xep0313"),
+		router:     router,
+		userRep:    userRep,
+		archiveRep: archiveRep,
+	}
+	if disco != nil {
+		disco.RegisterServerFeature(stanzaIDNamespace)
+		disco.RegisterAccountFeature(mamNamespace)
+		disco.RegisterAccountFeature(stanzaIDNamespace)
+	}
+	return x
+}
+
+// MatchesIQ returns whether or not an IQ should be processed by the message archive module.
+func (x *Mam) MatchesIQ(iq *xmpp.IQ) bool {
+	return (iq.IsGet() || iq.IsSet()) && iq.Elements().ChildNamespace("query", mamNamespace) != nil
+}
+
+// ProcessIQ processes a message archive IQ taking according actions over the associated stream.
+func (x *Mam) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
+	x.runQueue.Run(func() {
+		stm := x.router.LocalStream(iq.FromJID().Node(), iq.FromJID().Resource())
+		if stm == nil {
+			return
+		}
+		x.processIQ(ctx, iq, stm)
+	})
+}
+
+// ArchiveMessage stores a message into the archive of every local user taking part in the conversation.
+// Returned message is the one to be delivered to its recipient, tagged with the assigned stanza-id.
+func (x *Mam) ArchiveMessage(ctx context.Context, message *xmpp.Message) *xmpp.Message {
+	if !isMessageArchivable(message) {
+		return message
+	}
+	fromJID := message.FromJID()
+	toJID := message.ToJID()
+	stamp := time.Now().UTC()
+
+	if x.isLocalUser(ctx, fromJID) {
+		sent, _ := xmpp.NewMessageFromElement(message, fromJID, toJID)
+		x.archive(ctx, &model.ArchivedMessage{
+			Username: fromJID.Node(),
+			ID:       uuid.New(),
+			With:     toJID.ToBareJID().String(),
+			Message:  sent,
+			Stamp:    stamp,
+		})
+	}
+	if !x.isLocalUser(ctx, toJID) {
+		return message
+	}
+	archiveJID := toJID.ToBareJID().String()
+	stanzaID := uuid.New()
+
+	received, _ := xmpp.NewMessageFromElement(message, fromJID, toJID)
+	removeStanzaIDs(received, archiveJID) // never trust stanza-id elements provided by the sender
+	received.AppendElement(xmpp.NewElementNamespace("stanza-id", stanzaIDNamespace).
+		SetAttribute("by", archiveJID).
+		SetAttribute("id", stanzaID))
+
+	x.archive(ctx, &model.ArchivedMessage{
+		Username: toJID.Node(),
+		ID:       stanzaID,
+		With:     fromJID.ToBareJID().String(),
+		Message:  received,
+		Stamp:    stamp,
+	})
+	return received
+}
+
+// Shutdown shuts down message archive module.
+func (x *Mam) Shutdown() error {
+	c := make(chan struct{})
+	x.runQueue.Stop(func() { close(c) })
+	<-c
+	return nil
+}
+
+func (x *Mam) archive(ctx context.Context, message *model.ArchivedMessage) {
+	x.runQueue.Run(func() {
+		if err := x.archiveRep.InsertArchivedMessage(ctx, message); err != nil {
+			log.Error(err)
+			return
+		}
+		log.Infof("archived message... id: %s (%s)", message.ID, message.Username)
+	})
+}
+
+func (x *Mam) isLocalUser(ctx context.Context, j *jid.JID) bool {
+	if j == nil || len(j.Node()) == 0 || !x.router.Hosts().IsLocalHost(j.Domain()) {
+		return false
+	}
+	exists, err := x.userRep.UserExists(ctx, j.Node())
+	if err != nil {
+		log.Error(err)
+		return false
+	}
+	return exists
+}
+
+func (x *Mam) processIQ(ctx context.Context, iq *xmpp.IQ, stm stream.C2S) {
+	if !iq.ToJID().MatchesWithOptions(stm.JID(), jid.MatchesBare) {
+		stm.SendElement(ctx, iq.ForbiddenError())
+		return
+	}
+	if iq.IsGet() {
+		x.sendQueryForm(ctx, iq, stm)
+		return
+	}
+	x.queryArchive(ctx, iq, stm)
+}
+
+func (x *Mam) sendQueryForm(ctx context.Context, iq *xmpp.IQ, stm stream.C2S) {
+	form := &xep0004.DataForm{
+		Type: xep0004.Form,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{mamNamespace}},
+			{Var: "with", Type: xep0004.JidSingle},
+			{Var: "start", Type: xep0004.TextSingle},
+			{Var: "end", Type: xep0004.TextSingle},
+		},
+	}
+	query := xmpp.NewElementNamespace("query", mamNamespace)
+	query.AppendElement(form.Element())
+
+	result := iq.ResultIQ()
+	result.AppendElement(query)
+	stm.SendElement(ctx, result)
+}
+
+func (x *Mam) queryArchive(ctx context.Context, iq *xmpp.IQ, stm stream.C2S) {
+	query := iq.Elements().ChildNamespace("query", mamNamespace)
+
+	aq, errStanza := parseArchiveQuery(iq, query)
+	if errStanza != nil {
+		stm.SendElement(ctx, errStanza)
+		return
+	}
+	pageSize := aq.Max
+
+	// request one more message to find out whether or not the result set is complete
+	aq.Max++
+	messages, err := x.archiveRep.FetchArchivedMessages(ctx, stm.Username(), aq)
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(ctx, iq.InternalServerError())
+		return
+	}
+	complete := len(messages) <= pageSize
+	if !complete {
+		if aq.IsBackwards() {
+			messages = messages[1:]
+		} else {
+			messages = messages[:pageSize]
+		}
+	}
+	queryID := query.Attributes().Get("queryid")
+	userJID := stm.JID()
+
+	for _, am := range messages {
+		x.sendResult(ctx, queryID, &am, userJID, stm)
+	}
+	fin := xmpp.NewElementNamespace("fin", mamNamespace)
+	if complete {
+		fin.SetAttribute("complete", "true")
+	}
+	set := xmpp.NewElementNamespace("set", rsmNamespace)
+	if len(messages) > 0 {
+		set.AppendElement(xmpp.NewElementName("first").SetText(messages[0].ID))
+		set.AppendElement(xmpp.NewElementName("last").SetText(messages[len(messages)-1].ID))
+	} else {
+		set.AppendElement(xmpp.NewElementName("count").SetText("0"))
+	}
+	fin.AppendElement(set)
+
+	result := iq.ResultIQ()
+	result.AppendElement(fin)
+	stm.SendElement(ctx, result)
+}
+
+func (x *Mam) sendResult(ctx context.Context, queryID string, am *model.ArchivedMessage, userJID *jid.JID, stm stream.C2S) {
+	archived := xmpp.NewElementFromElement(am.Message)
+	archived.SetNamespace("jabber:client")
+
+	forwarded := xmpp.NewElementNamespace("forwarded", forwardNamespace)
+	forwarded.AppendElement(xmpp.NewElementNamespace("delay", delayNamespace).SetAttribute("stamp", am.Stamp.UTC().Format(timeLayout)))
+	forwarded.AppendElement(archived)
+
+	result := xmpp.NewElementNamespace("result", mamNamespace)
+	if len(queryID) > 0 {
+		result.SetAttribute("queryid", queryID)
+	}
+	result.SetAttribute("id", am.ID)
+	result.AppendElement(forwarded)
+
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.NormalType)
+	msg.SetFromJID(userJID.ToBareJID())
+	msg.SetToJID(userJID)
+	msg.AppendElement(result)
+	stm.SendElement(ctx, msg)
+}
+
+func parseArchiveQuery(iq *xmpp.IQ, query xmpp.XElement) (*model.ArchiveQuery, xmpp.Stanza) {
+	aq := &model.ArchiveQuery{Max: defaultPageSize}
+
+	if x := query.Elements().ChildNamespace("x", xep0004.FormNamespace); x != nil {
+		form, err := xep0004.NewFormFromElement(x)
+		if err != nil || form.Type != xep0004.Submit {
+			return nil, iq.BadRequestError()
+		}
+		for _, field := range form.Fields {
+			var value string
+			if len(field.Values) > 0 {
+				value = field.Values[0]
+			}
+			switch field.Var {
+			case xep0004.FormType:
+				if value != mamNamespace {
+					return nil, iq.BadRequestError()
+				}
+			case "with":
+				withJID, err := jid.NewWithString(value, false)
+				if err != nil {
+					return nil, iq.JidMalformedError()
+				}
+				aq.With = withJID.ToBareJID().String()
+			case "start", "end":
+				t, err := time.Parse(time.RFC3339, value)
+				if err != nil {
+					return nil, iq.BadRequestError()
+				}
+				if field.Var == "start" {
+					aq.Start = t
+				} else {
+					aq.End = t
+				}
+			default:
+				return nil, iq.FeatureNotImplementedError()
+			}
+		}
+	}
+	if set := query.Elements().ChildNamespace("set", rsmNamespace); set != nil {
+		if maxEl := set.Elements().Child("max"); maxEl != nil {
+			max, err := strconv.Atoi(maxEl.Text())
+			if err != nil || max < 0 {
+				return nil, iq.BadRequestError()
+			}
+			aq.Max = max
+		}
+		if after := set.Elements().Child("after"); after != nil {
+			aq.After = after.Text()
+		}
+		if before := set.Elements().Child("before"); before != nil {
+			aq.Before = before.Text()
+			aq.LastPage = len(aq.Before) == 0
+		}
+	}
+	if aq.Max > maxPageSize {
+		aq.Max = maxPageSize
+	}
+	return aq, nil
+}
+
+func removeStanzaIDs(message *xmpp.Message, by string) {
+	elements := message.Elements().All()
+	message.ClearElements()
+	for _, elem := range elements {
+		if elem.Name() == "stanza-id" && elem.Namespace() == stanzaIDNamespace && elem.Attributes().Get("by") == by {
+			continue
+		}
+		message.AppendElement(elem)
+	}
+}
+
+func isMessageArchivable(message *xmpp.Message) bool {
+	if message.Elements().ChildNamespace("no-store", hintsNamespace) != nil {
+		return false
+	}
+	if !message.IsNormal() && !message.IsChat() {
+		return false
+	}
+	if message.Elements().ChildNamespace("store", hintsNamespace) != nil {
+		return true
+	}
+	return message.IsMessageWithBody()
+}
@@ -0,0 +1,283 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0313
+
+import (
+	"context"
+	"crypto/tls"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/auth/backend"
+	c2srouter "github.com/ortuman/jackal/c2s/router"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/router/host"
+	memorystorage "github.com/ortuman/jackal/storage/memory"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXEP0313_Matching(t *testing.T) {
+	r, userRep, archiveRep := setupTest("jackal.im")
+
+	x := New(nil, r, userRep, archiveRep)
+	defer func() { _ = x.Shutdown() }()
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	iq.AppendElement(xmpp.NewElementNamespace("query", mamNamespace))
+	require.True(t, x.MatchesIQ(iq))
+
+	iq = xmpp.NewIQType(uuid.New(), xmpp.ResultType)
+	iq.AppendElement(xmpp.NewElementNamespace("query", mamNamespace))
+	require.False(t, x.MatchesIQ(iq))
+
+	iq = xmpp.NewIQType(uuid.New(), xmpp.GetType)
+	iq.AppendElement(xmpp.NewElementNamespace("query", "urn:xmpp:mam:1"))
+	require.False(t, x.MatchesIQ(iq))
+}
+
+func TestXEP0313_ArchiveMessage(t *testing.T) {
+	r, userRep, archiveRep := setupTest("jackal.im")
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "ortuman", Password: "1234"})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "noelia", Password: "1234"})
+
+	x := New(nil, r, userRep, archiveRep)
+	defer func() { _ = x.Shutdown() }()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "yard", true)
+	j3, _ := jid.New("romeo", "example.org", "garden", true)
+
+	// not archivable
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg.SetFromJID(j1)
+	msg.SetToJID(j2)
+	require.Equal(t, msg, x.ArchiveMessage(context.Background(), msg))
+
+	// local conversation
+	msg = tUtilChatMessage(j1, j2, "Hi!")
+	forged := xmpp.NewElementNamespace("stanza-id", stanzaIDNamespace)
+	forged.SetAttribute("by", "noelia@jackal.im")
+	forged.SetAttribute("id", "forged")
+	msg.AppendElement(forged)
+
+	delivered := x.ArchiveMessage(context.Background(), msg)
+	stanzaIDs := delivered.Elements().ChildrenNamespace("stanza-id", stanzaIDNamespace)
+	require.Len(t, stanzaIDs, 1)
+	require.Equal(t, "noelia@jackal.im", stanzaIDs[0].Attributes().Get("by"))
+	require.NotEqual(t, "forged", stanzaIDs[0].Attributes().Get("id"))
+
+	// remote recipient
+	msg = tUtilChatMessage(j1, j3, "Hi!")
+	delivered = x.ArchiveMessage(context.Background(), msg)
+	require.Nil(t, delivered.Elements().ChildNamespace("stanza-id", stanzaIDNamespace))
+
+	// wait for insertion...
+	time.Sleep(time.Millisecond * 100)
+
+	msgs, _ := archiveRep.FetchArchivedMessages(context.Background(), "ortuman", &model.ArchiveQuery{})
+	require.Len(t, msgs, 2)
+	require.Equal(t, "noelia@jackal.im", msgs[0].With)
+	require.Equal(t, "romeo@example.org", msgs[1].With)
+
+	msgs, _ = archiveRep.FetchArchivedMessages(context.Background(), "noelia", &model.ArchiveQuery{})
+	require.Len(t, msgs, 1)
+	require.Equal(t, "ortuman@jackal.im", msgs[0].With)
+	require.Equal(t, stanzaIDs[0].Attributes().Get("id"), msgs[0].ID)
+}
+
+func TestXEP0313_QueryForm(t *testing.T) {
+	r, userRep, archiveRep := setupTest("jackal.im")
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+	r.Bind(context.Background(), stm)
+
+	x := New(nil, r, userRep, archiveRep)
+	defer func() { _ = x.Shutdown() }()
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.GetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	iq.AppendElement(xmpp.NewElementNamespace("query", mamNamespace))
+
+	x.ProcessIQ(context.Background(), iq)
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	form, err := xep0004.NewFormFromElement(elem.Elements().ChildNamespace("query", mamNamespace).Elements().Child("x"))
+	require.Nil(t, err)
+	require.Equal(t, mamNamespace, form.Fields.ValueForFieldOfType(xep0004.FormType, xep0004.Hidden))
+
+	// querying someone else's archive
+	j2, _ := jid.New("noelia", "jackal.im", "", true)
+	iq.SetToJID(j2)
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestXEP0313_QueryArchive(t *testing.T) {
+	r, userRep, archiveRep := setupTest("jackal.im")
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+	r.Bind(context.Background(), stm)
+
+	x := New(nil, r, userRep, archiveRep)
+	defer func() { _ = x.Shutdown() }()
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		with := "noelia@jackal.im"
+		if i == 4 {
+			with = "romeo@example.org"
+		}
+		withJID, _ := jid.NewWithString(with, true)
+		_ = archiveRep.InsertArchivedMessage(context.Background(), &model.ArchivedMessage{
+			Username: "ortuman",
+			ID:       strconv.Itoa(i),
+			With:     with,
+			Message:  tUtilChatMessage(withJID, j, "Hi!"),
+			Stamp:    start.Add(time.Duration(i) * time.Hour),
+		})
+	}
+
+	// first page
+	iq := tUtilQueryIQ(j, "q1", nil, `<set xmlns="http://jabber.org/protocol/rsm"><max>2</max></set>`)
+	x.ProcessIQ(context.Background(), iq)
+
+	ids, fin := tUtilReceiveResults(t, stm, "q1", iq.ID())
+	require.Equal(t, []string{"0", "1"}, ids)
+	require.Equal(t, "", fin.Attributes().Get("complete"))
+	require.Equal(t, "1", fin.Elements().Child("set").Elements().Child("last").Text())
+
+	// next page
+	iq = tUtilQueryIQ(j, "q2", nil, `<set xmlns="http://jabber.org/protocol/rsm"><max>10</max><after>1</after></set>`)
+	x.ProcessIQ(context.Background(), iq)
+
+	ids, fin = tUtilReceiveResults(t, stm, "q2", iq.ID())
+	require.Equal(t, []string{"2", "3", "4"}, ids)
+	require.Equal(t, "true", fin.Attributes().Get("complete"))
+
+	// last page
+	iq = tUtilQueryIQ(j, "q3", nil, `<set xmlns="http://jabber.org/protocol/rsm"><max>2</max><before/></set>`)
+	x.ProcessIQ(context.Background(), iq)
+
+	ids, fin = tUtilReceiveResults(t, stm, "q3", iq.ID())
+	require.Equal(t, []string{"3", "4"}, ids)
+	require.Equal(t, "3", fin.Elements().Child("set").Elements().Child("first").Text())
+
+	// filtered query
+	form := &xep0004.DataForm{
+		Type: xep0004.Submit,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{mamNamespace}},
+			{Var: "with", Values: []string{"noelia@jackal.im"}},
+			{Var: "start", Values: []string{"2020-01-01T01:00:00Z"}},
+			{Var: "end", Values: []string{"2020-01-01T02:00:00Z"}},
+		},
+	}
+	iq = tUtilQueryIQ(j, "q4", form, "")
+	x.ProcessIQ(context.Background(), iq)
+
+	ids, fin = tUtilReceiveResults(t, stm, "q4", iq.ID())
+	require.Equal(t, []string{"1", "2"}, ids)
+	require.Equal(t, "true", fin.Attributes().Get("complete"))
+
+	// unsupported field
+	form.Fields = append(form.Fields, xep0004.Field{Var: "fulltext", Values: []string{"Hi"}})
+	iq = tUtilQueryIQ(j, "q5", form, "")
+	x.ProcessIQ(context.Background(), iq)
+
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrorType, elem.Type())
+	require.Equal(t, xmpp.ErrFeatureNotImplemented.Error(), elem.Error().Elements().All()[0].Name())
+
+	// malformed start
+	form.Fields = form.Fields[:3]
+	form.Fields[2] = xep0004.Field{Var: "start", Values: []string{"yesterday"}}
+	iq = tUtilQueryIQ(j, "q6", form, "")
+	x.ProcessIQ(context.Background(), iq)
+
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func tUtilChatMessage(from, to *jid.JID, body string) *xmpp.Message {
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg.SetFromJID(from)
+	msg.SetToJID(to)
+	msg.AppendElement(xmpp.NewElementName("body").SetText(body))
+	return msg
+}
+
+func tUtilQueryIQ(j *jid.JID, queryID string, form *xep0004.DataForm, set string) *xmpp.IQ {
+	query := xmpp.NewElementNamespace("query", mamNamespace)
+	query.SetAttribute("queryid", queryID)
+	if form != nil {
+		query.AppendElement(form.Element())
+	}
+	if len(set) > 0 {
+		p := xmpp.NewParser(strings.NewReader(set), xmpp.DefaultMode, 0)
+		setElem, _ := p.ParseElement()
+		query.AppendElement(setElem)
+	}
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	iq.AppendElement(query)
+	return iq
+}
+
+func tUtilReceiveResults(t *testing.T, stm *stream.MockC2S, queryID, iqID string) ([]string, xmpp.XElement) {
+	var ids []string
+	for {
+		elem := stm.ReceiveElement()
+		if elem.Name() == "iq" {
+			require.Equal(t, xmpp.ResultType, elem.Type())
+			require.Equal(t, iqID, elem.ID())
+
+			fin := elem.Elements().ChildNamespace("fin", mamNamespace)
+			require.NotNil(t, fin)
+			return ids, fin
+		}
+		result := elem.Elements().ChildNamespace("result", mamNamespace)
+		require.NotNil(t, result)
+		require.Equal(t, queryID, result.Attributes().Get("queryid"))
+
+		forwarded := result.Elements().ChildNamespace("forwarded", forwardNamespace)
+		require.NotNil(t, forwarded)
+		require.NotNil(t, forwarded.Elements().ChildNamespace("delay", delayNamespace))
+		require.NotNil(t, forwarded.Elements().Child("message"))
+
+		ids = append(ids, result.Attributes().Get("id"))
+	}
+}
+
+func setupTest(domain string) (router.Router, *memorystorage.User, *memorystorage.Archive) {
+	hosts, _ := host.New([]host.Config{{Name: domain, Certificate: tls.Certificate{}}})
+
+	userRep := memorystorage.NewUser()
+	archiveRep := memorystorage.NewArchive()
+	r, _ := router.New(
+		hosts,
+		c2srouter.New(backend.NewStorage(userRep), memorystorage.NewBlockList()),
+		nil,
+	)
+	return r, userRep, archiveRep
+}
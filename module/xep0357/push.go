@@ -0,0 +1,220 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0357
+
+import (
+	"context"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage/repository"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/util/runqueue"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+)
+
+const (
+	pushNamespace = "urn:xmpp:push:0"
+
+	pushSummaryNamespace = "urn:xmpp:push:summary"
+
+	pubSubNamespace = "http://jabber.org/protocol/pubsub"
+
+	publishOptionsNamespace = "http://jabber.org/protocol/pubsub#publish-options"
+
+	hintsNamespace = "urn:xmpp:hints"
+)
+
+// Push represents a push notifications (XEP-0357) module.
+type Push struct {
+	runQueue *runqueue.RunQueue
+	router   router.Router
+	pushRep  repository.Push
+}
+
+// New returns a push notifications IQ handler module.
+func New(disco *xep0030.DiscoInfo, router router.Router, pushRep repository.Push) *Push {
+	x := &Push{
+		runQueue: runqueue.NewMetered("xep0357"),
+		router:   router,
+		pushRep:  pushRep,
+	}
+	if disco != nil {
+		disco.RegisterAccountFeature(pushNamespace)
+	}
+	return x
+}
+
+// MatchesIQ returns whether or not an IQ should be processed by the push notifications module.
+func (x *Push) MatchesIQ(iq *xmpp.IQ) bool {
+	e := iq.Elements()
+	return iq.IsSet() && (e.ChildNamespace("enable", pushNamespace) != nil || e.ChildNamespace("disable", pushNamespace) != nil)
+}
+
+// ProcessIQ processes a push notifications IQ taking according actions over the associated stream.
+func (x *Push) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
+	x.runQueue.Run(func() {
+		stm := x.router.LocalStream(iq.FromJID().Node(), iq.FromJID().Resource())
+		if stm == nil {
+			return
+		}
+		x.processIQ(ctx, iq, stm)
+	})
+}
+
+// NotifyMessage publishes a push notification to every app server registered by the user.
+func (x *Push) NotifyMessage(ctx context.Context, username string, message *xmpp.Message) {
+	if !isMessageNotifiable(message) {
+		return
+	}
+	x.runQueue.Run(func() { x.notifyMessage(ctx, username, message) })
+}
+
+// Shutdown shuts down push notifications module.
+func (x *Push) Shutdown() error {
+	c := make(chan struct{})
+	x.runQueue.Stop(func() { close(c) })
+	<-c
+	return nil
+}
+
+func (x *Push) processIQ(ctx context.Context, iq *xmpp.IQ, stm stream.C2S) {
+	if !iq.ToJID().MatchesWithOptions(stm.JID(), jid.MatchesBare) {
+		stm.SendElement(ctx, iq.ForbiddenError())
+		return
+	}
+	if enable := iq.Elements().ChildNamespace("enable", pushNamespace); enable != nil {
+		x.enable(ctx, iq, enable, stm)
+	} else if disable := iq.Elements().ChildNamespace("disable", pushNamespace); disable != nil {
+		x.disable(ctx, iq, disable, stm)
+	}
+}
+
+func (x *Push) enable(ctx context.Context, iq *xmpp.IQ, enable xmpp.XElement, stm stream.C2S) {
+	appServerJID, err := jid.NewWithString(enable.Attributes().Get("jid"), false)
+	if err != nil {
+		stm.SendElement(ctx, iq.JidMalformedError())
+		return
+	}
+	node := enable.Attributes().Get("node")
+	if len(node) == 0 {
+		stm.SendElement(ctx, iq.BadRequestError())
+		return
+	}
+	var options xmpp.XElement
+	if x := enable.Elements().ChildNamespace("x", xep0004.FormNamespace); x != nil {
+		form, err := xep0004.NewFormFromElement(x)
+		if err != nil || form.Type != xep0004.Submit || form.Fields.ValueForFieldOfType(xep0004.FormType, xep0004.Hidden) != publishOptionsNamespace {
+			stm.SendElement(ctx, iq.BadRequestError())
+			return
+		}
+		options = x
+	}
+	err = x.pushRep.UpsertPushRegistration(ctx, &model.PushRegistration{
+		Username: stm.Username(),
+		JID:      appServerJID.String(),
+		Node:     node,
+		Options:  options,
+	})
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(ctx, iq.InternalServerError())
+		return
+	}
+	log.Infof("enabled push notifications: %s... (%s)", stm.Username(), appServerJID)
+
+	stm.SendElement(ctx, iq.ResultIQ())
+}
+
+func (x *Push) disable(ctx context.Context, iq *xmpp.IQ, disable xmpp.XElement, stm stream.C2S) {
+	appServerJID, err := jid.NewWithString(disable.Attributes().Get("jid"), false)
+	if err != nil {
+		stm.SendElement(ctx, iq.JidMalformedError())
+		return
+	}
+	node := disable.Attributes().Get("node")
+	if err := x.pushRep.DeletePushRegistrations(ctx, stm.Username(), appServerJID.String(), node); err != nil {
+		log.Error(err)
+		stm.SendElement(ctx, iq.InternalServerError())
+		return
+	}
+	log.Infof("disabled push notifications: %s... (%s)", stm.Username(), appServerJID)
+
+	stm.SendElement(ctx, iq.ResultIQ())
+}
+
+func (x *Push) notifyMessage(ctx context.Context, username string, message *xmpp.Message) {
+	registrations, err := x.pushRep.FetchPushRegistrations(ctx, username)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	if len(registrations) == 0 {
+		return
+	}
+	fromJID := message.ToJID().ToBareJID()
+	for _, reg := range registrations {
+		appServerJID, err := jid.NewWithString(reg.JID, true)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+		iq.SetFromJID(fromJID)
+		iq.SetToJID(appServerJID)
+		iq.AppendElement(notificationElement(&reg, message))
+
+		if err := x.router.Route(ctx, iq); err != nil {
+			log.Errorf("failed to publish push notification: %v", err)
+			continue
+		}
+		log.Infof("published push notification: %s... (%s)", username, appServerJID)
+	}
+}
+
+func notificationElement(reg *model.PushRegistration, message *xmpp.Message) xmpp.XElement {
+	summary := &xep0004.DataForm{
+		Type: xep0004.Submit,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{pushSummaryNamespace}},
+			{Var: "message-count", Values: []string{"1"}},
+			{Var: "last-message-sender", Values: []string{message.FromJID().String()}},
+		},
+	}
+	if body := message.Elements().Child("body"); body != nil {
+		summary.Fields = append(summary.Fields, xep0004.Field{Var: "last-message-body", Values: []string{body.Text()}})
+	}
+	notification := xmpp.NewElementNamespace("notification", pushNamespace)
+	notification.AppendElement(summary.Element())
+
+	item := xmpp.NewElementName("item")
+	item.AppendElement(notification)
+
+	publish := xmpp.NewElementName("publish")
+	publish.SetAttribute("node", reg.Node)
+	publish.AppendElement(item)
+
+	pubSub := xmpp.NewElementNamespace("pubsub", pubSubNamespace)
+	pubSub.AppendElement(publish)
+	if reg.Options != nil {
+		publishOptions := xmpp.NewElementName("publish-options")
+		publishOptions.AppendElement(reg.Options)
+		pubSub.AppendElement(publishOptions)
+	}
+	return pubSub
+}
+
+func isMessageNotifiable(message *xmpp.Message) bool {
+	if message.Elements().ChildNamespace("no-store", hintsNamespace) != nil {
+		return false
+	}
+	return (message.IsNormal() || message.IsChat()) && message.IsMessageWithBody()
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0357
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	"github.com/ortuman/jackal/auth/backend"
+	c2srouter "github.com/ortuman/jackal/c2s/router"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/router/host"
+	memorystorage "github.com/ortuman/jackal/storage/memory"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXEP0357_Matching(t *testing.T) {
+	r, pushRep := setupTest("jackal.im")
+
+	x := New(nil, r, pushRep)
+	defer func() { _ = x.Shutdown() }()
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.AppendElement(xmpp.NewElementNamespace("enable", pushNamespace))
+	require.True(t, x.MatchesIQ(iq))
+
+	iq = xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.AppendElement(xmpp.NewElementNamespace("disable", pushNamespace))
+	require.True(t, x.MatchesIQ(iq))
+
+	iq = xmpp.NewIQType(uuid.New(), xmpp.GetType)
+	iq.AppendElement(xmpp.NewElementNamespace("enable", pushNamespace))
+	require.False(t, x.MatchesIQ(iq))
+}
+
+func TestXEP0357_EnableDisable(t *testing.T) {
+	r, pushRep := setupTest("jackal.im")
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+	r.Bind(context.Background(), stm)
+
+	x := New(nil, r, pushRep)
+	defer func() { _ = x.Shutdown() }()
+
+	// missing node
+	iq := tUtilEnableIQ(j, "push.jackal.im", "", nil)
+	x.ProcessIQ(context.Background(), iq)
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	// bad publish options form
+	iq = tUtilEnableIQ(j, "push.jackal.im", "yxs32uqsflafdk3iuqo", &xep0004.DataForm{Type: xep0004.Submit})
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	iq = tUtilEnableIQ(j, "push.jackal.im", "yxs32uqsflafdk3iuqo", tUtilPublishOptions())
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	x.ProcessIQ(context.Background(), tUtilEnableIQ(j, "push.jackal.im", "d3a2b1", nil))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	regs, _ := pushRep.FetchPushRegistrations(context.Background(), "ortuman")
+	require.Len(t, regs, 2)
+
+	// disable a single node
+	iq = xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	iq.AppendElement(xmpp.NewElementNamespace("disable", pushNamespace).
+		SetAttribute("jid", "push.jackal.im").
+		SetAttribute("node", "d3a2b1"))
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	regs, _ = pushRep.FetchPushRegistrations(context.Background(), "ortuman")
+	require.Len(t, regs, 1)
+	require.Equal(t, "yxs32uqsflafdk3iuqo", regs[0].Node)
+	require.NotNil(t, regs[0].Options)
+
+	// enabling someone else's push notifications
+	j2, _ := jid.New("noelia", "jackal.im", "", true)
+	iq = tUtilEnableIQ(j, "push.jackal.im", "yxs32uqsflafdk3iuqo", nil)
+	iq.SetToJID(j2)
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestXEP0357_NotifyMessage(t *testing.T) {
+	r, pushRep := setupTest("jackal.im")
+
+	appServerJID, _ := jid.New("push", "jackal.im", "app", true)
+	appServerStm := stream.NewMockC2S(uuid.New(), appServerJID)
+	appServerStm.SetPresence(xmpp.NewPresence(appServerJID, appServerJID, xmpp.AvailableType))
+	r.Bind(context.Background(), appServerStm)
+
+	_ = pushRep.UpsertPushRegistration(context.Background(), &model.PushRegistration{
+		Username: "ortuman",
+		JID:      appServerJID.String(),
+		Node:     "yxs32uqsflafdk3iuqo",
+		Options:  tUtilPublishOptions().Element(),
+	})
+
+	x := New(nil, r, pushRep)
+	defer func() { _ = x.Shutdown() }()
+
+	j1, _ := jid.New("noelia", "jackal.im", "garden", true)
+	j2, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg.SetFromJID(j1)
+	msg.SetToJID(j2)
+	msg.AppendElement(xmpp.NewElementName("body").SetText("Hi!"))
+	x.NotifyMessage(context.Background(), "ortuman", msg)
+
+	elem := appServerStm.ReceiveElement()
+	require.Equal(t, "iq", elem.Name())
+	require.Equal(t, xmpp.SetType, elem.Type())
+	require.Equal(t, "ortuman@jackal.im", elem.From())
+
+	pubSub := elem.Elements().ChildNamespace("pubsub", pubSubNamespace)
+	require.NotNil(t, pubSub)
+	publish := pubSub.Elements().Child("publish")
+	require.NotNil(t, publish)
+	require.Equal(t, "yxs32uqsflafdk3iuqo", publish.Attributes().Get("node"))
+	require.NotNil(t, pubSub.Elements().Child("publish-options"))
+
+	notification := publish.Elements().Child("item").Elements().ChildNamespace("notification", pushNamespace)
+	require.NotNil(t, notification)
+	form, err := xep0004.NewFormFromElement(notification.Elements().ChildNamespace("x", xep0004.FormNamespace))
+	require.Nil(t, err)
+	require.Equal(t, pushSummaryNamespace, form.Fields.ValueForFieldOfType(xep0004.FormType, xep0004.Hidden))
+	require.Equal(t, j1.String(), form.Fields.ValueForField("last-message-sender"))
+	require.Equal(t, "Hi!", form.Fields.ValueForField("last-message-body"))
+
+	// not notifiable message
+	msg = xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg.SetFromJID(j1)
+	msg.SetToJID(j2)
+	msg.AppendElement(xmpp.NewElementNamespace("composing", "http://jabber.org/protocol/chatstates"))
+	x.NotifyMessage(context.Background(), "ortuman", msg)
+
+	appServerStm.SendElement(context.Background(), xmpp.NewElementName("marker"))
+	require.Equal(t, "marker", appServerStm.ReceiveElement().Name())
+}
+
+func tUtilEnableIQ(from *jid.JID, appServer, node string, publishOptions *xep0004.DataForm) *xmpp.IQ {
+	enable := xmpp.NewElementNamespace("enable", pushNamespace)
+	enable.SetAttribute("jid", appServer)
+	if len(node) > 0 {
+		enable.SetAttribute("node", node)
+	}
+	if publishOptions != nil {
+		enable.AppendElement(publishOptions.Element())
+	}
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(from.ToBareJID())
+	iq.AppendElement(enable)
+	return iq
+}
+
+func tUtilPublishOptions() *xep0004.DataForm {
+	return &xep0004.DataForm{
+		Type: xep0004.Submit,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{publishOptionsNamespace}},
+			{Var: "secret", Values: []string{"eruio234vzxc2kla-91"}},
+		},
+	}
+}
+
+func setupTest(domain string) (router.Router, *memorystorage.Push) {
+	hosts, _ := host.New([]host.Config{{Name: domain, Certificate: tls.Certificate{}}})
+
+	r, _ := router.New(
+		hosts,
+		c2srouter.New(backend.NewStorage(memorystorage.NewUser()), memorystorage.NewBlockList()),
+		nil,
+	)
+	return r, memorystorage.NewPush()
+}
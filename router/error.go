@@ -22,4 +22,7 @@ var (
 
 	// ErrFailedRemoteConnect will be returned by Route method if couldn't establish a connection to the remote server.
 	ErrFailedRemoteConnect = errors.New("router: failed remote connection")
+
+	// ErrComponentNotConnected will be returned by Route method if destination external component is not connected at this moment.
+	ErrComponentNotConnected = errors.New("router: component not connected")
 )
@@ -18,6 +18,7 @@ type TLSConfig struct {
 
 type Config struct {
 	Name        string
+	TLS         TLSConfig
 	Certificate tls.Certificate
 }
 
@@ -33,6 +34,7 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return err
 	}
 	c.Name = p.Name
+	c.TLS = p.TLS
 	cer, err := utiltls.LoadCertificate(p.TLS.PrivateKeyFile, p.TLS.CertFile, c.Name)
 	if err != nil {
 		return err
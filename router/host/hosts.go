@@ -6,63 +6,272 @@
 package host
 
 import (
+	"bytes"
 	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/ortuman/jackal/log"
 	utiltls "github.com/ortuman/jackal/util/tls"
 )
 
 const defaultDomain = "localhost"
 
+var errNoCertificate = errors.New("host: no certificate available")
+
 type Hosts struct {
+	mu              sync.RWMutex
 	defaultHostname string
 	hosts           map[string]tls.Certificate
+	certFiles       map[string]*certFiles
+	watchOnce       sync.Once
+	stopOnce        sync.Once
+	stopCh          chan struct{}
+}
+
+// certFiles keeps track of the PEM files a host certificate was loaded from.
+type certFiles struct {
+	certFile    string
+	keyFile     string
+	certModTime time.Time
+	keyModTime  time.Time
 }
 
 func New(hostsConfig []Config) (*Hosts, error) {
-	h := &Hosts{
-		hosts: make(map[string]tls.Certificate),
+	defaultHostname, hosts, files, err := loadHosts(hostsConfig)
+	if err != nil {
+		return nil, err
 	}
-	if len(hostsConfig) > 0 {
-		for i, host := range hostsConfig {
-			if i == 0 {
-				h.defaultHostname = host.Name
-			}
-			h.hosts[host.Name] = host.Certificate
+	return &Hosts{
+		defaultHostname: defaultHostname,
+		hosts:           hosts,
+		certFiles:       files,
+		stopCh:          make(chan struct{}),
+	}, nil
+}
+
+// Reload replaces the set of local hosts along with their certificates, returning the names
+// of the hosts that have been added, removed or whose certificate has changed.
+// Default host is not allowed to be removed.
+func (h *Hosts) Reload(hostsConfig []Config) (added, removed, updated []string, err error) {
+	_, hosts, files, err := loadHosts(hostsConfig)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := hosts[h.defaultHostname]; !ok {
+		return nil, nil, nil, fmt.Errorf("host: default host %s cannot be removed", h.defaultHostname)
+	}
+	for name, cer := range hosts {
+		oldCer, ok := h.hosts[name]
+		switch {
+		case !ok:
+			added = append(added, name)
+		case !equalCertificates(oldCer, cer):
+			updated = append(updated, name)
 		}
-	} else {
-		cer, err := utiltls.LoadCertificate("", "", defaultDomain)
-		if err != nil {
-			return nil, err
+	}
+	for name := range h.hosts {
+		if _, ok := hosts[name]; !ok {
+			removed = append(removed, name)
 		}
-		h.defaultHostname = defaultDomain
-		h.hosts[defaultDomain] = cer
 	}
-	return h, nil
+	h.hosts = hosts
+	h.certFiles = files
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(updated)
+	return added, removed, updated, nil
 }
 
 func (h *Hosts) DefaultHostName() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 	return h.defaultHostname
 }
 
 func (h *Hosts) IsLocalHost(domain string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 	_, ok := h.hosts[domain]
 	return ok
 }
 
 func (h *Hosts) HostNames() []string {
+	h.mu.RLock()
 	var ret []string
 	for n := range h.hosts {
 		ret = append(ret, n)
 	}
+	h.mu.RUnlock()
+
 	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
 	return ret
 }
 
 func (h *Hosts) Certificates() []tls.Certificate {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 	var certs []tls.Certificate
 	for _, cer := range h.hosts {
 		certs = append(certs, cer)
 	}
 	return certs
 }
+
+// GetCertificate returns the certificate matching the SNI server name requested by the client,
+// falling back to default host certificate when no host matches.
+func (h *Hosts) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return h.Certificate(hello.ServerName)
+}
+
+// Certificate returns the certificate associated to a local domain,
+// falling back to default host certificate when domain is not local.
+func (h *Hosts) Certificate(domain string) (*tls.Certificate, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if cer, ok := h.hosts[strings.ToLower(domain)]; ok {
+		return &cer, nil
+	}
+	cer, ok := h.hosts[h.defaultHostname]
+	if !ok {
+		return nil, errNoCertificate
+	}
+	return &cer, nil
+}
+
+// TLSConfig returns a server TLS configuration that picks host certificate by SNI,
+// or by domain when the client does not request any server name.
+func (h *Hosts) TLSConfig(domain string) *tls.Config {
+	return &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if len(hello.ServerName) > 0 {
+				return h.Certificate(hello.ServerName)
+			}
+			return h.Certificate(domain)
+		},
+	}
+}
+
+// WatchCertificates periodically checks host certificate files,
+// swapping certificates as soon as their files change.
+func (h *Hosts) WatchCertificates(interval time.Duration) {
+	h.watchOnce.Do(func() {
+		go h.watchLoop(interval)
+	})
+}
+
+// Shutdown stops watching host certificate files.
+func (h *Hosts) Shutdown() {
+	h.stopOnce.Do(func() {
+		close(h.stopCh)
+	})
+}
+
+func (h *Hosts) watchLoop(interval time.Duration) {
+	tc := time.NewTicker(interval)
+	defer tc.Stop()
+	for {
+		select {
+		case <-tc.C:
+			h.reloadCertificates()
+		case <-h.stopCh:
+			return
+		}
+	}
+}
+
+// reloadCertificates reloads every host certificate whose files have been modified,
+// returning the names of the updated hosts.
+func (h *Hosts) reloadCertificates() []string {
+	h.mu.RLock()
+	files := make(map[string]*certFiles, len(h.certFiles))
+	for name, f := range h.certFiles {
+		files[name] = f
+	}
+	h.mu.RUnlock()
+
+	var updated []string
+	for name, f := range files {
+		certModTime, keyModTime := modTime(f.certFile), modTime(f.keyFile)
+		if certModTime.Equal(f.certModTime) && keyModTime.Equal(f.keyModTime) {
+			continue
+		}
+		cer, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+		if err != nil {
+			// files may be in the middle of being renewed... try again later
+			log.Warnf("host: failed to reload %s certificate: %v", name, err)
+			continue
+		}
+		h.mu.Lock()
+		if h.certFiles[name] == f { // host has not been reloaded meanwhile
+			h.hosts[name] = cer
+			h.certFiles[name] = &certFiles{
+				certFile:    f.certFile,
+				keyFile:     f.keyFile,
+				certModTime: certModTime,
+				keyModTime:  keyModTime,
+			}
+			updated = append(updated, name)
+		}
+		h.mu.Unlock()
+	}
+	sort.Strings(updated)
+	for _, name := range updated {
+		log.Infof("host: %s certificate reloaded", name)
+	}
+	return updated
+}
+
+func loadHosts(hostsConfig []Config) (string, map[string]tls.Certificate, map[string]*certFiles, error) {
+	hosts := make(map[string]tls.Certificate)
+	files := make(map[string]*certFiles)
+	if len(hostsConfig) == 0 {
+		cer, err := utiltls.LoadCertificate("", "", defaultDomain)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		hosts[defaultDomain] = cer
+		return defaultDomain, hosts, files, nil
+	}
+	for _, host := range hostsConfig {
+		hosts[host.Name] = host.Certificate
+		if len(host.TLS.CertFile) > 0 && len(host.TLS.PrivateKeyFile) > 0 {
+			files[host.Name] = &certFiles{
+				certFile:    host.TLS.CertFile,
+				keyFile:     host.TLS.PrivateKeyFile,
+				certModTime: modTime(host.TLS.CertFile),
+				keyModTime:  modTime(host.TLS.PrivateKeyFile),
+			}
+		}
+	}
+	return hostsConfig[0].Name, hosts, files, nil
+}
+
+func modTime(filename string) time.Time {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
+func equalCertificates(c1, c2 tls.Certificate) bool {
+	if len(c1.Certificate) != len(c2.Certificate) {
+		return false
+	}
+	for i := range c1.Certificate {
+		if !bytes.Equal(c1.Certificate[i], c2.Certificate[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package host
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHosts_GetCertificate(t *testing.T) {
+	cer1 := tls.Certificate{OCSPStaple: []byte("jackal.im")}
+	cer2 := tls.Certificate{OCSPStaple: []byte("jabber.org")}
+
+	h, err := New([]Config{{Name: "jackal.im", Certificate: cer1}, {Name: "jabber.org", Certificate: cer2}})
+	require.Nil(t, err)
+
+	cer, err := h.GetCertificate(&tls.ClientHelloInfo{ServerName: "jabber.org"})
+	require.Nil(t, err)
+	require.Equal(t, cer2.OCSPStaple, cer.OCSPStaple)
+
+	// fallback to default host certificate
+	cer, err = h.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.org"})
+	require.Nil(t, err)
+	require.Equal(t, cer1.OCSPStaple, cer.OCSPStaple)
+
+	cer, err = h.GetCertificate(&tls.ClientHelloInfo{})
+	require.Nil(t, err)
+	require.Equal(t, cer1.OCSPStaple, cer.OCSPStaple)
+}
+
+func TestHosts_Reload(t *testing.T) {
+	cer1 := tls.Certificate{Certificate: [][]byte{[]byte("jackal.im")}}
+	cer2 := tls.Certificate{Certificate: [][]byte{[]byte("jabber.org")}}
+	cer3 := tls.Certificate{Certificate: [][]byte{[]byte("jabber.org (renewed)")}}
+	cer4 := tls.Certificate{Certificate: [][]byte{[]byte("example.org")}}
+
+	h, err := New([]Config{{Name: "jackal.im", Certificate: cer1}, {Name: "jabber.org", Certificate: cer2}})
+	require.Nil(t, err)
+
+	added, removed, updated, err := h.Reload([]Config{{Name: "jackal.im", Certificate: cer1}, {Name: "jabber.org", Certificate: cer3}, {Name: "example.org", Certificate: cer4}})
+	require.Nil(t, err)
+	require.Equal(t, []string{"example.org"}, added)
+	require.Len(t, removed, 0)
+	require.Equal(t, []string{"jabber.org"}, updated)
+	require.True(t, h.IsLocalHost("example.org"))
+
+	cer, _ := h.GetCertificate(&tls.ClientHelloInfo{ServerName: "jabber.org"})
+	require.Equal(t, cer3.Certificate, cer.Certificate)
+
+	added, removed, updated, err = h.Reload([]Config{{Name: "jackal.im", Certificate: cer1}})
+	require.Nil(t, err)
+	require.Len(t, added, 0)
+	require.Equal(t, []string{"example.org", "jabber.org"}, removed)
+	require.Len(t, updated, 0)
+	require.Equal(t, []string{"jackal.im"}, h.HostNames())
+
+	// default host removal
+	_, _, _, err = h.Reload([]Config{{Name: "jabber.org", Certificate: cer2}})
+	require.NotNil(t, err)
+	require.True(t, h.IsLocalHost("jackal.im"))
+	require.False(t, h.IsLocalHost("jabber.org"))
+}
+
+func TestHosts_TLSConfig(t *testing.T) {
+	cer1 := tls.Certificate{OCSPStaple: []byte("jackal.im")}
+	cer2 := tls.Certificate{OCSPStaple: []byte("jabber.org")}
+
+	h, err := New([]Config{{Name: "jackal.im", Certificate: cer1}, {Name: "jabber.org", Certificate: cer2}})
+	require.Nil(t, err)
+
+	tlsCfg := h.TLSConfig("jabber.org")
+	require.Len(t, tlsCfg.Certificates, 0)
+
+	// no SNI server name
+	cer, err := tlsCfg.GetCertificate(&tls.ClientHelloInfo{})
+	require.Nil(t, err)
+	require.Equal(t, cer2.OCSPStaple, cer.OCSPStaple)
+
+	cer, err = tlsCfg.GetCertificate(&tls.ClientHelloInfo{ServerName: "jackal.im"})
+	require.Nil(t, err)
+	require.Equal(t, cer1.OCSPStaple, cer.OCSPStaple)
+}
+
+func TestHosts_ReloadCertificates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jackal-hosts")
+	require.Nil(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	tUtilWriteCertificate(t, certFile, keyFile, 1)
+
+	cer1, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.Nil(t, err)
+	h, err := New([]Config{{Name: "jackal.im", TLS: TLSConfig{CertFile: certFile, PrivateKeyFile: keyFile}, Certificate: cer1}})
+	require.Nil(t, err)
+
+	require.Len(t, h.reloadCertificates(), 0) // unchanged files
+
+	// renew certificate
+	tUtilWriteCertificate(t, certFile, keyFile, 2)
+	later := time.Now().Add(time.Minute)
+	require.Nil(t, os.Chtimes(certFile, later, later))
+	require.Nil(t, os.Chtimes(keyFile, later, later))
+
+	require.Equal(t, []string{"jackal.im"}, h.reloadCertificates())
+
+	cer, err := h.GetCertificate(&tls.ClientHelloInfo{ServerName: "jackal.im"})
+	require.Nil(t, err)
+	leaf, err := x509.ParseCertificate(cer.Certificate[0])
+	require.Nil(t, err)
+	require.Equal(t, int64(2), leaf.SerialNumber.Int64())
+
+	// a broken key pair keeps current certificate
+	require.Nil(t, ioutil.WriteFile(keyFile, []byte("invalid"), 0600))
+	later = later.Add(time.Minute)
+	require.Nil(t, os.Chtimes(keyFile, later, later))
+
+	require.Len(t, h.reloadCertificates(), 0)
+	cer, _ = h.GetCertificate(&tls.ClientHelloInfo{ServerName: "jackal.im"})
+	require.True(t, equalCertificates(*cer, tls.Certificate{Certificate: [][]byte{leaf.Raw}}))
+
+	h.WatchCertificates(time.Millisecond * 10)
+	h.Shutdown()
+}
+
+func tUtilWriteCertificate(t *testing.T, certFile, keyFile string, serialNumber int64) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serialNumber),
+		Subject:      pkix.Name{CommonName: "jackal.im"},
+		DNSNames:     []string{"jackal.im"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+
+	require.Nil(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.Nil(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+}
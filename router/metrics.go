@@ -0,0 +1,39 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package router
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var routedStanzas = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "jackal_router_stanzas_total",
+	Help: "Routed stanzas by type and routing result.",
+}, []string{"type", "result"})
+
+func reportRoutedStanza(stanzaType string, err error) {
+	var result string
+	switch err {
+	case nil:
+		result = "ok"
+	case ErrNotExistingAccount:
+		result = "not_existing_account"
+	case ErrResourceNotFound:
+		result = "resource_not_found"
+	case ErrNotAuthenticated:
+		result = "not_authenticated"
+	case ErrBlockedJID:
+		result = "blocked_jid"
+	case ErrFailedRemoteConnect:
+		result = "failed_remote_connect"
+	case ErrComponentNotConnected:
+		result = "component_not_connected"
+	default:
+		result = "error"
+	}
+	routedStanzas.WithLabelValues(stanzaType, result).Inc()
+}
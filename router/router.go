@@ -7,6 +7,7 @@ package router
 
 import (
 	"context"
+	"sync"
 
 	"github.com/ortuman/jackal/router/host"
 	"github.com/ortuman/jackal/stream"
@@ -37,6 +38,9 @@ type Router interface {
 
 	// LocalStreams returns all streams associated to a given username.
 	LocalStreams(username string) []stream.C2S
+
+	// SetComponentRouter sets the router in charge of delivering stanzas to external components.
+	SetComponentRouter(compRouter ComponentRouter)
 }
 
 type C2SRouter interface {
@@ -63,10 +67,21 @@ type S2SRouter interface {
 	Route(ctx context.Context, stanza xmpp.Stanza, localDomain string) error
 }
 
+type ComponentRouter interface {
+	// IsComponentHost returns whether or not a domain is served by an external component.
+	IsComponentHost(domain string) bool
+
+	// Route routes a stanza to the external component serving its destination domain.
+	Route(ctx context.Context, stanza xmpp.Stanza) error
+}
+
 type router struct {
 	hosts *host.Hosts
 	c2s   C2SRouter
 	s2s   S2SRouter
+
+	mu    sync.RWMutex
+	comps ComponentRouter
 }
 
 func New(hosts *host.Hosts, c2sRouter C2SRouter, s2sRouter S2SRouter) (Router, error) {
@@ -106,8 +121,23 @@ func (r *router) LocalStream(username, resource string) stream.C2S {
 	return r.c2s.Stream(username, resource)
 }
 
+func (r *router) SetComponentRouter(compRouter ComponentRouter) {
+	r.mu.Lock()
+	r.comps = compRouter
+	r.mu.Unlock()
+}
+
 func (r *router) route(ctx context.Context, stanza xmpp.Stanza, validateStanza bool) error {
+	err := r.doRoute(ctx, stanza, validateStanza)
+	reportRoutedStanza(stanza.Name(), err)
+	return err
+}
+
+func (r *router) doRoute(ctx context.Context, stanza xmpp.Stanza, validateStanza bool) error {
 	toJID := stanza.ToJID()
+	if comps := r.componentRouter(); comps != nil && comps.IsComponentHost(toJID.Domain()) {
+		return comps.Route(ctx, stanza)
+	}
 	if !r.hosts.IsLocalHost(toJID.Domain()) {
 		if r.s2s == nil {
 			return ErrFailedRemoteConnect
@@ -116,3 +146,9 @@ func (r *router) route(ctx context.Context, stanza xmpp.Stanza, validateStanza b
 	}
 	return r.c2s.Route(ctx, stanza, validateStanza)
 }
+
+func (r *router) componentRouter() ComponentRouter {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.comps
+}
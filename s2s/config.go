@@ -7,9 +7,13 @@ package s2s
 
 import (
 	"crypto/tls"
+	"fmt"
 	"time"
 
 	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/transport/proxyproto"
+	"github.com/ortuman/jackal/util/connlimit"
+	"github.com/ortuman/jackal/util/ratelimit"
 	"github.com/pkg/errors"
 )
 
@@ -24,13 +28,17 @@ const (
 
 // TransportConfig represents s2s transport configuration.
 type TransportConfig struct {
-	BindAddress string
-	Port        int
+	BindAddress   string
+	Port          int
+	DirectTLS     bool
+	ProxyProtocol proxyproto.Config
 }
 
 type transportConfigProxy struct {
-	BindAddress string `yaml:"bind_addr"`
-	Port        int    `yaml:"port"`
+	BindAddress   string            `yaml:"bind_addr"`
+	Port          int               `yaml:"port"`
+	TLS           string            `yaml:"tls"`
+	ProxyProtocol proxyproto.Config `yaml:"proxy_protocol"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface.
@@ -44,6 +52,15 @@ func (c *TransportConfig) UnmarshalYAML(unmarshal func(interface{}) error) error
 	if c.Port == 0 {
 		c.Port = defaultTransportPort
 	}
+	switch p.TLS {
+	case "", "starttls":
+		c.DirectTLS = false
+	case "direct":
+		c.DirectTLS = true
+	default:
+		return fmt.Errorf("s2s.TransportConfig: unrecognized tls mode: %s", p.TLS)
+	}
+	c.ProxyProtocol = p.ProxyProtocol
 	return nil
 }
 
@@ -63,17 +80,21 @@ type Config struct {
 	DialbackSecret string
 	MaxStanzaSize  int
 	Transport      TransportConfig
+	RateLimit      ratelimit.Config
+	Connections    connlimit.Config
 }
 
 type configProxy struct {
-	ID             string          `yaml:"id"`
-	DialTimeout    int             `yaml:"dial_timeout"`
-	ConnectTimeout int             `yaml:"connect_timeout"`
-	KeepAlive      int             `yaml:"keep_alive"`
-	Timeout        int             `yaml:"timeout"`
-	DialbackSecret string          `yaml:"dialback_secret"`
-	MaxStanzaSize  int             `yaml:"max_stanza_size"`
-	Transport      TransportConfig `yaml:"transport"`
+	ID             string           `yaml:"id"`
+	DialTimeout    int              `yaml:"dial_timeout"`
+	ConnectTimeout int              `yaml:"connect_timeout"`
+	KeepAlive      int              `yaml:"keep_alive"`
+	Timeout        int              `yaml:"timeout"`
+	DialbackSecret string           `yaml:"dialback_secret"`
+	MaxStanzaSize  int              `yaml:"max_stanza_size"`
+	Transport      TransportConfig  `yaml:"transport"`
+	RateLimit      ratelimit.Config `yaml:"rate_limit"`
+	Connections    connlimit.Config `yaml:"connections"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface.
@@ -105,6 +126,8 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		c.Timeout = defaultTimeout
 	}
 	c.Transport = p.Transport
+	c.RateLimit = p.RateLimit
+	c.Connections = p.Connections
 	c.MaxStanzaSize = p.MaxStanzaSize
 	if c.MaxStanzaSize == 0 {
 		c.MaxStanzaSize = defaultMaxStanzaSize
@@ -119,6 +142,8 @@ type inConfig struct {
 	keepAlive      time.Duration
 	tls            *tls.Config
 	maxStanzaSize  int
+	directTLS      bool
+	rateLimit      ratelimit.Config
 	onDisconnect   func(s stream.S2SIn)
 }
 
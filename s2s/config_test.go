@@ -37,6 +37,21 @@ port: 5999
 	require.Nil(t, err)
 	require.Equal(t, "127.0.0.1", trCfg.BindAddress)
 	require.Equal(t, 5999, trCfg.Port)
+	require.False(t, trCfg.DirectTLS)
+
+	rawCfg = `
+port: 5270
+tls: direct
+`
+	err = yaml.Unmarshal([]byte(rawCfg), &trCfg)
+	require.Nil(t, err)
+	require.True(t, trCfg.DirectTLS)
+
+	rawCfg = `
+tls: invalid
+`
+	err = yaml.Unmarshal([]byte(rawCfg), &trCfg)
+	require.NotNil(t, err)
 }
 
 func TestConfig(t *testing.T) {
@@ -7,15 +7,17 @@ package s2s
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ortuman/jackal/log"
 )
 
 type Dialer interface {
-	Dial(ctx context.Context, remoteDomain string) (net.Conn, error)
+	Dial(ctx context.Context, remoteDomain string, tlsConfig *tls.Config) (net.Conn, error)
 }
 
 type srvResolveFunc func(service, proto, name string) (cname string, addrs []*net.SRV, err error)
@@ -34,7 +36,12 @@ func newDialer() *dialer {
 	}
 }
 
-func (d *dialer) Dial(ctx context.Context, remoteDomain string) (net.Conn, error) {
+// Dial establishes a connection against remote domain.
+// Direct TLS endpoints (XEP-0368) are tried first, returning a *tls.Conn on success.
+func (d *dialer) Dial(ctx context.Context, remoteDomain string, tlsConfig *tls.Config) (net.Conn, error) {
+	if conn := d.dialDirectTLS(ctx, remoteDomain, tlsConfig); conn != nil {
+		return conn, nil
+	}
 	_, address, err := d.srvResolve("xmpp-server", "tcp", remoteDomain)
 	if err != nil {
 		log.Warnf("srv lookup error: %v", err)
@@ -52,3 +59,41 @@ func (d *dialer) Dial(ctx context.Context, remoteDomain string) (net.Conn, error
 	}
 	return conn, err
 }
+
+func (d *dialer) dialDirectTLS(ctx context.Context, remoteDomain string, tlsConfig *tls.Config) net.Conn {
+	_, address, err := d.srvResolve("xmpps-server", "tcp", remoteDomain)
+	if err != nil || len(address) == 0 || len(address) == 1 && address[0].Target == "." {
+		return nil
+	}
+	var cfg *tls.Config
+	if tlsConfig != nil {
+		cfg = tlsConfig.Clone()
+	} else {
+		cfg = &tls.Config{}
+	}
+	if len(cfg.ServerName) == 0 {
+		cfg.ServerName = remoteDomain
+	}
+	cfg.NextProtos = []string{xmppServerALPN}
+
+	for _, addr := range address {
+		target := strings.TrimSuffix(addr.Target, ".") + ":" + strconv.Itoa(int(addr.Port))
+		conn, err := d.dialContext(ctx, "tcp", target)
+		if err != nil {
+			log.Warnf("direct tls dial error: %v", err)
+			continue
+		}
+		tlsConn := tls.Client(conn, cfg)
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = tlsConn.SetDeadline(deadline)
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			log.Warnf("direct tls handshake error: %v", err)
+			_ = tlsConn.Close()
+			continue
+		}
+		_ = tlsConn.SetDeadline(time.Time{})
+		return tlsConn
+	}
+	return nil
+}
@@ -7,10 +7,13 @@ package s2s
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"net"
+	"os"
 	"testing"
 
+	utiltls "github.com/ortuman/jackal/util/tls"
 	"github.com/stretchr/testify/require"
 )
 
@@ -22,18 +25,21 @@ func TestDialer_Dial(t *testing.T) {
 	d.srvResolve = func(_, _, _ string) (cname string, addrs []*net.SRV, err error) {
 		return "", nil, mockedErr
 	}
-	out, err := d.Dial(context.Background(), "jabber.org")
+	out, err := d.Dial(context.Background(), "jabber.org", nil)
 	require.NotNil(t, out)
 	require.Nil(t, err)
 
 	// dialer error...
-	d.srvResolve = func(service, proto, name string) (cname string, addrs []*net.SRV, err error) {
+	d.srvResolve = func(service, _, _ string) (cname string, addrs []*net.SRV, err error) {
+		if service != "xmpp-server" {
+			return "", nil, errNoSRVRecords
+		}
 		return "", []*net.SRV{{Target: "xmpp.jabber.org", Port: 5269}}, nil
 	}
 	d.dialContext = func(_ context.Context, _, _ string) (net.Conn, error) {
 		return nil, mockedErr
 	}
-	out, err = d.Dial(context.Background(), "jabber.org")
+	out, err = d.Dial(context.Background(), "jabber.org", nil)
 	require.Nil(t, out)
 	require.Equal(t, mockedErr, err)
 
@@ -41,7 +47,57 @@ func TestDialer_Dial(t *testing.T) {
 	d.dialContext = func(_ context.Context, _, _ string) (net.Conn, error) {
 		return newFakeSocketConn(), nil
 	}
-	out, err = d.Dial(context.Background(), "jabber.org")
+	out, err = d.Dial(context.Background(), "jabber.org", nil)
 	require.NotNil(t, out)
 	require.Nil(t, err)
 }
+
+func TestDialer_DialDirectTLS(t *testing.T) {
+	defer func() { _ = os.RemoveAll("./.cert") }()
+
+	cer, err := utiltls.LoadCertificate("", "", "localhost")
+	require.Nil(t, err)
+
+	d := newDialer()
+
+	var dialedAddr string
+	d.srvResolve = func(service, _, _ string) (cname string, addrs []*net.SRV, err error) {
+		switch service {
+		case "xmpps-server":
+			return "", []*net.SRV{{Target: "xmpps.jabber.org.", Port: 443}}, nil
+		default:
+			return "", []*net.SRV{{Target: "xmpp.jabber.org.", Port: 5269}}, nil
+		}
+	}
+	d.dialContext = func(_ context.Context, _, address string) (net.Conn, error) {
+		dialedAddr = address
+		c, s := net.Pipe()
+		go func() {
+			srv := tls.Server(s, &tls.Config{Certificates: []tls.Certificate{cer}, NextProtos: []string{"xmpp-server"}})
+			_ = srv.Handshake()
+		}()
+		return c, nil
+	}
+	out, err := d.Dial(context.Background(), "jabber.org", &tls.Config{InsecureSkipVerify: true})
+	require.Nil(t, err)
+	require.Equal(t, "xmpps.jabber.org:443", dialedAddr)
+
+	tlsConn, ok := out.(*tls.Conn)
+	require.True(t, ok)
+	require.Equal(t, "xmpp-server", tlsConn.ConnectionState().NegotiatedProtocol)
+	require.Equal(t, "jabber.org", tlsConn.ConnectionState().ServerName)
+
+	// failed handshake falls back to plain connection
+	d.dialContext = func(_ context.Context, _, address string) (net.Conn, error) {
+		dialedAddr = address
+		c, s := net.Pipe()
+		go func() { _ = s.Close() }()
+		return c, nil
+	}
+	out, err = d.Dial(context.Background(), "jabber.org", nil)
+	require.Nil(t, err)
+	require.Equal(t, "xmpp.jabber.org:5269", dialedAddr)
+
+	_, ok = out.(*tls.Conn)
+	require.False(t, ok)
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/session"
 	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/util/ratelimit"
 	"github.com/ortuman/jackal/util/runqueue"
 	"github.com/ortuman/jackal/xmpp"
 	"github.com/ortuman/jackal/xmpp/jid"
@@ -47,6 +48,7 @@ type inStream struct {
 	authenticated uint32
 	newOut        newOutFunc
 	runQueue      *runqueue.RunQueue
+	limiter       *ratelimit.Limiter
 }
 
 func newInStream(config *inConfig, tr transport.Transport, mods *module.Modules, newOutFn newOutFunc, router router.Router) *inStream {
@@ -60,6 +62,13 @@ func newInStream(config *inConfig, tr transport.Transport, mods *module.Modules,
 		mods:     mods,
 		runQueue: runqueue.New(id),
 	}
+	if config.directTLS {
+		// [xep-0368] TLS has been already negotiated on connection
+		atomic.StoreUint32(&s.secured, 1)
+	}
+	if config.rateLimit.Enabled() {
+		s.limiter = ratelimit.New(&config.rateLimit)
+	}
 	// start s2s in session
 	s.restartSession()
 
@@ -99,10 +108,27 @@ func (s *inStream) doRead() {
 	elem, sErr := s.sess.Receive()
 	s.cancelReadTimeout()
 
+	action := ratelimit.Allow
+	if sErr == nil {
+		action = s.limiter.Apply(elem)
+	}
+	if action == ratelimit.Bounce || action == ratelimit.Disconnect {
+		log.Warnf("rate limit exceeded... applying %s action (id: %s)", action, s.id)
+	}
 	ctx, _ := context.WithTimeout(context.Background(), s.cfg.timeout)
 	if sErr == nil {
 		s.runQueue.Run(func() {
-			s.readElement(ctx, elem)
+			switch action {
+			case ratelimit.Bounce:
+				if elem.Type() != xmpp.ErrorType {
+					s.writeStanzaErrorResponse(ctx, elem, xmpp.ErrPolicyViolation)
+				}
+				s.readElement(ctx, nil)
+			case ratelimit.Disconnect:
+				s.disconnectWithStreamError(ctx, streamerror.ErrPolicyViolation)
+			case ratelimit.Allow, ratelimit.Throttle:
+				s.readElement(ctx, elem)
+			}
 		})
 	} else {
 		s.runQueue.Run(func() {
@@ -239,13 +265,18 @@ func (s *inStream) processIQ(ctx context.Context, iq *xmpp.IQ) {
 }
 
 func (s *inStream) processMessage(ctx context.Context, message *xmpp.Message) {
+	if mam := s.mods.Mam; mam != nil {
+		message = mam.ArchiveMessage(ctx, message)
+	}
 	msg := message
 
 sendMessage:
 	err := s.router.Route(ctx, msg)
 	switch err {
 	case nil:
-		break
+		if c := s.mods.Carbons; c != nil {
+			c.ProcessReceivedMessage(ctx, msg)
+		}
 	case router.ErrResourceNotFound:
 		// treat the stanza as if it were addressed to <node@domain>
 		msg, _ = xmpp.NewMessageFromElement(msg, msg.FromJID(), msg.ToJID().ToBareJID())
@@ -272,11 +303,9 @@ func (s *inStream) proceedStartTLS(ctx context.Context, elem xmpp.XElement) {
 	}
 	s.writeElement(ctx, xmpp.NewElementNamespace("proceed", tlsNamespace))
 
-	s.tr.StartTLS(&tls.Config{
-		ServerName:   s.localDomain,
-		ClientAuth:   tls.VerifyClientCertIfGiven,
-		Certificates: s.router.Hosts().Certificates(),
-	}, false)
+	tlsCfg := s.router.Hosts().TLSConfig(s.localDomain)
+	tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	s.tr.StartTLS(tlsCfg, false)
 	atomic.StoreUint32(&s.secured, 1)
 
 	log.Infof("secured stream... id: %s", s.id)
@@ -18,6 +18,7 @@ import (
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/util/ratelimit"
 	utiltls "github.com/ortuman/jackal/util/tls"
 	"github.com/ortuman/jackal/xmpp"
 	"github.com/ortuman/jackal/xmpp/jid"
@@ -221,7 +222,10 @@ func TestStream_DialbackAuthorize(t *testing.T) {
 	r, h := setupTestRouter(jackaDomain)
 
 	op := NewOutProvider(&Config{KeepAlive: time.Second}, h)
-	op.dialer.(*dialer).srvResolve = func(_, _, _ string) (cname string, addrs []*net.SRV, err error) {
+	op.dialer.(*dialer).srvResolve = func(service, _, _ string) (cname string, addrs []*net.SRV, err error) {
+		if service != "xmpp-server" {
+			return "", nil, errNoSRVRecords
+		}
 		return "", []*net.SRV{{Target: "jackal.im", Port: 5269}}, nil
 	}
 
@@ -353,6 +357,50 @@ func TestStream_SendElement(t *testing.T) {
 	require.True(t, conn.waitClose())
 }
 
+func TestStream_RateLimit(t *testing.T) {
+	r, h := setupTestRouter(jackaDomain)
+
+	op := NewOutProvider(&Config{KeepAlive: time.Second}, h)
+
+	fromJID, _ := jid.New("ortuman", "localhost", "garden", true)
+	toJID, _ := jid.New("ortuman", "jackal.im", "garden", true)
+
+	stm2 := stream.NewMockC2S("abcd7890", toJID)
+	stm2.SetPresence(xmpp.NewPresence(toJID, toJID, xmpp.AvailableType))
+
+	r.Bind(context.Background(), stm2)
+
+	cfg, tr, conn := tUtilInStreamDefaultConfig(t, false)
+	cfg.rateLimit = ratelimit.Config{StanzasPerSecond: 0.1, Burst: 1, Action: ratelimit.Bounce}
+
+	stm := newInStream(cfg, tr, &module.Modules{}, op.newOut, r)
+	tUtilInStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+	atomic.StoreUint32(&stm.secured, 1)
+	atomic.StoreUint32(&stm.authenticated, 1)
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.ResultType)
+	iq.SetFromJID(fromJID)
+	iq.SetToJID(toJID)
+	_, _ = conn.inboundWriteString(iq.String())
+
+	elem := stm2.ReceiveElement()
+	require.Equal(t, iq.ID(), elem.ID())
+
+	// limit exceeded... bounced
+	iq2 := xmpp.NewIQType(uuid.New(), xmpp.ResultType)
+	iq2.SetFromJID(fromJID)
+	iq2.SetToJID(toJID)
+	_, _ = conn.inboundWriteString(iq2.String())
+
+	elem = conn.outboundRead()
+	require.Equal(t, iq2.ID(), elem.ID())
+	require.Equal(t, xmpp.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().Child("policy-violation"))
+	require.Equal(t, inConnected, stm.getState())
+}
+
 func tUtilInStreamInit(t *testing.T, router router.Router, outProvider *OutProvider, loadPeerCertificate bool) (*inStream, *fakeSocketConn) {
 	cfg, tr, conn := tUtilInStreamDefaultConfig(t, loadPeerCertificate)
 	stm := newInStream(cfg, tr, &module.Modules{}, outProvider.newOut, router)
@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package s2s
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var connections = newConnectionsCollector(prometheus.NewDesc(
+	"jackal_s2s_connections",
+	"Number of current s2s connections by direction and stream state.",
+	[]string{"direction", "state"}, nil,
+))
+
+var inStateNames = map[uint32]string{
+	inConnecting:   "connecting",
+	inConnected:    "connected",
+	inDisconnected: "disconnected",
+}
+
+var outStateNames = map[uint32]string{
+	outConnecting:             "connecting",
+	outConnected:              "connected",
+	outSecuring:               "securing",
+	outAuthenticating:         "authenticating",
+	outValidatingDialbackKey:  "validating_dialback_key",
+	outAuthorizingDialbackKey: "authorizing_dialback_key",
+	outVerified:               "verified",
+	outDisconnected:           "disconnected",
+}
+
+// connectionsCollector reports current connection gauges on every scrape.
+type connectionsCollector struct {
+	desc   *prometheus.Desc
+	mu     sync.RWMutex
+	report func(report func(val float64, labelValues ...string))
+}
+
+func newConnectionsCollector(desc *prometheus.Desc) *connectionsCollector {
+	c := &connectionsCollector{desc: desc}
+	prometheus.MustRegister(c)
+	return c
+}
+
+// Set assigns the function in charge of reporting connection gauges, replacing any previous one.
+func (c *connectionsCollector) Set(report func(report func(val float64, labelValues ...string))) {
+	c.mu.Lock()
+	c.report = report
+	c.mu.Unlock()
+}
+
+// Describe implements prometheus.Collector interface.
+func (c *connectionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector interface.
+func (c *connectionsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	report := c.report
+	c.mu.RUnlock()
+	if report == nil {
+		return
+	}
+	report(func(val float64, labelValues ...string) {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, val, labelValues...)
+	})
+}
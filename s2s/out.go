@@ -7,6 +7,7 @@ package s2s
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -148,10 +149,13 @@ func (s *outStream) doRead() {
 }
 
 func (s *outStream) dial(ctx context.Context) error {
-	conn, err := s.dialer.Dial(ctx, s.cfg.remoteDomain)
+	conn, err := s.dialer.Dial(ctx, s.cfg.remoteDomain, s.cfg.tls)
 	if err != nil {
 		return err
 	}
+	if _, ok := conn.(*tls.Conn); ok {
+		atomic.StoreUint32(&s.secured, 1) // direct TLS connection
+	}
 	s.tr = transport.NewSocketTransport(conn)
 	return nil
 }
@@ -246,6 +246,9 @@ func tUtilOutStreamOpen(conn *fakeSocketConn) {
 
 func tUtilOutStreamInitWithConfig(t *testing.T, hosts *host.Hosts, cfg *outConfig, conn *fakeSocketConn) *outStream {
 	d := newDialer()
+	d.srvResolve = func(_, _, _ string) (cname string, addrs []*net.SRV, err error) {
+		return "", nil, errNoSRVRecords
+	}
 	d.dialContext = func(_ context.Context, _, _ string) (net.Conn, error) {
 		return conn, nil
 	}
@@ -274,6 +277,9 @@ func tUtilOutStreamInit(t *testing.T, hosts *host.Hosts) (*outStream, *fakeSocke
 func tUtilOutStreamDefaultConfig() (*outConfig, Dialer, *fakeSocketConn) {
 	conn := newFakeSocketConn()
 	d := newDialer()
+	d.srvResolve = func(_, _, _ string) (cname string, addrs []*net.SRV, err error) {
+		return "", nil, errNoSRVRecords
+	}
 	d.dialContext = func(_ context.Context, _, _ string) (net.Conn, error) {
 		return conn, nil
 	}
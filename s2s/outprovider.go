@@ -71,10 +71,25 @@ func (p *OutProvider) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// connectionsByState returns the number of outgoing streams grouped by state name.
+func (p *OutProvider) connectionsByState() map[string]int {
+	ret := make(map[string]int)
+	p.mu.RLock()
+	for _, stm := range p.outConnections {
+		if out, ok := stm.(*outStream); ok {
+			ret[outStateNames[out.getState()]]++
+		}
+	}
+	p.mu.RUnlock()
+	return ret
+}
+
 func (p *OutProvider) newOut(localDomain, remoteDomain string) *outStream {
 	tlsConfig := &tls.Config{
-		ServerName:   remoteDomain,
-		Certificates: p.hosts.Certificates(),
+		ServerName: remoteDomain,
+		GetClientCertificate: func(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return p.hosts.Certificate(localDomain)
+		},
 	}
 	cfg := &outConfig{
 		keyGen:        &keyGen{secret: p.cfg.DialbackSecret},
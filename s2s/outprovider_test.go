@@ -19,7 +19,10 @@ func TestOutProvider_GetOut(t *testing.T) {
 
 	op := NewOutProvider(&Config{}, hosts)
 
-	op.dialer.(*dialer).srvResolve = func(service, proto, name string) (cname string, addrs []*net.SRV, err error) {
+	op.dialer.(*dialer).srvResolve = func(service, _, _ string) (cname string, addrs []*net.SRV, err error) {
+		if service != "xmpp-server" {
+			return "", nil, errNoSRVRecords
+		}
 		return "", []*net.SRV{{Target: "xmpp.jabber.org", Port: 5269}}, nil
 	}
 	op.dialer.(*dialer).dialContext = func(_ context.Context, _, _ string) (net.Conn, error) {
@@ -39,7 +42,10 @@ func TestOutProvider_Shutdown(t *testing.T) {
 
 	op := NewOutProvider(&Config{}, hosts)
 
-	op.dialer.(*dialer).srvResolve = func(service, proto, name string) (cname string, addrs []*net.SRV, err error) {
+	op.dialer.(*dialer).srvResolve = func(service, _, _ string) (cname string, addrs []*net.SRV, err error) {
+		if service != "xmpp-server" {
+			return "", nil, errNoSRVRecords
+		}
 		return "", []*net.SRV{{Target: "xmpp.jabber.org", Port: 5269}}, nil
 	}
 	op.dialer.(*dialer).dialContext = func(_ context.Context, _, _ string) (net.Conn, error) {
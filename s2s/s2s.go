@@ -7,6 +7,7 @@ package s2s
 
 import (
 	"context"
+	"sync"
 	"sync/atomic"
 
 	"github.com/ortuman/jackal/log"
@@ -19,11 +20,15 @@ const (
 	tlsNamespace      = "urn:ietf:params:xml:ns:xmpp-tls"
 	saslNamespace     = "urn:ietf:params:xml:ns:xmpp-sasl"
 	dialbackNamespace = "urn:xmpp:features:dialback"
+
+	xmppServerALPN = "xmpp-server"
 )
 
 type s2sServer interface {
 	start()
 	shutdown(ctx context.Context) error
+	reload(config *Config, mods *module.Modules) []string
+	connectionsByState() map[string]int
 }
 
 var createS2SServer = func(config *Config, mods *module.Modules, newOutFn newOutFunc, router router.Router) s2sServer {
@@ -37,26 +42,87 @@ var createS2SServer = func(config *Config, mods *module.Modules, newOutFn newOut
 
 // S2S represents a server-to-server connection manager.
 type S2S struct {
+	mu          sync.RWMutex
 	started     uint32
 	srv         s2sServer
 	outProvider *OutProvider
+	newServer   func(config *Config, mods *module.Modules) s2sServer
 }
 
 // New returns a new instance of an s2s connection manager.
 func New(config *Config, mods *module.Modules, outProvider *OutProvider, router router.Router) *S2S {
-	return &S2S{srv: createS2SServer(config, mods, outProvider.newOut, router)}
+	s := &S2S{
+		outProvider: outProvider,
+		newServer: func(config *Config, mods *module.Modules) s2sServer {
+			return createS2SServer(config, mods, outProvider.newOut, router)
+		},
+	}
+	s.srv = s.newServer(config, mods)
+	connections.Set(s.reportConnections)
+	return s
 }
 
 // Start initializes s2s manager.
 func (s *S2S) Start() {
-	if atomic.CompareAndSwapUint32(&s.started, 0, 1) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if atomic.CompareAndSwapUint32(&s.started, 0, 1) && s.srv != nil {
 		go s.srv.start()
 	}
 }
 
+// Reload applies a new s2s configuration to every incoming stream started from now on.
+// A nil configuration stops listening for incoming connections.
+func (s *S2S) Reload(ctx context.Context, config *Config, mods *module.Modules) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	started := atomic.LoadUint32(&s.started) == 1
+	switch {
+	case config == nil:
+		if s.srv == nil {
+			return
+		}
+		log.Infof("s2s_in: listener removed")
+		if started {
+			if err := s.srv.shutdown(ctx); err != nil {
+				log.Error(err)
+			}
+		}
+		s.srv = nil
+
+	case s.srv == nil:
+		log.Infof("s2s_in: listener added")
+		s.srv = s.newServer(config, mods)
+		if started {
+			go s.srv.start()
+		}
+
+	default:
+		for _, setting := range s.srv.reload(config, mods) {
+			log.Warnf("s2s_in: %s changes require a restart to be applied", setting)
+		}
+	}
+}
+
+func (s *S2S) reportConnections(report func(val float64, labelValues ...string)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.srv != nil {
+		for state, count := range s.srv.connectionsByState() {
+			report(float64(count), "in", state)
+		}
+	}
+	for state, count := range s.outProvider.connectionsByState() {
+		report(float64(count), "out", state)
+	}
+}
+
 // Shutdown gracefully shuts down s2s manager.
 func (s *S2S) Shutdown(ctx context.Context) {
-	if atomic.CompareAndSwapUint32(&s.started, 1, 0) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if atomic.CompareAndSwapUint32(&s.started, 1, 0) && s.srv != nil {
 		if err := s.srv.shutdown(ctx); err != nil {
 			log.Error(err)
 		}
@@ -16,6 +16,8 @@ import (
 	"testing"
 	"time"
 
+	"fmt"
+	"github.com/ortuman/jackal/auth/backend"
 	c2srouter "github.com/ortuman/jackal/c2s/router"
 	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/router"
@@ -49,6 +51,7 @@ const securedFeatures = `
 `
 
 var errFakeSockAlreadyClosed = errors.New("fakeSockReaderWriter: already closed")
+var errNoSRVRecords = errors.New("no such host")
 
 type fakeSockReaderWriter struct {
 	r      *io.PipeReader
@@ -193,7 +196,7 @@ func (a fakeAddr) String() string  { return "str" }
 
 func setupTestRouter(domain string) (router.Router, *host.Hosts) {
 	hosts := setupTestHosts(domain)
-	r, _ := router.New(hosts, c2srouter.New(memorystorage.NewUser(), memorystorage.NewBlockList()), nil)
+	r, _ := router.New(hosts, c2srouter.New(backend.NewStorage(memorystorage.NewUser()), memorystorage.NewBlockList()), nil)
 	return r, hosts
 }
 
@@ -205,12 +208,14 @@ func setupTestHosts(domain string) *host.Hosts {
 type fakeS2SServer struct {
 	startCh    chan struct{}
 	shutdownCh chan struct{}
+	reloadCh   chan *Config
 }
 
 func newFakeS2SServer() *fakeS2SServer {
 	return &fakeS2SServer{
 		startCh:    make(chan struct{}, 1),
 		shutdownCh: make(chan struct{}, 1),
+		reloadCh:   make(chan *Config, 1),
 	}
 }
 
@@ -223,6 +228,15 @@ func (s *fakeS2SServer) shutdown(_ context.Context) error {
 	return nil
 }
 
+func (s *fakeS2SServer) reload(config *Config, _ *module.Modules) []string {
+	s.reloadCh <- config
+	return nil
+}
+
+func (s *fakeS2SServer) connectionsByState() map[string]int {
+	return map[string]int{"connected": 1}
+}
+
 func TestS2S_StartAndShutdown(t *testing.T) {
 	s2s, fakeSrv := setupTestS2S()
 
@@ -243,6 +257,67 @@ func TestS2S_StartAndShutdown(t *testing.T) {
 	}
 }
 
+func TestS2S_Reload(t *testing.T) {
+	s2s, fakeSrv := setupTestS2S()
+
+	s2s.Start()
+	<-fakeSrv.startCh
+
+	s2s.Reload(context.Background(), &Config{MaxStanzaSize: 1024}, &module.Modules{})
+	select {
+	case cfg := <-fakeSrv.reloadCh:
+		require.Equal(t, 1024, cfg.MaxStanzaSize)
+	case <-time.After(time.Millisecond * 250):
+		require.Fail(t, "s2s reload timeout")
+	}
+
+	// removing s2s configuration stops listening...
+	s2s.Reload(context.Background(), nil, &module.Modules{})
+	select {
+	case <-fakeSrv.shutdownCh:
+		break
+	case <-time.After(time.Millisecond * 250):
+		require.Fail(t, "s2s shutdown timeout")
+	}
+
+	// ...and adding it back spawns a new listener
+	s2s.Reload(context.Background(), &Config{}, &module.Modules{})
+	select {
+	case <-fakeSrv.startCh:
+		break
+	case <-time.After(time.Millisecond * 250):
+		require.Fail(t, "s2s start timeout")
+	}
+	s2s.Shutdown(context.Background())
+	<-fakeSrv.shutdownCh
+}
+
+func TestS2S_ReportConnections(t *testing.T) {
+	s2s, _ := setupTestS2S()
+
+	var reported []string
+	s2s.reportConnections(func(val float64, labelValues ...string) {
+		reported = append(reported, fmt.Sprintf("%s:%s:%v", labelValues[0], labelValues[1], val))
+	})
+	require.Equal(t, []string{"in:connected:1"}, reported)
+}
+
+func TestS2SServer_Reload(t *testing.T) {
+	cfg := Config{DialbackSecret: "s3cr3t", MaxStanzaSize: 8192}
+	srv := newServer(&cfg, nil, nil, nil)
+
+	ignored := srv.reload(&Config{DialbackSecret: "s3cr3t", MaxStanzaSize: 1024}, &module.Modules{})
+	require.Len(t, ignored, 0)
+
+	ignored = srv.reload(&Config{DialbackSecret: "an0th3r", Transport: TransportConfig{Port: 5270}}, &module.Modules{})
+	require.Equal(t, []string{"transport", "dialback_secret"}, ignored)
+
+	streamCfg, mods := srv.streamConfig()
+	require.Equal(t, "s3cr3t", streamCfg.DialbackSecret)
+	require.Equal(t, 5270, streamCfg.Transport.Port)
+	require.NotNil(t, mods)
+}
+
 func setupTestS2S() (*S2S, *fakeS2SServer) {
 	srv := newFakeS2SServer()
 	createS2SServer = func(_ *Config, _ *module.Modules, _ newOutFunc, _ router.Router) s2sServer {
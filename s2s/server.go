@@ -7,10 +7,13 @@ package s2s
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
+	"reflect"
 	"strconv"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	streamerror "github.com/ortuman/jackal/errors"
 	"github.com/ortuman/jackal/log"
@@ -18,6 +21,8 @@ import (
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/transport/proxyproto"
+	"github.com/ortuman/jackal/util/connlimit"
 )
 
 var listenerProvider = net.Listen
@@ -25,10 +30,13 @@ var listenerProvider = net.Listen
 type server struct {
 	mu            sync.RWMutex
 	cfg           *Config
+	cfgMu         sync.RWMutex
+	streamCfg     *Config
 	router        router.Router
 	mods          *module.Modules
 	newOutFn      newOutFunc
 	inConnections map[string]stream.S2SIn
+	connLimiter   *connlimit.Limiter
 	ln            net.Listener
 	listening     uint32
 }
@@ -36,10 +44,12 @@ type server struct {
 func newServer(config *Config, mods *module.Modules, newOutFn newOutFunc, router router.Router) *server {
 	return &server{
 		cfg:           config,
+		streamCfg:     config,
 		router:        router,
 		mods:          mods,
 		newOutFn:      newOutFn,
 		inConnections: make(map[string]stream.S2SIn),
+		connLimiter:   connlimit.New(&config.Connections),
 	}
 }
 
@@ -71,6 +81,48 @@ func (s *server) shutdown(ctx context.Context) error {
 	return nil
 }
 
+// reload applies a new configuration to incoming streams started from now on,
+// returning the name of the settings whose changes require a restart to be applied.
+func (s *server) reload(config *Config, mods *module.Modules) []string {
+	var ignored []string
+	if !reflect.DeepEqual(config.Transport, s.cfg.Transport) {
+		ignored = append(ignored, "transport")
+	}
+	if !reflect.DeepEqual(config.Connections, s.cfg.Connections) {
+		ignored = append(ignored, "connections")
+	}
+	// outgoing streams are bound to the settings loaded at startup
+	if config.DialbackSecret != s.cfg.DialbackSecret {
+		ignored = append(ignored, "dialback_secret")
+	}
+	if config.DialTimeout != s.cfg.DialTimeout {
+		ignored = append(ignored, "dial_timeout")
+	}
+	cfg := *config
+	cfg.DialbackSecret = s.cfg.DialbackSecret
+
+	s.cfgMu.Lock()
+	s.streamCfg = &cfg
+	s.mods = mods
+	s.cfgMu.Unlock()
+	return ignored
+}
+
+func (s *server) streamConfig() (*Config, *module.Modules) {
+	s.cfgMu.RLock()
+	defer s.cfgMu.RUnlock()
+	return s.streamCfg, s.mods
+}
+
+// acquireStreamConfig returns current incoming stream configuration, marking its module set
+// as being used by a new stream.
+func (s *server) acquireStreamConfig() (*Config, *module.Modules) {
+	s.cfgMu.RLock()
+	defer s.cfgMu.RUnlock()
+	s.mods.Acquire()
+	return s.streamCfg, s.mods
+}
+
 func (s *server) listenConn(address string) error {
 	ln, err := listenerProvider("tcp", address)
 	if err != nil {
@@ -82,25 +134,76 @@ func (s *server) listenConn(address string) error {
 	for atomic.LoadUint32(&s.listening) == 1 {
 		conn, err := ln.Accept()
 		if err == nil {
-			go s.startInStream(transport.NewSocketTransport(conn))
+			go s.acceptConn(conn)
 			continue
 		}
 	}
 	return nil
 }
 
+func (s *server) acceptConn(conn net.Conn) {
+	// [PROXY protocol] original peer address is conveyed by trusted proxies
+	if s.cfg.Transport.ProxyProtocol.IsTrusted(conn.RemoteAddr()) {
+		cfg, _ := s.streamConfig()
+		pConn, err := proxyproto.Accept(conn, cfg.ConnectTimeout)
+		if err != nil {
+			log.Warnf("s2s_in: failed to read proxy protocol header from %s: %v", conn.RemoteAddr(), err)
+			_ = conn.Close()
+			return
+		}
+		conn = pConn
+	}
+	lConn, err := s.connLimiter.Admit(conn)
+	if err != nil {
+		log.Warnf("s2s_in: rejected connection from %s: %v (total rejected: %d)", conn.RemoteAddr(), err, s.connLimiter.Rejected())
+		_ = conn.Close()
+		return
+	}
+	if s.cfg.Transport.DirectTLS {
+		s.startDirectTLSInStream(lConn)
+		return
+	}
+	s.startInStream(transport.NewSocketTransport(lConn))
+}
+
+// startDirectTLSInStream performs TLS handshake before starting the incoming stream (XEP-0368).
+func (s *server) startDirectTLSInStream(conn net.Conn) {
+	tlsCfg := s.router.Hosts().TLSConfig("")
+	tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	tlsCfg.NextProtos = []string{xmppServerALPN}
+
+	tlsConn := tls.Server(conn, tlsCfg)
+	if cfg, _ := s.streamConfig(); cfg.ConnectTimeout > 0 {
+		_ = tlsConn.SetDeadline(time.Now().Add(cfg.ConnectTimeout))
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		log.Warnf("s2s_in: tls handshake failed: %v", err)
+		_ = tlsConn.Close()
+		return
+	}
+	_ = tlsConn.SetDeadline(time.Time{})
+
+	s.startInStream(transport.NewSocketTransport(tlsConn))
+}
+
 func (s *server) startInStream(tr transport.Transport) {
+	cfg, mods := s.acquireStreamConfig()
 	stm := newInStream(
 		&inConfig{
-			keyGen:         &keyGen{s.cfg.DialbackSecret},
-			connectTimeout: s.cfg.ConnectTimeout,
-			keepAlive:      s.cfg.KeepAlive,
-			timeout:        s.cfg.Timeout,
-			maxStanzaSize:  s.cfg.MaxStanzaSize,
-			onDisconnect:   s.unregisterInStream,
+			keyGen:         &keyGen{cfg.DialbackSecret},
+			connectTimeout: cfg.ConnectTimeout,
+			keepAlive:      cfg.KeepAlive,
+			timeout:        cfg.Timeout,
+			maxStanzaSize:  cfg.MaxStanzaSize,
+			directTLS:      s.cfg.Transport.DirectTLS,
+			rateLimit:      cfg.RateLimit,
+			onDisconnect: func(stm stream.S2SIn) {
+				s.unregisterInStream(stm)
+				mods.Release()
+			},
 		},
 		tr,
-		s.mods,
+		mods,
 		s.newOutFn,
 		s.router,
 	)
@@ -123,6 +226,19 @@ func (s *server) unregisterInStream(stm stream.S2SIn) {
 	log.Infof("unregistered s2s in stream... (id: %s)", stm.ID())
 }
 
+// connectionsByState returns the number of registered incoming streams grouped by state name.
+func (s *server) connectionsByState() map[string]int {
+	ret := make(map[string]int)
+	s.mu.RLock()
+	for _, stm := range s.inConnections {
+		if in, ok := stm.(*inStream); ok {
+			ret[inStateNames[in.getState()]]++
+		}
+	}
+	s.mu.RUnlock()
+	return ret
+}
+
 func (s *server) closeConnections(ctx context.Context) (count int, err error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
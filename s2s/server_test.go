@@ -11,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/router"
 
 	"github.com/stretchr/testify/require"
@@ -29,7 +30,7 @@ func TestS2SSocketServer(t *testing.T) {
 			Port: 12778,
 		},
 	}
-	srv := newServer(&cfg, nil, nil, r)
+	srv := newServer(&cfg, &module.Modules{}, nil, r)
 	go srv.start()
 	go func() {
 		time.Sleep(time.Millisecond * 150)
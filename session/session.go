@@ -8,6 +8,7 @@ package session
 import (
 	"context"
 	stdxml "encoding/xml"
+	"fmt"
 	"io"
 	"net"
 	"strings"
@@ -27,15 +28,13 @@ import (
 const (
 	jabberClientNamespace = "jabber:client"
 	jabberServerNamespace = "jabber:server"
+	componentNamespace    = "jabber:component:accept"
 	framedStreamNamespace = "urn:ietf:params:xml:ns:xmpp-framing"
+	httpBindNamespace     = "http://jabber.org/protocol/httpbind"
 	streamNamespace       = "http://etherx.jabber.org/streams"
 	dialbackNamespace     = "jabber:server:dialback"
 )
 
-type namespaceSettable interface {
-	SetNamespace(string)
-}
-
 // Error represents a session error.
 type Error struct {
 	// Element returns the original incoming element that generated
@@ -65,6 +64,10 @@ type Config struct {
 	// IsInitiating defines whether or not this is an initiating
 	// entity session.
 	IsInitiating bool
+
+	// IsComponent defines whether or not this session is established
+	// by an external component (XEP-0114).
+	IsComponent bool
 }
 
 // Session represents an XMPP session between the two peers.
@@ -76,6 +79,7 @@ type Session struct {
 	remoteDomain string
 	isServer     bool
 	isInitiating bool
+	isComponent  bool
 	opened       uint32
 	started      uint32
 
@@ -90,6 +94,10 @@ func New(id string, config *Config, tr transport.Transport, hosts *host.Hosts) *
 	switch tr.Type() {
 	case transport.Socket:
 		parsingMode = xmpp.SocketStream
+	case transport.WebSocket:
+		parsingMode = xmpp.WebSocketStream
+	case transport.BOSH:
+		parsingMode = xmpp.DefaultMode
 	}
 	s := &Session{
 		id:           id,
@@ -99,6 +107,7 @@ func New(id string, config *Config, tr transport.Transport, hosts *host.Hosts) *
 		remoteDomain: config.RemoteDomain,
 		isServer:     config.IsServer,
 		isInitiating: config.IsInitiating,
+		isComponent:  config.IsComponent,
 		sJID:         config.JID,
 	}
 	if !s.isInitiating {
@@ -147,6 +156,20 @@ func (s *Session) Open(ctx context.Context, featuresElem xmpp.XElement) error {
 		}
 		buf.WriteString(`<?xml version="1.0"?>`)
 
+	case transport.WebSocket:
+		ops = xmpp.NewElementName("open")
+		ops.SetAttribute("xmlns", framedStreamNamespace)
+		includeClosing = true
+
+	case transport.BOSH:
+		// [xep-0206] stream attributes are conveyed by the connection manager <body/> wrapper
+		if featuresElem != nil {
+			if err := featuresElem.ToXML(buf, true); err != nil {
+				return err
+			}
+		}
+		return s.writeOpenString(ctx, buf.String())
+
 	default:
 		return nil
 	}
@@ -167,20 +190,18 @@ func (s *Session) Open(ctx context.Context, featuresElem xmpp.XElement) error {
 	}
 
 	if featuresElem != nil {
+		if s.tr.Type() == transport.WebSocket {
+			// [rfc7395] every websocket message must contain a single complete element
+			if err := s.writeOpenString(ctx, buf.String()); err != nil {
+				return err
+			}
+			buf.Reset()
+		}
 		if err := featuresElem.ToXML(buf, true); err != nil {
 			return err
 		}
 	}
-	openStr := buf.String()
-	log.Debugf("SEND(%s): %s", s.id, openStr)
-
-	s.setWriteDeadline(ctx)
-
-	_, err := io.Copy(s.tr, strings.NewReader(openStr))
-	if err != nil {
-		return err
-	}
-	return s.tr.Flush()
+	return s.writeOpenString(ctx, buf.String())
 }
 
 // Close closes session sending the proper XMPP payload.
@@ -195,6 +216,8 @@ func (s *Session) Close(ctx context.Context) error {
 	switch s.tr.Type() {
 	case transport.Socket:
 		_, err = io.WriteString(s.tr, "</stream:stream>")
+	case transport.WebSocket:
+		_, err = io.WriteString(s.tr, fmt.Sprintf(`<close xmlns="%s" />`, framedStreamNamespace))
 	}
 	if err != nil {
 		return err
@@ -204,9 +227,25 @@ func (s *Session) Close(ctx context.Context) error {
 
 // Send writes an XML element to the underlying session transport.
 func (s *Session) Send(ctx context.Context, elem xmpp.XElement) error {
+	isFramedTr := s.tr.Type() == transport.WebSocket || s.tr.Type() == transport.BOSH
+
 	// clear namespace if sending a stanza
-	if e, ok := elem.(namespaceSettable); elem.IsStanza() && ok {
-		e.SetNamespace("")
+	if elem.IsStanza() {
+		var ns string
+		if isFramedTr {
+			// framed stanzas must be qualified by content namespace
+			ns = s.namespace()
+		}
+		if elem.Namespace() != ns {
+			// stanzas may be shared across sessions, so never modify them in place
+			elem = xmpp.NewElementFromElement(elem).SetNamespace(ns)
+		}
+	}
+	// declare stream prefix on framed stream level elements
+	if isFramedTr && strings.HasPrefix(elem.Name(), "stream:") && len(elem.Attributes().Get("xmlns:stream")) == 0 {
+		el := xmpp.NewElementFromElement(elem)
+		el.SetAttribute("xmlns:stream", streamNamespace)
+		elem = el
 	}
 	log.Debugf("SEND(%s): %v", s.id, elem)
 
@@ -248,6 +287,18 @@ func (s *Session) Receive() (xmpp.XElement, *Error) {
 	return elem, nil
 }
 
+func (s *Session) writeOpenString(ctx context.Context, openStr string) error {
+	log.Debugf("SEND(%s): %s", s.id, openStr)
+
+	s.setWriteDeadline(ctx)
+
+	_, err := io.Copy(s.tr, strings.NewReader(openStr))
+	if err != nil {
+		return err
+	}
+	return s.tr.Flush()
+}
+
 func (s *Session) setWriteDeadline(ctx context.Context) {
 	d, ok := ctx.Deadline()
 	if !ok {
@@ -297,7 +348,7 @@ func (s *Session) extractAddresses(elem xmpp.XElement) (*jid.JID, *jid.JID, *Err
 	var err error
 
 	from := elem.From()
-	if !s.isServer {
+	if !s.isServer && !s.isComponent {
 		// do not validate 'from' address until full user JID has been set
 		if s.jid().IsFullWithUser() {
 			if len(from) > 0 && !s.isValidFrom(from) {
@@ -351,6 +402,24 @@ func (s *Session) validateStreamElement(elem xmpp.XElement) *Error {
 		if elem.Namespace() != s.namespace() || elem.Attributes().Get("xmlns:stream") != streamNamespace {
 			return &Error{UnderlyingErr: streamerror.ErrInvalidNamespace}
 		}
+	case transport.WebSocket:
+		if elem.Name() != "open" {
+			return &Error{UnderlyingErr: streamerror.ErrUnsupportedStanzaType}
+		}
+		if elem.Namespace() != framedStreamNamespace {
+			return &Error{UnderlyingErr: streamerror.ErrInvalidNamespace}
+		}
+	case transport.BOSH:
+		if elem.Name() != "body" {
+			return &Error{UnderlyingErr: streamerror.ErrUnsupportedStanzaType}
+		}
+		if elem.Namespace() != httpBindNamespace {
+			return &Error{UnderlyingErr: streamerror.ErrInvalidNamespace}
+		}
+	}
+	if s.isComponent {
+		// [xep-0114] component domain is validated during handshake
+		return nil
 	}
 	to := elem.To()
 	if len(to) > 0 && !s.hosts.IsLocalHost(to) {
@@ -371,6 +440,9 @@ func (s *Session) validateNamespace(elem xmpp.XElement) *Error {
 }
 
 func (s *Session) namespace() string {
+	if s.isComponent {
+		return componentNamespace
+	}
 	if s.isServer {
 		return jabberServerNamespace
 	}
@@ -13,6 +13,7 @@ import (
 	stdxml "encoding/xml"
 	"errors"
 	"io"
+	"net"
 	"testing"
 	"time"
 
@@ -47,6 +48,7 @@ func (t *fakeTransport) StartTLS(_ *tls.Config, _ bool)
 func (t *fakeTransport) EnableCompression(compress.Level)                             {}
 func (t *fakeTransport) ChannelBindingBytes(transport.ChannelBindingMechanism) []byte { return nil }
 func (t *fakeTransport) PeerCertificates() []*x509.Certificate                        { return nil }
+func (t *fakeTransport) RemoteAddr() net.Addr                                         { return nil }
 
 func TestSession_Open(t *testing.T) {
 	hosts := setupTest("jackal.im")
@@ -81,6 +83,31 @@ func TestSession_Open(t *testing.T) {
 	require.Nil(t, err)
 	require.Equal(t, "jabber:server", elem.Namespace())
 
+	// test component socket session start
+	tr.wrBuf.Reset()
+	sess = New(uuid.New(), &Config{JID: j, IsComponent: true}, tr, hosts)
+
+	_ = sess.Open(context.Background(), nil)
+	pr = xmpp.NewParser(tr.wrBuf, xmpp.SocketStream, 0)
+	_, _ = pr.ParseElement() // read xml header
+	elem, err = pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "jabber:component:accept", elem.Namespace())
+
+	// test websocket session start
+	tr = newFakeTransport(transport.WebSocket)
+	sess = New(uuid.New(), &Config{JID: j}, tr, hosts)
+
+	_ = sess.Open(context.Background(), xmpp.NewElementName("stream:features"))
+	pr = xmpp.NewParser(tr.wrBuf, xmpp.WebSocketStream, 0)
+	elem, err = pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "open", elem.Name())
+	require.Equal(t, "urn:ietf:params:xml:ns:xmpp-framing", elem.Namespace())
+	elem, err = pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "stream:features", elem.Name())
+
 	// test unsupported transport type
 	tr = newFakeTransport(transport.Type(9999))
 	sess = New(uuid.New(), &Config{JID: j}, tr, hosts)
@@ -102,6 +129,14 @@ func TestSession_Close(t *testing.T) {
 
 	_ = sess.Close(context.Background())
 	require.Equal(t, "</stream:stream>", tr.wrBuf.String())
+
+	tr = newFakeTransport(transport.WebSocket)
+	sess = New(uuid.New(), &Config{JID: j}, tr, hosts)
+	_ = sess.Open(context.Background(), nil)
+	tr.wrBuf.Reset()
+
+	_ = sess.Close(context.Background())
+	require.Equal(t, `<close xmlns="urn:ietf:params:xml:ns:xmpp-framing" />`, tr.wrBuf.String())
 }
 
 func TestSession_Send(t *testing.T) {
@@ -117,6 +152,22 @@ func TestSession_Send(t *testing.T) {
 
 	_ = sess.Send(context.Background(), elem)
 	require.Equal(t, elem.String(), tr.wrBuf.String())
+
+	// framed stanzas keep content namespace
+	tr = newFakeTransport(transport.WebSocket)
+	sess = New(uuid.New(), &Config{JID: j}, tr, hosts)
+	_ = sess.Open(context.Background(), nil)
+	tr.wrBuf.Reset()
+
+	sent := xmpp.NewIQType(uuid.New(), xmpp.ResultType)
+	_ = sess.Send(context.Background(), sent)
+	pr := xmpp.NewParser(tr.wrBuf, xmpp.DefaultMode, 0)
+	iq, err := pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "jabber:client", iq.Namespace())
+
+	// sent stanza must remain untouched
+	require.Equal(t, "", sent.Namespace())
 }
 
 func TestSession_Receive(t *testing.T) {
@@ -3,11 +3,16 @@
  * See the LICENSE file for more information.
  */
 
+DROP TABLE IF EXISTS muc_affiliations;
+DROP TABLE IF EXISTS muc_rooms;
+DROP TABLE IF EXISTS archive_messages;
 DROP TABLE IF EXISTS pubsub_items;
 DROP TABLE IF EXISTS pubsub_subscriptions;
 DROP TABLE IF EXISTS pubsub_affiliations;
 DROP TABLE IF EXISTS pubsub_node_options;
 DROP TABLE IF EXISTS pubsub_nodes;
+DROP TABLE IF EXISTS fast_tokens;
+DROP TABLE IF EXISTS push_registrations;
 DROP TABLE IF EXISTS offline_messages;
 DROP TABLE IF EXISTS vcards;
 DROP TABLE IF EXISTS private_storage;
@@ -8,6 +8,9 @@
 CREATE TABLE IF NOT EXISTS users (
     username         VARCHAR(256) PRIMARY KEY,
     password         TEXT NOT NULL,
+    scram_sha_1      VARCHAR(512) NOT NULL DEFAULT '',
+    scram_sha_256    VARCHAR(512) NOT NULL DEFAULT '',
+    scram_sha_512    VARCHAR(512) NOT NULL DEFAULT '',
     last_presence    TEXT NOT NULL,
     last_presence_at DATETIME NOT NULL,
     updated_at       DATETIME NOT NULL,
@@ -153,6 +156,35 @@ CREATE TABLE IF NOT EXISTS offline_messages (
 
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
 
+-- push_registrations
+
+CREATE TABLE IF NOT EXISTS push_registrations (
+    username   VARCHAR(256) NOT NULL,
+    jid        VARCHAR(512) NOT NULL,
+    node       VARCHAR(256) NOT NULL,
+    options    TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+
+    UNIQUE INDEX i_push_registrations_username_jid_node (username(191), jid(191), node(191))
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- fast_tokens
+
+CREATE TABLE IF NOT EXISTS fast_tokens (
+    username   VARCHAR(256) NOT NULL,
+    client_id  VARCHAR(256) NOT NULL,
+    mechanism  VARCHAR(64) NOT NULL,
+    token      VARCHAR(256) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    INDEX i_fast_tokens_username (username(191)),
+    UNIQUE INDEX i_fast_tokens_token (token(191))
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
 -- pubsub_nodes
 
 CREATE TABLE IF NOT EXISTS pubsub_nodes (
@@ -224,3 +256,47 @@ CREATE TABLE IF NOT EXISTS pubsub_items (
     UNIQUE INDEX i_pubsub_items_node_id_item_id (node_id, item_id(36))
 
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- archive_messages
+
+CREATE TABLE IF NOT EXISTS archive_messages (
+    serial     BIGINT AUTO_INCREMENT PRIMARY KEY,
+    username   VARCHAR(256) NOT NULL,
+    id         VARCHAR(64) NOT NULL,
+    with_jid   VARCHAR(512) NOT NULL,
+    data       MEDIUMTEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+
+    UNIQUE INDEX i_archive_messages_username_id (username, id),
+    INDEX i_archive_messages_username_created_at (username, created_at),
+    INDEX i_archive_messages_username_with_jid (username(191), with_jid(191))
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- muc_rooms
+
+CREATE TABLE IF NOT EXISTS muc_rooms (
+    host       VARCHAR(256) NOT NULL,
+    name       VARCHAR(256) NOT NULL,
+    subject    TEXT NOT NULL,
+    config     TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+
+    UNIQUE INDEX i_muc_rooms_host_name (host(191), name(191))
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- muc_affiliations
+
+CREATE TABLE IF NOT EXISTS muc_affiliations (
+    host        VARCHAR(256) NOT NULL,
+    name        VARCHAR(256) NOT NULL,
+    jid         VARCHAR(512) NOT NULL,
+    affiliation VARCHAR(32) NOT NULL,
+    updated_at  DATETIME NOT NULL,
+    created_at  DATETIME NOT NULL,
+
+    UNIQUE INDEX i_muc_affiliations_host_name_jid (host(191), name(191), jid(191))
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
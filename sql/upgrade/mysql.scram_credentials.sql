@@ -0,0 +1,12 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+-- Adds SCRAM credentials columns to an existing users table.
+-- Stored cleartext passwords are converted into SCRAM credentials by jackal at startup.
+
+ALTER TABLE users
+    ADD COLUMN scram_sha_1   VARCHAR(512) NOT NULL DEFAULT '' AFTER password,
+    ADD COLUMN scram_sha_256 VARCHAR(512) NOT NULL DEFAULT '' AFTER scram_sha_1,
+    ADD COLUMN scram_sha_512 VARCHAR(512) NOT NULL DEFAULT '' AFTER scram_sha_256;
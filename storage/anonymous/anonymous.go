@@ -0,0 +1,244 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package anonymous
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ortuman/jackal/model"
+	memorystorage "github.com/ortuman/jackal/storage/memory"
+	"github.com/ortuman/jackal/storage/repository"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+// Storage wraps a repository container keeping user, roster, private, offline, block list,
+// push and FAST token data of anonymous (temporary) accounts in memory, while delegating
+// everything else to the underlying container.
+//
+// Pubsub, presence and MUC entries persisted on behalf of an anonymous account are tracked
+// so that they can be removed once the account is released.
+type Storage struct {
+	repository.Container
+
+	mu    sync.RWMutex
+	users map[string]*userData
+}
+
+type userData struct {
+	user      *memorystorage.User
+	roster    *memorystorage.Roster
+	private   *memorystorage.Private
+	offline   *memorystorage.Offline
+	blockList *memorystorage.BlockList
+	push      *memorystorage.Push
+	fastToken *memorystorage.FastToken
+
+	// persisted entries
+	nodes            map[entryKey]struct{}
+	nodeAffiliations map[entryKey]struct{}
+	subscriptions    map[entryKey]struct{}
+	roomAffiliations map[entryKey]struct{}
+	presences        map[string]*jid.JID
+}
+
+type entryKey struct {
+	jid  string
+	host string
+	name string
+}
+
+// New returns a new anonymous aware storage wrapping c container.
+func New(c repository.Container) *Storage {
+	return &Storage{
+		Container: c,
+		users:     make(map[string]*userData),
+	}
+}
+
+// Register creates a new temporary account associated to username.
+func (s *Storage) Register(ctx context.Context, username string) error {
+	ud := &userData{
+		user:             memorystorage.NewUser(),
+		roster:           memorystorage.NewRoster(),
+		private:          memorystorage.NewPrivate(),
+		offline:          memorystorage.NewOffline(),
+		blockList:        memorystorage.NewBlockList(),
+		push:             memorystorage.NewPush(),
+		fastToken:        memorystorage.NewFastToken(),
+		nodes:            make(map[entryKey]struct{}),
+		nodeAffiliations: make(map[entryKey]struct{}),
+		subscriptions:    make(map[entryKey]struct{}),
+		roomAffiliations: make(map[entryKey]struct{}),
+		presences:        make(map[string]*jid.JID),
+	}
+	if err := ud.user.UpsertUser(ctx, &model.User{Username: username}); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.users[username] = ud
+	s.mu.Unlock()
+	return nil
+}
+
+// Release discards all data associated to a temporary account.
+func (s *Storage) Release(ctx context.Context, username string) error {
+	s.mu.Lock()
+	ud, ok := s.users[username]
+	delete(s.users, username)
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	// remove whatever has been persisted into the underlying storage (pubsub, presences, MUC...)
+	pubSubRep := s.Container.PubSub()
+	for k := range ud.nodes {
+		if err := pubSubRep.DeleteNode(ctx, k.host, k.name); err != nil {
+			return err
+		}
+	}
+	for k := range ud.nodeAffiliations {
+		if err := pubSubRep.DeleteNodeAffiliation(ctx, k.jid, k.host, k.name); err != nil {
+			return err
+		}
+	}
+	for k := range ud.subscriptions {
+		if err := pubSubRep.DeleteNodeSubscription(ctx, k.jid, k.host, k.name); err != nil {
+			return err
+		}
+	}
+	for k := range ud.roomAffiliations {
+		if err := s.Container.Muc().DeleteRoomAffiliation(ctx, k.jid, k.host, k.name); err != nil {
+			return err
+		}
+	}
+	for _, j := range ud.presences {
+		if err := s.Container.Presences().DeletePresence(ctx, j); err != nil {
+			return err
+		}
+	}
+	if err := s.Container.Archive().DeleteArchivedMessages(ctx, username); err != nil {
+		return err
+	}
+	// ...along with vCard and any other user data
+	return s.Container.User().DeleteUser(ctx, username)
+}
+
+// IsAnonymous tells whether or not username belongs to a temporary account.
+func (s *Storage) IsAnonymous(username string) bool {
+	return s.userData(username) != nil
+}
+
+// User returns repository.User concrete implementation.
+func (s *Storage) User() repository.User { return &userRep{s: s} }
+
+// Roster returns repository.Roster concrete implementation.
+func (s *Storage) Roster() repository.Roster { return &rosterRep{s: s} }
+
+// Private returns repository.Private concrete implementation.
+func (s *Storage) Private() repository.Private { return &privateRep{s: s} }
+
+// Offline returns repository.Offline concrete implementation.
+func (s *Storage) Offline() repository.Offline { return &offlineRep{s: s} }
+
+// BlockList returns repository.BlockList concrete implementation.
+func (s *Storage) BlockList() repository.BlockList { return &blockListRep{s: s} }
+
+// Push returns repository.Push concrete implementation.
+func (s *Storage) Push() repository.Push { return &pushRep{s: s} }
+
+// FastToken returns repository.FastToken concrete implementation.
+func (s *Storage) FastToken() repository.FastToken { return &fastTokenRep{s: s} }
+
+// PubSub returns repository.PubSub concrete implementation.
+func (s *Storage) PubSub() repository.PubSub {
+	return &pubSubRep{PubSub: s.Container.PubSub(), s: s}
+}
+
+// Presences returns repository.Presences concrete implementation.
+func (s *Storage) Presences() repository.Presences {
+	return &presencesRep{Presences: s.Container.Presences(), s: s}
+}
+
+// Muc returns repository.Muc concrete implementation.
+func (s *Storage) Muc() repository.Muc {
+	return &mucRep{Muc: s.Container.Muc(), s: s}
+}
+
+func (s *Storage) userData(username string) *userData {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.users[username]
+}
+
+func (s *Storage) user(username string) repository.User {
+	if ud := s.userData(username); ud != nil {
+		return ud.user
+	}
+	return s.Container.User()
+}
+
+func (s *Storage) roster(username string) repository.Roster {
+	if ud := s.userData(username); ud != nil {
+		return ud.roster
+	}
+	return s.Container.Roster()
+}
+
+func (s *Storage) private(username string) repository.Private {
+	if ud := s.userData(username); ud != nil {
+		return ud.private
+	}
+	return s.Container.Private()
+}
+
+func (s *Storage) offline(username string) repository.Offline {
+	if ud := s.userData(username); ud != nil {
+		return ud.offline
+	}
+	return s.Container.Offline()
+}
+
+func (s *Storage) blockList(username string) repository.BlockList {
+	if ud := s.userData(username); ud != nil {
+		return ud.blockList
+	}
+	return s.Container.BlockList()
+}
+
+func (s *Storage) push(username string) repository.Push {
+	if ud := s.userData(username); ud != nil {
+		return ud.push
+	}
+	return s.Container.Push()
+}
+
+func (s *Storage) fastToken(username string) repository.FastToken {
+	if ud := s.userData(username); ud != nil {
+		return ud.fastToken
+	}
+	return s.Container.FastToken()
+}
+
+// track records an entry persisted on behalf of the account j belongs to, if anonymous.
+func (s *Storage) track(j *jid.JID, fn func(ud *userData)) {
+	if j == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ud := s.users[j.Node()]; ud != nil {
+		fn(ud)
+	}
+}
+
+func (s *Storage) trackString(j string, fn func(ud *userData)) {
+	pj, err := jid.NewWithString(j, true)
+	if err != nil {
+		return
+	}
+	s.track(pj, fn)
+}
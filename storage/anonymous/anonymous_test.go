@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package anonymous
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ortuman/jackal/model"
+	mucmodel "github.com/ortuman/jackal/model/muc"
+	pubsubmodel "github.com/ortuman/jackal/model/pubsub"
+	rostermodel "github.com/ortuman/jackal/model/roster"
+	memorystorage "github.com/ortuman/jackal/storage/memory"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_Anonymous(t *testing.T) {
+	c, _ := memorystorage.New()
+	s := New(c)
+
+	ctx := context.Background()
+	_ = s.User().UpsertUser(ctx, &model.User{Username: "ortuman"})
+
+	require.Nil(t, s.Register(ctx, "guest"))
+	require.True(t, s.IsAnonymous("guest"))
+	require.False(t, s.IsAnonymous("ortuman"))
+
+	ok, _ := s.User().UserExists(ctx, "guest")
+	require.True(t, ok)
+
+	// anonymous data never reaches underlying storage
+	ok, _ = c.User().UserExists(ctx, "guest")
+	require.False(t, ok)
+
+	_, err := s.Roster().UpsertRosterItem(ctx, &rostermodel.Item{Username: "guest", JID: "ortuman@jackal.im"})
+	require.Nil(t, err)
+	_, err = s.Roster().UpsertRosterItem(ctx, &rostermodel.Item{Username: "ortuman", JID: "noelia@jackal.im"})
+	require.Nil(t, err)
+
+	items, _, _ := s.Roster().FetchRosterItems(ctx, "guest")
+	require.Len(t, items, 1)
+	items, _, _ = c.Roster().FetchRosterItems(ctx, "guest")
+	require.Len(t, items, 0)
+	items, _, _ = c.Roster().FetchRosterItems(ctx, "ortuman")
+	require.Len(t, items, 1)
+
+	require.Nil(t, s.Private().UpsertPrivateXML(ctx, []xmpp.XElement{xmpp.NewElementNamespace("exodus", "exodus:ns")}, "exodus:ns", "guest"))
+	priv, _ := c.Private().FetchPrivateXML(ctx, "exodus:ns", "guest")
+	require.Len(t, priv, 0)
+
+	j, _ := jid.NewWithString("guest@jackal.im", true)
+	msg := xmpp.NewMessageType("m1", xmpp.NormalType)
+	msg.SetToJID(j)
+	require.Nil(t, s.Offline().InsertOfflineMessage(ctx, msg, "guest"))
+	cnt, _ := s.Offline().CountOfflineMessages(ctx, "guest")
+	require.Equal(t, 1, cnt)
+	cnt, _ = c.Offline().CountOfflineMessages(ctx, "guest")
+	require.Equal(t, 0, cnt)
+
+	// release temporary account
+	require.Nil(t, s.Release(ctx, "guest"))
+	require.False(t, s.IsAnonymous("guest"))
+
+	ok, _ = s.User().UserExists(ctx, "guest")
+	require.False(t, ok)
+	items, _, _ = s.Roster().FetchRosterItems(ctx, "guest")
+	require.Len(t, items, 0)
+	cnt, _ = s.Offline().CountOfflineMessages(ctx, "guest")
+	require.Equal(t, 0, cnt)
+
+	// regular users are left untouched
+	ok, _ = s.User().UserExists(ctx, "ortuman")
+	require.True(t, ok)
+}
+
+func TestStorage_ReleasePersistedData(t *testing.T) {
+	c, _ := memorystorage.New()
+	s := New(c)
+
+	ctx := context.Background()
+	require.Nil(t, s.Register(ctx, "guest"))
+
+	guestJID, _ := jid.NewWithString("guest@jackal.im/yard", true)
+
+	// kept in memory
+	require.Nil(t, s.BlockList().InsertBlockListItem(ctx, &model.BlockListItem{Username: "guest", JID: "noelia@jackal.im"}))
+	require.Nil(t, s.Push().UpsertPushRegistration(ctx, &model.PushRegistration{Username: "guest", JID: "push.jackal.im", Node: "n1"}))
+	require.Nil(t, s.FastToken().InsertFastToken(ctx, &model.FastToken{Username: "guest", Token: "t1"}))
+
+	blItems, _ := c.BlockList().FetchBlockListItems(ctx, "guest")
+	require.Len(t, blItems, 0)
+	regs, _ := c.Push().FetchPushRegistrations(ctx, "guest")
+	require.Len(t, regs, 0)
+	tokens, _ := c.FastToken().FetchFastTokens(ctx, "guest")
+	require.Len(t, tokens, 0)
+
+	// persisted into underlying storage
+	require.Nil(t, s.PubSub().UpsertNode(ctx, &pubsubmodel.Node{Host: "guest@jackal.im", Name: "princely_musings"}))
+	require.Nil(t, s.PubSub().UpsertNode(ctx, &pubsubmodel.Node{Host: "ortuman@jackal.im", Name: "princely_musings"}))
+	require.Nil(t, s.PubSub().UpsertNodeAffiliation(ctx, &pubsubmodel.Affiliation{JID: "guest@jackal.im", Affiliation: "publisher"}, "ortuman@jackal.im", "princely_musings"))
+	require.Nil(t, s.PubSub().UpsertNodeSubscription(ctx, &pubsubmodel.Subscription{JID: "guest@jackal.im", Subscription: "subscribed"}, "ortuman@jackal.im", "princely_musings"))
+	require.Nil(t, s.Muc().UpsertRoomAffiliation(ctx, &mucmodel.Affiliation{JID: "guest@jackal.im", Affiliation: "member"}, "conference.jackal.im", "room"))
+	_, err := s.Presences().UpsertPresence(ctx, xmpp.NewPresence(guestJID, guestJID, xmpp.AvailableType), guestJID, "alloc-1")
+	require.Nil(t, err)
+
+	node, _ := c.PubSub().FetchNode(ctx, "guest@jackal.im", "princely_musings")
+	require.NotNil(t, node)
+	pc, _ := c.Presences().FetchPresence(ctx, guestJID)
+	require.NotNil(t, pc)
+
+	require.Nil(t, s.Release(ctx, "guest"))
+
+	node, _ = c.PubSub().FetchNode(ctx, "guest@jackal.im", "princely_musings")
+	require.Nil(t, node)
+	aff, _ := c.PubSub().FetchNodeAffiliation(ctx, "ortuman@jackal.im", "princely_musings", "guest@jackal.im")
+	require.Nil(t, aff)
+	subs, _ := c.PubSub().FetchNodeSubscriptions(ctx, "ortuman@jackal.im", "princely_musings")
+	require.Len(t, subs, 0)
+	roomAffs, _ := c.Muc().FetchRoomAffiliations(ctx, "conference.jackal.im", "room")
+	require.Len(t, roomAffs, 0)
+	pc, _ = c.Presences().FetchPresence(ctx, guestJID)
+	require.Nil(t, pc)
+
+	// regular users data is left untouched
+	node, _ = c.PubSub().FetchNode(ctx, "ortuman@jackal.im", "princely_musings")
+	require.NotNil(t, node)
+}
@@ -0,0 +1,233 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package anonymous
+
+import (
+	"context"
+
+	"github.com/ortuman/jackal/model"
+	mucmodel "github.com/ortuman/jackal/model/muc"
+	pubsubmodel "github.com/ortuman/jackal/model/pubsub"
+	rostermodel "github.com/ortuman/jackal/model/roster"
+	"github.com/ortuman/jackal/storage/repository"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+type userRep struct {
+	s *Storage
+}
+
+func (r *userRep) UpsertUser(ctx context.Context, user *model.User) error {
+	return r.s.user(user.Username).UpsertUser(ctx, user)
+}
+
+func (r *userRep) DeleteUser(ctx context.Context, username string) error {
+	return r.s.user(username).DeleteUser(ctx, username)
+}
+
+func (r *userRep) FetchUser(ctx context.Context, username string) (*model.User, error) {
+	return r.s.user(username).FetchUser(ctx, username)
+}
+
+func (r *userRep) UserExists(ctx context.Context, username string) (bool, error) {
+	return r.s.user(username).UserExists(ctx, username)
+}
+
+type rosterRep struct {
+	s *Storage
+}
+
+func (r *rosterRep) UpsertRosterItem(ctx context.Context, ri *rostermodel.Item) (rostermodel.Version, error) {
+	return r.s.roster(ri.Username).UpsertRosterItem(ctx, ri)
+}
+
+func (r *rosterRep) DeleteRosterItem(ctx context.Context, username, jid string) (rostermodel.Version, error) {
+	return r.s.roster(username).DeleteRosterItem(ctx, username, jid)
+}
+
+func (r *rosterRep) FetchRosterItems(ctx context.Context, username string) ([]rostermodel.Item, rostermodel.Version, error) {
+	return r.s.roster(username).FetchRosterItems(ctx, username)
+}
+
+func (r *rosterRep) FetchRosterItemsInGroups(ctx context.Context, username string, groups []string) ([]rostermodel.Item, rostermodel.Version, error) {
+	return r.s.roster(username).FetchRosterItemsInGroups(ctx, username, groups)
+}
+
+func (r *rosterRep) FetchRosterItem(ctx context.Context, username, jid string) (*rostermodel.Item, error) {
+	return r.s.roster(username).FetchRosterItem(ctx, username, jid)
+}
+
+func (r *rosterRep) UpsertRosterNotification(ctx context.Context, rn *rostermodel.Notification) error {
+	return r.s.roster(rn.Contact).UpsertRosterNotification(ctx, rn)
+}
+
+func (r *rosterRep) DeleteRosterNotification(ctx context.Context, contact, jid string) error {
+	return r.s.roster(contact).DeleteRosterNotification(ctx, contact, jid)
+}
+
+func (r *rosterRep) FetchRosterNotification(ctx context.Context, contact string, jid string) (*rostermodel.Notification, error) {
+	return r.s.roster(contact).FetchRosterNotification(ctx, contact, jid)
+}
+
+func (r *rosterRep) FetchRosterNotifications(ctx context.Context, contact string) ([]rostermodel.Notification, error) {
+	return r.s.roster(contact).FetchRosterNotifications(ctx, contact)
+}
+
+func (r *rosterRep) FetchRosterGroups(ctx context.Context, username string) ([]string, error) {
+	return r.s.roster(username).FetchRosterGroups(ctx, username)
+}
+
+type privateRep struct {
+	s *Storage
+}
+
+func (r *privateRep) FetchPrivateXML(ctx context.Context, namespace string, username string) ([]xmpp.XElement, error) {
+	return r.s.private(username).FetchPrivateXML(ctx, namespace, username)
+}
+
+func (r *privateRep) UpsertPrivateXML(ctx context.Context, privateXML []xmpp.XElement, namespace string, username string) error {
+	return r.s.private(username).UpsertPrivateXML(ctx, privateXML, namespace, username)
+}
+
+type offlineRep struct {
+	s *Storage
+}
+
+func (r *offlineRep) InsertOfflineMessage(ctx context.Context, message *xmpp.Message, username string) error {
+	return r.s.offline(username).InsertOfflineMessage(ctx, message, username)
+}
+
+func (r *offlineRep) CountOfflineMessages(ctx context.Context, username string) (int, error) {
+	return r.s.offline(username).CountOfflineMessages(ctx, username)
+}
+
+func (r *offlineRep) FetchOfflineMessages(ctx context.Context, username string) ([]xmpp.Message, error) {
+	return r.s.offline(username).FetchOfflineMessages(ctx, username)
+}
+
+func (r *offlineRep) DeleteOfflineMessages(ctx context.Context, username string) error {
+	return r.s.offline(username).DeleteOfflineMessages(ctx, username)
+}
+
+type blockListRep struct {
+	s *Storage
+}
+
+func (r *blockListRep) InsertBlockListItem(ctx context.Context, item *model.BlockListItem) error {
+	return r.s.blockList(item.Username).InsertBlockListItem(ctx, item)
+}
+
+func (r *blockListRep) DeleteBlockListItem(ctx context.Context, item *model.BlockListItem) error {
+	return r.s.blockList(item.Username).DeleteBlockListItem(ctx, item)
+}
+
+func (r *blockListRep) FetchBlockListItems(ctx context.Context, username string) ([]model.BlockListItem, error) {
+	return r.s.blockList(username).FetchBlockListItems(ctx, username)
+}
+
+type pushRep struct {
+	s *Storage
+}
+
+func (r *pushRep) UpsertPushRegistration(ctx context.Context, registration *model.PushRegistration) error {
+	return r.s.push(registration.Username).UpsertPushRegistration(ctx, registration)
+}
+
+func (r *pushRep) FetchPushRegistrations(ctx context.Context, username string) ([]model.PushRegistration, error) {
+	return r.s.push(username).FetchPushRegistrations(ctx, username)
+}
+
+func (r *pushRep) DeletePushRegistrations(ctx context.Context, username, jid, node string) error {
+	return r.s.push(username).DeletePushRegistrations(ctx, username, jid, node)
+}
+
+type fastTokenRep struct {
+	s *Storage
+}
+
+func (r *fastTokenRep) InsertFastToken(ctx context.Context, token *model.FastToken) error {
+	return r.s.fastToken(token.Username).InsertFastToken(ctx, token)
+}
+
+func (r *fastTokenRep) FetchFastTokens(ctx context.Context, username string) ([]model.FastToken, error) {
+	return r.s.fastToken(username).FetchFastTokens(ctx, username)
+}
+
+func (r *fastTokenRep) DeleteFastToken(ctx context.Context, username, token string) error {
+	return r.s.fastToken(username).DeleteFastToken(ctx, username, token)
+}
+
+func (r *fastTokenRep) DeleteFastTokens(ctx context.Context, username string) error {
+	return r.s.fastToken(username).DeleteFastTokens(ctx, username)
+}
+
+type pubSubRep struct {
+	repository.PubSub
+	s *Storage
+}
+
+func (r *pubSubRep) UpsertNode(ctx context.Context, node *pubsubmodel.Node) error {
+	if err := r.PubSub.UpsertNode(ctx, node); err != nil {
+		return err
+	}
+	// PEP nodes are hosted at their owner bare JID
+	r.s.trackString(node.Host, func(ud *userData) {
+		ud.nodes[entryKey{host: node.Host, name: node.Name}] = struct{}{}
+	})
+	return nil
+}
+
+func (r *pubSubRep) UpsertNodeAffiliation(ctx context.Context, affiliation *pubsubmodel.Affiliation, host, name string) error {
+	if err := r.PubSub.UpsertNodeAffiliation(ctx, affiliation, host, name); err != nil {
+		return err
+	}
+	r.s.trackString(affiliation.JID, func(ud *userData) {
+		ud.nodeAffiliations[entryKey{jid: affiliation.JID, host: host, name: name}] = struct{}{}
+	})
+	return nil
+}
+
+func (r *pubSubRep) UpsertNodeSubscription(ctx context.Context, subscription *pubsubmodel.Subscription, host, name string) error {
+	if err := r.PubSub.UpsertNodeSubscription(ctx, subscription, host, name); err != nil {
+		return err
+	}
+	r.s.trackString(subscription.JID, func(ud *userData) {
+		ud.subscriptions[entryKey{jid: subscription.JID, host: host, name: name}] = struct{}{}
+	})
+	return nil
+}
+
+type presencesRep struct {
+	repository.Presences
+	s *Storage
+}
+
+func (r *presencesRep) UpsertPresence(ctx context.Context, presence *xmpp.Presence, j *jid.JID, allocationID string) (bool, error) {
+	inserted, err := r.Presences.UpsertPresence(ctx, presence, j, allocationID)
+	if err != nil {
+		return false, err
+	}
+	r.s.track(j, func(ud *userData) {
+		ud.presences[j.String()] = j
+	})
+	return inserted, nil
+}
+
+type mucRep struct {
+	repository.Muc
+	s *Storage
+}
+
+func (r *mucRep) UpsertRoomAffiliation(ctx context.Context, affiliation *mucmodel.Affiliation, host, name string) error {
+	if err := r.Muc.UpsertRoomAffiliation(ctx, affiliation, host, name); err != nil {
+		return err
+	}
+	r.s.trackString(affiliation.JID, func(ud *userData) {
+		ud.roomAffiliations[entryKey{jid: affiliation.JID, host: host, name: name}] = struct{}{}
+	})
+	return nil
+}
@@ -0,0 +1,33 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package measured
+
+import (
+	"context"
+	"time"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage/repository"
+)
+
+type measuredArchive struct {
+	rep repository.Archive
+}
+
+func (m *measuredArchive) InsertArchivedMessage(ctx context.Context, message *model.ArchivedMessage) error {
+	defer observe("archive", "InsertArchivedMessage", time.Now())
+	return m.rep.InsertArchivedMessage(ctx, message)
+}
+
+func (m *measuredArchive) FetchArchivedMessages(ctx context.Context, username string, query *model.ArchiveQuery) ([]model.ArchivedMessage, error) {
+	defer observe("archive", "FetchArchivedMessages", time.Now())
+	return m.rep.FetchArchivedMessages(ctx, username, query)
+}
+
+func (m *measuredArchive) DeleteArchivedMessages(ctx context.Context, username string) error {
+	defer observe("archive", "DeleteArchivedMessages", time.Now())
+	return m.rep.DeleteArchivedMessages(ctx, username)
+}
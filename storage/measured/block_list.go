@@ -0,0 +1,33 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package measured
+
+import (
+	"context"
+	"time"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage/repository"
+)
+
+type measuredBlockList struct {
+	rep repository.BlockList
+}
+
+func (m *measuredBlockList) InsertBlockListItem(ctx context.Context, item *model.BlockListItem) error {
+	defer observe("block_list", "InsertBlockListItem", time.Now())
+	return m.rep.InsertBlockListItem(ctx, item)
+}
+
+func (m *measuredBlockList) DeleteBlockListItem(ctx context.Context, item *model.BlockListItem) error {
+	defer observe("block_list", "DeleteBlockListItem", time.Now())
+	return m.rep.DeleteBlockListItem(ctx, item)
+}
+
+func (m *measuredBlockList) FetchBlockListItems(ctx context.Context, username string) ([]model.BlockListItem, error) {
+	defer observe("block_list", "FetchBlockListItems", time.Now())
+	return m.rep.FetchBlockListItems(ctx, username)
+}
@@ -0,0 +1,38 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package measured
+
+import (
+	"context"
+	"time"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage/repository"
+)
+
+type measuredFastToken struct {
+	rep repository.FastToken
+}
+
+func (m *measuredFastToken) InsertFastToken(ctx context.Context, token *model.FastToken) error {
+	defer observe("fast_token", "InsertFastToken", time.Now())
+	return m.rep.InsertFastToken(ctx, token)
+}
+
+func (m *measuredFastToken) FetchFastTokens(ctx context.Context, username string) ([]model.FastToken, error) {
+	defer observe("fast_token", "FetchFastTokens", time.Now())
+	return m.rep.FetchFastTokens(ctx, username)
+}
+
+func (m *measuredFastToken) DeleteFastToken(ctx context.Context, username, token string) error {
+	defer observe("fast_token", "DeleteFastToken", time.Now())
+	return m.rep.DeleteFastToken(ctx, username, token)
+}
+
+func (m *measuredFastToken) DeleteFastTokens(ctx context.Context, username string) error {
+	defer observe("fast_token", "DeleteFastTokens", time.Now())
+	return m.rep.DeleteFastTokens(ctx, username)
+}
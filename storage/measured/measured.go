@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package measured
+
+import (
+	"context"
+	"time"
+
+	"github.com/ortuman/jackal/storage/repository"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "jackal_storage_query_duration_seconds",
+	Help:    "Storage query latency in seconds by repository method.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"repository", "method"})
+
+// Container wraps a repository container measuring every single repository method latency.
+type Container struct {
+	rep       repository.Container
+	user      repository.User
+	roster    repository.Roster
+	presences repository.Presences
+	vCard     repository.VCard
+	private   repository.Private
+	blockList repository.BlockList
+	pubSub    repository.PubSub
+	offline   repository.Offline
+	archive   repository.Archive
+	push      repository.Push
+	muc       repository.Muc
+	fastToken repository.FastToken
+}
+
+// New returns a new measured container wrapping c.
+func New(c repository.Container) *Container {
+	return &Container{
+		rep:       c,
+		user:      &measuredUser{rep: c.User()},
+		roster:    &measuredRoster{rep: c.Roster()},
+		presences: &measuredPresences{rep: c.Presences()},
+		vCard:     &measuredVCard{rep: c.VCard()},
+		private:   &measuredPrivate{rep: c.Private()},
+		blockList: &measuredBlockList{rep: c.BlockList()},
+		pubSub:    &measuredPubSub{rep: c.PubSub()},
+		offline:   &measuredOffline{rep: c.Offline()},
+		archive:   &measuredArchive{rep: c.Archive()},
+		push:      &measuredPush{rep: c.Push()},
+		muc:       &measuredMuc{rep: c.Muc()},
+		fastToken: &measuredFastToken{rep: c.FastToken()},
+	}
+}
+
+// User returns repository.User concrete implementation.
+func (c *Container) User() repository.User { return c.user }
+
+// Roster returns repository.Roster concrete implementation.
+func (c *Container) Roster() repository.Roster { return c.roster }
+
+// Presences returns repository.Presences concrete implementation.
+func (c *Container) Presences() repository.Presences { return c.presences }
+
+// VCard returns repository.VCard concrete implementation.
+func (c *Container) VCard() repository.VCard { return c.vCard }
+
+// Private returns repository.Private concrete implementation.
+func (c *Container) Private() repository.Private { return c.private }
+
+// BlockList returns repository.BlockList concrete implementation.
+func (c *Container) BlockList() repository.BlockList { return c.blockList }
+
+// PubSub returns repository.PubSub concrete implementation.
+func (c *Container) PubSub() repository.PubSub { return c.pubSub }
+
+// Offline returns repository.Offline concrete implementation.
+func (c *Container) Offline() repository.Offline { return c.offline }
+
+// Archive returns repository.Archive concrete implementation.
+func (c *Container) Archive() repository.Archive { return c.archive }
+
+// Push returns repository.Push concrete implementation.
+func (c *Container) Push() repository.Push { return c.push }
+
+// Muc returns repository.Muc concrete implementation.
+func (c *Container) Muc() repository.Muc { return c.muc }
+
+// FastToken returns repository.FastToken concrete implementation.
+func (c *Container) FastToken() repository.FastToken { return c.fastToken }
+
+// Close closes underlying storage resources.
+func (c *Container) Close(ctx context.Context) error { return c.rep.Close(ctx) }
+
+// IsClusterCompatible tells whether or not underlying container can be safely used across multiple cluster nodes.
+func (c *Container) IsClusterCompatible() bool { return c.rep.IsClusterCompatible() }
+
+func observe(repository, method string, start time.Time) {
+	queryDuration.WithLabelValues(repository, method).Observe(time.Since(start).Seconds())
+}
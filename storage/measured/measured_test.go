@@ -0,0 +1,42 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package measured
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ortuman/jackal/model"
+	memorystorage "github.com/ortuman/jackal/storage/memory"
+	"github.com/ortuman/jackal/storage/repository"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeasured_Container(t *testing.T) {
+	s, _ := memorystorage.New()
+
+	var c repository.Container = New(s)
+
+	fetchCount := tUtilQueryCount(t, "user", "FetchUser")
+	upsertCount := tUtilQueryCount(t, "user", "UpsertUser")
+
+	require.Nil(t, c.User().UpsertUser(context.Background(), &model.User{Username: "ortuman"}))
+	usr, err := c.User().FetchUser(context.Background(), "ortuman")
+	require.Nil(t, err)
+	require.Equal(t, "ortuman", usr.Username)
+
+	require.Equal(t, upsertCount+1, tUtilQueryCount(t, "user", "UpsertUser"))
+	require.Equal(t, fetchCount+1, tUtilQueryCount(t, "user", "FetchUser"))
+	require.Equal(t, s.IsClusterCompatible(), c.IsClusterCompatible())
+}
+
+func tUtilQueryCount(t *testing.T, repository, method string) uint64 {
+	var m dto.Metric
+	require.Nil(t, queryDuration.WithLabelValues(repository, method).(prometheus.Histogram).Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package measured
+
+import (
+	"context"
+	"time"
+
+	mucmodel "github.com/ortuman/jackal/model/muc"
+	"github.com/ortuman/jackal/storage/repository"
+)
+
+type measuredMuc struct {
+	rep repository.Muc
+}
+
+func (m *measuredMuc) UpsertRoom(ctx context.Context, room *mucmodel.Room) error {
+	defer observe("muc", "UpsertRoom", time.Now())
+	return m.rep.UpsertRoom(ctx, room)
+}
+
+func (m *measuredMuc) FetchRoom(ctx context.Context, host, name string) (*mucmodel.Room, error) {
+	defer observe("muc", "FetchRoom", time.Now())
+	return m.rep.FetchRoom(ctx, host, name)
+}
+
+func (m *measuredMuc) FetchRooms(ctx context.Context, host string) ([]mucmodel.Room, error) {
+	defer observe("muc", "FetchRooms", time.Now())
+	return m.rep.FetchRooms(ctx, host)
+}
+
+func (m *measuredMuc) DeleteRoom(ctx context.Context, host, name string) error {
+	defer observe("muc", "DeleteRoom", time.Now())
+	return m.rep.DeleteRoom(ctx, host, name)
+}
+
+func (m *measuredMuc) UpsertRoomAffiliation(ctx context.Context, affiliation *mucmodel.Affiliation, host, name string) error {
+	defer observe("muc", "UpsertRoomAffiliation", time.Now())
+	return m.rep.UpsertRoomAffiliation(ctx, affiliation, host, name)
+}
+
+func (m *measuredMuc) DeleteRoomAffiliation(ctx context.Context, jid, host, name string) error {
+	defer observe("muc", "DeleteRoomAffiliation", time.Now())
+	return m.rep.DeleteRoomAffiliation(ctx, jid, host, name)
+}
+
+func (m *measuredMuc) FetchRoomAffiliations(ctx context.Context, host, name string) ([]mucmodel.Affiliation, error) {
+	defer observe("muc", "FetchRoomAffiliations", time.Now())
+	return m.rep.FetchRoomAffiliations(ctx, host, name)
+}
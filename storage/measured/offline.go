@@ -0,0 +1,38 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package measured
+
+import (
+	"context"
+	"time"
+
+	"github.com/ortuman/jackal/storage/repository"
+	"github.com/ortuman/jackal/xmpp"
+)
+
+type measuredOffline struct {
+	rep repository.Offline
+}
+
+func (m *measuredOffline) InsertOfflineMessage(ctx context.Context, message *xmpp.Message, username string) error {
+	defer observe("offline", "InsertOfflineMessage", time.Now())
+	return m.rep.InsertOfflineMessage(ctx, message, username)
+}
+
+func (m *measuredOffline) CountOfflineMessages(ctx context.Context, username string) (int, error) {
+	defer observe("offline", "CountOfflineMessages", time.Now())
+	return m.rep.CountOfflineMessages(ctx, username)
+}
+
+func (m *measuredOffline) FetchOfflineMessages(ctx context.Context, username string) ([]xmpp.Message, error) {
+	defer observe("offline", "FetchOfflineMessages", time.Now())
+	return m.rep.FetchOfflineMessages(ctx, username)
+}
+
+func (m *measuredOffline) DeleteOfflineMessages(ctx context.Context, username string) error {
+	defer observe("offline", "DeleteOfflineMessages", time.Now())
+	return m.rep.DeleteOfflineMessages(ctx, username)
+}
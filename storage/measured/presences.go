@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package measured
+
+import (
+	"context"
+	"time"
+
+	capsmodel "github.com/ortuman/jackal/model/capabilities"
+	"github.com/ortuman/jackal/storage/repository"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+type measuredPresences struct {
+	rep repository.Presences
+}
+
+func (m *measuredPresences) UpsertPresence(ctx context.Context, presence *xmpp.Presence, jid *jid.JID, allocationID string) (inserted bool, err error) {
+	defer observe("presences", "UpsertPresence", time.Now())
+	return m.rep.UpsertPresence(ctx, presence, jid, allocationID)
+}
+
+func (m *measuredPresences) FetchPresence(ctx context.Context, jid *jid.JID) (*capsmodel.PresenceCaps, error) {
+	defer observe("presences", "FetchPresence", time.Now())
+	return m.rep.FetchPresence(ctx, jid)
+}
+
+func (m *measuredPresences) FetchPresencesMatchingJID(ctx context.Context, jid *jid.JID) ([]capsmodel.PresenceCaps, error) {
+	defer observe("presences", "FetchPresencesMatchingJID", time.Now())
+	return m.rep.FetchPresencesMatchingJID(ctx, jid)
+}
+
+func (m *measuredPresences) DeletePresence(ctx context.Context, jid *jid.JID) error {
+	defer observe("presences", "DeletePresence", time.Now())
+	return m.rep.DeletePresence(ctx, jid)
+}
+
+func (m *measuredPresences) DeleteAllocationPresences(ctx context.Context, allocationID string) error {
+	defer observe("presences", "DeleteAllocationPresences", time.Now())
+	return m.rep.DeleteAllocationPresences(ctx, allocationID)
+}
+
+func (m *measuredPresences) ClearPresences(ctx context.Context) error {
+	defer observe("presences", "ClearPresences", time.Now())
+	return m.rep.ClearPresences(ctx)
+}
+
+func (m *measuredPresences) UpsertCapabilities(ctx context.Context, caps *capsmodel.Capabilities) error {
+	defer observe("presences", "UpsertCapabilities", time.Now())
+	return m.rep.UpsertCapabilities(ctx, caps)
+}
+
+func (m *measuredPresences) FetchCapabilities(ctx context.Context, node, ver string) (*capsmodel.Capabilities, error) {
+	defer observe("presences", "FetchCapabilities", time.Now())
+	return m.rep.FetchCapabilities(ctx, node, ver)
+}
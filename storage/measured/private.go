@@ -0,0 +1,28 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package measured
+
+import (
+	"context"
+	"time"
+
+	"github.com/ortuman/jackal/storage/repository"
+	"github.com/ortuman/jackal/xmpp"
+)
+
+type measuredPrivate struct {
+	rep repository.Private
+}
+
+func (m *measuredPrivate) FetchPrivateXML(ctx context.Context, namespace string, username string) ([]xmpp.XElement, error) {
+	defer observe("private", "FetchPrivateXML", time.Now())
+	return m.rep.FetchPrivateXML(ctx, namespace, username)
+}
+
+func (m *measuredPrivate) UpsertPrivateXML(ctx context.Context, privateXML []xmpp.XElement, namespace string, username string) error {
+	defer observe("private", "UpsertPrivateXML", time.Now())
+	return m.rep.UpsertPrivateXML(ctx, privateXML, namespace, username)
+}
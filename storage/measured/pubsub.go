@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package measured
+
+import (
+	"context"
+	"time"
+
+	pubsubmodel "github.com/ortuman/jackal/model/pubsub"
+	"github.com/ortuman/jackal/storage/repository"
+)
+
+type measuredPubSub struct {
+	rep repository.PubSub
+}
+
+func (m *measuredPubSub) FetchHosts(ctx context.Context) (hosts []string, err error) {
+	defer observe("pubsub", "FetchHosts", time.Now())
+	return m.rep.FetchHosts(ctx)
+}
+
+func (m *measuredPubSub) UpsertNode(ctx context.Context, node *pubsubmodel.Node) error {
+	defer observe("pubsub", "UpsertNode", time.Now())
+	return m.rep.UpsertNode(ctx, node)
+}
+
+func (m *measuredPubSub) FetchNode(ctx context.Context, host, name string) (*pubsubmodel.Node, error) {
+	defer observe("pubsub", "FetchNode", time.Now())
+	return m.rep.FetchNode(ctx, host, name)
+}
+
+func (m *measuredPubSub) FetchNodes(ctx context.Context, host string) ([]pubsubmodel.Node, error) {
+	defer observe("pubsub", "FetchNodes", time.Now())
+	return m.rep.FetchNodes(ctx, host)
+}
+
+func (m *measuredPubSub) FetchSubscribedNodes(ctx context.Context, jid string) ([]pubsubmodel.Node, error) {
+	defer observe("pubsub", "FetchSubscribedNodes", time.Now())
+	return m.rep.FetchSubscribedNodes(ctx, jid)
+}
+
+func (m *measuredPubSub) DeleteNode(ctx context.Context, host, name string) error {
+	defer observe("pubsub", "DeleteNode", time.Now())
+	return m.rep.DeleteNode(ctx, host, name)
+}
+
+func (m *measuredPubSub) UpsertNodeItem(ctx context.Context, item *pubsubmodel.Item, host, name string, maxNodeItems int) error {
+	defer observe("pubsub", "UpsertNodeItem", time.Now())
+	return m.rep.UpsertNodeItem(ctx, item, host, name, maxNodeItems)
+}
+
+func (m *measuredPubSub) FetchNodeItems(ctx context.Context, host, name string) ([]pubsubmodel.Item, error) {
+	defer observe("pubsub", "FetchNodeItems", time.Now())
+	return m.rep.FetchNodeItems(ctx, host, name)
+}
+
+func (m *measuredPubSub) FetchNodeItemsWithIDs(ctx context.Context, host, name string, identifiers []string) ([]pubsubmodel.Item, error) {
+	defer observe("pubsub", "FetchNodeItemsWithIDs", time.Now())
+	return m.rep.FetchNodeItemsWithIDs(ctx, host, name, identifiers)
+}
+
+func (m *measuredPubSub) FetchNodeLastItem(ctx context.Context, host, name string) (*pubsubmodel.Item, error) {
+	defer observe("pubsub", "FetchNodeLastItem", time.Now())
+	return m.rep.FetchNodeLastItem(ctx, host, name)
+}
+
+func (m *measuredPubSub) UpsertNodeAffiliation(ctx context.Context, affiliation *pubsubmodel.Affiliation, host, name string) error {
+	defer observe("pubsub", "UpsertNodeAffiliation", time.Now())
+	return m.rep.UpsertNodeAffiliation(ctx, affiliation, host, name)
+}
+
+func (m *measuredPubSub) FetchNodeAffiliation(ctx context.Context, host, name, jid string) (*pubsubmodel.Affiliation, error) {
+	defer observe("pubsub", "FetchNodeAffiliation", time.Now())
+	return m.rep.FetchNodeAffiliation(ctx, host, name, jid)
+}
+
+func (m *measuredPubSub) FetchNodeAffiliations(ctx context.Context, host, name string) ([]pubsubmodel.Affiliation, error) {
+	defer observe("pubsub", "FetchNodeAffiliations", time.Now())
+	return m.rep.FetchNodeAffiliations(ctx, host, name)
+}
+
+func (m *measuredPubSub) DeleteNodeAffiliation(ctx context.Context, jid, host, name string) error {
+	defer observe("pubsub", "DeleteNodeAffiliation", time.Now())
+	return m.rep.DeleteNodeAffiliation(ctx, jid, host, name)
+}
+
+func (m *measuredPubSub) UpsertNodeSubscription(ctx context.Context, subscription *pubsubmodel.Subscription, host, name string) error {
+	defer observe("pubsub", "UpsertNodeSubscription", time.Now())
+	return m.rep.UpsertNodeSubscription(ctx, subscription, host, name)
+}
+
+func (m *measuredPubSub) FetchNodeSubscriptions(ctx context.Context, host, name string) ([]pubsubmodel.Subscription, error) {
+	defer observe("pubsub", "FetchNodeSubscriptions", time.Now())
+	return m.rep.FetchNodeSubscriptions(ctx, host, name)
+}
+
+func (m *measuredPubSub) DeleteNodeSubscription(ctx context.Context, jid, host, name string) error {
+	defer observe("pubsub", "DeleteNodeSubscription", time.Now())
+	return m.rep.DeleteNodeSubscription(ctx, jid, host, name)
+}
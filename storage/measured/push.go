@@ -0,0 +1,33 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package measured
+
+import (
+	"context"
+	"time"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage/repository"
+)
+
+type measuredPush struct {
+	rep repository.Push
+}
+
+func (m *measuredPush) UpsertPushRegistration(ctx context.Context, registration *model.PushRegistration) error {
+	defer observe("push", "UpsertPushRegistration", time.Now())
+	return m.rep.UpsertPushRegistration(ctx, registration)
+}
+
+func (m *measuredPush) FetchPushRegistrations(ctx context.Context, username string) ([]model.PushRegistration, error) {
+	defer observe("push", "FetchPushRegistrations", time.Now())
+	return m.rep.FetchPushRegistrations(ctx, username)
+}
+
+func (m *measuredPush) DeletePushRegistrations(ctx context.Context, username, jid, node string) error {
+	defer observe("push", "DeletePushRegistrations", time.Now())
+	return m.rep.DeletePushRegistrations(ctx, username, jid, node)
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package measured
+
+import (
+	"context"
+	"time"
+
+	rostermodel "github.com/ortuman/jackal/model/roster"
+	"github.com/ortuman/jackal/storage/repository"
+)
+
+type measuredRoster struct {
+	rep repository.Roster
+}
+
+func (m *measuredRoster) UpsertRosterItem(ctx context.Context, ri *rostermodel.Item) (rostermodel.Version, error) {
+	defer observe("roster", "UpsertRosterItem", time.Now())
+	return m.rep.UpsertRosterItem(ctx, ri)
+}
+
+func (m *measuredRoster) DeleteRosterItem(ctx context.Context, username, jid string) (rostermodel.Version, error) {
+	defer observe("roster", "DeleteRosterItem", time.Now())
+	return m.rep.DeleteRosterItem(ctx, username, jid)
+}
+
+func (m *measuredRoster) FetchRosterItems(ctx context.Context, username string) ([]rostermodel.Item, rostermodel.Version, error) {
+	defer observe("roster", "FetchRosterItems", time.Now())
+	return m.rep.FetchRosterItems(ctx, username)
+}
+
+func (m *measuredRoster) FetchRosterItemsInGroups(ctx context.Context, username string, groups []string) ([]rostermodel.Item, rostermodel.Version, error) {
+	defer observe("roster", "FetchRosterItemsInGroups", time.Now())
+	return m.rep.FetchRosterItemsInGroups(ctx, username, groups)
+}
+
+func (m *measuredRoster) FetchRosterItem(ctx context.Context, username, jid string) (*rostermodel.Item, error) {
+	defer observe("roster", "FetchRosterItem", time.Now())
+	return m.rep.FetchRosterItem(ctx, username, jid)
+}
+
+func (m *measuredRoster) UpsertRosterNotification(ctx context.Context, rn *rostermodel.Notification) error {
+	defer observe("roster", "UpsertRosterNotification", time.Now())
+	return m.rep.UpsertRosterNotification(ctx, rn)
+}
+
+func (m *measuredRoster) DeleteRosterNotification(ctx context.Context, contact, jid string) error {
+	defer observe("roster", "DeleteRosterNotification", time.Now())
+	return m.rep.DeleteRosterNotification(ctx, contact, jid)
+}
+
+func (m *measuredRoster) FetchRosterNotification(ctx context.Context, contact string, jid string) (*rostermodel.Notification, error) {
+	defer observe("roster", "FetchRosterNotification", time.Now())
+	return m.rep.FetchRosterNotification(ctx, contact, jid)
+}
+
+func (m *measuredRoster) FetchRosterNotifications(ctx context.Context, contact string) ([]rostermodel.Notification, error) {
+	defer observe("roster", "FetchRosterNotifications", time.Now())
+	return m.rep.FetchRosterNotifications(ctx, contact)
+}
+
+func (m *measuredRoster) FetchRosterGroups(ctx context.Context, username string) ([]string, error) {
+	defer observe("roster", "FetchRosterGroups", time.Now())
+	return m.rep.FetchRosterGroups(ctx, username)
+}
@@ -0,0 +1,38 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package measured
+
+import (
+	"context"
+	"time"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage/repository"
+)
+
+type measuredUser struct {
+	rep repository.User
+}
+
+func (m *measuredUser) UpsertUser(ctx context.Context, user *model.User) error {
+	defer observe("user", "UpsertUser", time.Now())
+	return m.rep.UpsertUser(ctx, user)
+}
+
+func (m *measuredUser) DeleteUser(ctx context.Context, username string) error {
+	defer observe("user", "DeleteUser", time.Now())
+	return m.rep.DeleteUser(ctx, username)
+}
+
+func (m *measuredUser) FetchUser(ctx context.Context, username string) (*model.User, error) {
+	defer observe("user", "FetchUser", time.Now())
+	return m.rep.FetchUser(ctx, username)
+}
+
+func (m *measuredUser) UserExists(ctx context.Context, username string) (bool, error) {
+	defer observe("user", "UserExists", time.Now())
+	return m.rep.UserExists(ctx, username)
+}
@@ -0,0 +1,28 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package measured
+
+import (
+	"context"
+	"time"
+
+	"github.com/ortuman/jackal/storage/repository"
+	"github.com/ortuman/jackal/xmpp"
+)
+
+type measuredVCard struct {
+	rep repository.VCard
+}
+
+func (m *measuredVCard) UpsertVCard(ctx context.Context, vCard xmpp.XElement, username string) error {
+	defer observe("vcard", "UpsertVCard", time.Now())
+	return m.rep.UpsertVCard(ctx, vCard, username)
+}
+
+func (m *measuredVCard) FetchVCard(ctx context.Context, username string) (xmpp.XElement, error) {
+	defer observe("vcard", "FetchVCard", time.Now())
+	return m.rep.FetchVCard(ctx, username)
+}
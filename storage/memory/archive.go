@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memorystorage
+
+import (
+	"context"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/model/serializer"
+)
+
+// Archive represents an in-memory message archive storage.
+type Archive struct {
+	*memoryStorage
+}
+
+// NewArchive returns an instance of Archive in-memory storage.
+func NewArchive() *Archive {
+	return &Archive{memoryStorage: newStorage()}
+}
+
+// InsertArchivedMessage inserts a new message into user's archive.
+func (m *Archive) InsertArchivedMessage(_ context.Context, message *model.ArchivedMessage) error {
+	return m.updateInWriteLock(archiveKey(message.Username), func(b []byte) ([]byte, error) {
+		var messages []model.ArchivedMessage
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &messages); err != nil {
+				return nil, err
+			}
+		}
+		messages = append(messages, *message)
+
+		b, err := serializer.SerializeSlice(&messages)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	})
+}
+
+// FetchArchivedMessages retrieves from storage user's archived messages matching a given query.
+func (m *Archive) FetchArchivedMessages(_ context.Context, username string, query *model.ArchiveQuery) ([]model.ArchivedMessage, error) {
+	var messages []model.ArchivedMessage
+	if _, err := m.getEntities(archiveKey(username), &messages); err != nil {
+		return nil, err
+	}
+	from, to := 0, len(messages)
+	for i, msg := range messages {
+		if len(query.After) > 0 && msg.ID == query.After {
+			from = i + 1
+		}
+		if len(query.Before) > 0 && msg.ID == query.Before {
+			to = i
+		}
+	}
+	if to < from {
+		to = from
+	}
+	var res []model.ArchivedMessage
+	for _, msg := range messages[from:to] {
+		if len(query.With) > 0 && msg.With != query.With {
+			continue
+		}
+		if !query.Start.IsZero() && msg.Stamp.Before(query.Start) {
+			continue
+		}
+		if !query.End.IsZero() && msg.Stamp.After(query.End) {
+			continue
+		}
+		res = append(res, msg)
+	}
+	if query.Max > 0 && len(res) > query.Max {
+		if query.IsBackwards() {
+			res = res[len(res)-query.Max:]
+		} else {
+			res = res[:query.Max]
+		}
+	}
+	return res, nil
+}
+
+// DeleteArchivedMessages clears user's archive.
+func (m *Archive) DeleteArchivedMessages(_ context.Context, username string) error {
+	return m.deleteKey(archiveKey(username))
+}
+
+func archiveKey(username string) string {
+	return "archive:" + username
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memorystorage
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorage_InsertArchivedMessage(t *testing.T) {
+	s := NewArchive()
+	am := tUtilArchivedMessage(0, "noelia@jackal.im", time.Now())
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.InsertArchivedMessage(context.Background(), am))
+	DisableMockedError()
+
+	require.Nil(t, s.InsertArchivedMessage(context.Background(), am))
+}
+
+func TestMemoryStorage_FetchArchivedMessages(t *testing.T) {
+	s := NewArchive()
+
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		with := "noelia@jackal.im"
+		if i%2 == 1 {
+			with = "romeo@jackal.im"
+		}
+		_ = s.InsertArchivedMessage(context.Background(), tUtilArchivedMessage(i, with, now.Add(time.Duration(i)*time.Minute)))
+	}
+
+	EnableMockedError()
+	_, err := s.FetchArchivedMessages(context.Background(), "ortuman", &model.ArchiveQuery{})
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	msgs, _ := s.FetchArchivedMessages(context.Background(), "ortuman", &model.ArchiveQuery{})
+	require.Len(t, msgs, 10)
+
+	msgs, _ = s.FetchArchivedMessages(context.Background(), "ortuman", &model.ArchiveQuery{With: "romeo@jackal.im"})
+	require.Len(t, msgs, 5)
+	require.Equal(t, "1", msgs[0].ID)
+
+	msgs, _ = s.FetchArchivedMessages(context.Background(), "ortuman", &model.ArchiveQuery{
+		Start: now.Add(time.Minute * 2),
+		End:   now.Add(time.Minute * 4),
+	})
+	require.Len(t, msgs, 3)
+	require.Equal(t, "2", msgs[0].ID)
+
+	// paging
+	msgs, _ = s.FetchArchivedMessages(context.Background(), "ortuman", &model.ArchiveQuery{Max: 3})
+	require.Len(t, msgs, 3)
+	require.Equal(t, "0", msgs[0].ID)
+
+	msgs, _ = s.FetchArchivedMessages(context.Background(), "ortuman", &model.ArchiveQuery{After: "2", Max: 3})
+	require.Len(t, msgs, 3)
+	require.Equal(t, "3", msgs[0].ID)
+
+	msgs, _ = s.FetchArchivedMessages(context.Background(), "ortuman", &model.ArchiveQuery{Before: "7", Max: 2})
+	require.Len(t, msgs, 2)
+	require.Equal(t, "5", msgs[0].ID)
+	require.Equal(t, "6", msgs[1].ID)
+
+	msgs, _ = s.FetchArchivedMessages(context.Background(), "ortuman", &model.ArchiveQuery{LastPage: true, Max: 2})
+	require.Len(t, msgs, 2)
+	require.Equal(t, "8", msgs[0].ID)
+
+	msgs, _ = s.FetchArchivedMessages(context.Background(), "noelia", &model.ArchiveQuery{})
+	require.Len(t, msgs, 0)
+}
+
+func TestMemoryStorage_DeleteArchivedMessages(t *testing.T) {
+	s := NewArchive()
+	_ = s.InsertArchivedMessage(context.Background(), tUtilArchivedMessage(0, "noelia@jackal.im", time.Now()))
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.DeleteArchivedMessages(context.Background(), "ortuman"))
+	DisableMockedError()
+	require.Nil(t, s.DeleteArchivedMessages(context.Background(), "ortuman"))
+
+	msgs, _ := s.FetchArchivedMessages(context.Background(), "ortuman", &model.ArchiveQuery{})
+	require.Len(t, msgs, 0)
+}
+
+func tUtilArchivedMessage(i int, with string, stamp time.Time) *model.ArchivedMessage {
+	j1, _ := jid.NewWithString("ortuman@jackal.im/balcony", true)
+	j2, _ := jid.NewWithString(with, true)
+
+	msg := xmpp.NewMessageType(strconv.Itoa(i), xmpp.ChatType)
+	msg.SetFromJID(j1)
+	msg.SetToJID(j2)
+	return &model.ArchivedMessage{
+		Username: "ortuman",
+		ID:       strconv.Itoa(i),
+		With:     with,
+		Message:  msg,
+		Stamp:    stamp,
+	}
+}
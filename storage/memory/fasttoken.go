@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memorystorage
+
+import (
+	"context"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/model/serializer"
+)
+
+// FastToken represents an in-memory FAST authentication tokens storage.
+type FastToken struct {
+	*memoryStorage
+}
+
+// NewFastToken returns an instance of FastToken in-memory storage.
+func NewFastToken() *FastToken {
+	return &FastToken{memoryStorage: newStorage()}
+}
+
+// InsertFastToken inserts a new authentication token into storage.
+func (m *FastToken) InsertFastToken(_ context.Context, token *model.FastToken) error {
+	return m.updateInWriteLock(fastTokensKey(token.Username), func(b []byte) ([]byte, error) {
+		var tokens []model.FastToken
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &tokens); err != nil {
+				return nil, err
+			}
+		}
+		tokens = append(tokens, *token)
+		return serializer.SerializeSlice(&tokens)
+	})
+}
+
+// FetchFastTokens retrieves from storage all authentication tokens associated to a given user.
+func (m *FastToken) FetchFastTokens(_ context.Context, username string) ([]model.FastToken, error) {
+	var tokens []model.FastToken
+	if _, err := m.getEntities(fastTokensKey(username), &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// DeleteFastToken deletes a user authentication token.
+func (m *FastToken) DeleteFastToken(_ context.Context, username, token string) error {
+	return m.updateInWriteLock(fastTokensKey(username), func(b []byte) ([]byte, error) {
+		var tokens []model.FastToken
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &tokens); err != nil {
+				return nil, err
+			}
+		}
+		var res []model.FastToken
+		for _, t := range tokens {
+			if t.Token == token {
+				continue
+			}
+			res = append(res, t)
+		}
+		return serializer.SerializeSlice(&res)
+	})
+}
+
+// DeleteFastTokens deletes all authentication tokens associated to a given user.
+func (m *FastToken) DeleteFastTokens(_ context.Context, username string) error {
+	return m.deleteKey(fastTokensKey(username))
+}
+
+func fastTokensKey(username string) string {
+	return "fastTokens:" + username
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memorystorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorage_InsertFastToken(t *testing.T) {
+	s := NewFastToken()
+	token := &model.FastToken{Username: "ortuman", ClientID: "c1", Mechanism: "HT-SHA-256-NONE", Token: "t1", ExpiresAt: time.Now().Add(time.Hour)}
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.InsertFastToken(context.Background(), token))
+	DisableMockedError()
+
+	require.Nil(t, s.InsertFastToken(context.Background(), token))
+
+	tokens, _ := s.FetchFastTokens(context.Background(), "ortuman")
+	require.Len(t, tokens, 1)
+	require.Equal(t, "c1", tokens[0].ClientID)
+}
+
+func TestMemoryStorage_FetchFastTokens(t *testing.T) {
+	s := NewFastToken()
+	_ = s.InsertFastToken(context.Background(), &model.FastToken{Username: "ortuman", ClientID: "c1", Token: "t1"})
+	_ = s.InsertFastToken(context.Background(), &model.FastToken{Username: "ortuman", ClientID: "c2", Token: "t2"})
+
+	EnableMockedError()
+	_, err := s.FetchFastTokens(context.Background(), "ortuman")
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	tokens, _ := s.FetchFastTokens(context.Background(), "ortuman")
+	require.Len(t, tokens, 2)
+
+	tokens, _ = s.FetchFastTokens(context.Background(), "noelia")
+	require.Len(t, tokens, 0)
+}
+
+func TestMemoryStorage_DeleteFastTokens(t *testing.T) {
+	s := NewFastToken()
+	_ = s.InsertFastToken(context.Background(), &model.FastToken{Username: "ortuman", ClientID: "c1", Token: "t1"})
+	_ = s.InsertFastToken(context.Background(), &model.FastToken{Username: "ortuman", ClientID: "c2", Token: "t2"})
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.DeleteFastToken(context.Background(), "ortuman", "t1"))
+	DisableMockedError()
+
+	require.Nil(t, s.DeleteFastToken(context.Background(), "ortuman", "t1"))
+	tokens, _ := s.FetchFastTokens(context.Background(), "ortuman")
+	require.Len(t, tokens, 1)
+	require.Equal(t, "t2", tokens[0].Token)
+
+	require.Nil(t, s.DeleteFastTokens(context.Background(), "ortuman"))
+	tokens, _ = s.FetchFastTokens(context.Background(), "ortuman")
+	require.Len(t, tokens, 0)
+}
@@ -20,6 +20,10 @@ type memoryContainer struct {
 	blockList *BlockList
 	pubSub    *PubSub
 	offline   *Offline
+	archive   *Archive
+	push      *Push
+	muc       *Muc
+	fastToken *FastToken
 }
 
 // New initializes in-memory storage and returns associated container.
@@ -34,6 +38,10 @@ func New() (repository.Container, error) {
 	c.blockList = NewBlockList()
 	c.pubSub = NewPubSub()
 	c.offline = NewOffline()
+	c.archive = NewArchive()
+	c.push = NewPush()
+	c.muc = NewMuc()
+	c.fastToken = NewFastToken()
 
 	return &c, nil
 }
@@ -46,6 +54,10 @@ func (c *memoryContainer) Private() repository.Private     { return c.priv }
 func (c *memoryContainer) BlockList() repository.BlockList { return c.blockList }
 func (c *memoryContainer) PubSub() repository.PubSub       { return c.pubSub }
 func (c *memoryContainer) Offline() repository.Offline     { return c.offline }
+func (c *memoryContainer) Archive() repository.Archive     { return c.archive }
+func (c *memoryContainer) Push() repository.Push           { return c.push }
+func (c *memoryContainer) Muc() repository.Muc             { return c.muc }
+func (c *memoryContainer) FastToken() repository.FastToken { return c.fastToken }
 
 func (c *memoryContainer) Close(_ context.Context) error { return nil }
 
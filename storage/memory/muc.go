@@ -0,0 +1,157 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memorystorage
+
+import (
+	"context"
+
+	mucmodel "github.com/ortuman/jackal/model/muc"
+	"github.com/ortuman/jackal/model/serializer"
+)
+
+// Muc represents an in-memory multi-user chat storage.
+type Muc struct {
+	*memoryStorage
+}
+
+// NewMuc returns an instance of Muc in-memory storage.
+func NewMuc() *Muc {
+	return &Muc{memoryStorage: newStorage()}
+}
+
+// UpsertRoom inserts a new room entity into storage, or updates it if previously inserted.
+func (m *Muc) UpsertRoom(_ context.Context, room *mucmodel.Room) error {
+	return m.updateInWriteLock(mucRoomsKey(room.Host), func(b []byte) ([]byte, error) {
+		var rooms []mucmodel.Room
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &rooms); err != nil {
+				return nil, err
+			}
+		}
+		var updated bool
+		for i, r := range rooms {
+			if r.Name == room.Name {
+				rooms[i] = *room
+				updated = true
+				break
+			}
+		}
+		if !updated {
+			rooms = append(rooms, *room)
+		}
+		return serializer.SerializeSlice(&rooms)
+	})
+}
+
+// FetchRoom retrieves from storage a room entity.
+func (m *Muc) FetchRoom(ctx context.Context, host, name string) (*mucmodel.Room, error) {
+	rooms, err := m.FetchRooms(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, room := range rooms {
+		if room.Name == name {
+			return &room, nil
+		}
+	}
+	return nil, nil
+}
+
+// FetchRooms retrieves from storage all room entities associated with a host.
+func (m *Muc) FetchRooms(_ context.Context, host string) ([]mucmodel.Room, error) {
+	var rooms []mucmodel.Room
+	if _, err := m.getEntities(mucRoomsKey(host), &rooms); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+// DeleteRoom deletes a room and all its affiliations from storage.
+func (m *Muc) DeleteRoom(_ context.Context, host, name string) error {
+	return m.inWriteLock(func() error {
+		var rooms []mucmodel.Room
+		if b := m.b[mucRoomsKey(host)]; len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &rooms); err != nil {
+				return err
+			}
+		}
+		var res []mucmodel.Room
+		for _, room := range rooms {
+			if room.Name == name {
+				continue
+			}
+			res = append(res, room)
+		}
+		b, err := serializer.SerializeSlice(&res)
+		if err != nil {
+			return err
+		}
+		m.b[mucRoomsKey(host)] = b
+		delete(m.b, mucAffiliationsKey(host, name))
+		return nil
+	})
+}
+
+// UpsertRoomAffiliation inserts a new room affiliation into storage, or updates it if previously inserted.
+func (m *Muc) UpsertRoomAffiliation(_ context.Context, affiliation *mucmodel.Affiliation, host, name string) error {
+	return m.updateInWriteLock(mucAffiliationsKey(host, name), func(b []byte) ([]byte, error) {
+		var affiliations []mucmodel.Affiliation
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &affiliations); err != nil {
+				return nil, err
+			}
+		}
+		var updated bool
+		for i, aff := range affiliations {
+			if aff.JID == affiliation.JID {
+				affiliations[i] = *affiliation
+				updated = true
+				break
+			}
+		}
+		if !updated {
+			affiliations = append(affiliations, *affiliation)
+		}
+		return serializer.SerializeSlice(&affiliations)
+	})
+}
+
+// DeleteRoomAffiliation deletes a room affiliation from storage.
+func (m *Muc) DeleteRoomAffiliation(_ context.Context, jid, host, name string) error {
+	return m.updateInWriteLock(mucAffiliationsKey(host, name), func(b []byte) ([]byte, error) {
+		var affiliations []mucmodel.Affiliation
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &affiliations); err != nil {
+				return nil, err
+			}
+		}
+		var res []mucmodel.Affiliation
+		for _, aff := range affiliations {
+			if aff.JID == jid {
+				continue
+			}
+			res = append(res, aff)
+		}
+		return serializer.SerializeSlice(&res)
+	})
+}
+
+// FetchRoomAffiliations retrieves all affiliations associated to a room.
+func (m *Muc) FetchRoomAffiliations(_ context.Context, host, name string) ([]mucmodel.Affiliation, error) {
+	var affiliations []mucmodel.Affiliation
+	if _, err := m.getEntities(mucAffiliationsKey(host, name), &affiliations); err != nil {
+		return nil, err
+	}
+	return affiliations, nil
+}
+
+func mucRoomsKey(host string) string {
+	return "mucRooms:" + host
+}
+
+func mucAffiliationsKey(host, name string) string {
+	return "mucAffiliations:" + host + ":" + name
+}
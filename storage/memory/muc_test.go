@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memorystorage
+
+import (
+	"context"
+	"testing"
+
+	mucmodel "github.com/ortuman/jackal/model/muc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorage_UpsertRoom(t *testing.T) {
+	s := NewMuc()
+	room := &mucmodel.Room{Host: "conference.jackal.im", Name: "lounge", Config: mucmodel.DefaultConfig()}
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.UpsertRoom(context.Background(), room))
+	DisableMockedError()
+
+	require.Nil(t, s.UpsertRoom(context.Background(), room))
+
+	room.Subject = "Welcome!"
+	require.Nil(t, s.UpsertRoom(context.Background(), room))
+
+	rooms, _ := s.FetchRooms(context.Background(), "conference.jackal.im")
+	require.Len(t, rooms, 1)
+	require.Equal(t, "Welcome!", rooms[0].Subject)
+}
+
+func TestMemoryStorage_FetchRoom(t *testing.T) {
+	s := NewMuc()
+	_ = s.UpsertRoom(context.Background(), &mucmodel.Room{Host: "conference.jackal.im", Name: "lounge"})
+	_ = s.UpsertRoom(context.Background(), &mucmodel.Room{Host: "conference.jackal.im", Name: "garden"})
+
+	EnableMockedError()
+	_, err := s.FetchRoom(context.Background(), "conference.jackal.im", "lounge")
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	room, err := s.FetchRoom(context.Background(), "conference.jackal.im", "garden")
+	require.Nil(t, err)
+	require.NotNil(t, room)
+	require.Equal(t, "garden", room.Name)
+
+	room, err = s.FetchRoom(context.Background(), "conference.jackal.im", "yard")
+	require.Nil(t, err)
+	require.Nil(t, room)
+}
+
+func TestMemoryStorage_DeleteRoom(t *testing.T) {
+	s := NewMuc()
+	_ = s.UpsertRoom(context.Background(), &mucmodel.Room{Host: "conference.jackal.im", Name: "lounge"})
+	_ = s.UpsertRoomAffiliation(context.Background(), &mucmodel.Affiliation{JID: "ortuman@jackal.im", Affiliation: mucmodel.Owner}, "conference.jackal.im", "lounge")
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.DeleteRoom(context.Background(), "conference.jackal.im", "lounge"))
+	DisableMockedError()
+
+	require.Nil(t, s.DeleteRoom(context.Background(), "conference.jackal.im", "lounge"))
+
+	rooms, _ := s.FetchRooms(context.Background(), "conference.jackal.im")
+	require.Len(t, rooms, 0)
+
+	affiliations, _ := s.FetchRoomAffiliations(context.Background(), "conference.jackal.im", "lounge")
+	require.Len(t, affiliations, 0)
+}
+
+func TestMemoryStorage_RoomAffiliations(t *testing.T) {
+	s := NewMuc()
+	aff1 := &mucmodel.Affiliation{JID: "ortuman@jackal.im", Affiliation: mucmodel.Owner}
+	aff2 := &mucmodel.Affiliation{JID: "noelia@jackal.im", Affiliation: mucmodel.Member}
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.UpsertRoomAffiliation(context.Background(), aff1, "conference.jackal.im", "lounge"))
+	DisableMockedError()
+
+	require.Nil(t, s.UpsertRoomAffiliation(context.Background(), aff1, "conference.jackal.im", "lounge"))
+	require.Nil(t, s.UpsertRoomAffiliation(context.Background(), aff2, "conference.jackal.im", "lounge"))
+
+	aff2.Affiliation = mucmodel.Admin
+	require.Nil(t, s.UpsertRoomAffiliation(context.Background(), aff2, "conference.jackal.im", "lounge"))
+
+	affiliations, _ := s.FetchRoomAffiliations(context.Background(), "conference.jackal.im", "lounge")
+	require.Len(t, affiliations, 2)
+	require.Equal(t, mucmodel.Admin, affiliations[1].Affiliation)
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.DeleteRoomAffiliation(context.Background(), "noelia@jackal.im", "conference.jackal.im", "lounge"))
+	DisableMockedError()
+
+	require.Nil(t, s.DeleteRoomAffiliation(context.Background(), "noelia@jackal.im", "conference.jackal.im", "lounge"))
+
+	affiliations, _ = s.FetchRoomAffiliations(context.Background(), "conference.jackal.im", "lounge")
+	require.Len(t, affiliations, 1)
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memorystorage
+
+import (
+	"context"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/model/serializer"
+)
+
+// Push represents an in-memory push registrations storage.
+type Push struct {
+	*memoryStorage
+}
+
+// NewPush returns an instance of Push in-memory storage.
+func NewPush() *Push {
+	return &Push{memoryStorage: newStorage()}
+}
+
+// UpsertPushRegistration inserts a new push registration into storage, or updates it in case it's been previously inserted.
+func (m *Push) UpsertPushRegistration(_ context.Context, registration *model.PushRegistration) error {
+	return m.updateInWriteLock(pushRegistrationsKey(registration.Username), func(b []byte) ([]byte, error) {
+		var registrations []model.PushRegistration
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &registrations); err != nil {
+				return nil, err
+			}
+		}
+		var updated bool
+		for i, reg := range registrations {
+			if reg.JID == registration.JID && reg.Node == registration.Node {
+				registrations[i] = *registration
+				updated = true
+				break
+			}
+		}
+		if !updated {
+			registrations = append(registrations, *registration)
+		}
+		return serializer.SerializeSlice(&registrations)
+	})
+}
+
+// FetchPushRegistrations retrieves from storage all push registrations associated to a given user.
+func (m *Push) FetchPushRegistrations(_ context.Context, username string) ([]model.PushRegistration, error) {
+	var registrations []model.PushRegistration
+	if _, err := m.getEntities(pushRegistrationsKey(username), &registrations); err != nil {
+		return nil, err
+	}
+	return registrations, nil
+}
+
+// DeletePushRegistrations deletes user's push registrations associated to a given app server JID.
+func (m *Push) DeletePushRegistrations(_ context.Context, username, jid, node string) error {
+	return m.updateInWriteLock(pushRegistrationsKey(username), func(b []byte) ([]byte, error) {
+		var registrations []model.PushRegistration
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &registrations); err != nil {
+				return nil, err
+			}
+		}
+		var res []model.PushRegistration
+		for _, reg := range registrations {
+			if reg.JID == jid && (len(node) == 0 || reg.Node == node) {
+				continue
+			}
+			res = append(res, reg)
+		}
+		return serializer.SerializeSlice(&res)
+	})
+}
+
+func pushRegistrationsKey(username string) string {
+	return "pushRegistrations:" + username
+}
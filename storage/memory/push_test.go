@@ -0,0 +1,67 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memorystorage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorage_UpsertPushRegistration(t *testing.T) {
+	s := NewPush()
+	reg := &model.PushRegistration{Username: "ortuman", JID: "push.jackal.im", Node: "n1"}
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.UpsertPushRegistration(context.Background(), reg))
+	DisableMockedError()
+
+	require.Nil(t, s.UpsertPushRegistration(context.Background(), reg))
+	require.Nil(t, s.UpsertPushRegistration(context.Background(), reg))
+
+	regs, _ := s.FetchPushRegistrations(context.Background(), "ortuman")
+	require.Len(t, regs, 1)
+}
+
+func TestMemoryStorage_FetchPushRegistrations(t *testing.T) {
+	s := NewPush()
+	_ = s.UpsertPushRegistration(context.Background(), &model.PushRegistration{Username: "ortuman", JID: "push.jackal.im", Node: "n1"})
+	_ = s.UpsertPushRegistration(context.Background(), &model.PushRegistration{Username: "ortuman", JID: "push.jackal.im", Node: "n2"})
+
+	EnableMockedError()
+	_, err := s.FetchPushRegistrations(context.Background(), "ortuman")
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	regs, _ := s.FetchPushRegistrations(context.Background(), "ortuman")
+	require.Len(t, regs, 2)
+
+	regs, _ = s.FetchPushRegistrations(context.Background(), "noelia")
+	require.Len(t, regs, 0)
+}
+
+func TestMemoryStorage_DeletePushRegistrations(t *testing.T) {
+	s := NewPush()
+	_ = s.UpsertPushRegistration(context.Background(), &model.PushRegistration{Username: "ortuman", JID: "push.jackal.im", Node: "n1"})
+	_ = s.UpsertPushRegistration(context.Background(), &model.PushRegistration{Username: "ortuman", JID: "push.jackal.im", Node: "n2"})
+	_ = s.UpsertPushRegistration(context.Background(), &model.PushRegistration{Username: "ortuman", JID: "push.example.org", Node: "n3"})
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.DeletePushRegistrations(context.Background(), "ortuman", "push.jackal.im", "n1"))
+	DisableMockedError()
+
+	require.Nil(t, s.DeletePushRegistrations(context.Background(), "ortuman", "push.jackal.im", "n1"))
+	regs, _ := s.FetchPushRegistrations(context.Background(), "ortuman")
+	require.Len(t, regs, 2)
+
+	// delete every node
+	require.Nil(t, s.DeletePushRegistrations(context.Background(), "ortuman", "push.jackal.im", ""))
+	regs, _ = s.FetchPushRegistrations(context.Background(), "ortuman")
+	require.Len(t, regs, 1)
+	require.Equal(t, "push.example.org", regs[0].JID)
+}
@@ -37,10 +37,19 @@ func (m *User) FetchUser(_ context.Context, username string) (*model.User, error
 	ok, err := m.getEntity(userKey(username), &user)
 	switch err {
 	case nil:
-		if ok {
-			return &user, nil
+		if !ok {
+			return nil, nil
 		}
-		return nil, nil
+		if user.HasLegacyPassword() {
+			// migrate cleartext password into SCRAM credentials
+			if err := user.SetPassword(user.Password); err != nil {
+				return nil, err
+			}
+			if err := m.saveEntity(userKey(username), &user); err != nil {
+				return nil, err
+			}
+		}
+		return &user, nil
 	default:
 		return nil, err
 	}
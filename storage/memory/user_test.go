@@ -50,6 +50,16 @@ func TestMemoryStorage_FetchUser(t *testing.T) {
 
 	usr, _ = s.FetchUser(context.Background(), "ortuman")
 	require.NotNil(t, usr)
+
+	// cleartext password has been migrated
+	require.False(t, usr.HasLegacyPassword())
+	require.NotNil(t, usr.ScramSHA1)
+	require.NotNil(t, usr.ScramSHA256)
+	require.NotNil(t, usr.ScramSHA512)
+	require.True(t, usr.VerifyPassword("1234"))
+
+	usr2, _ := s.FetchUser(context.Background(), "ortuman")
+	require.Equal(t, usr.ScramSHA256.String(), usr2.ScramSHA256.String())
 }
 
 func TestMemoryStorage_DeleteUser(t *testing.T) {
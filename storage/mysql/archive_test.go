@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMySQLStorageInsertArchivedMessage(t *testing.T) {
+	j, _ := jid.NewWithString("ortuman@jackal.im/balcony", false)
+	message := xmpp.NewMessageType("abc", xmpp.ChatType)
+	message.SetFromJID(j)
+	message.SetToJID(j)
+	stamp := time.Now()
+
+	am := &model.ArchivedMessage{Username: "ortuman", ID: "1234", With: "noelia@jackal.im", Message: message, Stamp: stamp}
+
+	s, mock := newArchiveMock()
+	mock.ExpectExec("INSERT INTO archive_messages (.+)").
+		WithArgs("ortuman", "1234", "noelia@jackal.im", message.String(), stamp).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.InsertArchivedMessage(context.Background(), am)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newArchiveMock()
+	mock.ExpectExec("INSERT INTO archive_messages (.+)").
+		WithArgs("ortuman", "1234", "noelia@jackal.im", message.String(), stamp).
+		WillReturnError(errMySQLStorage)
+
+	err = s.InsertArchivedMessage(context.Background(), am)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorageFetchArchivedMessages(t *testing.T) {
+	var archiveColumns = []string{"id", "with_jid", "data", "created_at"}
+	now := time.Now()
+
+	s, mock := newArchiveMock()
+	mock.ExpectQuery("SELECT (.+) FROM archive_messages WHERE username = \\? AND with_jid = \\? ORDER BY serial LIMIT 10").
+		WithArgs("ortuman", "noelia@jackal.im").
+		WillReturnRows(sqlmock.NewRows(archiveColumns).
+			AddRow("1", "noelia@jackal.im", "<message id='a'><body>Hi!</body></message>", now).
+			AddRow("2", "noelia@jackal.im", "<message id='b'><body>Bye!</body></message>", now))
+
+	msgs, err := s.FetchArchivedMessages(context.Background(), "ortuman", &model.ArchiveQuery{With: "noelia@jackal.im", Max: 10})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, msgs, 2)
+	require.Equal(t, "1", msgs[0].ID)
+	require.Equal(t, "a", msgs[0].Message.ID())
+
+	// paging backwards
+	s, mock = newArchiveMock()
+	mock.ExpectQuery("SELECT (.+) FROM archive_messages WHERE username = \\? AND serial < (.+) ORDER BY serial DESC LIMIT 2").
+		WithArgs("ortuman", "ortuman", "3").
+		WillReturnRows(sqlmock.NewRows(archiveColumns).
+			AddRow("2", "noelia@jackal.im", "<message id='b'><body>Bye!</body></message>", now).
+			AddRow("1", "noelia@jackal.im", "<message id='a'><body>Hi!</body></message>", now))
+
+	msgs, err = s.FetchArchivedMessages(context.Background(), "ortuman", &model.ArchiveQuery{Before: "3", Max: 2})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, msgs, 2)
+	require.Equal(t, "1", msgs[0].ID)
+	require.Equal(t, "2", msgs[1].ID)
+
+	s, mock = newArchiveMock()
+	mock.ExpectQuery("SELECT (.+) FROM archive_messages (.+)").
+		WithArgs("ortuman").
+		WillReturnRows(sqlmock.NewRows(archiveColumns).AddRow("1", "noelia@jackal.im", "<message id='a'><body>Hi!", now))
+
+	_, err = s.FetchArchivedMessages(context.Background(), "ortuman", &model.ArchiveQuery{})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.NotNil(t, err)
+
+	s, mock = newArchiveMock()
+	mock.ExpectQuery("SELECT (.+) FROM archive_messages (.+)").
+		WithArgs("ortuman").
+		WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchArchivedMessages(context.Background(), "ortuman", &model.ArchiveQuery{})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorageDeleteArchivedMessages(t *testing.T) {
+	s, mock := newArchiveMock()
+	mock.ExpectExec("DELETE FROM archive_messages (.+)").
+		WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.DeleteArchivedMessages(context.Background(), "ortuman")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newArchiveMock()
+	mock.ExpectExec("DELETE FROM archive_messages (.+)").
+		WithArgs("ortuman").WillReturnError(errMySQLStorage)
+
+	err = s.DeleteArchivedMessages(context.Background(), "ortuman")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func newArchiveMock() (*mySQLArchive, sqlmock.Sqlmock) {
+	s, sqlMock := newStorageMock()
+	return &mySQLArchive{
+		mySQLStorage: s,
+	}, sqlMock
+}
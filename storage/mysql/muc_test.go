@@ -0,0 +1,194 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	mucmodel "github.com/ortuman/jackal/model/muc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMySQLStorageUpsertRoom(t *testing.T) {
+	room := &mucmodel.Room{Host: "conference.jackal.im", Name: "lounge", Subject: "Welcome!", Config: mucmodel.DefaultConfig()}
+	b, _ := json.Marshal(&room.Config)
+
+	s, mock := newMucMock()
+	mock.ExpectExec("INSERT INTO muc_rooms (.+) ON DUPLICATE KEY UPDATE (.+)").
+		WithArgs("conference.jackal.im", "lounge", "Welcome!", string(b), "Welcome!", string(b)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.UpsertRoom(context.Background(), room)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newMucMock()
+	mock.ExpectExec("INSERT INTO muc_rooms (.+) ON DUPLICATE KEY UPDATE (.+)").
+		WithArgs("conference.jackal.im", "lounge", "Welcome!", string(b), "Welcome!", string(b)).
+		WillReturnError(errMySQLStorage)
+
+	err = s.UpsertRoom(context.Background(), room)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorageFetchRoom(t *testing.T) {
+	cfg := mucmodel.DefaultConfig()
+	cfg.Name = "The Lounge"
+	b, _ := json.Marshal(&cfg)
+
+	s, mock := newMucMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_rooms (.+)").
+		WithArgs("conference.jackal.im", "lounge").
+		WillReturnRows(sqlmock.NewRows([]string{"subject", "config"}).AddRow("Welcome!", string(b)))
+
+	room, err := s.FetchRoom(context.Background(), "conference.jackal.im", "lounge")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.NotNil(t, room)
+	require.Equal(t, "Welcome!", room.Subject)
+	require.Equal(t, cfg, room.Config)
+
+	s, mock = newMucMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_rooms (.+)").
+		WithArgs("conference.jackal.im", "lounge").
+		WillReturnRows(sqlmock.NewRows([]string{"subject", "config"}))
+
+	room, err = s.FetchRoom(context.Background(), "conference.jackal.im", "lounge")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Nil(t, room)
+
+	s, mock = newMucMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_rooms (.+)").
+		WithArgs("conference.jackal.im", "lounge").
+		WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchRoom(context.Background(), "conference.jackal.im", "lounge")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorageFetchRooms(t *testing.T) {
+	s, mock := newMucMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_rooms (.+)").
+		WithArgs("conference.jackal.im").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "subject", "config"}).
+			AddRow("lounge", "", "").
+			AddRow("garden", "", ""))
+
+	rooms, err := s.FetchRooms(context.Background(), "conference.jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, rooms, 2)
+	require.Equal(t, "garden", rooms[1].Name)
+
+	s, mock = newMucMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_rooms (.+)").
+		WithArgs("conference.jackal.im").
+		WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchRooms(context.Background(), "conference.jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorageDeleteRoom(t *testing.T) {
+	s, mock := newMucMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM muc_rooms (.+)").
+		WithArgs("conference.jackal.im", "lounge").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM muc_affiliations (.+)").
+		WithArgs("conference.jackal.im", "lounge").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := s.DeleteRoom(context.Background(), "conference.jackal.im", "lounge")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newMucMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM muc_rooms (.+)").
+		WithArgs("conference.jackal.im", "lounge").WillReturnError(errMySQLStorage)
+	mock.ExpectRollback()
+
+	err = s.DeleteRoom(context.Background(), "conference.jackal.im", "lounge")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorageUpsertRoomAffiliation(t *testing.T) {
+	aff := &mucmodel.Affiliation{JID: "ortuman@jackal.im", Affiliation: mucmodel.Owner}
+
+	s, mock := newMucMock()
+	mock.ExpectExec("INSERT INTO muc_affiliations (.+) ON DUPLICATE KEY UPDATE (.+)").
+		WithArgs("conference.jackal.im", "lounge", "ortuman@jackal.im", "owner", "owner").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.UpsertRoomAffiliation(context.Background(), aff, "conference.jackal.im", "lounge")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newMucMock()
+	mock.ExpectExec("INSERT INTO muc_affiliations (.+) ON DUPLICATE KEY UPDATE (.+)").
+		WithArgs("conference.jackal.im", "lounge", "ortuman@jackal.im", "owner", "owner").
+		WillReturnError(errMySQLStorage)
+
+	err = s.UpsertRoomAffiliation(context.Background(), aff, "conference.jackal.im", "lounge")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorageDeleteRoomAffiliation(t *testing.T) {
+	s, mock := newMucMock()
+	mock.ExpectExec("DELETE FROM muc_affiliations (.+)").
+		WithArgs("conference.jackal.im", "lounge", "ortuman@jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.DeleteRoomAffiliation(context.Background(), "ortuman@jackal.im", "conference.jackal.im", "lounge")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newMucMock()
+	mock.ExpectExec("DELETE FROM muc_affiliations (.+)").
+		WithArgs("conference.jackal.im", "lounge", "ortuman@jackal.im").WillReturnError(errMySQLStorage)
+
+	err = s.DeleteRoomAffiliation(context.Background(), "ortuman@jackal.im", "conference.jackal.im", "lounge")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorageFetchRoomAffiliations(t *testing.T) {
+	s, mock := newMucMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_affiliations (.+)").
+		WithArgs("conference.jackal.im", "lounge").
+		WillReturnRows(sqlmock.NewRows([]string{"jid", "affiliation"}).
+			AddRow("ortuman@jackal.im", "owner").
+			AddRow("noelia@jackal.im", "member"))
+
+	affiliations, err := s.FetchRoomAffiliations(context.Background(), "conference.jackal.im", "lounge")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, affiliations, 2)
+
+	s, mock = newMucMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_affiliations (.+)").
+		WithArgs("conference.jackal.im", "lounge").
+		WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchRoomAffiliations(context.Background(), "conference.jackal.im", "lounge")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func newMucMock() (*mySQLMuc, sqlmock.Sqlmock) {
+	s, sqlMock := newStorageMock()
+	return &mySQLMuc{
+		mySQLStorage: s,
+	}, sqlMock
+}
@@ -25,6 +25,10 @@ type mySQLContainer struct {
 	blockList *mySQLBlockList
 	pubSub    *mySQLPubSub
 	offline   *mySQLOffline
+	archive   *mySQLArchive
+	push      *mySQLPush
+	fastToken *mySQLFastToken
+	muc       *mySQLMuc
 
 	h      *sql.DB
 	doneCh chan chan bool
@@ -60,7 +64,14 @@ func New(cfg *Config) (repository.Container, error) {
 	c.blockList = newBlockList(c.h)
 	c.pubSub = newPubSub(c.h)
 	c.offline = newOffline(c.h)
+	c.archive = newArchive(c.h)
+	c.push = newPush(c.h)
+	c.fastToken = newFastToken(c.h)
+	c.muc = newMuc(c.h)
 
+	if err := c.migrateUserCredentials(); err != nil {
+		return nil, err
+	}
 	return c, nil
 }
 
@@ -72,6 +83,10 @@ func (c *mySQLContainer) Private() repository.Private     { return c.priv }
 func (c *mySQLContainer) BlockList() repository.BlockList { return c.blockList }
 func (c *mySQLContainer) PubSub() repository.PubSub       { return c.pubSub }
 func (c *mySQLContainer) Offline() repository.Offline     { return c.offline }
+func (c *mySQLContainer) Archive() repository.Archive     { return c.archive }
+func (c *mySQLContainer) Push() repository.Push           { return c.push }
+func (c *mySQLContainer) FastToken() repository.FastToken { return c.fastToken }
+func (c *mySQLContainer) Muc() repository.Muc             { return c.muc }
 
 func (c *mySQLContainer) Close(ctx context.Context) error {
 	ch := make(chan bool)
@@ -86,6 +101,18 @@ func (c *mySQLContainer) Close(ctx context.Context) error {
 
 func (c *mySQLContainer) IsClusterCompatible() bool { return true }
 
+// migrateUserCredentials converts legacy cleartext passwords into SCRAM credentials.
+func (c *mySQLContainer) migrateUserCredentials() error {
+	n, err := c.user.migrateCredentials(context.Background())
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Infof("migrated %d user password(s) to SCRAM credentials", n)
+	}
+	return nil
+}
+
 func (c *mySQLContainer) loop() {
 	tc := time.NewTicker(time.Second * 15)
 	defer tc.Stop()
@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/xmpp"
+)
+
+type mySQLPush struct {
+	*mySQLStorage
+}
+
+func newPush(db *sql.DB) *mySQLPush {
+	return &mySQLPush{
+		mySQLStorage: newStorage(db),
+	}
+}
+
+func (s *mySQLPush) UpsertPushRegistration(ctx context.Context, registration *model.PushRegistration) error {
+	var options string
+	if registration.Options != nil {
+		options = registration.Options.String()
+	}
+	q := sq.Insert("push_registrations").
+		Columns("username", "jid", "node", "options", "updated_at", "created_at").
+		Values(registration.Username, registration.JID, registration.Node, options, nowExpr, nowExpr).
+		Suffix("ON DUPLICATE KEY UPDATE options = ?, updated_at = NOW()", options)
+
+	_, err := q.RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *mySQLPush) FetchPushRegistrations(ctx context.Context, username string) ([]model.PushRegistration, error) {
+	q := sq.Select("jid", "node", "options").
+		From("push_registrations").
+		Where(sq.Eq{"username": username}).
+		OrderBy("created_at")
+
+	rows, err := q.RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var registrations []model.PushRegistration
+	for rows.Next() {
+		var options string
+		reg := model.PushRegistration{Username: username}
+		if err := rows.Scan(&reg.JID, &reg.Node, &options); err != nil {
+			return nil, err
+		}
+		if len(options) > 0 {
+			parser := xmpp.NewParser(strings.NewReader(options), xmpp.DefaultMode, 0)
+			if reg.Options, err = parser.ParseElement(); err != nil {
+				return nil, err
+			}
+		}
+		registrations = append(registrations, reg)
+	}
+	return registrations, rows.Err()
+}
+
+func (s *mySQLPush) DeletePushRegistrations(ctx context.Context, username, jid, node string) error {
+	where := sq.Eq{"username": username, "jid": jid}
+	if len(node) > 0 {
+		where["node"] = node
+	}
+	_, err := sq.Delete("push_registrations").Where(where).RunWith(s.db).ExecContext(ctx)
+	return err
+}
@@ -40,21 +40,20 @@ func (u *mySQLUser) UpsertUser(ctx context.Context, usr *model.User) error {
 		presenceXML = buf.String()
 		u.pool.Put(buf)
 	}
-	columns := []string{"username", "password", "updated_at", "created_at"}
-	values := []interface{}{usr.Username, usr.Password, nowExpr, nowExpr}
+	scramSHA1, scramSHA256, scramSHA512 := usr.ScramSHA1.String(), usr.ScramSHA256.String(), usr.ScramSHA512.String()
+
+	// last presence columns are non-nullable... always set them on insertion
+	columns := []string{"username", "password", "scram_sha_1", "scram_sha_256", "scram_sha_512", "last_presence", "last_presence_at", "updated_at", "created_at"}
+	values := []interface{}{usr.Username, usr.Password, scramSHA1, scramSHA256, scramSHA512, presenceXML, nowExpr, nowExpr, nowExpr}
 
-	if len(presenceXML) > 0 {
-		columns = append(columns, []string{"last_presence", "last_presence_at"}...)
-		values = append(values, []interface{}{presenceXML, nowExpr}...)
-	}
 	var suffix string
 	var suffixArgs []interface{}
 	if len(presenceXML) > 0 {
-		suffix = "ON DUPLICATE KEY UPDATE password = ?, last_presence = ?, last_presence_at = NOW(), updated_at = NOW()"
-		suffixArgs = []interface{}{usr.Password, presenceXML}
+		suffix = "ON DUPLICATE KEY UPDATE password = ?, scram_sha_1 = ?, scram_sha_256 = ?, scram_sha_512 = ?, last_presence = ?, last_presence_at = NOW(), updated_at = NOW()"
+		suffixArgs = []interface{}{usr.Password, scramSHA1, scramSHA256, scramSHA512, presenceXML}
 	} else {
-		suffix = "ON DUPLICATE KEY UPDATE password = ?, updated_at = NOW()"
-		suffixArgs = []interface{}{usr.Password}
+		suffix = "ON DUPLICATE KEY UPDATE password = ?, scram_sha_1 = ?, scram_sha_256 = ?, scram_sha_512 = ?, updated_at = NOW()"
+		suffixArgs = []interface{}{usr.Password, scramSHA1, scramSHA256, scramSHA512}
 	}
 	q := sq.Insert("users").
 		Columns(columns...).
@@ -66,19 +65,23 @@ func (u *mySQLUser) UpsertUser(ctx context.Context, usr *model.User) error {
 }
 
 func (u *mySQLUser) FetchUser(ctx context.Context, username string) (*model.User, error) {
-	q := sq.Select("username", "password", "last_presence", "last_presence_at").
+	q := sq.Select("username", "password", "scram_sha_1", "scram_sha_256", "scram_sha_512", "last_presence", "last_presence_at").
 		From("users").
 		Where(sq.Eq{"username": username})
 
+	var scramSHA1, scramSHA256, scramSHA512 string
 	var presenceXML string
 	var presenceAt time.Time
 	var usr model.User
 
 	err := q.RunWith(u.db).
 		QueryRowContext(ctx).
-		Scan(&usr.Username, &usr.Password, &presenceXML, &presenceAt)
+		Scan(&usr.Username, &usr.Password, &scramSHA1, &scramSHA256, &scramSHA512, &presenceXML, &presenceAt)
 	switch err {
 	case nil:
+		if err := scanScramCredentials(&usr, scramSHA1, scramSHA256, scramSHA512); err != nil {
+			return nil, err
+		}
 		if len(presenceXML) > 0 {
 			parser := xmpp.NewParser(strings.NewReader(presenceXML), xmpp.DefaultMode, 0)
 			lastPresence, err := parser.ParseElement()
@@ -143,3 +146,54 @@ func (u *mySQLUser) UserExists(ctx context.Context, username string) (bool, erro
 		return false, err
 	}
 }
+
+// migrateCredentials replaces every stored cleartext password by its derived SCRAM credentials.
+func (u *mySQLUser) migrateCredentials(ctx context.Context) (int, error) {
+	rows, err := sq.Select("username", "password").
+		From("users").
+		Where(sq.NotEq{"password": ""}).
+		RunWith(u.db).QueryContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var users []model.User
+	for rows.Next() {
+		var usr model.User
+		if err := rows.Scan(&usr.Username, &usr.Password); err != nil {
+			_ = rows.Close()
+			return 0, err
+		}
+		users = append(users, usr)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+	for _, usr := range users {
+		if err := usr.SetPassword(usr.Password); err != nil {
+			return 0, err
+		}
+		_, err := sq.Update("users").
+			Set("password", "").
+			Set("scram_sha_1", usr.ScramSHA1.String()).
+			Set("scram_sha_256", usr.ScramSHA256.String()).
+			Set("scram_sha_512", usr.ScramSHA512.String()).
+			Where(sq.Eq{"username": usr.Username}).
+			RunWith(u.db).ExecContext(ctx)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(users), nil
+}
+
+func scanScramCredentials(usr *model.User, scramSHA1, scramSHA256, scramSHA512 string) error {
+	var err error
+	if usr.ScramSHA1, err = model.ParseScramCredentials(scramSHA1); err != nil {
+		return err
+	}
+	if usr.ScramSHA256, err = model.ParseScramCredentials(scramSHA256); err != nil {
+		return err
+	}
+	usr.ScramSHA512, err = model.ParseScramCredentials(scramSHA512)
+	return err
+}
@@ -7,6 +7,7 @@ package mysql
 
 import (
 	"context"
+	"crypto/sha256"
 	"testing"
 	"time"
 
@@ -23,11 +24,12 @@ func TestMySQLStorageInsertUser(t *testing.T) {
 	to, _ := jid.NewWithString("ortuman@jackal.im", true)
 	p := xmpp.NewPresence(from, to, xmpp.UnavailableType)
 
-	user := model.User{Username: "ortuman", Password: "1234", LastPresence: p}
+	creds := model.DeriveScramCredentials(sha256.New, "1234", []byte("salt"), model.ScramIterationsCount)
+	user := model.User{Username: "ortuman", ScramSHA256: creds, LastPresence: p}
 
 	s, mock := newUserMock()
 	mock.ExpectExec("INSERT INTO users (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("ortuman", "1234", p.String(), "1234", p.String()).
+		WithArgs("ortuman", "", "", creds.String(), "", p.String(), "", "", creds.String(), "", p.String()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err := s.UpsertUser(context.Background(), &user)
@@ -36,7 +38,7 @@ func TestMySQLStorageInsertUser(t *testing.T) {
 
 	s, mock = newUserMock()
 	mock.ExpectExec("INSERT INTO users (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("ortuman", "1234", p.String(), "1234", p.String()).
+		WithArgs("ortuman", "", "", creds.String(), "", p.String(), "", "", creds.String(), "", p.String()).
 		WillReturnError(errMocked)
 
 	err = s.UpsertUser(context.Background(), &user)
@@ -44,6 +46,20 @@ func TestMySQLStorageInsertUser(t *testing.T) {
 	require.Equal(t, errMocked, err)
 }
 
+func TestMySQLStorageInsertUserWithoutPresence(t *testing.T) {
+	user := model.User{Username: "ortuman", Password: "1234"}
+
+	// non-nullable last presence columns must be set anyway
+	s, mock := newUserMock()
+	mock.ExpectExec(`INSERT INTO users \(username,password,scram_sha_1,scram_sha_256,scram_sha_512,last_presence,last_presence_at,updated_at,created_at\) (.+) ON DUPLICATE KEY UPDATE password = \?, scram_sha_1 = \?, scram_sha_256 = \?, scram_sha_512 = \?, updated_at = NOW\(\)`).
+		WithArgs("ortuman", "1234", "", "", "", "", "1234", "", "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.UpsertUser(context.Background(), &user)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+}
+
 func TestMySQLStorageDeleteUser(t *testing.T) {
 	s, mock := newUserMock()
 	mock.ExpectBegin()
@@ -81,7 +97,9 @@ func TestMySQLStorageFetchUser(t *testing.T) {
 	to, _ := jid.NewWithString("ortuman@jackal.im", true)
 	p := xmpp.NewPresence(from, to, xmpp.UnavailableType)
 
-	var userColumns = []string{"username", "password", "last_presence", "last_presence_at"}
+	creds := model.DeriveScramCredentials(sha256.New, "1234", []byte("salt"), model.ScramIterationsCount)
+
+	var userColumns = []string{"username", "password", "scram_sha_1", "scram_sha_256", "scram_sha_512", "last_presence", "last_presence_at"}
 
 	s, mock := newUserMock()
 	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
@@ -95,10 +113,21 @@ func TestMySQLStorageFetchUser(t *testing.T) {
 	s, mock = newUserMock()
 	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
 		WithArgs("ortuman").
-		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("ortuman", "1234", p.String(), time.Now()))
-	_, err := s.FetchUser(context.Background(), "ortuman")
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("ortuman", "", "", creds.String(), "", p.String(), time.Now()))
+	usr, err := s.FetchUser(context.Background(), "ortuman")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
+	require.Nil(t, usr.ScramSHA1)
+	require.Equal(t, creds, usr.ScramSHA256)
+	require.True(t, usr.VerifyPassword("1234"))
+
+	s, mock = newUserMock()
+	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
+		WithArgs("ortuman").
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("ortuman", "", "", "invalid", "", "", time.Now()))
+	_, err = s.FetchUser(context.Background(), "ortuman")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.NotNil(t, err)
 
 	s, mock = newUserMock()
 	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
@@ -108,6 +137,29 @@ func TestMySQLStorageFetchUser(t *testing.T) {
 	require.Equal(t, errMocked, err)
 }
 
+func TestMySQLStorageMigrateUserCredentials(t *testing.T) {
+	s, mock := newUserMock()
+	mock.ExpectQuery("SELECT username, password FROM users (.+)").
+		WithArgs("").
+		WillReturnRows(sqlmock.NewRows([]string{"username", "password"}).AddRow("ortuman", "1234"))
+	mock.ExpectExec("UPDATE users SET (.+)").
+		WithArgs("", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "ortuman").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := s.migrateCredentials(context.Background())
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 1, n)
+
+	s, mock = newUserMock()
+	mock.ExpectQuery("SELECT username, password FROM users (.+)").
+		WithArgs("").WillReturnError(errMocked)
+
+	_, err = s.migrateCredentials(context.Background())
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMocked, err)
+}
+
 func TestMySQLStorageUserExists(t *testing.T) {
 	countCols := []string{"count"}
 
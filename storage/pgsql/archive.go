@@ -0,0 +1,117 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+type pgSQLArchive struct {
+	*pgSQLStorage
+}
+
+func newArchive(db *sql.DB) *pgSQLArchive {
+	return &pgSQLArchive{
+		pgSQLStorage: newStorage(db),
+	}
+}
+
+func (s *pgSQLArchive) InsertArchivedMessage(ctx context.Context, message *model.ArchivedMessage) error {
+	q := sq.Insert("archive_messages").
+		Columns("username", "id", "with_jid", "data", "created_at").
+		Values(message.Username, message.ID, message.With, message.Message.String(), message.Stamp)
+	_, err := q.RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *pgSQLArchive) FetchArchivedMessages(ctx context.Context, username string, query *model.ArchiveQuery) ([]model.ArchivedMessage, error) {
+	q := sq.Select("id", "with_jid", "data", "created_at").
+		From("archive_messages").
+		Where(sq.Eq{"username": username})
+
+	if len(query.With) > 0 {
+		q = q.Where(sq.Eq{"with_jid": query.With})
+	}
+	if !query.Start.IsZero() {
+		q = q.Where(sq.GtOrEq{"created_at": query.Start})
+	}
+	if !query.End.IsZero() {
+		q = q.Where(sq.LtOrEq{"created_at": query.End})
+	}
+	if len(query.After) > 0 {
+		q = q.Where("serial > (SELECT serial FROM archive_messages WHERE username = ? AND id = ?)", username, query.After)
+	}
+	if len(query.Before) > 0 {
+		q = q.Where("serial < (SELECT serial FROM archive_messages WHERE username = ? AND id = ?)", username, query.Before)
+	}
+	if query.IsBackwards() {
+		q = q.OrderBy("serial DESC")
+	} else {
+		q = q.OrderBy("serial")
+	}
+	if query.Max > 0 {
+		q = q.Limit(uint64(query.Max))
+	}
+	rows, err := q.RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var messages []model.ArchivedMessage
+	for rows.Next() {
+		var id, with, data string
+		var stamp time.Time
+		if err := rows.Scan(&id, &with, &data, &stamp); err != nil {
+			return nil, err
+		}
+		msg, err := parseArchivedMessage(data)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, model.ArchivedMessage{
+			Username: username,
+			ID:       id,
+			With:     with,
+			Message:  msg,
+			Stamp:    stamp,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if query.IsBackwards() {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+	return messages, nil
+}
+
+func (s *pgSQLArchive) DeleteArchivedMessages(ctx context.Context, username string) error {
+	q := sq.Delete("archive_messages").Where(sq.Eq{"username": username})
+	_, err := q.RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func parseArchivedMessage(data string) (*xmpp.Message, error) {
+	parser := xmpp.NewParser(strings.NewReader(data), xmpp.DefaultMode, 0)
+	elem, err := parser.ParseElement()
+	if err != nil {
+		return nil, err
+	}
+	fromJID, _ := jid.NewWithString(elem.From(), true)
+	toJID, _ := jid.NewWithString(elem.To(), true)
+	return xmpp.NewMessageFromElement(elem, fromJID, toJID)
+}
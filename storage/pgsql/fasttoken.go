@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"context"
+	"database/sql"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ortuman/jackal/model"
+)
+
+type pgSQLFastToken struct {
+	*pgSQLStorage
+}
+
+func newFastToken(db *sql.DB) *pgSQLFastToken {
+	return &pgSQLFastToken{
+		pgSQLStorage: newStorage(db),
+	}
+}
+
+func (s *pgSQLFastToken) InsertFastToken(ctx context.Context, token *model.FastToken) error {
+	q := sq.Insert("fast_tokens").
+		Columns("username", "client_id", "mechanism", "token", "expires_at").
+		Values(token.Username, token.ClientID, token.Mechanism, token.Token, token.ExpiresAt)
+
+	_, err := q.RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *pgSQLFastToken) FetchFastTokens(ctx context.Context, username string) ([]model.FastToken, error) {
+	q := sq.Select("client_id", "mechanism", "token", "expires_at").
+		From("fast_tokens").
+		Where(sq.Eq{"username": username}).
+		OrderBy("expires_at")
+
+	rows, err := q.RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var tokens []model.FastToken
+	for rows.Next() {
+		token := model.FastToken{Username: username}
+		if err := rows.Scan(&token.ClientID, &token.Mechanism, &token.Token, &token.ExpiresAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *pgSQLFastToken) DeleteFastToken(ctx context.Context, username, token string) error {
+	_, err := sq.Delete("fast_tokens").
+		Where(sq.Eq{"username": username, "token": token}).
+		RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *pgSQLFastToken) DeleteFastTokens(ctx context.Context, username string) error {
+	_, err := sq.Delete("fast_tokens").
+		Where(sq.Eq{"username": username}).
+		RunWith(s.db).ExecContext(ctx)
+	return err
+}
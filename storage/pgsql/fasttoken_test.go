@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/ortuman/jackal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertFastToken(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	token := &model.FastToken{Username: "ortuman", ClientID: "c1", Mechanism: "HT-SHA-256-NONE", Token: "t1", ExpiresAt: expiresAt}
+
+	s, mock := newFastTokenMock()
+	mock.ExpectExec("INSERT INTO fast_tokens (.+)").
+		WithArgs("ortuman", "c1", "HT-SHA-256-NONE", "t1", expiresAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.InsertFastToken(context.Background(), token)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newFastTokenMock()
+	mock.ExpectExec("INSERT INTO fast_tokens (.+)").
+		WithArgs("ortuman", "c1", "HT-SHA-256-NONE", "t1", expiresAt).
+		WillReturnError(errGeneric)
+
+	err = s.InsertFastToken(context.Background(), token)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errGeneric, err)
+}
+
+func TestFetchFastTokens(t *testing.T) {
+	var fastTokenColumns = []string{"client_id", "mechanism", "token", "expires_at"}
+
+	s, mock := newFastTokenMock()
+	mock.ExpectQuery("SELECT (.+) FROM fast_tokens (.+)").
+		WithArgs("ortuman").
+		WillReturnRows(sqlmock.NewRows(fastTokenColumns).
+			AddRow("c1", "HT-SHA-256-NONE", "t1", time.Now()).
+			AddRow("c2", "HT-SHA-256-EXPR", "t2", time.Now()))
+
+	tokens, err := s.FetchFastTokens(context.Background(), "ortuman")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, tokens, 2)
+	require.Equal(t, "ortuman", tokens[1].Username)
+	require.Equal(t, "HT-SHA-256-EXPR", tokens[1].Mechanism)
+
+	s, mock = newFastTokenMock()
+	mock.ExpectQuery("SELECT (.+) FROM fast_tokens (.+)").
+		WithArgs("ortuman").
+		WillReturnError(errGeneric)
+
+	_, err = s.FetchFastTokens(context.Background(), "ortuman")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errGeneric, err)
+}
+
+func TestDeleteFastTokens(t *testing.T) {
+	s, mock := newFastTokenMock()
+	mock.ExpectExec("DELETE FROM fast_tokens (.+)").
+		WithArgs("t1", "ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.DeleteFastToken(context.Background(), "ortuman", "t1")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newFastTokenMock()
+	mock.ExpectExec("DELETE FROM fast_tokens (.+)").
+		WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err = s.DeleteFastTokens(context.Background(), "ortuman")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newFastTokenMock()
+	mock.ExpectExec("DELETE FROM fast_tokens (.+)").
+		WithArgs("ortuman").WillReturnError(errGeneric)
+
+	err = s.DeleteFastTokens(context.Background(), "ortuman")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errGeneric, err)
+}
+
+func newFastTokenMock() (*pgSQLFastToken, sqlmock.Sqlmock) {
+	s, sqlMock := newStorageMock()
+	return &pgSQLFastToken{
+		pgSQLStorage: s,
+	}, sqlMock
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	mucmodel "github.com/ortuman/jackal/model/muc"
+)
+
+type pgSQLMuc struct {
+	*pgSQLStorage
+}
+
+func newMuc(db *sql.DB) *pgSQLMuc {
+	return &pgSQLMuc{
+		pgSQLStorage: newStorage(db),
+	}
+}
+
+func (s *pgSQLMuc) UpsertRoom(ctx context.Context, room *mucmodel.Room) error {
+	b, err := json.Marshal(&room.Config)
+	if err != nil {
+		return err
+	}
+	q := sq.Insert("muc_rooms").
+		Columns("host", "name", "subject", "config").
+		Values(room.Host, room.Name, room.Subject, string(b)).
+		Suffix("ON CONFLICT (host, name) DO UPDATE SET subject = $5, config = $6", room.Subject, string(b))
+
+	_, err = q.RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *pgSQLMuc) FetchRoom(ctx context.Context, host, name string) (*mucmodel.Room, error) {
+	var subject, config string
+
+	err := sq.Select("subject", "config").
+		From("muc_rooms").
+		Where(sq.And{sq.Eq{"host": host}, sq.Eq{"name": name}}).
+		RunWith(s.db).
+		QueryRowContext(ctx).
+		Scan(&subject, &config)
+
+	switch err {
+	case nil:
+		return parseRoom(host, name, subject, config)
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+func (s *pgSQLMuc) FetchRooms(ctx context.Context, host string) ([]mucmodel.Room, error) {
+	rows, err := sq.Select("name", "subject", "config").
+		From("muc_rooms").
+		Where(sq.Eq{"host": host}).
+		OrderBy("created_at").
+		RunWith(s.db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var rooms []mucmodel.Room
+	for rows.Next() {
+		var name, subject, config string
+		if err := rows.Scan(&name, &subject, &config); err != nil {
+			return nil, err
+		}
+		room, err := parseRoom(host, name, subject, config)
+		if err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, *room)
+	}
+	return rooms, rows.Err()
+}
+
+func (s *pgSQLMuc) DeleteRoom(ctx context.Context, host, name string) error {
+	return s.inTransaction(ctx, func(tx *sql.Tx) error {
+		_, err := sq.Delete("muc_rooms").
+			Where(sq.And{sq.Eq{"host": host}, sq.Eq{"name": name}}).
+			RunWith(tx).ExecContext(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = sq.Delete("muc_affiliations").
+			Where(sq.And{sq.Eq{"host": host}, sq.Eq{"name": name}}).
+			RunWith(tx).ExecContext(ctx)
+		return err
+	})
+}
+
+func (s *pgSQLMuc) UpsertRoomAffiliation(ctx context.Context, affiliation *mucmodel.Affiliation, host, name string) error {
+	q := sq.Insert("muc_affiliations").
+		Columns("host", "name", "jid", "affiliation").
+		Values(host, name, affiliation.JID, affiliation.Affiliation).
+		Suffix("ON CONFLICT (host, name, jid) DO UPDATE SET affiliation = $5", affiliation.Affiliation)
+
+	_, err := q.RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *pgSQLMuc) DeleteRoomAffiliation(ctx context.Context, jid, host, name string) error {
+	_, err := sq.Delete("muc_affiliations").
+		Where(sq.And{sq.Eq{"host": host}, sq.Eq{"name": name}, sq.Eq{"jid": jid}}).
+		RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *pgSQLMuc) FetchRoomAffiliations(ctx context.Context, host, name string) ([]mucmodel.Affiliation, error) {
+	rows, err := sq.Select("jid", "affiliation").
+		From("muc_affiliations").
+		Where(sq.And{sq.Eq{"host": host}, sq.Eq{"name": name}}).
+		OrderBy("created_at").
+		RunWith(s.db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var affiliations []mucmodel.Affiliation
+	for rows.Next() {
+		var aff mucmodel.Affiliation
+		if err := rows.Scan(&aff.JID, &aff.Affiliation); err != nil {
+			return nil, err
+		}
+		affiliations = append(affiliations, aff)
+	}
+	return affiliations, rows.Err()
+}
+
+func parseRoom(host, name, subject, config string) (*mucmodel.Room, error) {
+	room := &mucmodel.Room{Host: host, Name: name, Subject: subject}
+	if len(config) > 0 {
+		if err := json.NewDecoder(strings.NewReader(config)).Decode(&room.Config); err != nil {
+			return nil, err
+		}
+	}
+	return room, nil
+}
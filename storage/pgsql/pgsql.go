@@ -32,6 +32,10 @@ type pgSQLContainer struct {
 	blockList *pgSQLBlockList
 	pubSub    *pgSQLPubSub
 	offline   *pgSQLOffline
+	archive   *pgSQLArchive
+	push      *pgSQLPush
+	fastToken *pgSQLFastToken
+	muc       *pgSQLMuc
 
 	h          *sql.DB
 	cancelPing context.CancelFunc
@@ -69,7 +73,14 @@ func New(cfg *Config) (repository.Container, error) {
 	c.blockList = newBlockList(c.h)
 	c.pubSub = newPubSub(c.h)
 	c.offline = newOffline(c.h)
+	c.archive = newArchive(c.h)
+	c.push = newPush(c.h)
+	c.fastToken = newFastToken(c.h)
+	c.muc = newMuc(c.h)
 
+	if err := c.migrateUserCredentials(); err != nil {
+		return nil, err
+	}
 	return c, nil
 }
 
@@ -81,6 +92,10 @@ func (c *pgSQLContainer) Private() repository.Private     { return c.priv }
 func (c *pgSQLContainer) BlockList() repository.BlockList { return c.blockList }
 func (c *pgSQLContainer) PubSub() repository.PubSub       { return c.pubSub }
 func (c *pgSQLContainer) Offline() repository.Offline     { return c.offline }
+func (c *pgSQLContainer) Archive() repository.Archive     { return c.archive }
+func (c *pgSQLContainer) Push() repository.Push           { return c.push }
+func (c *pgSQLContainer) FastToken() repository.FastToken { return c.fastToken }
+func (c *pgSQLContainer) Muc() repository.Muc             { return c.muc }
 
 func (c *pgSQLContainer) Close(ctx context.Context) error {
 	ch := make(chan bool)
@@ -95,6 +110,18 @@ func (c *pgSQLContainer) Close(ctx context.Context) error {
 
 func (c *pgSQLContainer) IsClusterCompatible() bool { return true }
 
+// migrateUserCredentials converts legacy cleartext passwords into SCRAM credentials.
+func (c *pgSQLContainer) migrateUserCredentials() error {
+	n, err := c.user.migrateCredentials(context.Background())
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Infof("migrated %d user password(s) to SCRAM credentials", n)
+	}
+	return nil
+}
+
 func (c *pgSQLContainer) loop(ctx context.Context) {
 	tick := time.NewTicker(pingInterval)
 	defer tick.Stop()
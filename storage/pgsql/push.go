@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/xmpp"
+)
+
+type pgSQLPush struct {
+	*pgSQLStorage
+}
+
+func newPush(db *sql.DB) *pgSQLPush {
+	return &pgSQLPush{
+		pgSQLStorage: newStorage(db),
+	}
+}
+
+func (s *pgSQLPush) UpsertPushRegistration(ctx context.Context, registration *model.PushRegistration) error {
+	var options string
+	if registration.Options != nil {
+		options = registration.Options.String()
+	}
+	q := sq.Insert("push_registrations").
+		Columns("username", "jid", "node", "options").
+		Values(registration.Username, registration.JID, registration.Node, options).
+		Suffix("ON CONFLICT (username, jid, node) DO UPDATE SET options = $5", options)
+
+	_, err := q.RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *pgSQLPush) FetchPushRegistrations(ctx context.Context, username string) ([]model.PushRegistration, error) {
+	q := sq.Select("jid", "node", "options").
+		From("push_registrations").
+		Where(sq.Eq{"username": username}).
+		OrderBy("created_at")
+
+	rows, err := q.RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var registrations []model.PushRegistration
+	for rows.Next() {
+		var options string
+		reg := model.PushRegistration{Username: username}
+		if err := rows.Scan(&reg.JID, &reg.Node, &options); err != nil {
+			return nil, err
+		}
+		if len(options) > 0 {
+			parser := xmpp.NewParser(strings.NewReader(options), xmpp.DefaultMode, 0)
+			if reg.Options, err = parser.ParseElement(); err != nil {
+				return nil, err
+			}
+		}
+		registrations = append(registrations, reg)
+	}
+	return registrations, rows.Err()
+}
+
+func (s *pgSQLPush) DeletePushRegistrations(ctx context.Context, username, jid, node string) error {
+	where := sq.Eq{"username": username, "jid": jid}
+	if len(node) > 0 {
+		where["node"] = node
+	}
+	_, err := sq.Delete("push_registrations").Where(where).RunWith(s.db).ExecContext(ctx)
+	return err
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"context"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpsertPushRegistration(t *testing.T) {
+	options := xmpp.NewElementNamespace("x", "jabber:x:data")
+	reg := &model.PushRegistration{Username: "ortuman", JID: "push.jackal.im", Node: "n1", Options: options}
+
+	s, mock := newPushMock()
+	mock.ExpectExec("INSERT INTO push_registrations (.+) ON CONFLICT (.+)").
+		WithArgs("ortuman", "push.jackal.im", "n1", options.String(), options.String()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.UpsertPushRegistration(context.Background(), reg)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newPushMock()
+	mock.ExpectExec("INSERT INTO push_registrations (.+) ON CONFLICT (.+)").
+		WithArgs("ortuman", "push.jackal.im", "n1", options.String(), options.String()).
+		WillReturnError(errGeneric)
+
+	err = s.UpsertPushRegistration(context.Background(), reg)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errGeneric, err)
+}
+
+func TestFetchPushRegistrations(t *testing.T) {
+	var pushColumns = []string{"jid", "node", "options"}
+
+	s, mock := newPushMock()
+	mock.ExpectQuery("SELECT (.+) FROM push_registrations (.+)").
+		WithArgs("ortuman").
+		WillReturnRows(sqlmock.NewRows(pushColumns).
+			AddRow("push.jackal.im", "n1", `<x xmlns="jabber:x:data" type="submit"/>`).
+			AddRow("push.jackal.im", "n2", ""))
+
+	regs, err := s.FetchPushRegistrations(context.Background(), "ortuman")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, regs, 2)
+	require.Equal(t, "x", regs[0].Options.Name())
+	require.Nil(t, regs[1].Options)
+
+	s, mock = newPushMock()
+	mock.ExpectQuery("SELECT (.+) FROM push_registrations (.+)").
+		WithArgs("ortuman").
+		WillReturnError(errGeneric)
+
+	_, err = s.FetchPushRegistrations(context.Background(), "ortuman")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errGeneric, err)
+}
+
+func TestDeletePushRegistrations(t *testing.T) {
+	s, mock := newPushMock()
+	mock.ExpectExec("DELETE FROM push_registrations (.+)").
+		WithArgs("push.jackal.im", "n1", "ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.DeletePushRegistrations(context.Background(), "ortuman", "push.jackal.im", "n1")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newPushMock()
+	mock.ExpectExec("DELETE FROM push_registrations (.+)").
+		WithArgs("push.jackal.im", "ortuman").WillReturnError(errGeneric)
+
+	err = s.DeletePushRegistrations(context.Background(), "ortuman", "push.jackal.im", "")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errGeneric, err)
+}
+
+func newPushMock() (*pgSQLPush, sqlmock.Sqlmock) {
+	s, sqlMock := newStorageMock()
+	return &pgSQLPush{
+		pgSQLStorage: s,
+	}, sqlMock
+}
@@ -43,16 +43,19 @@ func (u *pgSQLUser) UpsertUser(ctx context.Context, usr *model.User) error {
 		u.pool.Put(buf)
 	}
 
+	scramSHA1, scramSHA256, scramSHA512 := usr.ScramSHA1.String(), usr.ScramSHA256.String(), usr.ScramSHA512.String()
+
 	q := sq.Insert("users")
 
 	if len(presenceXML) > 0 {
-		q = q.Columns("username", "password", "last_presence", "last_presence_at").
-			Values(usr.Username, usr.Password, presenceXML, nowExpr).
-			Suffix("ON CONFLICT (username) DO UPDATE SET password = $2, last_presence = $3, last_presence_at = NOW()")
+		q = q.Columns("username", "password", "scram_sha_1", "scram_sha_256", "scram_sha_512", "last_presence", "last_presence_at").
+			Values(usr.Username, usr.Password, scramSHA1, scramSHA256, scramSHA512, presenceXML, nowExpr).
+			Suffix("ON CONFLICT (username) DO UPDATE SET password = $2, scram_sha_1 = $3, scram_sha_256 = $4, scram_sha_512 = $5, last_presence = $6, last_presence_at = NOW()")
 	} else {
-		q = q.Columns("username", "password").
-			Values(usr.Username, usr.Password).
-			Suffix("ON CONFLICT (username) DO UPDATE SET password = $2")
+		// last presence columns are non-nullable... only set them on insertion
+		q = q.Columns("username", "password", "scram_sha_1", "scram_sha_256", "scram_sha_512", "last_presence", "last_presence_at").
+			Values(usr.Username, usr.Password, scramSHA1, scramSHA256, scramSHA512, "", nowExpr).
+			Suffix("ON CONFLICT (username) DO UPDATE SET password = $2, scram_sha_1 = $3, scram_sha_256 = $4, scram_sha_512 = $5")
 	}
 	_, err := q.RunWith(u.db).ExecContext(ctx)
 	return err
@@ -60,17 +63,22 @@ func (u *pgSQLUser) UpsertUser(ctx context.Context, usr *model.User) error {
 
 // FetchUser retrieves from storage a user entity.
 func (u *pgSQLUser) FetchUser(ctx context.Context, username string) (*model.User, error) {
-	q := sq.Select("username", "password", "last_presence", "last_presence_at").
+	q := sq.Select("username", "password", "scram_sha_1", "scram_sha_256", "scram_sha_512", "last_presence", "last_presence_at").
 		From("users").
 		Where(sq.Eq{"username": username})
 
+	var scramSHA1, scramSHA256, scramSHA512 string
 	var presenceXML string
 	var presenceAt time.Time
 	var usr model.User
 
-	err := q.RunWith(u.db).QueryRowContext(ctx).Scan(&usr.Username, &usr.Password, &presenceXML, &presenceAt)
+	err := q.RunWith(u.db).QueryRowContext(ctx).
+		Scan(&usr.Username, &usr.Password, &scramSHA1, &scramSHA256, &scramSHA512, &presenceXML, &presenceAt)
 	switch err {
 	case nil:
+		if err := scanScramCredentials(&usr, scramSHA1, scramSHA256, scramSHA512); err != nil {
+			return nil, err
+		}
 		if len(presenceXML) > 0 {
 			parser := xmpp.NewParser(strings.NewReader(presenceXML), xmpp.DefaultMode, 0)
 			lastPresence, err := parser.ParseElement()
@@ -135,3 +143,54 @@ func (u *pgSQLUser) UserExists(ctx context.Context, username string) (bool, erro
 		return false, err
 	}
 }
+
+// migrateCredentials replaces every stored cleartext password by its derived SCRAM credentials.
+func (u *pgSQLUser) migrateCredentials(ctx context.Context) (int, error) {
+	rows, err := sq.Select("username", "password").
+		From("users").
+		Where(sq.NotEq{"password": ""}).
+		RunWith(u.db).QueryContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var users []model.User
+	for rows.Next() {
+		var usr model.User
+		if err := rows.Scan(&usr.Username, &usr.Password); err != nil {
+			_ = rows.Close()
+			return 0, err
+		}
+		users = append(users, usr)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+	for _, usr := range users {
+		if err := usr.SetPassword(usr.Password); err != nil {
+			return 0, err
+		}
+		_, err := sq.Update("users").
+			Set("password", "").
+			Set("scram_sha_1", usr.ScramSHA1.String()).
+			Set("scram_sha_256", usr.ScramSHA256.String()).
+			Set("scram_sha_512", usr.ScramSHA512.String()).
+			Where(sq.Eq{"username": usr.Username}).
+			RunWith(u.db).ExecContext(ctx)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(users), nil
+}
+
+func scanScramCredentials(usr *model.User, scramSHA1, scramSHA256, scramSHA512 string) error {
+	var err error
+	if usr.ScramSHA1, err = model.ParseScramCredentials(scramSHA1); err != nil {
+		return err
+	}
+	if usr.ScramSHA256, err = model.ParseScramCredentials(scramSHA256); err != nil {
+		return err
+	}
+	usr.ScramSHA512, err = model.ParseScramCredentials(scramSHA512)
+	return err
+}
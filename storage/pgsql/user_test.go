@@ -7,6 +7,7 @@ package pgsql
 
 import (
 	"context"
+	"crypto/sha256"
 	"testing"
 	"time"
 
@@ -23,11 +24,12 @@ func TestInsertUser(t *testing.T) {
 	to, _ := jid.NewWithString("ortuman@jackal.im", true)
 	p := xmpp.NewPresence(from, to, xmpp.UnavailableType)
 
-	user := model.User{Username: "ortuman", Password: "1234", LastPresence: p}
+	creds := model.DeriveScramCredentials(sha256.New, "1234", []byte("salt"), model.ScramIterationsCount)
+	user := model.User{Username: "ortuman", ScramSHA256: creds, LastPresence: p}
 
 	s, mock := newUserMock()
 	mock.ExpectExec("INSERT INTO users (.+) ON CONFLICT (.+) DO UPDATE SET (.+)").
-		WithArgs(user.Username, user.Password, user.LastPresence.String()).
+		WithArgs(user.Username, "", "", creds.String(), "", user.LastPresence.String()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err := s.UpsertUser(context.Background(), &user)
@@ -36,7 +38,7 @@ func TestInsertUser(t *testing.T) {
 
 	s, mock = newUserMock()
 	mock.ExpectExec("INSERT INTO users (.+) ON CONFLICT (.+) DO UPDATE SET (.+)").
-		WithArgs(user.Username, user.Password, user.LastPresence.String()).
+		WithArgs(user.Username, "", "", creds.String(), "", user.LastPresence.String()).
 		WillReturnError(errMocked)
 
 	err = s.UpsertUser(context.Background(), &user)
@@ -44,6 +46,20 @@ func TestInsertUser(t *testing.T) {
 	require.Nil(t, mock.ExpectationsWereMet())
 }
 
+func TestInsertUserWithoutPresence(t *testing.T) {
+	user := model.User{Username: "ortuman", Password: "1234"}
+
+	// non-nullable last presence columns must be set anyway
+	s, mock := newUserMock()
+	mock.ExpectExec(`INSERT INTO users \(username,password,scram_sha_1,scram_sha_256,scram_sha_512,last_presence,last_presence_at\) (.+) ON CONFLICT \(username\) DO UPDATE SET password = \$2, scram_sha_1 = \$3, scram_sha_256 = \$4, scram_sha_512 = \$5$`).
+		WithArgs("ortuman", "1234", "", "", "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.UpsertUser(context.Background(), &user)
+	require.Nil(t, err)
+	require.Nil(t, mock.ExpectationsWereMet())
+}
+
 func TestDeleteUser(t *testing.T) {
 	s, mock := newUserMock()
 	mock.ExpectBegin()
@@ -81,7 +97,9 @@ func TestFetchUser(t *testing.T) {
 	to, _ := jid.NewWithString("ortuman@jackal.im", true)
 	p := xmpp.NewPresence(from, to, xmpp.UnavailableType)
 
-	var userColumns = []string{"username", "password", "last_presence", "last_presence_at"}
+	creds := model.DeriveScramCredentials(sha256.New, "1234", []byte("salt"), model.ScramIterationsCount)
+
+	var userColumns = []string{"username", "password", "scram_sha_1", "scram_sha_256", "scram_sha_512", "last_presence", "last_presence_at"}
 
 	s, mock := newUserMock()
 	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
@@ -95,10 +113,21 @@ func TestFetchUser(t *testing.T) {
 	s, mock = newUserMock()
 	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
 		WithArgs("ortuman").
-		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("ortuman", "1234", p.String(), time.Now()))
-	_, err := s.FetchUser(context.Background(), "ortuman")
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("ortuman", "", "", creds.String(), "", p.String(), time.Now()))
+	usr, err := s.FetchUser(context.Background(), "ortuman")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
+	require.Nil(t, usr.ScramSHA1)
+	require.Equal(t, creds, usr.ScramSHA256)
+	require.True(t, usr.VerifyPassword("1234"))
+
+	s, mock = newUserMock()
+	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
+		WithArgs("ortuman").
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("ortuman", "", "", "invalid", "", "", time.Now()))
+	_, err = s.FetchUser(context.Background(), "ortuman")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.NotNil(t, err)
 
 	s, mock = newUserMock()
 	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
@@ -108,6 +137,29 @@ func TestFetchUser(t *testing.T) {
 	require.Equal(t, errMocked, err)
 }
 
+func TestMigrateUserCredentials(t *testing.T) {
+	s, mock := newUserMock()
+	mock.ExpectQuery("SELECT username, password FROM users (.+)").
+		WithArgs("").
+		WillReturnRows(sqlmock.NewRows([]string{"username", "password"}).AddRow("ortuman", "1234"))
+	mock.ExpectExec("UPDATE users SET (.+)").
+		WithArgs("", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "ortuman").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := s.migrateCredentials(context.Background())
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 1, n)
+
+	s, mock = newUserMock()
+	mock.ExpectQuery("SELECT username, password FROM users (.+)").
+		WithArgs("").WillReturnError(errMocked)
+
+	_, err = s.migrateCredentials(context.Background())
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMocked, err)
+}
+
 func TestUserExists(t *testing.T) {
 	countColums := []string{"count"}
 
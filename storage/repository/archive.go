@@ -0,0 +1,25 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package repository
+
+import (
+	"context"
+
+	"github.com/ortuman/jackal/model"
+)
+
+// Archive defines storage operations for message archive (XEP-0313).
+type Archive interface {
+	// InsertArchivedMessage inserts a new message into user's archive.
+	InsertArchivedMessage(ctx context.Context, message *model.ArchivedMessage) error
+
+	// FetchArchivedMessages retrieves from storage user's archived messages matching a given query,
+	// in chronological order.
+	FetchArchivedMessages(ctx context.Context, username string, query *model.ArchiveQuery) ([]model.ArchivedMessage, error)
+
+	// DeleteArchivedMessages clears user's archive.
+	DeleteArchivedMessages(ctx context.Context, username string) error
+}
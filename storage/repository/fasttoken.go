@@ -0,0 +1,27 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package repository
+
+import (
+	"context"
+
+	"github.com/ortuman/jackal/model"
+)
+
+// FastToken defines storage operations for FAST (XEP-0484) authentication tokens.
+type FastToken interface {
+	// InsertFastToken inserts a new authentication token into storage.
+	InsertFastToken(ctx context.Context, token *model.FastToken) error
+
+	// FetchFastTokens retrieves from storage all authentication tokens associated to a given user.
+	FetchFastTokens(ctx context.Context, username string) ([]model.FastToken, error)
+
+	// DeleteFastToken deletes a user authentication token.
+	DeleteFastToken(ctx context.Context, username, token string) error
+
+	// DeleteFastTokens deletes all authentication tokens associated to a given user.
+	DeleteFastTokens(ctx context.Context, username string) error
+}
@@ -0,0 +1,36 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package repository
+
+import (
+	"context"
+
+	mucmodel "github.com/ortuman/jackal/model/muc"
+)
+
+// Muc defines storage operations for persistent multi-user chat rooms.
+type Muc interface {
+	// UpsertRoom inserts a new room entity into storage, or updates it if previously inserted.
+	UpsertRoom(ctx context.Context, room *mucmodel.Room) error
+
+	// FetchRoom retrieves from storage a room entity.
+	FetchRoom(ctx context.Context, host, name string) (*mucmodel.Room, error)
+
+	// FetchRooms retrieves from storage all room entities associated with a host.
+	FetchRooms(ctx context.Context, host string) ([]mucmodel.Room, error)
+
+	// DeleteRoom deletes a room and all its affiliations from storage.
+	DeleteRoom(ctx context.Context, host, name string) error
+
+	// UpsertRoomAffiliation inserts a new room affiliation into storage, or updates it if previously inserted.
+	UpsertRoomAffiliation(ctx context.Context, affiliation *mucmodel.Affiliation, host, name string) error
+
+	// DeleteRoomAffiliation deletes a room affiliation from storage.
+	DeleteRoomAffiliation(ctx context.Context, jid, host, name string) error
+
+	// FetchRoomAffiliations retrieves all affiliations associated to a room.
+	FetchRoomAffiliations(ctx context.Context, host, name string) ([]mucmodel.Affiliation, error)
+}
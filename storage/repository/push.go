@@ -0,0 +1,25 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package repository
+
+import (
+	"context"
+
+	"github.com/ortuman/jackal/model"
+)
+
+// Push defines storage operations for push notifications (XEP-0357) registrations.
+type Push interface {
+	// UpsertPushRegistration inserts a new push registration into storage, or updates it in case it's been previously inserted.
+	UpsertPushRegistration(ctx context.Context, registration *model.PushRegistration) error
+
+	// FetchPushRegistrations retrieves from storage all push registrations associated to a given user.
+	FetchPushRegistrations(ctx context.Context, username string) ([]model.PushRegistration, error)
+
+	// DeletePushRegistrations deletes user's push registrations associated to a given app server JID.
+	// In case node is empty every registration matching the app server JID will be deleted.
+	DeletePushRegistrations(ctx context.Context, username, jid, node string) error
+}
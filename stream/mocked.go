@@ -8,6 +8,7 @@ package stream
 import (
 	"context"
 	"errors"
+	"net"
 	"sync"
 	"time"
 
@@ -24,6 +25,7 @@ type MockC2S struct {
 	isCompressed    bool
 	isDisconnected  bool
 	jid             *jid.JID
+	remoteAddr      net.Addr
 	presence        *xmpp.Presence
 	elemCh          chan xmpp.XElement
 	actorCh         chan func()
@@ -136,6 +138,20 @@ func (m *MockC2S) IsDisconnected() bool {
 	return m.isDisconnected
 }
 
+// SetRemoteAddr sets the mocked stream remote peer address.
+func (m *MockC2S) SetRemoteAddr(addr net.Addr) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remoteAddr = addr
+}
+
+// RemoteAddr returns the mocked stream remote peer address.
+func (m *MockC2S) RemoteAddr() net.Addr {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.remoteAddr
+}
+
 // SetPresence sets the mocked stream last received
 // presence element.
 func (m *MockC2S) SetPresence(presence *xmpp.Presence) {
@@ -7,6 +7,7 @@ package stream
 
 import (
 	"context"
+	"net"
 
 	"github.com/ortuman/jackal/xmpp"
 	"github.com/ortuman/jackal/xmpp/jid"
@@ -42,6 +43,8 @@ type C2S interface {
 	IsSecured() bool
 	IsAuthenticated() bool
 
+	RemoteAddr() net.Addr
+
 	Presence() *xmpp.Presence
 }
 
@@ -0,0 +1,208 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package bosh
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/xmpp"
+)
+
+const (
+	httpBindNamespace = "http://jabber.org/protocol/httpbind"
+	xboshNamespace    = "urn:xmpp:xbosh"
+	streamNamespace   = "http://etherx.jabber.org/streams"
+
+	boshVersion = "1.11"
+)
+
+// Handler represents a BOSH (XEP-0124 & XEP-0206) connection manager.
+// Every created session is handed over as a transport.Transport, so that
+// the regular c2s stream state machine can be applied on top of it.
+type Handler struct {
+	cfg       *Config
+	onSession func(tr transport.Transport)
+	mu        sync.RWMutex
+	sessions  map[string]*session
+}
+
+// NewHandler returns a new BOSH connection manager handler.
+func NewHandler(config *Config, onSession func(tr transport.Transport)) *Handler {
+	cfg := *config
+	cfg.setDefaults()
+	return &Handler{
+		cfg:       &cfg,
+		onSession: onSession,
+		sessions:  make(map[string]*session),
+	}
+}
+
+// ServeHTTP satisfies http.Handler interface.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+		return
+	case http.MethodPost:
+		break
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := h.readBody(r.Body)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	rid, err := strconv.ParseInt(body.Attributes().Get("rid"), 10, 64)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	var sess *session
+	if sid := body.Attributes().Get("sid"); len(sid) > 0 {
+		sess = h.session(sid)
+		if sess == nil {
+			writeResponse(w, terminateBody("item-not-found", nil))
+			return
+		}
+	} else {
+		sess = h.createSession(body, rid, requestRemoteAddr(r))
+	}
+	req, condition := sess.handleRequest(body, rid)
+	if req == nil {
+		writeResponse(w, terminateBody(condition, nil))
+		return
+	}
+	select {
+	case resp := <-req.respCh:
+		writeResponse(w, resp)
+	case <-r.Context().Done():
+		sess.cancelRequest(req)
+	}
+}
+
+// Shutdown terminates all active BOSH sessions.
+func (h *Handler) Shutdown() {
+	h.mu.RLock()
+	var sessions []*session
+	for _, sess := range h.sessions {
+		sessions = append(sessions, sess)
+	}
+	h.mu.RUnlock()
+
+	for _, sess := range sessions {
+		_ = sess.Close()
+	}
+}
+
+func (h *Handler) createSession(body xmpp.XElement, rid int64, remoteAddr net.Addr) *session {
+	attrs := body.Attributes()
+
+	wait := h.cfg.Wait
+	if w, err := strconv.Atoi(attrs.Get("wait")); err == nil && w >= 0 && time.Duration(w)*time.Second < wait {
+		wait = time.Duration(w) * time.Second
+	}
+	hold := h.cfg.Hold
+	if hd, err := strconv.Atoi(attrs.Get("hold")); err == nil && hd >= 0 && hd < hold {
+		hold = hd
+	}
+	sess := newSession(uuid.New().String(), remoteAddr, h, rid-1, wait, hold)
+
+	h.mu.Lock()
+	h.sessions[sess.sid] = sess
+	h.mu.Unlock()
+
+	log.Infof("created bosh session... (sid: %s)", sess.sid)
+
+	go h.onSession(sess)
+	return sess
+}
+
+func (h *Handler) session(sid string) *session {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.sessions[sid]
+}
+
+func (h *Handler) removeSession(sid string) {
+	h.mu.Lock()
+	delete(h.sessions, sid)
+	h.mu.Unlock()
+
+	log.Infof("removed bosh session... (sid: %s)", sid)
+}
+
+func requestRemoteAddr(r *http.Request) net.Addr {
+	addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+	if err != nil {
+		return nil
+	}
+	return addr
+}
+
+func (h *Handler) readBody(r io.Reader) (xmpp.XElement, error) {
+	p := xmpp.NewParser(io.LimitReader(r, int64(h.cfg.MaxBodySize)), xmpp.DefaultMode, h.cfg.MaxBodySize)
+	for {
+		elem, err := p.ParseElement()
+		if err != nil {
+			return nil, err
+		}
+		if elem == nil {
+			continue // skip xml header
+		}
+		if elem.Name() != "body" || elem.Namespace() != httpBindNamespace {
+			return nil, errInvalidBody
+		}
+		return elem, nil
+	}
+}
+
+func writeResponse(w http.ResponseWriter, b []byte) {
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(b)
+}
+
+func terminateBody(condition string, payload []byte) []byte {
+	body := newBody()
+	body.SetType("terminate")
+	if len(condition) > 0 {
+		body.SetAttribute("condition", condition)
+	}
+	return encodeBody(body, payload)
+}
+
+func newBody() *xmpp.Element {
+	body := xmpp.NewElementNamespace("body", httpBindNamespace)
+	body.SetAttribute("xmlns:stream", streamNamespace)
+	return body
+}
+
+func encodeBody(body *xmpp.Element, payload []byte) []byte {
+	buf := new(bytes.Buffer)
+	if len(payload) == 0 {
+		_ = body.ToXML(buf, true)
+		return buf.Bytes()
+	}
+	_ = body.ToXML(buf, false)
+	buf.Write(payload)
+	buf.WriteString("</body>")
+	return buf.Bytes()
+}
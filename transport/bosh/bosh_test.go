@@ -0,0 +1,149 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package bosh
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBOSH_SessionLifecycle(t *testing.T) {
+	trCh := make(chan transport.Transport, 1)
+	h := NewHandler(&Config{Wait: time.Second}, func(tr transport.Transport) { trCh <- tr })
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	// create session
+	respCh := postAsync(srv.URL, `<body rid="100" to="localhost" wait="60" hold="1" xmpp:version="1.0" xmlns="http://jabber.org/protocol/httpbind" xmlns:xmpp="urn:xmpp:xbosh"/>`)
+
+	tr := <-trCh
+	require.Equal(t, transport.BOSH, tr.Type())
+
+	p := xmpp.NewParser(tr, xmpp.DefaultMode, 0)
+	open, err := p.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "body", open.Name())
+	require.Equal(t, "localhost", open.To())
+	require.Equal(t, "1.0", open.Version())
+
+	_ = xmpp.NewElementName("stream:features").ToXML(tr, true)
+	require.Nil(t, tr.Flush())
+
+	resp := parseBody(t, <-respCh)
+	sid := resp.Attributes().Get("sid")
+	require.True(t, len(sid) > 0)
+	require.Equal(t, "1", resp.Attributes().Get("hold"))
+	require.Equal(t, "1", resp.Attributes().Get("wait"))
+	require.NotNil(t, resp.Elements().Child("stream:features"))
+
+	// send a stanza
+	respCh = postAsync(srv.URL, `<body rid="101" sid="`+sid+`" xmlns="http://jabber.org/protocol/httpbind"><message xmlns="jabber:client" to="romeo@localhost"/></body>`)
+	msg, err := p.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "message", msg.Name())
+
+	_ = xmpp.NewElementName("message").ToXML(tr, true)
+	_ = tr.Flush()
+
+	resp = parseBody(t, <-respCh)
+	require.NotNil(t, resp.Elements().Child("message"))
+
+	// retransmitted request
+	resp = parseBody(t, <-postAsync(srv.URL, `<body rid="101" sid="`+sid+`" xmlns="http://jabber.org/protocol/httpbind"/>`))
+	require.NotNil(t, resp.Elements().Child("message"))
+
+	// empty request expires after 'wait' seconds
+	resp = parseBody(t, <-postAsync(srv.URL, `<body rid="102" sid="`+sid+`" xmlns="http://jabber.org/protocol/httpbind"/>`))
+	require.Equal(t, 0, resp.Elements().Count())
+
+	// client termination
+	respCh = postAsync(srv.URL, `<body rid="103" sid="`+sid+`" type="terminate" xmlns="http://jabber.org/protocol/httpbind"/>`)
+	for {
+		_, err = p.ParseElement()
+		if err != nil {
+			break
+		}
+	}
+	require.Equal(t, io.EOF, err)
+	_ = tr.Close()
+
+	resp = parseBody(t, <-respCh)
+	require.Equal(t, "terminate", resp.Type())
+
+	// unknown session
+	resp = parseBody(t, <-postAsync(srv.URL, `<body rid="104" sid="`+sid+`" xmlns="http://jabber.org/protocol/httpbind"/>`))
+	require.Equal(t, "terminate", resp.Type())
+	require.Equal(t, "item-not-found", resp.Attributes().Get("condition"))
+}
+
+func TestBOSH_InvalidRequests(t *testing.T) {
+	h := NewHandler(&Config{}, func(tr transport.Transport) {})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	resp, err = http.Post(srv.URL, "text/xml", strings.NewReader(`<iq/>`))
+	require.Nil(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = http.Post(srv.URL, "text/xml", strings.NewReader(`<body xmlns="http://jabber.org/protocol/httpbind"/>`))
+	require.Nil(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestBOSH_InactivityTimeout(t *testing.T) {
+	trCh := make(chan transport.Transport, 1)
+	h := NewHandler(&Config{Wait: time.Millisecond * 50, Inactivity: time.Millisecond * 50}, func(tr transport.Transport) { trCh <- tr })
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	respCh := postAsync(srv.URL, `<body rid="1" to="localhost" xmpp:version="1.0" xmlns="http://jabber.org/protocol/httpbind" xmlns:xmpp="urn:xmpp:xbosh"/>`)
+	tr := <-trCh
+	<-respCh
+
+	buf := make([]byte, 1024)
+	var err error
+	for err == nil {
+		_, err = tr.Read(buf)
+	}
+	require.Equal(t, io.EOF, err)
+}
+
+func postAsync(url, body string) <-chan []byte {
+	ch := make(chan []byte, 1)
+	go func() {
+		resp, err := http.Post(url, "text/xml; charset=utf-8", strings.NewReader(body))
+		if err != nil {
+			ch <- nil
+			return
+		}
+		defer func() { _ = resp.Body.Close() }()
+		b := new(bytes.Buffer)
+		_, _ = b.ReadFrom(resp.Body)
+		ch <- b.Bytes()
+	}()
+	return ch
+}
+
+func parseBody(t *testing.T, b []byte) xmpp.XElement {
+	p := xmpp.NewParser(bytes.NewReader(b), xmpp.DefaultMode, 0)
+	elem, err := p.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "body", elem.Name())
+	return elem
+}
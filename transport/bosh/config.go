@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package bosh
+
+import (
+	"time"
+)
+
+const (
+	defaultWait        = time.Duration(60) * time.Second
+	defaultHold        = 1
+	defaultInactivity  = time.Duration(60) * time.Second
+	defaultMaxPause    = time.Duration(120) * time.Second
+	defaultMaxBodySize = 65536
+)
+
+// Config represents a BOSH connection manager configuration.
+type Config struct {
+	Wait        time.Duration
+	Hold        int
+	Inactivity  time.Duration
+	MaxPause    time.Duration
+	MaxBodySize int
+}
+
+type configProxy struct {
+	Wait        int `yaml:"wait"`
+	Hold        int `yaml:"hold"`
+	Inactivity  int `yaml:"inactivity"`
+	MaxPause    int `yaml:"max_pause"`
+	MaxBodySize int `yaml:"max_body_size"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	c.Wait = time.Duration(p.Wait) * time.Second
+	c.Hold = p.Hold
+	c.Inactivity = time.Duration(p.Inactivity) * time.Second
+	c.MaxPause = time.Duration(p.MaxPause) * time.Second
+	c.MaxBodySize = p.MaxBodySize
+	c.setDefaults()
+	return nil
+}
+
+func (c *Config) setDefaults() {
+	if c.Wait == 0 {
+		c.Wait = defaultWait
+	}
+	if c.Hold == 0 {
+		c.Hold = defaultHold
+	}
+	if c.Inactivity == 0 {
+		c.Inactivity = defaultInactivity
+	}
+	if c.MaxPause == 0 {
+		c.MaxPause = defaultMaxPause
+	}
+	if c.MaxBodySize == 0 {
+		c.MaxBodySize = defaultMaxBodySize
+	}
+}
@@ -0,0 +1,345 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package bosh
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/transport/compress"
+	"github.com/ortuman/jackal/xmpp"
+)
+
+var errInvalidBody = errors.New("bosh: invalid body element")
+
+type request struct {
+	rid    int64
+	respCh chan []byte
+	waitTm *time.Timer
+}
+
+type incoming struct {
+	body xmpp.XElement
+	req  *request
+}
+
+// session represents a single BOSH session, exposed to the c2s stream as a transport.
+type session struct {
+	sid        string
+	remoteAddr net.Addr
+	h          *Handler
+	wait       time.Duration
+	hold       int
+	requests   int
+
+	mu           sync.Mutex
+	readCond     *sync.Cond
+	rb           bytes.Buffer
+	wb           bytes.Buffer
+	outQ         bytes.Buffer
+	held         []*request
+	pendingIn    map[int64]*incoming
+	responses    map[int64][]byte
+	lastRID      int64
+	opened       bool
+	created      bool
+	terminated   bool
+	closed       bool
+	inactivityTm *time.Timer
+}
+
+func newSession(sid string, remoteAddr net.Addr, h *Handler, lastRID int64, wait time.Duration, hold int) *session {
+	s := &session{
+		sid:        sid,
+		remoteAddr: remoteAddr,
+		h:          h,
+		wait:       wait,
+		hold:       hold,
+		requests:   hold + 1,
+		lastRID:    lastRID,
+		pendingIn:  make(map[int64]*incoming),
+		responses:  make(map[int64][]byte),
+	}
+	s.readCond = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *session) Read(p []byte) (n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.rb.Len() == 0 && !s.terminated && !s.closed {
+		s.readCond.Wait()
+	}
+	if s.rb.Len() == 0 {
+		return 0, io.EOF
+	}
+	return s.rb.Read(p)
+}
+
+func (s *session) Write(p []byte) (n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.wb.Write(p)
+}
+
+func (s *session) WriteString(str string) (n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.wb.WriteString(str)
+}
+
+// Close terminates BOSH session delivering any pending payload.
+func (s *session) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	_, _ = s.outQ.ReadFrom(&s.wb)
+	if s.inactivityTm != nil {
+		s.inactivityTm.Stop()
+	}
+	s.dispatch()
+	s.readCond.Broadcast()
+	s.mu.Unlock()
+
+	s.h.removeSession(s.sid)
+	return nil
+}
+
+func (s *session) Type() transport.Type {
+	return transport.BOSH
+}
+
+// Flush makes buffered data available to the next held request.
+func (s *session) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.wb.Len() == 0 {
+		return nil
+	}
+	_, _ = s.outQ.ReadFrom(&s.wb)
+	s.dispatch()
+	return nil
+}
+
+func (s *session) SetWriteDeadline(_ time.Time) error { return nil }
+
+func (s *session) StartTLS(_ *tls.Config, _ bool) {}
+
+func (s *session) EnableCompression(_ compress.Level) {}
+
+func (s *session) ChannelBindingBytes(_ transport.ChannelBindingMechanism) []byte { return nil }
+
+func (s *session) PeerCertificates() []*x509.Certificate { return nil }
+
+func (s *session) RemoteAddr() net.Addr { return s.remoteAddr }
+
+func (s *session) handleRequest(body xmpp.XElement, rid int64) (*request, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, "item-not-found"
+	}
+	if rid <= s.lastRID {
+		// retransmission of an already answered request?
+		if resp, ok := s.responses[rid]; ok {
+			req := &request{rid: rid, respCh: make(chan []byte, 1)}
+			req.respCh <- resp
+			return req, ""
+		}
+		s.terminate()
+		return nil, "item-not-found"
+	}
+	if rid > s.lastRID+int64(s.requests) {
+		s.terminate()
+		return nil, "item-not-found"
+	}
+	req := &request{rid: rid, respCh: make(chan []byte, 1)}
+	s.pendingIn[rid] = &incoming{body: body, req: req}
+
+	// process requests in rid order
+	for {
+		in := s.pendingIn[s.lastRID+1]
+		if in == nil {
+			break
+		}
+		delete(s.pendingIn, s.lastRID+1)
+		s.lastRID++
+		s.processBody(in.body, in.req)
+	}
+	return req, ""
+}
+
+func (s *session) cancelRequest(req *request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, r := range s.held {
+		if r == req {
+			r.waitTm.Stop()
+			s.held = append(s.held[:i], s.held[i+1:]...)
+			break
+		}
+	}
+	s.scheduleInactivity(s.h.cfg.Inactivity)
+}
+
+func (s *session) processBody(body xmpp.XElement, req *request) {
+	attrs := body.Attributes()
+
+	if !s.opened || attrs.Get("xmpp:restart") == "true" {
+		// open (or restart) stream on behalf of the client
+		open := xmpp.NewElementNamespace("body", httpBindNamespace)
+		to := attrs.Get("to")
+		if len(to) == 0 {
+			to = body.To()
+		}
+		open.SetTo(to)
+		open.SetVersion(attrs.Get("xmpp:version"))
+		_ = open.ToXML(&s.rb, true)
+		s.opened = true
+	}
+	for _, el := range body.Elements().All() {
+		_ = el.ToXML(&s.rb, true)
+	}
+	if s.rb.Len() == 0 {
+		// [xep-0124] empty requests are handled as whitespace keepalives
+		s.rb.WriteString(" ")
+	}
+	s.readCond.Broadcast()
+
+	req.waitTm = time.AfterFunc(s.wait, func() { s.expireRequest(req) })
+	s.held = append(s.held, req)
+
+	switch {
+	case body.Type() == "terminate":
+		s.terminate()
+
+	case len(attrs.Get("pause")) > 0:
+		pause, err := strconv.Atoi(attrs.Get("pause"))
+		if err != nil || pause < 0 {
+			break
+		}
+		pauseDuration := time.Duration(pause) * time.Second
+		if pauseDuration > s.h.cfg.MaxPause {
+			pauseDuration = s.h.cfg.MaxPause
+		}
+		// release all held requests
+		for len(s.held) > 0 {
+			s.respond(s.popHeld(), nil)
+		}
+		s.scheduleInactivity(pauseDuration)
+		return
+	}
+	s.dispatch()
+}
+
+func (s *session) expireRequest(req *request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, r := range s.held {
+		if r == req {
+			s.held = append(s.held[:i], s.held[i+1:]...)
+			s.respond(req, nil)
+			break
+		}
+	}
+	s.scheduleInactivity(s.h.cfg.Inactivity)
+}
+
+// dispatch answers held requests according to pending payload and session state.
+func (s *session) dispatch() {
+	if s.closed {
+		for len(s.held) > 0 {
+			req := s.popHeld()
+			condition := ""
+			if bytes.Contains(s.outQ.Bytes(), []byte("<stream:error")) {
+				condition = "remote-stream-error"
+			}
+			resp := terminateBody(condition, s.outQ.Bytes())
+			s.outQ.Reset()
+			s.cacheResponse(req.rid, resp)
+			req.respCh <- resp
+		}
+		return
+	}
+	if s.outQ.Len() > 0 && len(s.held) > 0 {
+		payload := s.outQ.Bytes()
+		s.outQ = bytes.Buffer{}
+		s.respond(s.popHeld(), payload)
+	}
+	for len(s.held) > s.hold {
+		s.respond(s.popHeld(), nil)
+	}
+	if len(s.held) == 0 {
+		s.scheduleInactivity(s.h.cfg.Inactivity)
+	} else if s.inactivityTm != nil {
+		s.inactivityTm.Stop()
+	}
+}
+
+func (s *session) respond(req *request, payload []byte) {
+	body := newBody()
+	if !s.created {
+		body.SetAttribute("sid", s.sid)
+		body.SetAttribute("wait", strconv.Itoa(int(s.wait.Seconds())))
+		body.SetAttribute("hold", strconv.Itoa(s.hold))
+		body.SetAttribute("requests", strconv.Itoa(s.requests))
+		body.SetAttribute("inactivity", strconv.Itoa(int(s.h.cfg.Inactivity.Seconds())))
+		body.SetAttribute("maxpause", strconv.Itoa(int(s.h.cfg.MaxPause.Seconds())))
+		body.SetAttribute("ver", boshVersion)
+		body.SetAttribute("xmlns:xmpp", xboshNamespace)
+		body.SetAttribute("xmpp:version", "1.0")
+		body.SetAttribute("xmpp:restartlogic", "true")
+		s.created = true
+	}
+	resp := encodeBody(body, payload)
+	s.cacheResponse(req.rid, resp)
+	req.respCh <- resp
+}
+
+func (s *session) cacheResponse(rid int64, resp []byte) {
+	s.responses[rid] = resp
+	delete(s.responses, rid-int64(s.requests))
+}
+
+func (s *session) popHeld() *request {
+	req := s.held[0]
+	s.held = s.held[1:]
+	req.waitTm.Stop()
+	return req
+}
+
+func (s *session) terminate() {
+	s.terminated = true
+	s.readCond.Broadcast()
+}
+
+func (s *session) scheduleInactivity(d time.Duration) {
+	if s.closed || len(s.held) > 0 {
+		return
+	}
+	if s.inactivityTm != nil {
+		s.inactivityTm.Stop()
+	}
+	s.inactivityTm = time.AfterFunc(d, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if len(s.held) == 0 {
+			s.terminate()
+		}
+	})
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package proxyproto
+
+import (
+	"fmt"
+	"net"
+)
+
+// Config represents PROXY protocol configuration.
+type Config struct {
+	Enabled bool
+	Trusted []*net.IPNet
+}
+
+type configProxy struct {
+	Enabled bool     `yaml:"enabled"`
+	Trusted []string `yaml:"trusted_proxies"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	var trusted []*net.IPNet
+	for _, cidr := range p.Trusted {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("proxyproto.Config: invalid trusted proxy network: %s", cidr)
+		}
+		trusted = append(trusted, ipNet)
+	}
+	// otherwise any peer could spoof its own address
+	if p.Enabled && len(trusted) == 0 {
+		return fmt.Errorf("proxyproto.Config: trusted_proxies must be set when enabled")
+	}
+	c.Enabled = p.Enabled
+	c.Trusted = trusted
+	return nil
+}
+
+// IsTrusted tells whether or not a PROXY protocol header is expected to be sent by addr peer.
+// No peer is trusted in case no trusted network has been configured.
+func (c *Config) IsTrusted(addr net.Addr) bool {
+	if !c.Enabled || len(c.Trusted) == 0 {
+		return false
+	}
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case nil:
+		return false
+	default:
+		host, _, err := net.SplitHostPort(addr.String())
+		if err != nil {
+			return false
+		}
+		ip = net.ParseIP(host)
+	}
+	for _, ipNet := range c.Trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
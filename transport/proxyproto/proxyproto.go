@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	v1Prefix       = "PROXY "
+	v1MaxLength    = 107
+	v2HeaderLength = 16
+
+	v2CmdLocal = 0x0
+	v2CmdProxy = 0x1
+
+	v2FamilyInet  = 0x1
+	v2FamilyInet6 = 0x2
+	v2ProtoStream = 0x1
+)
+
+var v2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+// ErrInvalidHeader is returned when no valid PROXY protocol header could be read from a connection.
+var ErrInvalidHeader = errors.New("proxyproto: invalid header")
+
+type conn struct {
+	net.Conn
+	br         *bufio.Reader
+	remoteAddr net.Addr
+}
+
+func (c *conn) Read(p []byte) (int, error) {
+	return c.br.Read(p)
+}
+
+// NetConn returns the underlying connection.
+func (c *conn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// RemoteAddr returns the original client address conveyed by the proxy, if any.
+func (c *conn) RemoteAddr() net.Addr {
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// Accept reads a PROXY protocol (v1 or v2) header from c, returning a connection
+// reporting the original client address as its remote address.
+// Connections originated by the proxy itself (e.g. health checks) keep reporting the proxy address.
+func Accept(c net.Conn, timeout time.Duration) (net.Conn, error) {
+	if timeout > 0 {
+		_ = c.SetReadDeadline(time.Now().Add(timeout))
+		defer func() { _ = c.SetReadDeadline(time.Time{}) }()
+	}
+	br := bufio.NewReader(c)
+
+	prefix, err := br.Peek(len(v1Prefix))
+	if err != nil {
+		return nil, err
+	}
+	var remoteAddr net.Addr
+	if string(prefix) == v1Prefix {
+		remoteAddr, err = readV1Header(br)
+	} else {
+		remoteAddr, err = readV2Header(br)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: c, br: br, remoteAddr: remoteAddr}, nil
+}
+
+// readV1Header reads a human-readable header such as 'PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n'.
+func readV1Header(br *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for len(line) < v1MaxLength {
+		b, err := br.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, ErrInvalidHeader
+	}
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) < 2 {
+		return nil, ErrInvalidHeader
+	}
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil, nil
+	case "TCP4", "TCP6":
+		if len(fields) != 6 {
+			return nil, ErrInvalidHeader
+		}
+	default:
+		return nil, ErrInvalidHeader
+	}
+	ip := net.ParseIP(fields[2])
+	if ip == nil || (fields[1] == "TCP4") != (ip.To4() != nil) {
+		return nil, ErrInvalidHeader
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, ErrInvalidHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readV2Header reads a binary header as described in section 2.2 of PROXY protocol specification.
+func readV2Header(br *bufio.Reader) (net.Addr, error) {
+	hdr := make([]byte, v2HeaderLength)
+	if _, err := io.ReadFull(br, hdr); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(hdr[:len(v2Signature)], v2Signature) || hdr[12]>>4 != 0x2 {
+		return nil, ErrInvalidHeader
+	}
+	cmd := hdr[12] & 0x0F
+	family, proto := hdr[13]>>4, hdr[13]&0x0F
+
+	payload := make([]byte, binary.BigEndian.Uint16(hdr[14:16]))
+	if _, err := io.ReadFull(br, payload); err != nil {
+		return nil, err
+	}
+	switch cmd {
+	case v2CmdLocal:
+		return nil, nil
+	case v2CmdProxy:
+		break
+	default:
+		return nil, ErrInvalidHeader
+	}
+	if proto != v2ProtoStream {
+		return nil, nil // unsupported transport protocol... ignore conveyed addresses
+	}
+	switch family {
+	case v2FamilyInet:
+		if len(payload) < 12 {
+			return nil, ErrInvalidHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case v2FamilyInet6:
+		if len(payload) < 36 {
+			return nil, ErrInvalidHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	}
+	return nil, nil
+}
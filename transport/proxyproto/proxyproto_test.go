@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package proxyproto
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig(t *testing.T) {
+	var cfg Config
+	err := yaml.Unmarshal([]byte("{enabled: true, trusted_proxies: [10.0.0.0/8]}"), &cfg)
+	require.Nil(t, err)
+	require.True(t, cfg.Enabled)
+	require.Len(t, cfg.Trusted, 1)
+
+	require.True(t, cfg.IsTrusted(&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 5222}))
+	require.False(t, cfg.IsTrusted(&net.TCPAddr{IP: net.ParseIP("192.168.1.1"), Port: 5222}))
+
+	cfg.Trusted = nil
+	require.False(t, cfg.IsTrusted(&net.TCPAddr{IP: net.ParseIP("192.168.1.1"), Port: 5222}))
+
+	cfg.Enabled = false
+	require.False(t, cfg.IsTrusted(&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 5222}))
+
+	err = yaml.Unmarshal([]byte("{enabled: true, trusted_proxies: [10.0.0.1]}"), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("{enabled: true}"), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("{enabled: false}"), &cfg)
+	require.Nil(t, err)
+}
+
+func TestAccept_V1(t *testing.T) {
+	c, err := tUtilAccept("PROXY TCP4 192.168.0.1 192.168.0.11 56324 5222\r\n<stream>")
+	require.Nil(t, err)
+	require.Equal(t, "192.168.0.1:56324", c.RemoteAddr().String())
+	tUtilRequireRemaining(t, c, "<stream>")
+
+	c, err = tUtilAccept("PROXY TCP6 2001:db8::1 2001:db8::2 56324 5222\r\n<stream>")
+	require.Nil(t, err)
+	require.Equal(t, "[2001:db8::1]:56324", c.RemoteAddr().String())
+
+	// unknown connection... proxy address is kept
+	c, err = tUtilAccept("PROXY UNKNOWN\r\n<stream>")
+	require.Nil(t, err)
+	require.Equal(t, "pipe", c.RemoteAddr().String())
+	tUtilRequireRemaining(t, c, "<stream>")
+
+	_, err = tUtilAccept("PROXY TCP4 2001:db8::1 192.168.0.11 56324 5222\r\n")
+	require.Equal(t, ErrInvalidHeader, err)
+
+	_, err = tUtilAccept("PROXY TCP4 192.168.0.1 192.168.0.11 56324\r\n")
+	require.Equal(t, ErrInvalidHeader, err)
+
+	_, err = tUtilAccept("<?xml version='1.0'?><stream:stream>")
+	require.Equal(t, ErrInvalidHeader, err)
+}
+
+func TestAccept_V2(t *testing.T) {
+	payload := make([]byte, 12)
+	copy(payload[0:4], net.ParseIP("192.168.0.1").To4())
+	copy(payload[4:8], net.ParseIP("192.168.0.11").To4())
+	binary.BigEndian.PutUint16(payload[8:10], 56324)
+	binary.BigEndian.PutUint16(payload[10:12], 5222)
+
+	c, err := tUtilAccept(string(tUtilV2Header(v2CmdProxy, v2FamilyInet, payload)) + "<stream>")
+	require.Nil(t, err)
+	require.Equal(t, "192.168.0.1:56324", c.RemoteAddr().String())
+	tUtilRequireRemaining(t, c, "<stream>")
+
+	payload = make([]byte, 36+8) // including TLVs
+	copy(payload[0:16], net.ParseIP("2001:db8::1"))
+	copy(payload[16:32], net.ParseIP("2001:db8::2"))
+	binary.BigEndian.PutUint16(payload[32:34], 56324)
+	binary.BigEndian.PutUint16(payload[34:36], 5222)
+
+	c, err = tUtilAccept(string(tUtilV2Header(v2CmdProxy, v2FamilyInet6, payload)) + "<stream>")
+	require.Nil(t, err)
+	require.Equal(t, "[2001:db8::1]:56324", c.RemoteAddr().String())
+	tUtilRequireRemaining(t, c, "<stream>")
+
+	// health check
+	c, err = tUtilAccept(string(tUtilV2Header(v2CmdLocal, 0, nil)) + "<stream>")
+	require.Nil(t, err)
+	require.Equal(t, "pipe", c.RemoteAddr().String())
+
+	// truncated address block
+	_, err = tUtilAccept(string(tUtilV2Header(v2CmdProxy, v2FamilyInet, make([]byte, 4))) + "<stream>")
+	require.Equal(t, ErrInvalidHeader, err)
+}
+
+func tUtilAccept(s string) (net.Conn, error) {
+	c1, c2 := net.Pipe()
+	go func() {
+		_, _ = c2.Write([]byte(s))
+		_ = c2.Close()
+	}()
+	return Accept(c1, time.Second)
+}
+
+func tUtilRequireRemaining(t *testing.T, c net.Conn, expected string) {
+	b, _ := ioutil.ReadAll(c)
+	require.Equal(t, expected, string(b))
+}
+
+func tUtilV2Header(cmd, family byte, payload []byte) []byte {
+	hdr := append([]byte{}, v2Signature...)
+	hdr = append(hdr, 0x20|cmd, family<<4|v2ProtoStream, 0, 0)
+	binary.BigEndian.PutUint16(hdr[14:16], uint16(len(payload)))
+	return append(hdr, payload...)
+}
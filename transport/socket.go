@@ -21,6 +21,7 @@ const socketBuffSize = 4096
 
 type socketTransport struct {
 	conn       net.Conn
+	tlsCfg     *tls.Config
 	rw         io.ReadWriter
 	br         *bufio.Reader
 	bw         *bufio.Writer
@@ -38,6 +39,14 @@ func NewSocketTransport(conn net.Conn) Transport {
 	return s
 }
 
+// NewTLSSocketTransport creates a socket class stream transport over an already established
+// server TLS connection. cfg must be the configuration the connection was created with.
+func NewTLSSocketTransport(conn *tls.Conn, cfg *tls.Config) Transport {
+	s := NewSocketTransport(conn).(*socketTransport)
+	s.tlsCfg = cfg
+	return s
+}
+
 func (s *socketTransport) Read(p []byte) (n int, err error) {
 	return s.br.Read(p)
 }
@@ -70,11 +79,12 @@ func (s *socketTransport) SetWriteDeadline(d time.Time) error {
 }
 
 func (s *socketTransport) StartTLS(cfg *tls.Config, asClient bool) {
-	if _, ok := s.conn.(*net.TCPConn); ok {
+	if isNetworkConn(s.conn) {
 		if asClient {
 			s.conn = tls.Client(s.conn, cfg)
 		} else {
 			s.conn = tls.Server(s.conn, cfg)
+			s.tlsCfg = cfg
 		}
 		s.rw = s.conn
 		s.bw.Reset(s.rw)
@@ -93,13 +103,7 @@ func (s *socketTransport) EnableCompression(level compress.Level) {
 
 func (s *socketTransport) ChannelBindingBytes(mechanism ChannelBindingMechanism) []byte {
 	if conn, ok := s.conn.(tlsStateQueryable); ok {
-		switch mechanism {
-		case TLSUnique:
-			st := conn.ConnectionState()
-			return st.TLSUnique
-		default:
-			break
-		}
+		return channelBindingBytes(conn.ConnectionState(), mechanism, s.tlsCfg)
 	}
 	return nil
 }
@@ -111,3 +115,29 @@ func (s *socketTransport) PeerCertificates() []*x509.Certificate {
 	}
 	return nil
 }
+
+func (s *socketTransport) RemoteAddr() net.Addr {
+	return s.conn.RemoteAddr()
+}
+
+// netConnWrapper is implemented by connections wrapping an underlying network connection,
+// such as the ones admitted by a connection limiter or accepted from a PROXY protocol peer.
+type netConnWrapper interface {
+	NetConn() net.Conn
+}
+
+// isNetworkConn tells whether or not a connection is backed by a not yet secured TCP socket.
+func isNetworkConn(conn net.Conn) bool {
+	for {
+		switch c := conn.(type) {
+		case *tls.Conn:
+			return false
+		case *net.TCPConn:
+			return true
+		case netConnWrapper:
+			conn = c.NetConn()
+		default:
+			return false
+		}
+	}
+}
@@ -7,6 +7,7 @@ package transport
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"crypto/tls"
 	"net"
 	"testing"
@@ -49,6 +50,12 @@ var (
 func (a fakeAddr) Network() string { return "net" }
 func (a fakeAddr) String() string  { return "str" }
 
+type fakeWrappedConn struct {
+	net.Conn
+}
+
+func (c *fakeWrappedConn) NetConn() net.Conn { return c.Conn }
+
 func TestSocket(t *testing.T) {
 	buff := make([]byte, 4096)
 	conn := newFakeSocketConn()
@@ -73,6 +80,17 @@ func TestSocket(t *testing.T) {
 	st.StartTLS(&tls.Config{}, false)
 	_, ok := st2.conn.(*tls.Conn)
 	require.True(t, ok)
+
+	// already secured connections are left untouched
+	tlsConn := st2.conn
+	st.StartTLS(&tls.Config{}, false)
+	require.Equal(t, tlsConn, st2.conn)
+
+	// wrapped network connections are secured as well
+	st.(*socketTransport).conn = &fakeWrappedConn{Conn: &net.TCPConn{}}
+	st.StartTLS(&tls.Config{}, false)
+	_, ok = st2.conn.(*tls.Conn)
+	require.True(t, ok)
 	st.(*socketTransport).conn = conn
 
 	require.Nil(t, st2.ChannelBindingBytes(ChannelBindingMechanism(99)))
@@ -81,3 +99,38 @@ func TestSocket(t *testing.T) {
 	st.Close()
 	require.True(t, conn.closed)
 }
+
+func TestSocketTransportChannelBinding(t *testing.T) {
+	cer, err := tls.LoadX509KeyPair("../testdata/cert/test.server.crt", "../testdata/cert/test.server.key")
+	require.Nil(t, err)
+
+	srvConn, cliConn := net.Pipe()
+	defer func() { _ = srvConn.Close() }()
+
+	srvCfg := &tls.Config{Certificates: []tls.Certificate{cer}}
+	srvTLSConn := tls.Server(srvConn, srvCfg)
+	cliTLSConn := tls.Client(cliConn, &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS13})
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- cliTLSConn.Handshake() }()
+	require.Nil(t, srvTLSConn.Handshake())
+	require.Nil(t, <-errCh)
+
+	st := NewTLSSocketTransport(srvTLSConn, srvCfg)
+	cliState := cliTLSConn.ConnectionState()
+
+	// tls-unique is not defined for TLS 1.3
+	require.Nil(t, st.ChannelBindingBytes(TLSUnique))
+
+	exporterBytes, err := cliState.ExportKeyingMaterial("EXPORTER-Channel-Binding", nil, 32)
+	require.Nil(t, err)
+	require.Equal(t, exporterBytes, st.ChannelBindingBytes(TLSExporter))
+
+	endPointBytes := sha256.Sum256(cliState.PeerCertificates[0].Raw)
+	require.Equal(t, endPointBytes[:], st.ChannelBindingBytes(TLSServerEndPoint))
+
+	require.Equal(t, "tls-unique", TLSUnique.String())
+	require.Equal(t, "tls-exporter", TLSExporter.String())
+	require.Equal(t, "tls-server-end-point", TLSServerEndPoint.String())
+	require.Equal(t, "", ChannelBindingMechanism(99).String())
+}
@@ -6,20 +6,30 @@
 package transport
 
 import (
+	"crypto/sha256"
+	"crypto/sha512"
 	"crypto/tls"
 	"crypto/x509"
+	"hash"
 	"io"
+	"net"
 	"time"
 
 	"github.com/ortuman/jackal/transport/compress"
 )
 
-// Type represents a stream transport type (socket).
+// Type represents a stream transport type (socket, websocket or bosh).
 type Type int
 
 const (
 	// Socket represents a socket transport type.
 	Socket Type = iota + 1
+
+	// WebSocket represents a websocket transport type.
+	WebSocket
+
+	// BOSH represents a BOSH (XEP-0124) transport type.
+	BOSH
 )
 
 // String returns TransportType string representation.
@@ -27,6 +37,10 @@ func (tt Type) String() string {
 	switch tt {
 	case Socket:
 		return "socket"
+	case WebSocket:
+		return "websocket"
+	case BOSH:
+		return "bosh"
 	}
 	return ""
 }
@@ -37,8 +51,30 @@ type ChannelBindingMechanism int
 const (
 	// TLSUnique represents 'tls-unique' channel binding mechanism.
 	TLSUnique ChannelBindingMechanism = iota
+
+	// TLSExporter represents 'tls-exporter' channel binding mechanism (RFC 9266).
+	TLSExporter
+
+	// TLSServerEndPoint represents 'tls-server-end-point' channel binding mechanism (RFC 5929).
+	TLSServerEndPoint
 )
 
+// String returns ChannelBindingMechanism string representation.
+func (cb ChannelBindingMechanism) String() string {
+	switch cb {
+	case TLSUnique:
+		return "tls-unique"
+	case TLSExporter:
+		return "tls-exporter"
+	case TLSServerEndPoint:
+		return "tls-server-end-point"
+	}
+	return ""
+}
+
+// ChannelBindingMechanisms contains all supported channel binding mechanisms.
+var ChannelBindingMechanisms = []ChannelBindingMechanism{TLSExporter, TLSServerEndPoint, TLSUnique}
+
 // Transport represents a stream transport mechanism.
 type Transport interface {
 	io.ReadWriteCloser
@@ -66,8 +102,90 @@ type Transport interface {
 
 	// PeerCertificates returns the certificate chain presented by remote peer.
 	PeerCertificates() []*x509.Certificate
+
+	// RemoteAddr returns the remote peer network address, if known.
+	RemoteAddr() net.Addr
 }
 
 type tlsStateQueryable interface {
 	ConnectionState() tls.ConnectionState
 }
+
+const exporterLabel = "EXPORTER-Channel-Binding"
+
+// channelBindingBytes returns the channel binding data of a TLS connection.
+// cfg is the server TLS configuration used to resolve the local certificate.
+func channelBindingBytes(st tls.ConnectionState, mechanism ChannelBindingMechanism, cfg *tls.Config) []byte {
+	switch mechanism {
+	case TLSUnique:
+		return st.TLSUnique
+
+	case TLSExporter:
+		// [rfc9266] only defined for TLS 1.3 or when extended master secret has been negotiated
+		b, err := st.ExportKeyingMaterial(exporterLabel, nil, 32)
+		if err != nil {
+			return nil
+		}
+		return b
+
+	case TLSServerEndPoint:
+		cert := serverCertificate(st, cfg)
+		if cert == nil {
+			return nil
+		}
+		// [rfc5929] MD5 and SHA-1 signature hashes are upgraded to SHA-256
+		var h hash.Hash
+		switch cert.SignatureAlgorithm {
+		case x509.SHA384WithRSA, x509.ECDSAWithSHA384, x509.SHA384WithRSAPSS:
+			h = sha512.New384()
+		case x509.SHA512WithRSA, x509.ECDSAWithSHA512, x509.SHA512WithRSAPSS:
+			h = sha512.New()
+		default:
+			h = sha256.New()
+		}
+		h.Write(cert.Raw)
+		return h.Sum(nil)
+	}
+	return nil
+}
+
+// serverCertificate returns the leaf certificate presented by the server side of a TLS connection.
+func serverCertificate(st tls.ConnectionState, cfg *tls.Config) *x509.Certificate {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.GetCertificate != nil {
+		cert, err := cfg.GetCertificate(&tls.ClientHelloInfo{ServerName: st.ServerName})
+		if err == nil && cert != nil {
+			return leafCertificate(cert)
+		}
+	}
+	var first *x509.Certificate
+	for i := range cfg.Certificates {
+		leaf := leafCertificate(&cfg.Certificates[i])
+		if leaf == nil {
+			continue
+		}
+		if len(st.ServerName) == 0 || leaf.VerifyHostname(st.ServerName) == nil {
+			return leaf
+		}
+		if first == nil {
+			first = leaf
+		}
+	}
+	return first
+}
+
+func leafCertificate(cert *tls.Certificate) *x509.Certificate {
+	if cert.Leaf != nil {
+		return cert.Leaf
+	}
+	if len(cert.Certificate) == 0 {
+		return nil
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil
+	}
+	return leaf
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package transport
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ortuman/jackal/transport/compress"
+)
+
+// WebSocketConn represents the subset of websocket connection methods used by the transport.
+type WebSocketConn interface {
+	NextReader() (messageType int, r io.Reader, err error)
+	WriteMessage(messageType int, data []byte) error
+	SetWriteDeadline(t time.Time) error
+	UnderlyingConn() net.Conn
+	Close() error
+}
+
+type webSocketTransport struct {
+	conn WebSocketConn
+	r    io.Reader
+	wb   bytes.Buffer
+}
+
+// NewWebSocketTransport creates a websocket class stream transport.
+func NewWebSocketTransport(conn WebSocketConn) Transport {
+	return &webSocketTransport{conn: conn}
+}
+
+func (wst *webSocketTransport) Read(p []byte) (n int, err error) {
+	for {
+		if wst.r == nil {
+			var mt int
+			mt, wst.r, err = wst.conn.NextReader()
+			if _, ok := err.(*websocket.CloseError); ok {
+				return 0, io.EOF // peer closed websocket connection
+			} else if err != nil {
+				return 0, err
+			}
+			if mt != websocket.TextMessage {
+				wst.r = nil
+				continue
+			}
+		}
+		n, err = wst.r.Read(p)
+		if err == io.EOF {
+			wst.r = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (wst *webSocketTransport) Write(p []byte) (n int, err error) {
+	return wst.wb.Write(p)
+}
+
+func (wst *webSocketTransport) Close() error {
+	return wst.conn.Close()
+}
+
+func (wst *webSocketTransport) Type() Type {
+	return WebSocket
+}
+
+func (wst *webSocketTransport) WriteString(s string) (n int, err error) {
+	return wst.wb.WriteString(s)
+}
+
+// Flush sends every buffered byte as a single websocket text frame.
+func (wst *webSocketTransport) Flush() error {
+	if wst.wb.Len() == 0 {
+		return nil
+	}
+	defer wst.wb.Reset()
+	return wst.conn.WriteMessage(websocket.TextMessage, wst.wb.Bytes())
+}
+
+// SetWriteDeadline sets the deadline for future write calls.
+func (wst *webSocketTransport) SetWriteDeadline(d time.Time) error {
+	return wst.conn.SetWriteDeadline(d)
+}
+
+func (wst *webSocketTransport) StartTLS(*tls.Config, bool) {}
+
+func (wst *webSocketTransport) EnableCompression(compress.Level) {}
+
+func (wst *webSocketTransport) ChannelBindingBytes(mechanism ChannelBindingMechanism) []byte {
+	if conn, ok := wst.conn.UnderlyingConn().(tlsStateQueryable); ok {
+		return channelBindingBytes(conn.ConnectionState(), mechanism, nil)
+	}
+	return nil
+}
+
+func (wst *webSocketTransport) PeerCertificates() []*x509.Certificate {
+	if conn, ok := wst.conn.UnderlyingConn().(tlsStateQueryable); ok {
+		st := conn.ConnectionState()
+		return st.PeerCertificates
+	}
+	return nil
+}
+
+func (wst *webSocketTransport) RemoteAddr() net.Addr {
+	return wst.conn.UnderlyingConn().RemoteAddr()
+}
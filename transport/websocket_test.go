@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package transport
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeWebSocketConn struct {
+	rdMessages [][]byte
+	wrMessages [][]byte
+	closed     bool
+}
+
+func (c *fakeWebSocketConn) NextReader() (int, io.Reader, error) {
+	if len(c.rdMessages) == 0 {
+		return 0, nil, &websocket.CloseError{Code: websocket.CloseNormalClosure}
+	}
+	msg := c.rdMessages[0]
+	c.rdMessages = c.rdMessages[1:]
+	return websocket.TextMessage, bytes.NewReader(msg), nil
+}
+
+func (c *fakeWebSocketConn) WriteMessage(_ int, data []byte) error {
+	c.wrMessages = append(c.wrMessages, append([]byte(nil), data...))
+	return nil
+}
+
+func (c *fakeWebSocketConn) SetWriteDeadline(time.Time) error { return nil }
+func (c *fakeWebSocketConn) UnderlyingConn() net.Conn         { return newFakeSocketConn() }
+func (c *fakeWebSocketConn) Close() error                     { c.closed = true; return nil }
+
+func TestWebSocket(t *testing.T) {
+	buff := make([]byte, 4096)
+	conn := &fakeWebSocketConn{}
+	wst := NewWebSocketTransport(conn)
+	require.Equal(t, WebSocket, wst.Type())
+
+	// every flush produces a single frame
+	el1 := xmpp.NewElementNamespace("elem", "exodus:ns")
+	_ = el1.ToXML(wst, true)
+	_, _ = wst.WriteString("")
+	require.Nil(t, wst.Flush())
+	require.Nil(t, wst.Flush())
+	require.Equal(t, 1, len(conn.wrMessages))
+	require.Equal(t, el1.String(), string(conn.wrMessages[0]))
+
+	el2 := xmpp.NewElementNamespace("elem2", "exodus2:ns")
+	conn.rdMessages = append(conn.rdMessages, []byte(el2.String()))
+	n, err := wst.Read(buff)
+	require.Nil(t, err)
+	require.Equal(t, el2.String(), string(buff[:n]))
+
+	// peer closed connection
+	_, err = wst.Read(buff)
+	require.Equal(t, io.EOF, err)
+
+	require.Nil(t, wst.ChannelBindingBytes(TLSUnique))
+	require.Nil(t, wst.PeerCertificates())
+
+	_ = wst.Close()
+	require.True(t, conn.closed)
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package connlimit
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Config represents a listener connection limits configuration.
+type Config struct {
+	Allow          []*net.IPNet
+	Deny           []*net.IPNet
+	MaxPerIP       int
+	MaxConnections int
+}
+
+type configProxy struct {
+	Allow          []string `yaml:"allow"`
+	Deny           []string `yaml:"deny"`
+	MaxPerIP       int      `yaml:"max_connections_per_ip"`
+	MaxConnections int      `yaml:"max_connections"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	allow, err := parseNetworks(p.Allow)
+	if err != nil {
+		return err
+	}
+	deny, err := parseNetworks(p.Deny)
+	if err != nil {
+		return err
+	}
+	if p.MaxPerIP < 0 || p.MaxConnections < 0 {
+		return errors.New("connlimit.Config: connection limits must be positive")
+	}
+	c.Allow = allow
+	c.Deny = deny
+	c.MaxPerIP = p.MaxPerIP
+	c.MaxConnections = p.MaxConnections
+	return nil
+}
+
+// parseNetworks parses a list of CIDR notation networks. Single IP addresses are also accepted.
+func parseNetworks(networks []string) ([]*net.IPNet, error) {
+	var ret []*net.IPNet
+	for _, network := range networks {
+		if !strings.Contains(network, "/") {
+			ip := net.ParseIP(network)
+			if ip == nil {
+				return nil, fmt.Errorf("connlimit.Config: invalid IP address: %s", network)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			ret = append(ret, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			return nil, fmt.Errorf("connlimit.Config: invalid network: %s", network)
+		}
+		ret = append(ret, ipNet)
+	}
+	return ret, nil
+}
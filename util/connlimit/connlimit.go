@@ -0,0 +1,171 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package connlimit
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrDenied is returned when the remote address is not allowed to connect.
+	ErrDenied = errors.New("connlimit: remote address denied")
+
+	// ErrTooManyConnectionsFromIP is returned when the remote address
+	// reached the maximum number of concurrent connections.
+	ErrTooManyConnectionsFromIP = errors.New("connlimit: too many connections from remote address")
+
+	// ErrTooManyConnections is returned when the maximum number of concurrent connections has been reached.
+	ErrTooManyConnections = errors.New("connlimit: too many connections")
+)
+
+// Limiter enforces access lists and concurrent connection limits over accepted connections.
+type Limiter struct {
+	cfg      *Config
+	mu       sync.Mutex
+	total    int
+	perIP    map[string]int
+	rejected uint64
+}
+
+// New returns a new connection limiter instance.
+func New(cfg *Config) *Limiter {
+	return &Limiter{
+		cfg:   cfg,
+		perIP: make(map[string]int),
+	}
+}
+
+// Admit checks whether an accepted connection complies with configured limits, returning
+// a connection wrapper that releases its slot once closed.
+// In case the connection is rejected the original connection is left untouched.
+func (l *Limiter) Admit(conn net.Conn) (net.Conn, error) {
+	ip := remoteIP(conn.RemoteAddr())
+	if !l.isAllowed(ip) {
+		atomic.AddUint64(&l.rejected, 1)
+		return nil, ErrDenied
+	}
+	key := ip.String()
+
+	l.mu.Lock()
+	if l.cfg.MaxConnections > 0 && l.total >= l.cfg.MaxConnections {
+		l.mu.Unlock()
+		atomic.AddUint64(&l.rejected, 1)
+		return nil, ErrTooManyConnections
+	}
+	if l.cfg.MaxPerIP > 0 && l.perIP[key] >= l.cfg.MaxPerIP {
+		l.mu.Unlock()
+		atomic.AddUint64(&l.rejected, 1)
+		return nil, ErrTooManyConnectionsFromIP
+	}
+	l.total++
+	l.perIP[key]++
+	l.mu.Unlock()
+
+	return &limitedConn{Conn: conn, release: func() { l.release(key) }}, nil
+}
+
+// Listener returns a listener admitting every connection accepted by ln, so that limits can also
+// be enforced over listeners whose connections are handled elsewhere (e.g. by an HTTP server).
+// Rejected connections are reported through onReject and closed right away.
+func (l *Limiter) Listener(ln net.Listener, onReject func(conn net.Conn, err error)) net.Listener {
+	return &limitedListener{Listener: ln, l: l, onReject: onReject}
+}
+
+// Connections returns current number of admitted connections.
+func (l *Limiter) Connections() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.total
+}
+
+// Rejected returns the total number of rejected connections.
+func (l *Limiter) Rejected() uint64 {
+	return atomic.LoadUint64(&l.rejected)
+}
+
+func (l *Limiter) isAllowed(ip net.IP) bool {
+	if ip == nil {
+		return len(l.cfg.Allow) == 0
+	}
+	for _, n := range l.cfg.Deny {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	if len(l.cfg.Allow) == 0 {
+		return true
+	}
+	for _, n := range l.cfg.Allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *Limiter) release(key string) {
+	l.mu.Lock()
+	l.total--
+	if l.perIP[key]--; l.perIP[key] <= 0 {
+		delete(l.perIP, key)
+	}
+	l.mu.Unlock()
+}
+
+type limitedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *limitedConn) Close() error {
+	c.once.Do(c.release)
+	return c.Conn.Close()
+}
+
+// NetConn returns the underlying connection.
+func (c *limitedConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+type limitedListener struct {
+	net.Listener
+	l        *Limiter
+	onReject func(conn net.Conn, err error)
+}
+
+func (ln *limitedListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := ln.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		lConn, err := ln.l.Admit(conn)
+		if err != nil {
+			ln.onReject(conn, err)
+			_ = conn.Close()
+			continue
+		}
+		return lConn, nil
+	}
+}
+
+func remoteIP(addr net.Addr) net.IP {
+	if addr == nil {
+		return nil
+	}
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package connlimit
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+type fakeConn struct {
+	net.Conn
+	addr   net.Addr
+	closed bool
+}
+
+func (c *fakeConn) RemoteAddr() net.Addr { return c.addr }
+func (c *fakeConn) Close() error         { c.closed = true; return nil }
+
+func TestConfig(t *testing.T) {
+	var cfg Config
+	err := yaml.Unmarshal([]byte("{allow: [10.0.0.0/8, 192.168.1.10], deny: ['::1'], max_connections_per_ip: 4, max_connections: 100}"), &cfg)
+	require.Nil(t, err)
+	require.Len(t, cfg.Allow, 2)
+	require.Equal(t, "192.168.1.10/32", cfg.Allow[1].String())
+	require.Len(t, cfg.Deny, 1)
+	require.Equal(t, "::1/128", cfg.Deny[0].String())
+	require.Equal(t, 4, cfg.MaxPerIP)
+	require.Equal(t, 100, cfg.MaxConnections)
+
+	err = yaml.Unmarshal([]byte("{allow: [10.0.0.0/33]}"), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("{deny: [localhost]}"), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("{max_connections: -1}"), &cfg)
+	require.NotNil(t, err)
+}
+
+func TestLimiter_AccessLists(t *testing.T) {
+	allow, _ := parseNetworks([]string{"10.0.0.0/8"})
+	deny, _ := parseNetworks([]string{"10.0.0.66"})
+	l := New(&Config{Allow: allow, Deny: deny})
+
+	_, err := l.Admit(tUtilConn("10.1.2.3:5222"))
+	require.Nil(t, err)
+
+	_, err = l.Admit(tUtilConn("10.0.0.66:5222"))
+	require.Equal(t, ErrDenied, err)
+
+	_, err = l.Admit(tUtilConn("192.168.1.1:5222"))
+	require.Equal(t, ErrDenied, err)
+
+	require.Equal(t, 1, l.Connections())
+	require.Equal(t, uint64(2), l.Rejected())
+}
+
+func TestLimiter_ConnectionLimits(t *testing.T) {
+	l := New(&Config{MaxPerIP: 2, MaxConnections: 3})
+
+	c1, err := l.Admit(tUtilConn("10.0.0.1:5222"))
+	require.Nil(t, err)
+	_, err = l.Admit(tUtilConn("10.0.0.1:5223"))
+	require.Nil(t, err)
+
+	_, err = l.Admit(tUtilConn("10.0.0.1:5224"))
+	require.Equal(t, ErrTooManyConnectionsFromIP, err)
+
+	_, err = l.Admit(tUtilConn("10.0.0.2:5222"))
+	require.Nil(t, err)
+
+	_, err = l.Admit(tUtilConn("10.0.0.3:5222"))
+	require.Equal(t, ErrTooManyConnections, err)
+
+	// closing a connection releases its slot
+	require.Nil(t, c1.Close())
+	require.Nil(t, c1.Close())
+	require.True(t, c1.(*limitedConn).Conn.(*fakeConn).closed)
+	require.Equal(t, 2, l.Connections())
+
+	_, err = l.Admit(tUtilConn("10.0.0.1:5225"))
+	require.Nil(t, err)
+	require.Equal(t, uint64(2), l.Rejected())
+}
+
+type fakeListener struct {
+	net.Listener
+	conns []net.Conn
+}
+
+func (ln *fakeListener) Accept() (net.Conn, error) {
+	if len(ln.conns) == 0 {
+		return nil, errListenerClosed
+	}
+	conn := ln.conns[0]
+	ln.conns = ln.conns[1:]
+	return conn, nil
+}
+
+var errListenerClosed = errors.New("listener closed")
+
+func TestLimiter_Listener(t *testing.T) {
+	deny, _ := parseNetworks([]string{"10.0.0.66"})
+	l := New(&Config{Deny: deny})
+
+	denied := tUtilConn("10.0.0.66:5222")
+	allowed := tUtilConn("10.0.0.1:5222")
+
+	var rejected []error
+	ln := l.Listener(&fakeListener{conns: []net.Conn{denied, allowed}}, func(_ net.Conn, err error) {
+		rejected = append(rejected, err)
+	})
+
+	// denied connections are closed and skipped
+	conn, err := ln.Accept()
+	require.Nil(t, err)
+	require.Equal(t, allowed, conn.(*limitedConn).Conn)
+	require.True(t, denied.(*fakeConn).closed)
+	require.Equal(t, []error{ErrDenied}, rejected)
+	require.Equal(t, 1, l.Connections())
+
+	_, err = ln.Accept()
+	require.Equal(t, errListenerClosed, err)
+}
+
+func tUtilConn(addr string) net.Conn {
+	tcpAddr, _ := net.ResolveTCPAddr("tcp", addr)
+	return &fakeConn{addr: tcpAddr}
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Action represents the action taken whenever a rate limit is exceeded.
+type Action int
+
+const (
+	// Throttle delays reading from the offending stream until enough tokens are available.
+	Throttle Action = iota
+
+	// Bounce replies offending stanzas with a 'policy-violation' error.
+	Bounce
+
+	// Disconnect closes the offending stream with a 'policy-violation' stream error.
+	Disconnect
+
+	// Allow lets an element within limits be processed. Not a configurable action.
+	Allow
+)
+
+// String returns Action string representation.
+func (a Action) String() string {
+	switch a {
+	case Bounce:
+		return "bounce"
+	case Disconnect:
+		return "disconnect"
+	case Allow:
+		return "allow"
+	}
+	return "throttle"
+}
+
+// Config represents a stream rate limiter configuration.
+type Config struct {
+	StanzasPerSecond float64
+	BytesPerSecond   float64
+	Burst            int
+	BytesBurst       int
+	Action           Action
+}
+
+type configProxy struct {
+	StanzasPerSecond float64 `yaml:"stanzas_per_second"`
+	BytesPerSecond   float64 `yaml:"bytes_per_second"`
+	Burst            int     `yaml:"burst"`
+	BytesBurst       int     `yaml:"bytes_burst"`
+	Action           string  `yaml:"action"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if p.StanzasPerSecond < 0 || p.BytesPerSecond < 0 || p.Burst < 0 || p.BytesBurst < 0 {
+		return errors.New("ratelimit.Config: rates and bursts must be positive")
+	}
+	c.StanzasPerSecond = p.StanzasPerSecond
+	c.BytesPerSecond = p.BytesPerSecond
+
+	// by default allow one second worth of traffic to be consumed at once
+	c.Burst = p.Burst
+	if c.Burst == 0 {
+		c.Burst = burstFromRate(c.StanzasPerSecond)
+	}
+	c.BytesBurst = p.BytesBurst
+	if c.BytesBurst == 0 {
+		c.BytesBurst = burstFromRate(c.BytesPerSecond)
+	}
+	switch p.Action {
+	case "", "throttle":
+		c.Action = Throttle
+	case "bounce":
+		c.Action = Bounce
+	case "disconnect":
+		c.Action = Disconnect
+	default:
+		return fmt.Errorf("ratelimit.Config: unrecognized action: %s", p.Action)
+	}
+	return nil
+}
+
+// Enabled tells whether or not any rate limit has been configured.
+func (c *Config) Enabled() bool {
+	return c.StanzasPerSecond > 0 || c.BytesPerSecond > 0
+}
+
+func burstFromRate(rate float64) int {
+	if rate <= 0 {
+		return 0
+	}
+	if rate < 1 {
+		return 1
+	}
+	return int(rate)
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ortuman/jackal/xmpp"
+)
+
+// bucket implements a token bucket refilled at a constant rate.
+type bucket struct {
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newBucket(rate float64, capacity int, now time.Time) *bucket {
+	return &bucket{
+		rate:     rate,
+		capacity: float64(capacity),
+		tokens:   float64(capacity),
+		last:     now,
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+}
+
+// delay returns the time to wait until n tokens are available.
+// Requests greater than bucket capacity only need to wait for a full bucket.
+func (b *bucket) delay(n float64) time.Duration {
+	if n > b.capacity {
+		n = b.capacity
+	}
+	if b.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
+
+// take consumes n tokens, leaving the bucket in debt if necessary.
+func (b *bucket) take(n float64) {
+	b.tokens -= n
+}
+
+// Limiter limits the rate of incoming stream elements by number of stanzas and bytes.
+type Limiter struct {
+	cfg     *Config
+	mu      sync.Mutex
+	stanzas *bucket
+	bytes   *bucket
+}
+
+// New returns a new rate limiter instance.
+func New(cfg *Config) *Limiter {
+	now := time.Now()
+	l := &Limiter{cfg: cfg}
+	if cfg.StanzasPerSecond > 0 {
+		l.stanzas = newBucket(cfg.StanzasPerSecond, cfg.Burst, now)
+	}
+	if cfg.BytesPerSecond > 0 {
+		l.bytes = newBucket(cfg.BytesPerSecond, cfg.BytesBurst, now)
+	}
+	return l
+}
+
+// Reserve accounts an incoming element, returning the time the caller should wait
+// before processing it in order to comply with the configured limits.
+// A zero value means the element is within limits.
+//
+// Under Throttle action tokens are always consumed, so the caller is expected
+// to wait for the returned duration. Otherwise, elements exceeding the limits are not accounted.
+func (l *Limiter) Reserve(elem xmpp.XElement) time.Duration {
+	var stanzaCount float64
+	if isStanza(elem) {
+		stanzaCount = 1
+	}
+	var byteCount float64
+	if l.bytes != nil {
+		byteCount = float64(elementSize(elem))
+	}
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var d time.Duration
+	if l.stanzas != nil {
+		l.stanzas.refill(now)
+		d = maxDuration(d, l.stanzas.delay(stanzaCount))
+	}
+	if l.bytes != nil {
+		l.bytes.refill(now)
+		d = maxDuration(d, l.bytes.delay(byteCount))
+	}
+	if d == 0 || l.cfg.Action == Throttle {
+		if l.stanzas != nil {
+			l.stanzas.take(stanzaCount)
+		}
+		if l.bytes != nil {
+			l.bytes.take(byteCount)
+		}
+	}
+	return d
+}
+
+// Apply accounts an incoming element against the configured limits, returning the action to be taken over it.
+// Throttled elements are delayed here, so that the caller stops reading for the required time before
+// processing them. Only stanzas can be bounced, any other element exceeding the limits is throttled instead.
+func (l *Limiter) Apply(elem xmpp.XElement) Action {
+	if l == nil || elem == nil {
+		return Allow
+	}
+	d := l.Reserve(elem)
+	if d == 0 {
+		return Allow
+	}
+	action := l.cfg.Action
+	if _, ok := elem.(xmpp.Stanza); !ok && action == Bounce {
+		action = Throttle
+	}
+	if action == Throttle {
+		time.Sleep(d)
+	}
+	return action
+}
+
+func isStanza(elem xmpp.XElement) bool {
+	switch elem.Name() {
+	case "message", "presence", "iq":
+		return true
+	}
+	return false
+}
+
+type countingWriter struct{ n int }
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += len(p)
+	return len(p), nil
+}
+
+func (w *countingWriter) WriteString(s string) (int, error) {
+	w.n += len(s)
+	return len(s), nil
+}
+
+func elementSize(elem xmpp.XElement) int {
+	w := &countingWriter{}
+	_ = elem.ToXML(w, true)
+	return w.n
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig(t *testing.T) {
+	var cfg Config
+	err := yaml.Unmarshal([]byte("{stanzas_per_second: 10, bytes_per_second: 2048}"), &cfg)
+	require.Nil(t, err)
+	require.True(t, cfg.Enabled())
+	require.Equal(t, 10, cfg.Burst)
+	require.Equal(t, 2048, cfg.BytesBurst)
+	require.Equal(t, Throttle, cfg.Action)
+
+	err = yaml.Unmarshal([]byte("{stanzas_per_second: 0.5, burst: 4, action: bounce}"), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, 4, cfg.Burst)
+	require.Equal(t, 0, cfg.BytesBurst)
+	require.Equal(t, Bounce, cfg.Action)
+
+	err = yaml.Unmarshal([]byte("{action: disconnect}"), &cfg)
+	require.Nil(t, err)
+	require.False(t, cfg.Enabled())
+	require.Equal(t, Disconnect, cfg.Action)
+
+	err = yaml.Unmarshal([]byte("{action: ignore}"), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("{stanzas_per_second: -1}"), &cfg)
+	require.NotNil(t, err)
+}
+
+func TestLimiter_Stanzas(t *testing.T) {
+	l := New(&Config{StanzasPerSecond: 10, Burst: 2, Action: Bounce})
+
+	msg := xmpp.NewElementName("message")
+	require.Equal(t, time.Duration(0), l.Reserve(msg))
+	require.Equal(t, time.Duration(0), l.Reserve(msg))
+	require.True(t, l.Reserve(msg) > 0)
+
+	// non-stanza elements are not accounted
+	require.Equal(t, time.Duration(0), l.Reserve(xmpp.NewElementName("r")))
+
+	time.Sleep(time.Millisecond * 110) // wait for refill...
+	require.Equal(t, time.Duration(0), l.Reserve(msg))
+	require.True(t, l.Reserve(msg) > 0)
+}
+
+func TestLimiter_Bytes(t *testing.T) {
+	elem := xmpp.NewElementName("iq")
+	elem.SetText("0123456789")
+	size := elementSize(elem)
+
+	l := New(&Config{BytesPerSecond: float64(size * 10), BytesBurst: size, Action: Throttle})
+	require.Equal(t, time.Duration(0), l.Reserve(elem))
+
+	// throttled elements are accounted, so that delays accumulate
+	d1 := l.Reserve(elem)
+	require.True(t, d1 > 0)
+	d2 := l.Reserve(elem)
+	require.True(t, d2 > d1)
+	require.True(t, d2 <= time.Millisecond*200)
+}
+
+func TestLimiter_Apply(t *testing.T) {
+	var nilLimiter *Limiter
+	require.Equal(t, Allow, nilLimiter.Apply(xmpp.NewElementName("message")))
+
+	j, _ := jid.NewWithString("ortuman@jackal.im", true)
+	msg, _ := xmpp.NewMessageFromElement(xmpp.NewElementName("message"), j, j)
+
+	l := New(&Config{StanzasPerSecond: 10, Burst: 1, Action: Bounce})
+	require.Equal(t, Allow, l.Apply(msg))
+	require.Equal(t, Bounce, l.Apply(msg))
+
+	// only stanzas are bounced
+	require.Equal(t, Throttle, l.Apply(xmpp.NewElementName("message")))
+
+	l = New(&Config{StanzasPerSecond: 10, Burst: 1, Action: Disconnect})
+	require.Equal(t, Allow, l.Apply(msg))
+	require.Equal(t, Disconnect, l.Apply(msg))
+
+	l = New(&Config{StanzasPerSecond: 20, Burst: 1, Action: Throttle})
+	require.Equal(t, Allow, l.Apply(msg))
+
+	start := time.Now()
+	require.Equal(t, Throttle, l.Apply(msg))
+	require.True(t, time.Since(start) >= time.Millisecond*40)
+}
@@ -11,8 +11,15 @@ import (
 
 	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/util/runqueue/mpsc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+var backlog = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "jackal_runqueue_backlog",
+	Help: "Number of operations waiting to be run by module run queues.",
+}, []string{"queue"})
+
 const (
 	idle int32 = iota
 	running
@@ -25,6 +32,7 @@ type RunQueue struct {
 	messageCount int32
 	state        int32
 	stopped      int32
+	metered      bool
 }
 
 type funcMessage struct{ fn func() }
@@ -38,6 +46,14 @@ func New(name string) *RunQueue {
 	}
 }
 
+// NewMetered returns an initialized lock-free operation queue whose backlog is exposed through metrics.
+// Since queue name is used as metric label it's meant to be used by long-lived queues, such as module ones.
+func NewMetered(name string) *RunQueue {
+	rq := New(name)
+	rq.metered = true
+	return rq
+}
+
 // Run pushes a new operation function into the queue.
 func (m *RunQueue) Run(fn func()) {
 	if atomic.LoadInt32(&m.stopped) == 1 {
@@ -45,6 +61,9 @@ func (m *RunQueue) Run(fn func()) {
 	}
 	m.queue.Push(&funcMessage{fn: fn})
 	atomic.AddInt32(&m.messageCount, 1)
+	if m.metered {
+		backlog.WithLabelValues(m.name).Inc()
+	}
 	m.schedule()
 }
 
@@ -97,6 +116,9 @@ func (m *RunQueue) run() {
 	for {
 		switch msg := m.queue.Pop().(type) {
 		case *funcMessage:
+			if m.metered {
+				backlog.WithLabelValues(m.name).Dec()
+			}
 			msg.fn()
 			atomic.AddInt32(&m.messageCount, -1)
 		case *stopMessage:
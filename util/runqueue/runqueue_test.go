@@ -10,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -36,6 +37,28 @@ func TestRunQueueConsistency(t *testing.T) {
 	require.Equal(t, int32(2000), i)
 }
 
+func TestRunQueueBacklog(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	rq := NewMetered("test_backlog")
+	rq.Run(func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	rq.Run(wg.Done)
+	rq.Run(wg.Done)
+	require.Equal(t, float64(2), testutil.ToFloat64(backlog.WithLabelValues("test_backlog")))
+
+	close(release)
+	wg.Wait()
+	require.Equal(t, float64(0), testutil.ToFloat64(backlog.WithLabelValues("test_backlog")))
+}
+
 func TestRunQueueStop(t *testing.T) {
 	fn := func() {
 		time.Sleep(time.Millisecond * 500)
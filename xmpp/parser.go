@@ -16,6 +16,8 @@ const rootElementIndex = -1
 
 const (
 	streamName = "stream"
+
+	framedStreamNamespace = "urn:ietf:params:xml:ns:xmpp-framing"
 )
 
 // ParsingMode defines the way in which special parsed element
@@ -28,6 +30,9 @@ const (
 
 	// SocketStream treats incoming elements as provided from a socket transport.
 	SocketStream
+
+	// WebSocketStream treats incoming elements as provided from a websocket transport.
+	WebSocketStream
 )
 
 // ErrTooLargeStanza is returned by ReadElement when the size of
@@ -109,6 +114,10 @@ done:
 	ret := p.nextElement
 
 	p.nextElement = nil
+
+	if p.mode == WebSocketStream && ret.Name() == "close" && ret.Namespace() == framedStreamNamespace {
+		return nil, ErrStreamClosedByPeer
+	}
 	return ret, nil
 }
 
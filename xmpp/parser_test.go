@@ -100,3 +100,16 @@ func TestStream(t *testing.T) {
 	_, err = p.ParseElement()
 	require.Equal(t, xmpp.ErrStreamClosedByPeer, err)
 }
+
+func TestParser_WebSocketClose(t *testing.T) {
+	openXML := `<open xmlns="urn:ietf:params:xml:ns:xmpp-framing" to="localhost" version="1.0"/>`
+	p := xmpp.NewParser(strings.NewReader(openXML), xmpp.WebSocketStream, 0)
+	elem, err := p.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "open", elem.Name())
+
+	closeXML := `<close xmlns="urn:ietf:params:xml:ns:xmpp-framing"/>`
+	p = xmpp.NewParser(strings.NewReader(closeXML), xmpp.WebSocketStream, 0)
+	_, err = p.ParseElement()
+	require.Equal(t, xmpp.ErrStreamClosedByPeer, err)
+}